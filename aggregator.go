@@ -32,6 +32,7 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/RedHatInsights/insights-operator-utils/logger"
@@ -59,6 +60,10 @@ const (
 	ExitStatusServerError
 	// ExitStatusMigrationError is returned in case of an error while attempting to perform DB migrations
 	ExitStatusMigrationError
+	// ExitStatusReplayError is returned in case of an error while replaying messages from dead letter queue
+	ExitStatusReplayError
+	// ExitStatusCleanupError is returned in case of an error while deleting expired records
+	ExitStatusCleanupError
 )
 
 // Messages
@@ -97,6 +102,11 @@ var (
 	// the database to the latest migration version. This is necessary
 	// for certain tests that work with a temporary, empty SQLite DB.
 	autoMigrate = false
+
+	// memoryStorage is the in-memory storage shared by the consumer and
+	// the REST API server (each of them creates its own storage otherwise)
+	memoryStorage     *storage.MemoryStorage
+	memoryStorageOnce sync.Once
 )
 
 // fillInInfoParams function fills-in additional info used by /info endpoint
@@ -110,10 +120,19 @@ func fillInInfoParams(params map[string]string) {
 }
 
 // createStorage function initializes connection to preconfigured storage,
-// usually SQLite, PostgreSQL, or AWS RDS.
-func createStorage() (*storage.DBStorage, error) {
+// usually SQLite, PostgreSQL, or AWS RDS. In-memory storage is created just
+// once, because the data need to be visible for all parts of the service.
+func createStorage() (storage.Storage, error) {
 	storageCfg := conf.GetStorageConfiguration()
 
+	if storageCfg.Driver == storage.MemoryDriver {
+		memoryStorageOnce.Do(func() {
+			log.Info().Msg("Using in-memory storage, data won't be persisted")
+			memoryStorage = storage.NewMemoryStorage(storageCfg)
+		})
+		return memoryStorage, nil
+	}
+
 	// try to initialize connection to storage
 	dbStorage, err := storage.New(storageCfg)
 	if err != nil {
@@ -124,9 +143,9 @@ func createStorage() (*storage.DBStorage, error) {
 	return dbStorage, nil
 }
 
-// closeStorage function closes specified DBStorage with proper error checking
+// closeStorage function closes specified storage with proper error checking
 // whether the close operation was successful or not.
-func closeStorage(storage *storage.DBStorage) {
+func closeStorage(storage storage.Storage) {
 	err := storage.Close()
 	if err != nil {
 		// TODO: error state might be returned from this function
@@ -171,9 +190,14 @@ func prepareDB() int {
 	}
 	defer closeStorage(dbStorage)
 
+	// migrations are performed for SQL databases only
+	sqlStorage, isSQLStorage := dbStorage.(*storage.DBStorage)
+
 	// Ensure that the DB is at the latest migration version.
-	if exitCode := prepareDBMigrations(dbStorage); exitCode != ExitStatusOK {
-		return exitCode
+	if isSQLStorage {
+		if exitCode := prepareDBMigrations(sqlStorage); exitCode != ExitStatusOK {
+			return exitCode
+		}
 	}
 
 	// Initialize the database.
@@ -184,7 +208,9 @@ func prepareDB() int {
 	}
 
 	// temporarily print some information from DB because of limited access to DB
-	dbStorage.PrintRuleDisableDebugInfo()
+	if isSQLStorage {
+		sqlStorage.PrintRuleDisableDebugInfo()
+	}
 
 	return ExitStatusOK
 }
@@ -204,17 +230,29 @@ func startService() int {
 		return prepDbExitCode
 	}
 
+	// storage shared by the consumer and the janitor, the REST API server
+	// uses its own storage
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
 	ctx, cancel := context.WithCancel(context.Background())
 
+	startJanitor(ctx, dbStorage)
+
 	errorGroup := new(errgroup.Group)
 
 	brokerConf := conf.GetBrokerConfiguration()
 	// if broker is disabled, simply don't start it
 	if brokerConf.Enabled {
+		startOrgAllowlistWatcher(ctx, brokerConf)
+
 		errorGroup.Go(func() error {
 			defer cancel()
 
-			err := startConsumer(brokerConf)
+			err := startConsumer(brokerConf, dbStorage)
 			if err != nil {
 				log.Error().Err(err)
 				return err
@@ -310,6 +348,10 @@ The commands are:
     print-version-info  prints version info
     migration           prints information about migrations (current, latest)
     migration <version> migrates database to the specified version
+    replay-dead-letters processes messages from dead letter queue again, see
+                        replay-dead-letters -help for filters
+    cleanup             deletes records older than configured retention periods,
+                        use cleanup --dry-run to just print their numbers
 
 `
 
@@ -347,12 +389,18 @@ func printEnv() int {
 // migrations. Non-OK exit code is returned as the last return value in case
 // of an error. Otherwise, database and connection pointers are returned.
 func getDBForMigrations() (*storage.DBStorage, *sql.DB, int) {
-	db, err := createStorage()
+	createdStorage, err := createStorage()
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to prepare DB for migrations")
 		return nil, nil, ExitStatusPrepareDbError
 	}
 
+	db, ok := createdStorage.(*storage.DBStorage)
+	if !ok {
+		log.Error().Msg("Migrations are supported for SQL databases only")
+		return nil, nil, ExitStatusPrepareDbError
+	}
+
 	dbConn := db.GetConnection()
 
 	if err := migration.InitInfoTable(dbConn); err != nil {
@@ -495,6 +543,10 @@ func handleCommand(command string) int {
 		printVersionInfo()
 	case "migrations", "migration", "migrate":
 		return performMigrations()
+	case "replay-dead-letters":
+		return replayDeadLetters(os.Args[2:])
+	case "cleanup":
+		return cleanup(os.Args[2:])
 	default:
 		fmt.Printf("\nCommand '%v' not found\n", command)
 		return printHelp()
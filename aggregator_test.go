@@ -120,6 +120,21 @@ func TestCreateStorage_BadDriver(t *testing.T) {
 	assert.EqualError(t, err, "driver non-existing-driver is not supported")
 }
 
+func TestCreateStorage_Memory(t *testing.T) {
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER": "memory",
+	})
+
+	firstStorage, err := main.CreateStorage()
+	helpers.FailOnError(t, err)
+	assert.IsType(t, &storage.MemoryStorage{}, firstStorage)
+
+	// the same storage needs to be shared by consumer and server
+	secondStorage, err := main.CreateStorage()
+	helpers.FailOnError(t, err)
+	assert.Same(t, firstStorage, secondStorage)
+}
+
 func TestCloseStorage_Error(t *testing.T) {
 	const errStr = "close error"
 
@@ -184,16 +199,6 @@ func TestPrepareDB_BadRules(t *testing.T) {
 	assert.Equal(t, main.ExitStatusPrepareDbError, errCode)
 }
 
-func TestStartConsumer_DBError(t *testing.T) {
-	setEnvSettings(t, map[string]string{
-		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "non-existing-driver",
-		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__SQLITE_DATASOURCE": "bad-data-source",
-	})
-
-	err := main.StartConsumer(conf.GetBrokerConfiguration())
-	assert.EqualError(t, err, "driver non-existing-driver is not supported")
-}
-
 func TestStartConsumer_BadBrokerAddress(t *testing.T) {
 	setEnvSettings(t, map[string]string{
 		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":         "sqlite3",
@@ -203,7 +208,11 @@ func TestStartConsumer_BadBrokerAddress(t *testing.T) {
 		"INSIGHTS_RESULTS_AGGREGATOR__BROKER__ENABLED": "true",
 	})
 
-	err := main.StartConsumer(conf.GetBrokerConfiguration())
+	dbStorage, err := main.CreateStorage()
+	helpers.FailOnError(t, err)
+	defer main.CloseStorage(dbStorage)
+
+	err = main.StartConsumer(conf.GetBrokerConfiguration(), dbStorage)
 	assert.EqualError(
 		t, err, "kafka: client has run out of available brokers to talk to (Is your cluster reachable?)",
 	)
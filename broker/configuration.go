@@ -26,8 +26,32 @@ import (
 	"github.com/Shopify/sarama"
 	mapset "github.com/deckarep/golang-set"
 	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// ProcessingModeStore stores reports from the organization normally
+	ProcessingModeStore = "store"
+	// ProcessingModeNoNotifications stores reports from the organization,
+	// but doesn't publish rule hits changes for its clusters
+	ProcessingModeNoNotifications = "no_notifications"
+	// ProcessingModeLatestOnly stores only the latest report of each
+	// cluster of the organization, without report history
+	ProcessingModeLatestOnly = "latest_only"
+	// ProcessingModeDrop drops messages from the organization without
+	// storing them
+	ProcessingModeDrop = "drop"
 )
 
+// ProcessingModes contains all supported per organization processing modes
+var ProcessingModes = []string{
+	ProcessingModeStore,
+	ProcessingModeNoNotifications,
+	ProcessingModeLatestOnly,
+	ProcessingModeDrop,
+}
+
 // Configuration represents configuration of Kafka broker
 type Configuration struct {
 	Address              string        `mapstructure:"address" toml:"address"`
@@ -40,17 +64,39 @@ type Configuration struct {
 	Timeout              time.Duration `mapstructure:"timeout" toml:"timeout"`
 	PayloadTrackerTopic  string        `mapstructure:"payload_tracker_topic" toml:"payload_tracker_topic"`
 	DeadLetterQueueTopic string        `mapstructure:"dead_letter_queue_topic" toml:"dead_letter_queue_topic"`
+	RuleHitsChangesTopic string        `mapstructure:"rule_hits_changes_topic" toml:"rule_hits_changes_topic"`
 	ServiceName          string        `mapstructure:"service_name" toml:"service_name"`
 	Group                string        `mapstructure:"group" toml:"group"`
 	Enabled              bool          `mapstructure:"enabled" toml:"enabled"`
+	Workers              int           `mapstructure:"workers" toml:"workers"`
+	BatchSize            int           `mapstructure:"batch_size" toml:"batch_size"`
+	BatchTimeout         time.Duration `mapstructure:"batch_timeout" toml:"batch_timeout"`
+	RetryMaxAttempts     int           `mapstructure:"retry_max_attempts" toml:"retry_max_attempts"`
+	RetryInitialBackoff  time.Duration `mapstructure:"retry_initial_backoff" toml:"retry_initial_backoff"`
+	RetryMaxBackoff      time.Duration `mapstructure:"retry_max_backoff" toml:"retry_max_backoff"`
+	RetryTopic           string        `mapstructure:"retry_topic" toml:"retry_topic"`
+	RetryTopicDelay      time.Duration `mapstructure:"retry_topic_delay" toml:"retry_topic_delay"`
+	RetryTopicAttempts   int           `mapstructure:"retry_topic_attempts" toml:"retry_topic_attempts"`
+	OrgRateLimit         int           `mapstructure:"org_rate_limit" toml:"org_rate_limit"`
+	ClusterRateLimit     int           `mapstructure:"cluster_rate_limit" toml:"cluster_rate_limit"`
+	RateLimitInterval    time.Duration `mapstructure:"rate_limit_interval" toml:"rate_limit_interval"`
+	RateLimitAction      string        `mapstructure:"rate_limit_action" toml:"rate_limit_action"`
 	OrgAllowlist         mapset.Set    `mapstructure:"org_allowlist_file" toml:"org_allowlist_file"`
 	OrgAllowlistEnabled  bool          `mapstructure:"enable_org_allowlist" toml:"enable_org_allowlist"`
+	// OrgDenylist and OrgProcessingModes are filled in from [processing]
+	// section of the service configuration
+	OrgDenylist        mapset.Set             `mapstructure:"-" toml:"-"`
+	OrgProcessingModes map[types.OrgID]string `mapstructure:"-" toml:"-"`
 }
 
 // SaramaConfigFromBrokerConfig returns a Config struct from broker.Configuration parameters
 func SaramaConfigFromBrokerConfig(cfg Configuration) (*sarama.Config, error) {
 	saramaConfig := sarama.NewConfig()
 	saramaConfig.Version = sarama.V0_10_2_0
+	if cfg.RetryTopic != "" {
+		// record headers used by retry topic are supported since Kafka 0.11
+		saramaConfig.Version = sarama.V0_11_0_0
+	}
 
 	if cfg.Timeout > 0 {
 		saramaConfig.Net.DialTimeout = cfg.Timeout
@@ -64,6 +64,14 @@ func TestSaramaConfigFromBrokerConfig(t *testing.T) {
 	assert.Equal(t, sarama.SASLMechanism("PLAIN"), saramaConfig.Net.SASL.Mechanism)
 	assert.Equal(t, "username", saramaConfig.Net.SASL.User)
 	assert.Equal(t, "password", saramaConfig.Net.SASL.Password)
+
+	// record headers are needed for retry topic
+	cfg = broker.Configuration{
+		RetryTopic: "retry-topic",
+	}
+	saramaConfig, err = broker.SaramaConfigFromBrokerConfig(cfg)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, sarama.V0_11_0_0, saramaConfig.Version)
 }
 
 func TestBadConfiguration(t *testing.T) {
@@ -0,0 +1,196 @@
+/*
+Copyright © 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+// defaultRetentionInterval is the time between two cleanups performed by
+// the janitors when no interval is configured
+const defaultRetentionInterval = time.Hour
+
+// cleanupExpiredRecords deletes records older than configured retention
+// periods (or just counts them when dryRun is set) and logs the results
+func cleanupExpiredRecords(
+	dbStorage storage.Storage, retention storage.RetentionConfiguration, dryRun bool,
+) ([]storage.RetentionResult, error) {
+	results, err := dbStorage.DeleteExpiredRecords(retention, dryRun)
+
+	// results of tables cleaned up before the error are still valid
+	logRetentionResults(results, dryRun)
+
+	return results, err
+}
+
+// cleanupExpiredRuleDisables deletes rule disables whose expiration time has
+// passed (or just counts them when dryRun is set) and logs the results
+func cleanupExpiredRuleDisables(dbStorage storage.Storage, dryRun bool) ([]storage.RetentionResult, error) {
+	results, err := dbStorage.DeleteExpiredRuleDisables(dryRun)
+
+	logRetentionResults(results, dryRun)
+
+	return results, err
+}
+
+// logRetentionResults logs numbers of expired records and updates metrics
+// when the records have been deleted
+func logRetentionResults(results []storage.RetentionResult, dryRun bool) {
+	for _, result := range results {
+		log.Info().
+			Str("table", result.Table).
+			Int64("rows", result.Rows).
+			Bool("dry_run", dryRun).
+			Msg("Expired records cleaned up")
+
+		if !dryRun {
+			metrics.RetentionDeletedRows.WithLabelValues(result.Table).Add(float64(result.Rows))
+		}
+	}
+}
+
+// runPeriodically calls the task right away and then after each interval
+// until the context is cancelled
+func runPeriodically(ctx context.Context, name string, interval time.Duration, task func()) {
+	log.Info().Str("janitor", name).Dur("interval", interval).Msg("Janitor started")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		task()
+
+		select {
+		case <-ctx.Done():
+			log.Info().Str("janitor", name).Msg("Janitor stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runJanitor periodically deletes expired records from the storage until the
+// context is cancelled. Errors are logged only, the next cleanup is tried
+// after the interval.
+func runJanitor(ctx context.Context, dbStorage storage.Storage, retention storage.RetentionConfiguration) {
+	interval := retention.Interval
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+
+	runPeriodically(ctx, "retention", interval, func() {
+		if _, err := cleanupExpiredRecords(dbStorage, retention, false); err != nil {
+			log.Error().Err(err).Msg("Unable to clean up expired records")
+		}
+	})
+}
+
+// runExpiredRuleDisablesJanitor periodically deletes expired rule disables
+// from the storage until the context is cancelled. Errors are logged only,
+// the next cleanup is tried after the interval.
+func runExpiredRuleDisablesJanitor(ctx context.Context, dbStorage storage.Storage, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+
+	runPeriodically(ctx, "expired rule disables", interval, func() {
+		if _, err := cleanupExpiredRuleDisables(dbStorage, false); err != nil {
+			log.Error().Err(err).Msg("Unable to clean up expired rule disables")
+		}
+	})
+}
+
+// startJanitor function starts the janitors in separate goroutines. Expired
+// rule disables are always cleaned up, expired records only when the
+// retention policy is enabled. The janitors use the storage of the consumer,
+// so the cache of clusters last checked times is updated for deleted
+// reports.
+func startJanitor(ctx context.Context, dbStorage storage.Storage) {
+	retention := conf.GetRetentionConfiguration()
+
+	go runExpiredRuleDisablesJanitor(ctx, dbStorage, retention.ExpiredDisablesInterval)
+
+	if !retention.Enabled {
+		log.Info().Msg("Retention policy is disabled, not starting janitor")
+		return
+	}
+
+	go runJanitor(ctx, dbStorage, retention)
+}
+
+// cleanup function handles cleanup command. Records older than configured
+// retention periods and expired rule disables are deleted once, regardless
+// of whether the retention policy is enabled for the service. With --dry-run
+// flag, expired records are just counted.
+func cleanup(args []string) int {
+	var dryRun bool
+
+	flags := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	flags.BoolVar(&dryRun, "dry-run", false, "just print number of expired records, don't delete them")
+
+	if err := flags.Parse(args); err != nil {
+		log.Error().Err(err).Msg("Invalid arguments of cleanup command")
+		return ExitStatusError
+	}
+
+	if exitCode := prepareDB(); exitCode != ExitStatusOK {
+		log.Info().Msgf(databasePreparationMessage, exitCode)
+		return exitCode
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
+	results, err := cleanupExpiredRecords(dbStorage, conf.GetRetentionConfiguration(), dryRun)
+
+	if len(results) == 0 && err == nil {
+		fmt.Println("No retention period is configured, only expired rule disables are cleaned up")
+	}
+
+	if err == nil {
+		var ruleDisableResults []storage.RetentionResult
+		ruleDisableResults, err = cleanupExpiredRuleDisables(dbStorage, dryRun)
+		results = append(results, ruleDisableResults...)
+	}
+
+	for _, result := range results {
+		if dryRun {
+			fmt.Printf("%-20s %d rows would be deleted\n", result.Table, result.Rows)
+		} else {
+			fmt.Printf("%-20s %d rows deleted\n", result.Table, result.Rows)
+		}
+	}
+
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to clean up expired records")
+		return ExitStatusCleanupError
+	}
+
+	return ExitStatusOK
+}
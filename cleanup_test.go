@@ -0,0 +1,150 @@
+/*
+Copyright © 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	main "github.com/RedHatInsights/insights-results-aggregator"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+var testRetention = storage.RetentionConfiguration{Report: 24 * time.Hour}
+
+// newStorageWithExpiredReport returns in-memory storage with one report
+// older than testRetention
+func newStorageWithExpiredReport(t *testing.T) *storage.MemoryStorage {
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+
+	storedAt := time.Now().Add(-48 * time.Hour)
+	err := memoryStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed,
+		storedAt, storedAt, storedAt, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	return memoryStorage
+}
+
+func TestCleanupExpiredRecords(t *testing.T) {
+	memoryStorage := newStorageWithExpiredReport(t)
+	deletedRows := metrics.RetentionDeletedRows.WithLabelValues(storage.ReportTable)
+	initValue := testutil.ToFloat64(deletedRows)
+
+	// metrics are not updated in dry run
+	results, err := main.CleanupExpiredRecords(memoryStorage, testRetention, true)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []storage.RetentionResult{{Table: storage.ReportInfoTable}, {Table: storage.ReportTable, Rows: 1}}, results)
+	assert.Equal(t, initValue, testutil.ToFloat64(deletedRows))
+
+	results, err = main.CleanupExpiredRecords(memoryStorage, testRetention, false)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []storage.RetentionResult{{Table: storage.ReportInfoTable}, {Table: storage.ReportTable, Rows: 1}}, results)
+	assert.Equal(t, initValue+1, testutil.ToFloat64(deletedRows))
+
+	count, err := memoryStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+// expiredRuleDisablesCount returns the number of expired rule disables that
+// would be deleted from the storage
+func expiredRuleDisablesCount(t testing.TB, memoryStorage storage.Storage) int64 {
+	results, err := memoryStorage.DeleteExpiredRuleDisables(true)
+	helpers.FailOnError(t, err)
+
+	var count int64
+	for _, result := range results {
+		count += result.Rows
+	}
+	return count
+}
+
+// disableRuleUntilPast disables the rule system-wide with expiration time
+// that has already passed
+func disableRuleUntilPast(t testing.TB, memoryStorage storage.Storage) {
+	err := memoryStorage.DisableRuleSystemWideUntil(
+		testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, "maintenance", time.Now().Add(-time.Minute),
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestRunJanitor(t *testing.T) {
+	helpers.RunTestWithTimeout(t, func(t testing.TB) {
+		memoryStorage := newStorageWithExpiredReport(t.(*testing.T))
+		disableRuleUntilPast(t, memoryStorage)
+
+		// the cleanup is performed right after the start, then the janitor
+		// stops because the context is cancelled already
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		main.RunJanitor(ctx, memoryStorage, testRetention)
+
+		_, _, _, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+		assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+		// expired rule disables are deleted by their own janitor
+		assert.Equal(t, int64(1), expiredRuleDisablesCount(t, memoryStorage))
+	}, testsTimeout)
+}
+
+// TestStartJanitorRetentionDisabled checks that expired rule disables are
+// cleaned up even when the retention policy is disabled
+func TestStartJanitorRetentionDisabled(t *testing.T) {
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__RETENTION__ENABLED":                   "false",
+		"INSIGHTS_RESULTS_AGGREGATOR__RETENTION__REPORT":                    "24h",
+		"INSIGHTS_RESULTS_AGGREGATOR__RETENTION__RULE_HIT":                  "24h",
+		"INSIGHTS_RESULTS_AGGREGATOR__RETENTION__EXPIRED_DISABLES_INTERVAL": "10ms",
+	})
+
+	memoryStorage := newStorageWithExpiredReport(t)
+	disableRuleUntilPast(t, memoryStorage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	main.StartJanitor(ctx, memoryStorage)
+
+	assert.Eventually(t, func() bool {
+		return expiredRuleDisablesCount(t, memoryStorage) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// expired records are kept, because the retention policy is disabled
+	_, _, _, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+}
+
+func TestCleanupCommand(t *testing.T) {
+	setEnvSettings(t, map[string]string{
+		"INSIGHTS_RESULTS_AGGREGATOR__STORAGE__DB_DRIVER":  "memory",
+		"INSIGHTS_RESULTS_AGGREGATOR__RETENTION__REPORT":   "24h",
+		"INSIGHTS_RESULTS_AGGREGATOR__RETENTION__RULE_HIT": "24h",
+	})
+
+	assert.Equal(t, main.ExitStatusOK, main.Cleanup([]string{"--dry-run"}))
+	assert.Equal(t, main.ExitStatusOK, main.Cleanup(nil))
+	assert.Equal(t, main.ExitStatusError, main.Cleanup([]string{"--unknown-flag"}))
+}
@@ -19,9 +19,10 @@ limitations under the License.
 // contains function named LoadConfiguration that can be used to load
 // configuration from provided configuration file and/or from environment
 // variables. Additionally several specific functions named
-// GetBrokerConfiguration, GetStorageConfiguration, GetLoggingConfiguration,
-// GetCloudWatchConfiguration, and GetServerConfiguration are to be used to
-// return specific configuration options.
+// GetBrokerConfiguration, GetStorageConfiguration, GetRetentionConfiguration,
+// GetLoggingConfiguration, GetCloudWatchConfiguration, and
+// GetServerConfiguration are to be used to return specific configuration
+// options.
 //
 // Generated documentation is available at:
 // https://godoc.org/github.com/RedHatInsights/insights-results-aggregator/conf
@@ -39,6 +40,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/RedHatInsights/insights-operator-utils/logger"
@@ -73,9 +75,15 @@ type ConfigStruct struct {
 	Broker     broker.Configuration `mapstructure:"broker" toml:"broker"`
 	Server     server.Configuration `mapstructure:"server" toml:"server"`
 	Processing struct {
-		OrgAllowlistFile string `mapstructure:"org_allowlist_file" toml:"org_allowlist_file"`
+		OrgAllowlistFile           string        `mapstructure:"org_allowlist_file" toml:"org_allowlist_file"`
+		OrgAllowlistReloadInterval time.Duration `mapstructure:"org_allowlist_reload_interval" toml:"org_allowlist_reload_interval"`
+		OrgDenylist                []types.OrgID `mapstructure:"org_denylist" toml:"org_denylist"`
+		// OrgModes maps organization IDs to their processing modes,
+		// organizations not listed here are processed normally
+		OrgModes map[string]string `mapstructure:"org_modes" toml:"org_modes"`
 	} `mapstructure:"processing"`
 	Storage           storage.Configuration             `mapstructure:"storage" toml:"storage"`
+	Retention         storage.RetentionConfiguration    `mapstructure:"retention" toml:"retention"`
 	Logging           logger.LoggingConfiguration       `mapstructure:"logging" toml:"logging"`
 	CloudWatch        logger.CloudWatchConfiguration    `mapstructure:"cloudwatch" toml:"cloudwatch"`
 	Metrics           MetricsConfiguration              `mapstructure:"metrics" toml:"metrics"`
@@ -145,6 +153,10 @@ func LoadConfiguration(defaultConfigFile string) error {
 		return err
 	}
 
+	if err := checkRetentionConfiguration(Config.Retention); err != nil {
+		return err
+	}
+
 	// everything's should be ok
 	return nil
 }
@@ -152,6 +164,8 @@ func LoadConfiguration(defaultConfigFile string) error {
 // GetBrokerConfiguration returns broker configuration
 func GetBrokerConfiguration() broker.Configuration {
 	Config.Broker.OrgAllowlist = getOrganizationAllowlist()
+	Config.Broker.OrgDenylist = getOrganizationDenylist()
+	Config.Broker.OrgProcessingModes = getOrgProcessingModes()
 
 	return Config.Broker
 }
@@ -161,28 +175,145 @@ func getOrganizationAllowlist() mapset.Set {
 		return nil
 	}
 
+	allowlist, err := readOrganizationAllowlist()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Organization allowlist could not be loaded")
+	}
+
+	return allowlist
+}
+
+// readOrganizationAllowlist reads the organization allowlist from the
+// configured CSV file
+func readOrganizationAllowlist() (mapset.Set, error) {
 	if Config.Processing.OrgAllowlistFile == "" {
 		Config.Processing.OrgAllowlistFile = defaultOrgAllowlistFileName
 	}
 
 	orgAllowlistFileData, err := os.ReadFile(Config.Processing.OrgAllowlistFile)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Organization allowlist file could not be opened")
+		return nil, fmt.Errorf("organization allowlist file could not be opened: %w", err)
 	}
 
 	allowlist, err := loadAllowlistFromCSV(bytes.NewBuffer(orgAllowlistFileData))
 	if err != nil {
-		log.Fatal().Err(err).Msg("Allowlist CSV could not be processed")
+		return nil, fmt.Errorf("allowlist CSV could not be processed: %w", err)
 	}
 
-	return allowlist
+	return allowlist, nil
+}
+
+// getOrganizationDenylist returns set of organizations whose messages are
+// rejected by the consumer
+func getOrganizationDenylist() mapset.Set {
+	if len(Config.Processing.OrgDenylist) == 0 {
+		return nil
+	}
+
+	denylist := mapset.NewSet()
+	for _, orgID := range Config.Processing.OrgDenylist {
+		denylist.Add(orgID)
+	}
+
+	return denylist
+}
+
+// getOrgProcessingModes returns processing modes of organizations
+// configured in [processing] section
+func getOrgProcessingModes() map[types.OrgID]string {
+	modes, err := parseOrgProcessingModes(Config.Processing.OrgModes)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Organization processing modes could not be processed")
+	}
+
+	return modes
+}
+
+// parseOrgProcessingModes checks the configured processing modes and
+// converts organization IDs into numbers
+func parseOrgProcessingModes(orgModes map[string]string) (map[types.OrgID]string, error) {
+	if len(orgModes) == 0 {
+		return nil, nil
+	}
+
+	modes := make(map[types.OrgID]string, len(orgModes))
+
+	for orgIDStr, mode := range orgModes {
+		orgID, err := strconv.ParseUint(orgIDStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("organization ID in processing modes is not numerical. Found value: %v", orgIDStr)
+		}
+
+		if !isSupportedProcessingMode(mode) {
+			return nil, fmt.Errorf("unsupported processing mode '%s' for organization %d", mode, orgID)
+		}
+
+		modes[types.OrgID(orgID)] = mode
+	}
+
+	return modes, nil
+}
+
+// isSupportedProcessingMode checks if the mode is one of
+// broker.ProcessingModes
+func isSupportedProcessingMode(mode string) bool {
+	for _, supported := range broker.ProcessingModes {
+		if mode == supported {
+			return true
+		}
+	}
+	return false
 }
 
 // GetStorageConfiguration returns storage configuration
 func GetStorageConfiguration() storage.Configuration {
+	Config.Storage.ReportHistoryDisabledOrgs = getReportHistoryDisabledOrgs()
+
 	return Config.Storage
 }
 
+// getReportHistoryDisabledOrgs returns organizations whose reports are not
+// stored in the history because of their processing mode
+func getReportHistoryDisabledOrgs() map[types.OrgID]bool {
+	var orgs map[types.OrgID]bool
+
+	for orgID, mode := range getOrgProcessingModes() {
+		if mode != broker.ProcessingModeLatestOnly {
+			continue
+		}
+
+		if orgs == nil {
+			orgs = make(map[types.OrgID]bool)
+		}
+		orgs[orgID] = true
+	}
+
+	return orgs
+}
+
+// GetRetentionConfiguration returns configuration of retention policy
+func GetRetentionConfiguration() storage.RetentionConfiguration {
+	return Config.Retention
+}
+
+// checkRetentionConfiguration checks that rule hits don't outlive reports.
+// Rule hits are selected by the reports of their clusters, so they would be
+// kept forever when the report is deleted first.
+func checkRetentionConfiguration(retention storage.RetentionConfiguration) error {
+	if retention.Report == 0 {
+		return nil
+	}
+
+	if retention.RuleHit == 0 || retention.RuleHit > retention.Report {
+		return fmt.Errorf(
+			"retention period of rule hits (%v) must be set and not longer than retention period of reports (%v)",
+			retention.RuleHit, retention.Report,
+		)
+	}
+
+	return nil
+}
+
 // GetLoggingConfiguration returns logging configuration
 func GetLoggingConfiguration() logger.LoggingConfiguration {
 	return Config.Logging
@@ -213,6 +344,12 @@ func GetServerConfiguration() server.Configuration {
 	return Config.Server
 }
 
+// GetOrgAllowlistReloadInterval returns the interval in which the
+// organization allowlist file is checked for changes
+func GetOrgAllowlistReloadInterval() time.Duration {
+	return Config.Processing.OrgAllowlistReloadInterval
+}
+
 // GetMetricsConfiguration returns metrics configuration
 func GetMetricsConfiguration() MetricsConfiguration {
 	return Config.Metrics
@@ -343,5 +480,11 @@ func updateTopicsMapping(c *ConfigStruct) error {
 		fmt.Printf(noTopicMapping, c.Broker.PayloadTrackerTopic)
 	}
 
+	if topicCfg, ok := clowder.KafkaTopics[c.Broker.RuleHitsChangesTopic]; ok {
+		c.Broker.RuleHitsChangesTopic = topicCfg.Name
+	} else {
+		fmt.Printf(noTopicMapping, c.Broker.RuleHitsChangesTopic)
+	}
+
 	return nil
 }
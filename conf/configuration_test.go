@@ -30,6 +30,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
@@ -162,6 +163,33 @@ func TestLoadStorageConfiguration(t *testing.T) {
 	assert.Equal(t, ":memory:", storageCfg.SQLiteDataSource)
 }
 
+// TestLoadRetentionConfiguration tests loading the retention configuration sub-tree
+func TestLoadRetentionConfiguration(t *testing.T) {
+	TestLoadConfiguration(t)
+
+	assert.Equal(t, storage.RetentionConfiguration{
+		Enabled:       true,
+		Interval:      30 * time.Minute,
+		ConsumerError: 7 * 24 * time.Hour,
+		Report:        60 * 24 * time.Hour,
+		RuleHit:       30 * 24 * time.Hour,
+	}, conf.GetRetentionConfiguration())
+}
+
+// TestLoadRetentionConfigurationRuleHitOutlivesReport tests that rule hits
+// can't be kept longer than reports they belong to
+func TestLoadRetentionConfigurationRuleHitOutlivesReport(t *testing.T) {
+	for _, ruleHit := range []string{"0", "2000h"} {
+		os.Clearenv()
+
+		mustSetEnv(t, "INSIGHTS_RESULTS_AGGREGATOR__RETENTION__RULE_HIT", ruleHit)
+
+		err := conf.LoadConfiguration("tests/config1")
+		assert.Error(t, err, ruleHit)
+		assert.Contains(t, err.Error(), "retention period of rule hits", ruleHit)
+	}
+}
+
 // TestLoadConfigurationOverrideFromEnv tests overriding configuration by env variables
 func TestLoadConfigurationOverrideFromEnv(t *testing.T) {
 	os.Clearenv()
@@ -327,6 +355,67 @@ func TestLoadConfigurationFromFile(t *testing.T) {
 	}, conf.GetStorageConfiguration())
 }
 
+// TestLoadOrgProcessingModes checks that the deny list and per organization
+// processing modes are loaded from [processing] section
+func TestLoadOrgProcessingModes(t *testing.T) {
+	config := `[broker]
+		enabled = true
+
+		[processing]
+		org_denylist = [42, 43]
+
+		[processing.org_modes]
+		1 = "no_notifications"
+		2 = "latest_only"
+		3 = "drop"
+
+		[storage]
+		report_history_length = 10
+	`
+
+	tmpFilename, err := GetTmpConfigFile(config)
+	helpers.FailOnError(t, err)
+
+	defer removeFile(t, tmpFilename)
+
+	// the values are not overwritten by configurations loaded later
+	defer func() {
+		conf.Config.Processing.OrgDenylist = nil
+		conf.Config.Processing.OrgModes = nil
+		conf.Config.Storage.ReportHistoryLength = 0
+	}()
+
+	os.Clearenv()
+	mustSetEnv(t, conf.ConfigFileEnvVariableName, tmpFilename)
+	mustLoadConfiguration("../tests/config1")
+
+	brokerCfg := conf.GetBrokerConfiguration()
+
+	assert.True(t, brokerCfg.OrgDenylist.Equal(mapset.NewSetWith(types.OrgID(42), types.OrgID(43))))
+	assert.Equal(t, map[types.OrgID]string{
+		1: broker.ProcessingModeNoNotifications,
+		2: broker.ProcessingModeLatestOnly,
+		3: broker.ProcessingModeDrop,
+	}, brokerCfg.OrgProcessingModes)
+
+	storageCfg := conf.GetStorageConfiguration()
+	assert.Equal(t, 10, storageCfg.ReportHistoryLength)
+	assert.Equal(t, map[types.OrgID]bool{2: true}, storageCfg.ReportHistoryDisabledOrgs)
+}
+
+// TestParseOrgProcessingModesErrors tests invalid processing modes
+func TestParseOrgProcessingModesErrors(t *testing.T) {
+	_, err := conf.ParseOrgProcessingModes(map[string]string{"org": broker.ProcessingModeDrop})
+	assert.EqualError(t, err, "organization ID in processing modes is not numerical. Found value: org")
+
+	_, err = conf.ParseOrgProcessingModes(map[string]string{"1": "ignore"})
+	assert.EqualError(t, err, "unsupported processing mode 'ignore' for organization 1")
+
+	modes, err := conf.ParseOrgProcessingModes(nil)
+	helpers.FailOnError(t, err)
+	assert.Nil(t, modes)
+}
+
 func TestLoadConfigurationFromEnv(t *testing.T) {
 	setEnvVariables(t)
 
@@ -28,5 +28,6 @@ package conf
 var (
 	GetOrganizationAllowlist  = getOrganizationAllowlist
 	LoadAllowlistFromCSV      = loadAllowlistFromCSV
+	ParseOrgProcessingModes   = parseOrgProcessingModes
 	ConfigFileEnvVariableName = configFileEnvVariableName
 )
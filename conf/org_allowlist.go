@@ -0,0 +1,144 @@
+/*
+Copyright © 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ReloadOrganizationAllowlist reads the organization allowlist file again
+// and updates the given allowlist in place, so the consumer sees the
+// changes without restart. Only added and removed organizations are
+// changed, organizations present in both versions of the file are allowed
+// during the whole reload. When the file can't be read or processed, the
+// allowlist is left unchanged.
+func ReloadOrganizationAllowlist(allowlist mapset.Set) error {
+	newAllowlist, err := readOrganizationAllowlist()
+	if err != nil {
+		return err
+	}
+
+	added := sortedOrgIDs(newAllowlist.Difference(allowlist))
+	removed := sortedOrgIDs(allowlist.Difference(newAllowlist))
+
+	for _, orgID := range added {
+		allowlist.Add(orgID)
+	}
+	for _, orgID := range removed {
+		allowlist.Remove(orgID)
+	}
+
+	metrics.AllowedOrganizations.Set(float64(allowlist.Cardinality()))
+
+	if len(added) > 0 || len(removed) > 0 {
+		log.Info().
+			Interface("added", added).
+			Interface("removed", removed).
+			Int("allowed", allowlist.Cardinality()).
+			Msg("Organization allowlist reloaded")
+	}
+
+	return nil
+}
+
+// allowlistFileStamp identifies the version of the allowlist file, so the
+// file is parsed again only when it has been changed
+type allowlistFileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// readAllowlistFileStamp returns modification time and size of the
+// organization allowlist file
+func readAllowlistFileStamp() (allowlistFileStamp, error) {
+	info, err := os.Stat(Config.Processing.OrgAllowlistFile)
+	if err != nil {
+		return allowlistFileStamp{}, fmt.Errorf("organization allowlist file could not be opened: %w", err)
+	}
+
+	return allowlistFileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// WatchOrganizationAllowlist periodically checks the organization allowlist
+// file until the context is cancelled. The file is polled, i.e. its
+// modification time and size are compared in each interval and the
+// allowlist is reloaded only when they have been changed. Errors are logged
+// only, the last successfully loaded allowlist stays in use and the file is
+// read again in the next interval.
+func WatchOrganizationAllowlist(ctx context.Context, allowlist mapset.Set, interval time.Duration) {
+	log.Info().
+		Str("file", Config.Processing.OrgAllowlistFile).
+		Dur("interval", interval).
+		Msg("Organization allowlist watcher started")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// the file is read in the first interval, it might have been changed
+	// since the allowlist was loaded
+	var loaded allowlistFileStamp
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Organization allowlist watcher stopped")
+			return
+		case <-ticker.C:
+			stamp, err := readAllowlistFileStamp()
+			if err != nil {
+				log.Error().Err(err).Msg("Unable to reload organization allowlist")
+				continue
+			}
+
+			if stamp.modTime.Equal(loaded.modTime) && stamp.size == loaded.size {
+				continue
+			}
+
+			if err := ReloadOrganizationAllowlist(allowlist); err != nil {
+				log.Error().Err(err).Msg("Unable to reload organization allowlist")
+				continue
+			}
+
+			loaded = stamp
+		}
+	}
+}
+
+// sortedOrgIDs returns organization IDs from the set in ascending order,
+// so they are logged in readable form
+func sortedOrgIDs(set mapset.Set) []types.OrgID {
+	orgIDs := make([]types.OrgID, 0, set.Cardinality())
+	for item := range set.Iter() {
+		orgIDs = append(orgIDs, item.(types.OrgID))
+	}
+
+	sort.Slice(orgIDs, func(i, j int) bool {
+		return orgIDs[i] < orgIDs[j]
+	})
+
+	return orgIDs
+}
@@ -0,0 +1,142 @@
+/*
+Copyright © 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package conf_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	mapset "github.com/deckarep/golang-set"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustWriteAllowlistFile writes the allowlist CSV into a temporary file and
+// configures it as the organization allowlist file
+func mustWriteAllowlistFile(t *testing.T, path, content string) {
+	helpers.FailOnError(t, os.WriteFile(path, []byte(content), 0600))
+	conf.Config.Processing.OrgAllowlistFile = path
+}
+
+// TestReloadOrganizationAllowlist checks that added and removed
+// organizations are applied to the allowlist in place
+func TestReloadOrganizationAllowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "org_allowlist.csv")
+	mustWriteAllowlistFile(t, path, "OrgID\n1\n2\n")
+	defer func() { conf.Config.Processing.OrgAllowlistFile = "" }()
+
+	allowlist := mapset.NewSetWith(types.OrgID(1), types.OrgID(3))
+
+	helpers.FailOnError(t, conf.ReloadOrganizationAllowlist(allowlist))
+
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(1), types.OrgID(2))))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.AllowedOrganizations))
+}
+
+// TestReloadOrganizationAllowlistInvalidFile checks that the allowlist is
+// not changed when the new file can't be processed
+func TestReloadOrganizationAllowlistInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "org_allowlist.csv")
+	mustWriteAllowlistFile(t, path, "OrgID\nstr\n")
+	defer func() { conf.Config.Processing.OrgAllowlistFile = "" }()
+
+	allowlist := mapset.NewSetWith(types.OrgID(1))
+
+	err := conf.ReloadOrganizationAllowlist(allowlist)
+	assert.EqualError(
+		t, err,
+		"allowlist CSV could not be processed: "+
+			"organization ID on line 2 in allowlist CSV is not numerical. Found value: str",
+	)
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(1))))
+
+	conf.Config.Processing.OrgAllowlistFile = filepath.Join(t.TempDir(), "nonexisting.csv")
+	assert.Error(t, conf.ReloadOrganizationAllowlist(allowlist))
+	assert.True(t, allowlist.Equal(mapset.NewSetWith(types.OrgID(1))))
+}
+
+// TestWatchOrganizationAllowlist checks that changes of the allowlist file
+// are picked up by the watcher
+func TestWatchOrganizationAllowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "org_allowlist.csv")
+	mustWriteAllowlistFile(t, path, "OrgID\n1\n")
+	defer func() { conf.Config.Processing.OrgAllowlistFile = "" }()
+
+	allowlist := mapset.NewSetWith(types.OrgID(1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		conf.WatchOrganizationAllowlist(ctx, allowlist, 10*time.Millisecond)
+		close(stopped)
+	}()
+
+	helpers.FailOnError(t, os.WriteFile(path, []byte("OrgID\n1\n42\n"), 0600))
+
+	assert.Eventually(t, func() bool {
+		return allowlist.Contains(types.OrgID(42))
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-stopped
+}
+
+// TestWatchOrganizationAllowlistUnchangedFile checks that the allowlist file
+// is not parsed again when its modification time and size are the same
+func TestWatchOrganizationAllowlistUnchangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "org_allowlist.csv")
+	mustWriteAllowlistFile(t, path, "OrgID\n1\n")
+	defer func() { conf.Config.Processing.OrgAllowlistFile = "" }()
+
+	allowlist := mapset.NewSet()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		conf.WatchOrganizationAllowlist(ctx, allowlist, 10*time.Millisecond)
+		close(stopped)
+	}()
+
+	// the file is read in the first interval
+	assert.Eventually(t, func() bool {
+		return allowlist.Contains(types.OrgID(1))
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the change made outside of the file is not overwritten, because the
+	// file has not been parsed again
+	allowlist.Add(types.OrgID(42))
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, allowlist.Contains(types.OrgID(42)))
+
+	modTime := time.Now().Add(time.Minute)
+	helpers.FailOnError(t, os.Chtimes(path, modTime, modTime))
+
+	assert.Eventually(t, func() bool {
+		return !allowlist.Contains(types.OrgID(42))
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-stopped
+}
@@ -20,7 +20,10 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/conf"
 	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 )
 
 var (
@@ -29,17 +32,12 @@ var (
 )
 
 // startConsumer function starts the consumer or returns an error in case of
-// any error. When consumer is started properly, nil is returned instead.
-func startConsumer(brokerConf broker.Configuration) error {
+// any error. When consumer is started properly, nil is returned instead. The
+// storage is shared with the janitor, so it is not closed by the consumer.
+func startConsumer(brokerConf broker.Configuration, dbStorage storage.Storage) error {
 	defer finishConsumerInstanceInitialization()
 
-	dbStorage, err := createStorage()
-	if err != nil {
-		return err
-	}
-
-	defer closeStorage(dbStorage)
-
+	var err error
 	consumerInstance, err = consumer.New(brokerConf, dbStorage)
 	if err != nil {
 		log.Error().Err(err).Msg("Broker initialization error")
@@ -52,6 +50,27 @@ func startConsumer(brokerConf broker.Configuration) error {
 	return nil
 }
 
+// startOrgAllowlistWatcher function exposes the number of allowed
+// organizations as a metric and starts periodic reloading of the
+// organization allowlist in a separate goroutine when the allowlist is
+// enabled and the reload interval is configured. The watcher is stopped
+// when the context is cancelled. It needs to be called after the metrics
+// are initialized, because namespaced metrics replace the default ones.
+func startOrgAllowlistWatcher(ctx context.Context, brokerConf broker.Configuration) {
+	if !brokerConf.OrgAllowlistEnabled {
+		return
+	}
+
+	metrics.AllowedOrganizations.Set(float64(brokerConf.OrgAllowlist.Cardinality()))
+
+	interval := conf.GetOrgAllowlistReloadInterval()
+	if interval <= 0 {
+		return
+	}
+
+	go conf.WatchOrganizationAllowlist(ctx, brokerConf.OrgAllowlist, interval)
+}
+
 // stopConsumer function tries to stop the consumer. If consumer is not started
 // or if it is not possible to stop it properly, error value is returned.
 func stopConsumer() error {
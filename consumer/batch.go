@@ -0,0 +1,190 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// batchedMessage is a prepared message waiting in a batch to be written
+// into the storage
+type batchedMessage struct {
+	msg             *sarama.ConsumerMessage
+	message         incomingMessage
+	startTime       time.Time
+	lastCheckedTime time.Time
+	previousHits    map[types.RuleSelector]bool
+}
+
+// consumeClaimInBatches accumulates messages from the claim and writes them
+// into the storage in batches. A batch is written when it contains
+// BatchSize messages or when BatchTimeout elapsed since its first message
+// was consumed. Offsets are marked after the whole batch is processed.
+func (consumer *KafkaConsumer) consumeClaimInBatches(
+	session sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim,
+	latestMessageOffset types.KafkaOffset,
+) {
+	batchSize := consumer.Configuration.BatchSize
+	batchTimeout := consumer.Configuration.BatchTimeout
+
+	log.Info().
+		Int("batch_size", batchSize).
+		Dur("batch_timeout", batchTimeout).
+		Msg("processing messages in batches")
+
+	batch := make([]*sarama.ConsumerMessage, 0, batchSize)
+	var timeout <-chan time.Time
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		consumer.handleMessagesBatch(session.Context(), batch)
+		session.MarkMessage(batch[len(batch)-1], "")
+
+		batch = batch[:0]
+		timeout = nil
+	}
+
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				flush()
+				return
+			}
+
+			if !consumer.waitForRetryTopicDelay(session.Context(), message) {
+				flush()
+				return
+			}
+
+			if types.KafkaOffset(message.Offset) <= latestMessageOffset {
+				log.Warn().
+					Int64(offsetKey, message.Offset).
+					Msg("this offset was already processed by aggregator")
+			}
+			if types.KafkaOffset(message.Offset) > latestMessageOffset {
+				latestMessageOffset = types.KafkaOffset(message.Offset)
+			}
+
+			batch = append(batch, message)
+			if len(batch) == 1 && batchTimeout > 0 {
+				timeout = time.After(batchTimeout)
+			}
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-timeout:
+			flush()
+		}
+	}
+}
+
+// handleMessagesBatch handles several messages at once, all reports are
+// written into the storage by one call. Logging, metrics, error reporting
+// and payload tracking is done for each message the same way as in
+// HandleMessage.
+func (consumer *KafkaConsumer) handleMessagesBatch(ctx context.Context, msgs []*sarama.ConsumerMessage) {
+	batched := make([]batchedMessage, 0, len(msgs))
+	items := make([]storage.ReportBatchItem, 0, len(msgs))
+
+	for _, msg := range msgs {
+		log.Info().
+			Int64(offsetKey, msg.Offset).
+			Int32(partitionKey, msg.Partition).
+			Str(topicKey, msg.Topic).
+			Time("message_timestamp", msg.Timestamp).
+			Msgf("started processing message")
+
+		metrics.ConsumedMessages.Inc()
+
+		startTime := time.Now()
+		message, reportAsBytes, lastCheckedTime, err := consumer.prepareMessage(msg)
+		if err == errMessageDropped {
+			consumer.finishMessage(msg, message.RequestID, message, startTime, nil)
+			continue
+		} else if err != nil {
+			consumer.finishMessage(msg, message.RequestID, message, startTime, err)
+			continue
+		}
+
+		batched = append(batched, batchedMessage{
+			msg:             msg,
+			message:         message,
+			startTime:       startTime,
+			lastCheckedTime: lastCheckedTime,
+			// rule hits need to be read before they are overwritten by new report
+			previousHits: consumer.readPreviousRuleHits(msg, message),
+		})
+		items = append(items, storage.ReportBatchItem{
+			OrgID:       *message.Organization,
+			ClusterName: *message.ClusterName,
+			Report:      types.ClusterReport(reportAsBytes),
+			Rules:       message.ParsedHits,
+			Info:        message.ParsedInfo,
+			LastChecked: lastCheckedTime,
+			GatheredAt:  message.Metadata.GatheredAt,
+			StoredAt:    time.Now(),
+			KafkaOffset: types.KafkaOffset(msg.Offset),
+		})
+	}
+
+	if len(items) == 0 {
+		return
+	}
+
+	tStart := time.Now()
+	errs := consumer.Storage.WriteReportsBatch(items)
+	log.Info().
+		Int("reports", len(items)).
+		Int64(durationKey, time.Since(tStart).Milliseconds()).
+		Msg("db_store_batch")
+
+	for i := range batched {
+		b := &batched[i]
+		err := errs[i]
+
+		if consumer.shouldRetry(1, err) {
+			// nothing has been written for the message, so it is stored again
+			// without batching, storeMessage retries each step on its own
+			// and does all the logging
+			if consumer.waitBeforeRetry(ctx, b.msg, 1, err) {
+				err = consumer.storeMessage(ctx, b.msg, b.message, []byte(items[i].Report), b.lastCheckedTime, b.previousHits)
+			}
+		} else if err == types.ErrOldReport {
+			logMessageInfo(consumer, b.msg, b.message, "Skipping because a more recent report already exists for this cluster")
+			err = nil
+		} else if err != nil {
+			logMessageError(consumer, b.msg, b.message, "Error writing report to database", err)
+		} else {
+			logMessageInfo(consumer, b.msg, b.message, "Stored report")
+			logClusterInfo(&b.message)
+			consumer.sendRuleHitsChanges(b.msg, b.message, b.previousHits, b.lastCheckedTime)
+		}
+
+		consumer.finishMessage(b.msg, b.message.RequestID, b.message, b.startTime, err)
+	}
+}
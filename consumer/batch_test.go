@@ -0,0 +1,155 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-operator-utils/tests/saramahelpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// batchStorage is an in-memory storage remembering sizes of written batches
+type batchStorage struct {
+	*storage.MemoryStorage
+	mutex          sync.Mutex
+	batches        []int
+	consumerErrors int
+}
+
+func (s *batchStorage) WriteReportsBatch(items []storage.ReportBatchItem) []error {
+	s.mutex.Lock()
+	s.batches = append(s.batches, len(items))
+	s.mutex.Unlock()
+
+	return s.MemoryStorage.WriteReportsBatch(items)
+}
+
+func (s *batchStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
+	s.mutex.Lock()
+	s.consumerErrors++
+	s.mutex.Unlock()
+
+	return s.MemoryStorage.WriteConsumerError(msg, consumerErr)
+}
+
+// openClaim is a consumer group claim whose messages channel is closed by
+// the test
+type openClaim struct {
+	saramahelpers.MockConsumerGroupClaim
+	channel chan *sarama.ConsumerMessage
+}
+
+func (claim *openClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return claim.channel
+}
+
+func newBatchStorage() *batchStorage {
+	return &batchStorage{
+		MemoryStorage: storage.NewMemoryStorage(storage.Configuration{}),
+	}
+}
+
+func reportMessage(offset int64, clusterName types.ClusterName, lastChecked time.Time) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Offset: offset,
+		Value: []byte(`{
+			"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+			"ClusterName": "` + string(clusterName) + `",
+			"Report": ` + testdata.ConsumerReport + `,
+			"LastChecked": "` + lastChecked.Format(time.RFC3339) + `"
+		}`),
+	}
+}
+
+func TestKafkaConsumer_ConsumeClaim_Batches(t *testing.T) {
+	mockStorage := newBatchStorage()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{BatchSize: 3, BatchTimeout: time.Minute},
+		Storage:       mockStorage,
+	}
+
+	olderCluster := testdata.GetRandomClusterID()
+	messages := []*sarama.ConsumerMessage{
+		reportMessage(0, testdata.ClusterName, testdata.LastCheckedAt),
+		reportMessage(1, olderCluster, testdata.LastCheckedAt.Add(time.Hour)),
+		{Offset: 2, Value: []byte("this is not a message")},
+		reportMessage(3, olderCluster, testdata.LastCheckedAt),
+		reportMessage(4, testdata.GetRandomClusterID(), testdata.LastCheckedAt),
+	}
+
+	session := &recordingSession{}
+	err := kafkaConsumer.ConsumeClaim(session, saramahelpers.NewMockConsumerGroupClaim(messages))
+	helpers.FailOnError(t, err)
+
+	// the invalid message is not part of the batch, the rest is written
+	// when the claim is closed
+	assert.Equal(t, []int{2, 2}, mockStorage.batches)
+	assert.Equal(t, 1, mockStorage.consumerErrors)
+	assert.Equal(t, uint64(4), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+	assert.Equal(t, uint64(1), kafkaConsumer.GetNumberOfErrorsConsumingMessages())
+	assert.Equal(t, []int64{2, 4}, session.marked)
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 3, count)
+
+	// the older report has been skipped
+	_, lastChecked, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, olderCluster)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)), lastChecked)
+}
+
+func TestKafkaConsumer_ConsumeClaim_BatchTimeout(t *testing.T) {
+	mockStorage := newBatchStorage()
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{BatchSize: 100, BatchTimeout: 10 * time.Millisecond},
+		Storage:       mockStorage,
+	}
+
+	claim := &openClaim{channel: make(chan *sarama.ConsumerMessage, 1)}
+	claim.channel <- reportMessage(0, testdata.ClusterName, testdata.LastCheckedAt)
+
+	session := &recordingSession{}
+	done := make(chan error)
+	go func() {
+		done <- kafkaConsumer.ConsumeClaim(session, claim)
+	}()
+
+	// the batch is written even though the claim is still open
+	assert.Eventually(t, func() bool {
+		session.mutex.Lock()
+		defer session.mutex.Unlock()
+		return len(session.marked) == 1
+	}, testCaseTimeLimit, time.Millisecond)
+
+	close(claim.channel)
+	helpers.FailOnError(t, <-done)
+
+	assert.Equal(t, []int{1}, mockStorage.batches)
+}
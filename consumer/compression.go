@@ -0,0 +1,148 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Shopify/sarama"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// ContentEncodingHeader is the name of Kafka header that can be used
+	// to specify the compression of message value. Compressed values are
+	// also detected by their magic bytes, so the header is optional.
+	ContentEncodingHeader = "content-encoding"
+
+	// GzipEncoding represents message value compressed by gzip
+	GzipEncoding = "gzip"
+	// ZstdEncoding represents message value compressed by zstd
+	ZstdEncoding = "zstd"
+	// IdentityEncoding represents message value that is not compressed
+	IdentityEncoding = "identity"
+
+	// MaxDecompressedMessageSize is the maximum size of decompressed message
+	// value, bigger messages are rejected
+	MaxDecompressedMessageSize = 256 * 1024 * 1024
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// messageEncoding returns the compression of message value specified by
+// Kafka header, or an empty string when the header is not set
+func messageEncoding(msg *sarama.ConsumerMessage) string {
+	for _, header := range msg.Headers {
+		if header != nil && strings.EqualFold(string(header.Key), ContentEncodingHeader) {
+			return strings.ToLower(strings.TrimSpace(string(header.Value)))
+		}
+	}
+	return ""
+}
+
+// DecompressedMessageValue returns the decompressed value of the message.
+// Compression is specified by content-encoding header or detected by magic
+// bytes of the value.
+func DecompressedMessageValue(msg *sarama.ConsumerMessage) ([]byte, error) {
+	return decompressMessageValue(msg.Value, messageEncoding(msg))
+}
+
+// decompressedMessage returns a copy of the message with decompressed value
+// and without content-encoding header, so the payload stored as consumer
+// error or sent to dead letter queue can be read without knowing the
+// compression. The original message is returned when its value can't be
+// decompressed.
+func decompressedMessage(msg *sarama.ConsumerMessage) *sarama.ConsumerMessage {
+	messageValue, err := DecompressedMessageValue(msg)
+	if err != nil {
+		return msg
+	}
+
+	decompressed := *msg
+	decompressed.Value = messageValue
+	decompressed.Headers = nil
+	for _, header := range msg.Headers {
+		if header != nil && strings.EqualFold(string(header.Key), ContentEncodingHeader) {
+			continue
+		}
+		decompressed.Headers = append(decompressed.Headers, header)
+	}
+
+	return &decompressed
+}
+
+// detectEncoding returns the compression of message value detected by its
+// magic bytes
+func detectEncoding(messageValue []byte) string {
+	switch {
+	case bytes.HasPrefix(messageValue, gzipMagic):
+		return GzipEncoding
+	case bytes.HasPrefix(messageValue, zstdMagic):
+		return ZstdEncoding
+	default:
+		return IdentityEncoding
+	}
+}
+
+// decompressMessageValue returns the decompressed message value. When the
+// encoding is not known, it's detected by magic bytes of the value.
+func decompressMessageValue(messageValue []byte, encoding string) ([]byte, error) {
+	if encoding == "" {
+		encoding = detectEncoding(messageValue)
+	}
+
+	var reader io.Reader
+	switch encoding {
+	case IdentityEncoding:
+		return messageValue, nil
+	case GzipEncoding:
+		gzipReader, err := gzip.NewReader(bytes.NewReader(messageValue))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress gzip message: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case ZstdEncoding:
+		zstdReader, err := zstd.NewReader(bytes.NewReader(messageValue))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress zstd message: %w", err)
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	default:
+		return nil, fmt.Errorf("unsupported message encoding '%s'", encoding)
+	}
+
+	// one more byte is read to find out that the limit was exceeded
+	decompressed, err := ioutil.ReadAll(io.LimitReader(reader, MaxDecompressedMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress %s message: %w", encoding, err)
+	}
+	if len(decompressed) > MaxDecompressedMessageSize {
+		return nil, fmt.Errorf(
+			"decompressed %s message is bigger than %d bytes", encoding, MaxDecompressedMessageSize,
+		)
+	}
+
+	return decompressed, nil
+}
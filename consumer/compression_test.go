@@ -0,0 +1,162 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func gzipCompress(t *testing.T, data string) []byte {
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(data))
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, writer.Close())
+
+	return buffer.Bytes()
+}
+
+func zstdCompress(t *testing.T, data string) []byte {
+	encoder, err := zstd.NewWriter(nil)
+	helpers.FailOnError(t, err)
+	defer func() {
+		helpers.FailOnError(t, encoder.Close())
+	}()
+
+	return encoder.EncodeAll([]byte(data), nil)
+}
+
+func TestParseCompressedMessage(t *testing.T) {
+	for name, compressed := range map[string][]byte{
+		"gzip": gzipCompress(t, createVersionedConsumerMessage(1, testReport)),
+		"zstd": zstdCompress(t, createVersionedConsumerMessage(1, testReport)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			message, err := consumer.ParseMessage(compressed)
+			helpers.FailOnError(t, err)
+
+			assert.Equal(t, testdata.ClusterName, *message.ClusterName)
+			assert.Len(t, message.ParsedHits, 3)
+		})
+	}
+}
+
+func TestParseCorruptedCompressedMessage(t *testing.T) {
+	compressed := gzipCompress(t, testdata.ConsumerMessage)
+
+	_, err := consumer.ParseMessage(compressed[:len(compressed)/2])
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to decompress gzip message")
+}
+
+func TestHandleCompressedMessageWithHeader(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       memoryStorage,
+	}
+
+	msg := &sarama.ConsumerMessage{
+		Topic: testTopicName,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("Content-Encoding"), Value: []byte("zstd")},
+		},
+		Value: zstdCompress(t, testdata.ConsumerMessage),
+	}
+	helpers.FailOnError(t, mockConsumer.HandleMessage(msg))
+
+	reports, err := memoryStorage.ReadReportsForClusters([]types.ClusterName{testdata.ClusterName})
+	helpers.FailOnError(t, err)
+	assert.Contains(t, reports, testdata.ClusterName)
+}
+
+func TestHandleMessageWithUnsupportedEncoding(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       memoryStorage,
+	}
+
+	msg := &sarama.ConsumerMessage{
+		Topic: testTopicName,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte(consumer.ContentEncodingHeader), Value: []byte("br")},
+		},
+		Value: []byte(testdata.ConsumerMessage),
+	}
+	assert.EqualError(t, mockConsumer.HandleMessage(msg), "unsupported message encoding 'br'")
+
+	consumerErrors, err := memoryStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+}
+
+func TestClusterNameFromCompressedMessage(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Value: gzipCompress(t, testdata.ConsumerMessage)}
+	assert.Equal(t, testdata.ClusterName, consumer.ClusterNameFromMessage(msg))
+}
+
+func TestHandleCompressedMessageErrorStoredDecompressed(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mockProducer := &capturingProducer{}
+
+	brokerCfg := broker.Configuration{
+		Topic:                testTopicName,
+		DeadLetterQueueTopic: testDeadLetterTopic,
+	}
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       memoryStorage,
+	}
+	consumer.SetDeadLetterProducer(mockConsumer, &producer.DeadLetterProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: brokerCfg,
+	})
+
+	messageValue := createVersionedConsumerMessage(42, testReport)
+	msg := &sarama.ConsumerMessage{
+		Topic: testTopicName,
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte(consumer.ContentEncodingHeader), Value: []byte(consumer.GzipEncoding)},
+		},
+		Value: gzipCompress(t, messageValue),
+	}
+	assert.EqualError(t, mockConsumer.HandleMessage(msg), "unsupported message schema version 42")
+
+	consumerErrors, err := memoryStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, []byte(messageValue), consumerErrors[0].Message)
+
+	assert.Len(t, mockProducer.messages, 1)
+	deadLetter, err := mockProducer.messages[0].Value.Encode()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []byte(messageValue), deadLetter)
+}
@@ -20,6 +20,7 @@ package consumer
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/Shopify/sarama"
 	"github.com/rs/zerolog/log"
@@ -61,6 +62,9 @@ type KafkaConsumer struct {
 	cancel                               context.CancelFunc
 	payloadTrackerProducer               *producer.PayloadTrackerProducer
 	deadLetterProducer                   *producer.DeadLetterProducer
+	ruleHitsChangesProducer              *producer.RuleHitsChangesProducer
+	retryProducer                        *producer.RetryProducer
+	rateLimiter                          *ingestRateLimiter
 }
 
 // DefaultSaramaConfig is a config which will be used by default
@@ -94,6 +98,29 @@ func NewWithSaramaConfig(
 		return nil, err
 	}
 
+	// messages passed to the message handler directly (for example replayed
+	// dead letters) are not rate limited, only consumed messages are
+	rateLimiter, err := newIngestRateLimiter(brokerCfg)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to construct ingest rate limiter")
+		return nil, err
+	}
+
+	consumer, err := NewMessageHandler(brokerCfg, storage)
+	if err != nil {
+		return nil, err
+	}
+	consumer.ConsumerGroup = consumerGroup
+	consumer.rateLimiter = rateLimiter
+
+	return consumer, nil
+}
+
+// NewMessageHandler constructs implementation of Consumer interface that
+// doesn't consume any topic. Messages can be passed to its HandleMessage
+// method directly, for example when messages from the dead letter queue are
+// replayed. All configured producers are constructed.
+func NewMessageHandler(brokerCfg broker.Configuration, storage storage.Storage) (*KafkaConsumer, error) {
 	payloadTrackerProducer, err := producer.NewPayloadTrackerProducer(brokerCfg)
 	if err != nil {
 		log.Error().Err(err).Msg("unable to construct payload tracker producer")
@@ -112,15 +139,34 @@ func NewWithSaramaConfig(
 		log.Info().Msg("dead letter producer not configured")
 	}
 
+	ruleHitsChangesProducer, err := producer.NewRuleHitsChangesProducer(brokerCfg)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to construct rule hits changes producer")
+		return nil, err
+	}
+	if ruleHitsChangesProducer == nil {
+		log.Info().Msg("rule hits changes producer not configured")
+	}
+
+	retryProducer, err := producer.NewRetryProducer(brokerCfg)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to construct retry producer")
+		return nil, err
+	}
+	if retryProducer == nil {
+		log.Info().Msg("retry producer not configured")
+	}
+
 	consumer := &KafkaConsumer{
 		Configuration:                        brokerCfg,
-		ConsumerGroup:                        consumerGroup,
 		Storage:                              storage,
 		numberOfSuccessfullyConsumedMessages: 0,
 		numberOfErrorsConsumingMessages:      0,
 		ready:                                make(chan bool),
 		payloadTrackerProducer:               payloadTrackerProducer,
 		deadLetterProducer:                   deadLetterProducer,
+		ruleHitsChangesProducer:              ruleHitsChangesProducer,
+		retryProducer:                        retryProducer,
 	}
 
 	return consumer, nil
@@ -136,7 +182,7 @@ func (consumer *KafkaConsumer) Serve() {
 			// `Consume` should be called inside an infinite loop, when a
 			// server-side rebalance happens, the consumer session will need to be
 			// recreated to get the new claims
-			if err := consumer.ConsumerGroup.Consume(ctx, []string{consumer.Configuration.Topic}, consumer); err != nil {
+			if err := consumer.ConsumerGroup.Consume(ctx, consumer.topics(), consumer); err != nil {
 				log.Fatal().Err(err).Msg("unable to recreate kafka session")
 			}
 
@@ -190,6 +236,16 @@ func (consumer *KafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession,
 		latestMessageOffset = 0
 	}
 
+	if consumer.Configuration.BatchSize > 1 {
+		consumer.consumeClaimInBatches(session, claim, latestMessageOffset)
+		return nil
+	}
+
+	if consumer.Configuration.Workers > 1 {
+		consumer.consumeClaimConcurrently(session, claim, latestMessageOffset)
+		return nil
+	}
+
 	for message := range claim.Messages() {
 		if types.KafkaOffset(message.Offset) <= latestMessageOffset {
 			log.Warn().
@@ -197,7 +253,11 @@ func (consumer *KafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession,
 				Msg("this offset was already processed by aggregator")
 		}
 
-		err = consumer.HandleMessage(message)
+		if !consumer.waitForRetryTopicDelay(session.Context(), message) {
+			return nil
+		}
+
+		err = consumer.handleMessage(session.Context(), message)
 		if err != nil {
 			// already hanadled in HandleMessage, just log
 			log.Error().Err(err).Msg("Problem while handling the message")
@@ -235,17 +295,29 @@ func (consumer *KafkaConsumer) Close() error {
 		}
 	}
 
+	if consumer.ruleHitsChangesProducer != nil {
+		if err := consumer.ruleHitsChangesProducer.Close(); err != nil {
+			log.Error().Err(err).Msg("unable to close rule hits changes Kafka producer")
+		}
+	}
+
+	if consumer.retryProducer != nil {
+		if err := consumer.retryProducer.Close(); err != nil {
+			log.Error().Err(err).Msg("unable to close retry Kafka producer")
+		}
+	}
+
 	return nil
 }
 
 // GetNumberOfSuccessfullyConsumedMessages returns number of consumed messages
 // since creating KafkaConsumer obj
 func (consumer *KafkaConsumer) GetNumberOfSuccessfullyConsumedMessages() uint64 {
-	return consumer.numberOfSuccessfullyConsumedMessages
+	return atomic.LoadUint64(&consumer.numberOfSuccessfullyConsumedMessages)
 }
 
 // GetNumberOfErrorsConsumingMessages returns number of errors during consuming messages
 // since creating KafkaConsumer obj
 func (consumer *KafkaConsumer) GetNumberOfErrorsConsumingMessages() uint64 {
-	return consumer.numberOfErrorsConsumingMessages
+	return atomic.LoadUint64(&consumer.numberOfErrorsConsumingMessages)
 }
@@ -53,7 +53,7 @@ const (
 	// message to be checked
 	organizationIDNotInAllowList = "organization ID is not in allow list"
 
-	testReport = `{"fingerprints": [], "info": [], "skips": [], "system": {}, "analysis_metadata":{"metadata":"some metadata"},"reports":[{"rule_id":"rule_4|RULE_4","component":"ccx_rules_ocp.external.rules.rule_1.report","type":"rule","key":"RULE_4","details":"some details"},{"rule_id":"rule_4|RULE_4","component":"ccx_rules_ocp.external.rules.rule_2.report","type":"rule","key":"RULE_2","details":"some details"},{"rule_id":"rule_5|RULE_5","component":"ccx_rules_ocp.external.rules.rule_5.report","type":"rule","key":"RULE_3","details":"some details"}]}`
+	testReport = `{"fingerprints": [], "info": [], "skips": [], "system": {}, "analysis_metadata":{"metadata":"some metadata"},"reports":[{"rule_id":"rule_4|RULE_4","component":"ccx_rules_ocp.external.rules.rule_1.report","type":"rule","key":"RULE_4","details":{"description":"some details"}},{"rule_id":"rule_4|RULE_4","component":"ccx_rules_ocp.external.rules.rule_2.report","type":"rule","key":"RULE_2","details":{"description":"some details"}},{"rule_id":"rule_5|RULE_5","component":"ccx_rules_ocp.external.rules.rule_5.report","type":"rule","key":"RULE_3","details":{"description":"some details"}}]}`
 )
 
 var (
@@ -173,7 +173,7 @@ func TestParseMessageWithImproperReport(t *testing.T) {
 	assert.EqualError(
 		t,
 		err,
-		"json: cannot unmarshal string into Go value of type []types.ReportItem",
+		"message does not conform to schema version 1: /Report/reports: expected array, got string",
 	)
 }
 
@@ -0,0 +1,84 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// messageDecoder decodes the message with one schema version into the
+// internal form, ie. it fills ParsedHits, ParsedInfo and Metadata of the
+// message. Common attributes of the message (organization, cluster name,
+// report etc.) have already been read and checked.
+type messageDecoder func(messageValue []byte, message *incomingMessage) error
+
+// messageDecoders contains decoders for all supported schema versions.
+// Messages without Version attribute were produced before the versioning
+// was introduced, and they have the same format as version 1.
+var messageDecoders = map[types.SchemaVersion]messageDecoder{
+	0: decodeMessageV1,
+	1: decodeMessageV1,
+}
+
+// UnsupportedSchemaVersionError is returned when there is no decoder for
+// the version of incoming message
+type UnsupportedSchemaVersionError struct {
+	Version types.SchemaVersion
+}
+
+// Error returns the version that is not supported
+func (err *UnsupportedSchemaVersionError) Error() string {
+	return fmt.Sprintf("unsupported message schema version %d", err.Version)
+}
+
+// decodeMessage decodes the message by decoder registered for its version
+func decodeMessage(messageValue []byte, message *incomingMessage) error {
+	decoder, found := messageDecoders[message.Version]
+	if !found {
+		err := &UnsupportedSchemaVersionError{Version: message.Version}
+		log.Error().Uint8(versionKey, uint8(message.Version)).Err(err).Msg("Unable to decode message")
+		return err
+	}
+
+	return decoder(messageValue, message)
+}
+
+// decodeMessageV1 decodes messages with schema version 1, where rule hits
+// and info items are stored directly in the report
+func decodeMessageV1(messageValue []byte, message *incomingMessage) error {
+	err := checkReportStructure(*message.Report)
+	if err != nil {
+		log.Err(err).Msgf("Deserialized report read from message with improper structure: %v", *message.Report)
+		return err
+	}
+
+	err = validateMessage(messageValue, 1)
+	if err != nil {
+		log.Err(err).Msg("Message does not conform to its schema")
+		return err
+	}
+
+	err = json.Unmarshal(*((*message.Report)["reports"]), &message.ParsedHits)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(*((*message.Report)["info"]), &message.ParsedInfo)
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const testDeadLetterTopic = "dead-letter-topic"
+
+func TestParseMessageSupportedVersions(t *testing.T) {
+	// message without version is decoded as version 1
+	for _, version := range []int{0, 1} {
+		message, err := consumer.ParseMessage([]byte(createVersionedConsumerMessage(version, testReport)))
+		helpers.FailOnError(t, err)
+
+		assert.EqualValues(t, version, message.Version)
+		assert.Len(t, message.ParsedHits, 3)
+		assert.Equal(t, types.ErrorKey("RULE_4"), message.ParsedHits[0].ErrorKey)
+		assert.Empty(t, message.ParsedInfo)
+	}
+}
+
+func TestParseMessageUnsupportedVersion(t *testing.T) {
+	_, err := consumer.ParseMessage([]byte(createVersionedConsumerMessage(42, testReport)))
+	assert.EqualError(t, err, "unsupported message schema version 42")
+
+	var versionError *consumer.UnsupportedSchemaVersionError
+	if !errors.As(err, &versionError) {
+		t.Fatalf("expected unsupported schema version error, got %+v", err)
+	}
+	assert.EqualValues(t, 42, versionError.Version)
+}
+
+func TestHandleMessageUnsupportedVersionSentToDeadLetterQueue(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mockProducer := &capturingProducer{}
+
+	brokerCfg := broker.Configuration{
+		Topic:                testTopicName,
+		DeadLetterQueueTopic: testDeadLetterTopic,
+	}
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       memoryStorage,
+	}
+	consumer.SetDeadLetterProducer(mockConsumer, &producer.DeadLetterProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: brokerCfg,
+	})
+
+	msg := &sarama.ConsumerMessage{
+		Topic: testTopicName,
+		Value: []byte(createVersionedConsumerMessage(42, testReport)),
+	}
+	assert.EqualError(t, mockConsumer.HandleMessage(msg), "unsupported message schema version 42")
+
+	assert.Len(t, mockProducer.messages, 1)
+	assert.Equal(t, testDeadLetterTopic, mockProducer.messages[0].Topic)
+
+	consumerErrors, err := memoryStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, "unsupported message schema version 42", consumerErrors[0].Error)
+
+	_, _, _, _, err = memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.Error(t, err, "report with unsupported version must not be stored")
+}
@@ -16,6 +16,15 @@ limitations under the License.
 
 package consumer
 
+import (
+	"context"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+)
+
 // Export for testing
 //
 // This source file contains name aliases of all package-private functions
@@ -26,6 +35,67 @@ package consumer
 // https://medium.com/@robiplus/golang-trick-export-for-test-aa16cbd7b8cd
 // to see why this trick is needed.
 var (
-	ParseMessage         = parseMessage
-	CheckReportStructure = checkReportStructure
+	ParseMessage           = parseMessage
+	CheckReportStructure   = checkReportStructure
+	ComputeRuleHitsChanges = computeRuleHitsChanges
+	ClusterNameFromMessage = clusterNameFromMessage
+	WorkerForCluster       = workerForCluster
+	IsTransientError       = isTransientError
+	RetryTopicAttempt      = retryTopicAttempt
+	RetryTopicNotBefore    = retryTopicNotBefore
+)
+
+type PendingMessage = pendingMessage
+
+type OffsetTracker struct {
+	tracker offsetTracker
+}
+
+func NewOffsetTracker() *OffsetTracker {
+	return &OffsetTracker{}
+}
+
+func (t *OffsetTracker) Add(message *sarama.ConsumerMessage) *PendingMessage {
+	return t.tracker.add(message)
+}
+
+func (t *OffsetTracker) Complete(item *PendingMessage) *sarama.ConsumerMessage {
+	return t.tracker.complete(item)
+}
+
+func SetRuleHitsChangesProducer(consumer *KafkaConsumer, ruleHitsChangesProducer *producer.RuleHitsChangesProducer) {
+	consumer.ruleHitsChangesProducer = ruleHitsChangesProducer
+}
+
+func SetRetryProducer(consumer *KafkaConsumer, retryProducer *producer.RetryProducer) {
+	consumer.retryProducer = retryProducer
+}
+
+func SetDeadLetterProducer(consumer *KafkaConsumer, deadLetterProducer *producer.DeadLetterProducer) {
+	consumer.deadLetterProducer = deadLetterProducer
+}
+
+func RetryBackoff(consumer *KafkaConsumer, attempt int) time.Duration {
+	return consumer.retryBackoff(attempt)
+}
+
+var (
+	NewRateLimiter       = newRateLimiter
+	NewIngestRateLimiter = newIngestRateLimiter
 )
+
+func RateLimiterAllow(limiter *rateLimiter, key string, now time.Time) bool {
+	return limiter.allow(key, now)
+}
+
+func IngestRateLimiterAllow(limiter *ingestRateLimiter, orgID, clusterName string, now time.Time) error {
+	return limiter.allow(orgID, clusterName, now)
+}
+
+func SetRateLimiter(consumer *KafkaConsumer, limiter *ingestRateLimiter) {
+	consumer.rateLimiter = limiter
+}
+
+func HandleMessageWithContext(consumer *KafkaConsumer, ctx context.Context, msg *sarama.ConsumerMessage) error {
+	return consumer.handleMessage(ctx, msg)
+}
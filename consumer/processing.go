@@ -15,19 +15,27 @@
 package consumer
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
 	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/producer"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
+// errMessageDropped is returned by prepareMessage when the message is not
+// going to be stored because of processing mode of its organization. It's
+// not reported as an error.
+var errMessageDropped = errors.New("message dropped because of organization processing mode")
+
 // Report represents report send in a message consumed from any broker
 type Report map[string]*json.RawMessage
 
@@ -48,6 +56,12 @@ type incomingMessage struct {
 
 // HandleMessage handles the message and does all logging, metrics, etc
 func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) error {
+	return consumer.handleMessage(context.Background(), msg)
+}
+
+// handleMessage handles the message like HandleMessage, retries of storage
+// steps are stopped when the context is cancelled
+func (consumer *KafkaConsumer) handleMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
 	log.Info().
 		Int64(offsetKey, msg.Offset).
 		Int32(partitionKey, msg.Partition).
@@ -58,7 +72,21 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) error
 	metrics.ConsumedMessages.Inc()
 
 	startTime := time.Now()
-	requestID, message, err := consumer.processMessage(msg)
+	requestID, message, err := consumer.processMessage(ctx, msg)
+	consumer.finishMessage(msg, requestID, message, startTime, err)
+
+	return err
+}
+
+// finishMessage does all logging, metrics, error reporting and payload
+// tracking after the message has been processed
+func (consumer *KafkaConsumer) finishMessage(
+	msg *sarama.ConsumerMessage,
+	requestID types.RequestID,
+	message incomingMessage,
+	startTime time.Time,
+	err error,
+) {
 	timeAfterProcessingMessage := time.Now()
 	messageProcessingDuration := timeAfterProcessingMessage.Sub(startTime).Seconds()
 
@@ -71,36 +99,51 @@ func (consumer *KafkaConsumer) HandleMessage(msg *sarama.ConsumerMessage) error
 		Str(topicKey, msg.Topic).
 		Msgf("processing of message took '%v' seconds", messageProcessingDuration)
 
-	// Something went wrong while processing the message.
-	if err != nil {
+	if isRateLimited(err) {
+		// The message exceeded ingest rate limit, it is not an error.
+		consumer.handleRateLimitedMessage(msg, requestID, message, err)
+	} else if err != nil {
+		// Something went wrong while processing the message.
 		metrics.FailedMessagesProcessingTime.Observe(messageProcessingDuration)
 		metrics.ConsumingErrors.Inc()
 
 		log.Error().Err(err).Msg("Error processing message consumed from Kafka")
-		consumer.numberOfErrorsConsumingMessages++
-
-		if err := consumer.Storage.WriteConsumerError(msg, err); err != nil {
-			log.Error().Err(err).Msg("Unable to write consumer error to storage")
+		atomic.AddUint64(&consumer.numberOfErrorsConsumingMessages, 1)
+
+		// transient failures are processed again later if retry topic is
+		// configured, so they are not reported as consumer errors yet
+		if isTransientError(err) && consumer.sendToRetryTopic(msg) {
+			log.Warn().
+				Int64(offsetKey, msg.Offset).
+				Int32(partitionKey, msg.Partition).
+				Str(topicKey, msg.Topic).
+				Msg("message has been sent to retry topic")
+		} else {
+			// the payload is stored and forwarded decompressed
+			failedMsg := decompressedMessage(msg)
+
+			if err := consumer.Storage.WriteConsumerError(failedMsg, err); err != nil {
+				log.Error().Err(err).Msg("Unable to write consumer error to storage")
+			}
+
+			consumer.sendDeadLetter(failedMsg)
+
+			consumer.updatePayloadTracker(requestID, time.Now(), message.Organization, message.Account, producer.StatusError)
 		}
-
-		consumer.sendDeadLetter(msg)
-
-		consumer.updatePayloadTracker(requestID, time.Now(), message.Organization, message.Account, producer.StatusError)
 	} else {
 		// The message was processed successfully.
 		metrics.SuccessfulMessagesProcessingTime.Observe(messageProcessingDuration)
-		consumer.numberOfSuccessfullyConsumedMessages++
+		atomic.AddUint64(&consumer.numberOfSuccessfullyConsumedMessages, 1)
 
 		consumer.updatePayloadTracker(requestID, time.Now(), message.Organization, message.Account, producer.StatusSuccess)
 	}
 
 	totalMessageDuration := time.Since(startTime)
 	log.Info().Int64(durationKey, totalMessageDuration.Milliseconds()).Int64(offsetKey, msg.Offset).Msg("Message consumed")
-	return err
 }
 
 // updatePayloadTracker
-func (consumer KafkaConsumer) updatePayloadTracker(
+func (consumer *KafkaConsumer) updatePayloadTracker(
 	requestID types.RequestID,
 	timestamp time.Time,
 	orgID *types.OrgID,
@@ -116,7 +159,7 @@ func (consumer KafkaConsumer) updatePayloadTracker(
 }
 
 // sendDeadLetter - sends unprocessed message to dead letter queue
-func (consumer KafkaConsumer) sendDeadLetter(msg *sarama.ConsumerMessage) {
+func (consumer *KafkaConsumer) sendDeadLetter(msg *sarama.ConsumerMessage) {
 	if consumer.deadLetterProducer != nil {
 		if err := consumer.deadLetterProducer.SendDeadLetter(msg); err != nil {
 			log.Error().Err(err).Msg("Failed to load message to dead letter queue")
@@ -150,15 +193,37 @@ func checkMessageOrgInAllowList(consumer *KafkaConsumer, message *incomingMessag
 	return true, ""
 }
 
+// checkMessageOrgNotInDenyList - checks up incoming data's OrganizationID against denied orgs list
+func checkMessageOrgNotInDenyList(consumer *KafkaConsumer, message *incomingMessage) (bool, string) {
+	denyList := consumer.Configuration.OrgDenylist
+	if denyList != nil && denyList.Contains(*message.Organization) {
+		const cause = "organization ID is in deny list"
+		return false, cause
+	}
+	return true, ""
+}
+
+// orgProcessingMode returns the processing mode configured for the
+// organization, messages from organizations without configured mode are
+// stored normally
+func (consumer *KafkaConsumer) orgProcessingMode(orgID types.OrgID) string {
+	if mode, found := consumer.Configuration.OrgProcessingModes[orgID]; found {
+		return mode
+	}
+	return broker.ProcessingModeStore
+}
+
 func (consumer *KafkaConsumer) writeRecommendations(
-	msg *sarama.ConsumerMessage, message incomingMessage, reportAsBytes []byte,
+	ctx context.Context, msg *sarama.ConsumerMessage, message incomingMessage, reportAsBytes []byte,
 ) (time.Time, error) {
-	err := consumer.Storage.WriteRecommendationsForCluster(
-		*message.Organization,
-		*message.ClusterName,
-		types.ClusterReport(reportAsBytes),
-		types.Timestamp(time.Now().UTC().Format(time.RFC3339)),
-	)
+	err := consumer.withRetries(ctx, msg, func() error {
+		return consumer.Storage.WriteRecommendationsForCluster(
+			*message.Organization,
+			*message.ClusterName,
+			types.ClusterReport(reportAsBytes),
+			types.Timestamp(time.Now().UTC().Format(time.RFC3339)),
+		)
+	})
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error writing recommendations to database", err)
 		return time.Time{}, err
@@ -170,14 +235,16 @@ func (consumer *KafkaConsumer) writeRecommendations(
 }
 
 func (consumer *KafkaConsumer) writeInfoReport(
-	msg *sarama.ConsumerMessage, message incomingMessage, infoStoredAtTime time.Time,
+	ctx context.Context, msg *sarama.ConsumerMessage, message incomingMessage, infoStoredAtTime time.Time,
 ) error {
-	err := consumer.Storage.WriteReportInfoForCluster(
-		*message.Organization,
-		*message.ClusterName,
-		message.ParsedInfo,
-		infoStoredAtTime,
-	)
+	err := consumer.withRetries(ctx, msg, func() error {
+		return consumer.Storage.WriteReportInfoForCluster(
+			*message.Organization,
+			*message.ClusterName,
+			message.ParsedInfo,
+			infoStoredAtTime,
+		)
+	})
 	if err == types.ErrOldReport {
 		logMessageInfo(consumer, msg, message, "Skipping because a more recent info report already exists for this cluster")
 		return nil
@@ -190,14 +257,106 @@ func (consumer *KafkaConsumer) writeInfoReport(
 }
 
 // processMessage processes an incoming message
-func (consumer *KafkaConsumer) processMessage(msg *sarama.ConsumerMessage) (types.RequestID, incomingMessage, error) {
+func (consumer *KafkaConsumer) processMessage(
+	ctx context.Context, msg *sarama.ConsumerMessage,
+) (types.RequestID, incomingMessage, error) {
+	message, reportAsBytes, lastCheckedTime, err := consumer.prepareMessage(msg)
+	if err == errMessageDropped {
+		return message.RequestID, message, nil
+	} else if err != nil {
+		return message.RequestID, message, err
+	}
+
+	// rule hits need to be read before they are overwritten by new report
+	previousHits := consumer.readPreviousRuleHits(msg, message)
+
+	err = consumer.storeMessage(ctx, msg, message, reportAsBytes, lastCheckedTime, previousHits)
+	return message.RequestID, message, err
+}
+
+// storeMessage writes the report, recommendations and info report of the
+// prepared message into the storage. Each of these steps is retried on its
+// own when it fails because of a transient error, so the steps that
+// succeeded already are not repeated.
+func (consumer *KafkaConsumer) storeMessage(
+	ctx context.Context,
+	msg *sarama.ConsumerMessage,
+	message incomingMessage,
+	reportAsBytes []byte,
+	lastCheckedTime time.Time,
+	previousHits map[types.RuleSelector]bool,
+) error {
+	tTimeCheck := time.Now()
+
+	err := consumer.withRetries(ctx, msg, func() error {
+		return consumer.Storage.WriteReportForCluster(
+			*message.Organization,
+			*message.ClusterName,
+			types.ClusterReport(reportAsBytes),
+			message.ParsedHits,
+			lastCheckedTime,
+			message.Metadata.GatheredAt,
+			// timestamp when the report is about to be written into database
+			time.Now(),
+			types.KafkaOffset(msg.Offset),
+		)
+	})
+	if err == types.ErrOldReport {
+		logMessageInfo(consumer, msg, message, "Skipping because a more recent report already exists for this cluster")
+		return nil
+	} else if err != nil {
+		logMessageError(consumer, msg, message, "Error writing report to database", err)
+		return err
+	}
+	logMessageInfo(consumer, msg, message, "Stored report")
+	tStored := time.Now()
+
+	tRecommendationsStored, err := consumer.writeRecommendations(ctx, msg, message, reportAsBytes)
+	if err != nil {
+		return err
+	}
+
+	logClusterInfo(&message)
+
+	infoStoredAtTime := time.Now()
+	if err := consumer.writeInfoReport(ctx, msg, message, infoStoredAtTime); err != nil {
+		return err
+	}
+	infoStored := time.Now()
+
+	consumer.sendRuleHitsChanges(msg, message, previousHits, lastCheckedTime)
+
+	// log durations for message storing steps
+	logDuration(tTimeCheck, tStored, msg.Offset, "db_store_report")
+	logDuration(tStored, tRecommendationsStored, msg.Offset, "db_store_recommendations")
+	logDuration(infoStoredAtTime, infoStored, msg.Offset, "db_store_info_report")
+
+	// message has been parsed and stored into storage
+	return nil
+}
+
+// prepareMessage parses and checks the incoming message, so it is ready to
+// be written into the storage
+func (consumer *KafkaConsumer) prepareMessage(
+	msg *sarama.ConsumerMessage,
+) (incomingMessage, []byte, time.Time, error) {
 	tStart := time.Now()
 
 	log.Info().Int(offsetKey, int(msg.Offset)).Str(topicKey, consumer.Configuration.Topic).Str(groupKey, consumer.Configuration.Group).Msg("Consumed")
-	message, err := parseMessage(msg.Value)
+	messageValue := msg.Value
+	if encoding := messageEncoding(msg); encoding != "" {
+		var err error
+		messageValue, err = decompressMessageValue(messageValue, encoding)
+		if err != nil {
+			logUnparsedMessageError(consumer, msg, "Error decompressing message from Kafka", err)
+			return incomingMessage{}, nil, time.Time{}, err
+		}
+	}
+
+	message, err := parseMessage(messageValue)
 	if err != nil {
 		logUnparsedMessageError(consumer, msg, "Error parsing message from Kafka", err)
-		return message.RequestID, message, err
+		return message, nil, time.Time{}, err
 	}
 
 	logMessageInfo(consumer, msg, message, "Read")
@@ -207,7 +366,21 @@ func (consumer *KafkaConsumer) processMessage(msg *sarama.ConsumerMessage) (type
 
 	if ok, cause := checkMessageOrgInAllowList(consumer, &message, msg); !ok {
 		logMessageError(consumer, msg, message, cause, err)
-		return message.RequestID, message, errors.New(cause)
+		return message, nil, time.Time{}, errors.New(cause)
+	}
+
+	if ok, cause := checkMessageOrgNotInDenyList(consumer, &message); !ok {
+		logMessageError(consumer, msg, message, cause, err)
+		return message, nil, time.Time{}, errors.New(cause)
+	}
+
+	if consumer.orgProcessingMode(*message.Organization) == broker.ProcessingModeDrop {
+		logMessageInfo(consumer, msg, message, "Dropping message because of organization processing mode")
+		return message, nil, time.Time{}, errMessageDropped
+	}
+
+	if err := consumer.checkRateLimit(&message); err != nil {
+		return message, nil, time.Time{}, err
 	}
 
 	tAllowlisted := time.Now()
@@ -215,7 +388,7 @@ func (consumer *KafkaConsumer) processMessage(msg *sarama.ConsumerMessage) (type
 	reportAsBytes, err := json.Marshal(*message.Report)
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error marshalling report", err)
-		return message.RequestID, message, err
+		return message, nil, time.Time{}, err
 	}
 
 	logMessageInfo(consumer, msg, message, "Marshalled")
@@ -224,7 +397,7 @@ func (consumer *KafkaConsumer) processMessage(msg *sarama.ConsumerMessage) (type
 	lastCheckedTime, err := time.Parse(time.RFC3339Nano, message.LastChecked)
 	if err != nil {
 		logMessageError(consumer, msg, message, "Error parsing date from message", err)
-		return message.RequestID, message, err
+		return message, nil, time.Time{}, err
 	}
 
 	lastCheckedTimestampLagMinutes := time.Since(lastCheckedTime).Minutes()
@@ -237,53 +410,13 @@ func (consumer *KafkaConsumer) processMessage(msg *sarama.ConsumerMessage) (type
 	logMessageInfo(consumer, msg, message, "Time ok")
 	tTimeCheck := time.Now()
 
-	// timestamp when the report is about to be written into database
-	storedAtTime := time.Now()
-
-	err = consumer.Storage.WriteReportForCluster(
-		*message.Organization,
-		*message.ClusterName,
-		types.ClusterReport(reportAsBytes),
-		message.ParsedHits,
-		lastCheckedTime,
-		message.Metadata.GatheredAt,
-		storedAtTime,
-		types.KafkaOffset(msg.Offset),
-	)
-	if err == types.ErrOldReport {
-		logMessageInfo(consumer, msg, message, "Skipping because a more recent report already exists for this cluster")
-		return message.RequestID, message, nil
-	} else if err != nil {
-		logMessageError(consumer, msg, message, "Error writing report to database", err)
-		return message.RequestID, message, err
-	}
-	logMessageInfo(consumer, msg, message, "Stored report")
-	tStored := time.Now()
-
-	tRecommendationsStored, err := consumer.writeRecommendations(msg, message, reportAsBytes)
-	if err != nil {
-		return message.RequestID, message, err
-	}
-
-	logClusterInfo(&message)
-
-	infoStoredAtTime := time.Now()
-	if err := consumer.writeInfoReport(msg, message, infoStoredAtTime); err != nil {
-		return message.RequestID, message, err
-	}
-	infoStored := time.Now()
-
-	// log durations for every message consumption steps
+	// log durations for message preparation steps
 	logDuration(tStart, tRead, msg.Offset, "read")
 	logDuration(tRead, tAllowlisted, msg.Offset, "org_filtering")
 	logDuration(tAllowlisted, tMarshalled, msg.Offset, "marshalling")
 	logDuration(tMarshalled, tTimeCheck, msg.Offset, "time_check")
-	logDuration(tTimeCheck, tStored, msg.Offset, "db_store_report")
-	logDuration(tStored, tRecommendationsStored, msg.Offset, "db_store_recommendations")
-	logDuration(infoStoredAtTime, infoStored, msg.Offset, "db_store_info_report")
 
-	// message has been parsed and stored into storage
-	return message.RequestID, message, nil
+	return message, reportAsBytes, lastCheckedTime, nil
 }
 
 // organizationAllowed checks whether the given organization is on allow list or not
@@ -317,11 +450,17 @@ func checkReportStructure(r Report) error {
 	return nil
 }
 
-// parseMessage tries to parse incoming message and read all required attributes from it
+// parseMessage tries to parse incoming message and read all required attributes from it.
+// Message compressed by gzip or zstd is decompressed first.
 func parseMessage(messageValue []byte) (incomingMessage, error) {
 	var deserialized incomingMessage
 
-	err := json.Unmarshal(messageValue, &deserialized)
+	messageValue, err := decompressMessageValue(messageValue, "")
+	if err != nil {
+		return deserialized, err
+	}
+
+	err = json.Unmarshal(messageValue, &deserialized)
 	if err != nil {
 		return deserialized, err
 	}
@@ -342,18 +481,7 @@ func parseMessage(messageValue []byte) (incomingMessage, error) {
 		return deserialized, errors.New("cluster name is not a UUID")
 	}
 
-	err = checkReportStructure(*deserialized.Report)
-	if err != nil {
-		log.Err(err).Msgf("Deserialized report read from message with improper structure: %v", *deserialized.Report)
-		return deserialized, err
-	}
-
-	err = json.Unmarshal(*((*deserialized.Report)["reports"]), &deserialized.ParsedHits)
-	if err != nil {
-		return deserialized, err
-	}
-
-	err = json.Unmarshal(*((*deserialized.Report)["info"]), &deserialized.ParsedInfo)
+	err = decodeMessage(messageValue, &deserialized)
 	if err != nil {
 		return deserialized, err
 	}
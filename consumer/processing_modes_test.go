@@ -0,0 +1,132 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	mapset "github.com/deckarep/golang-set"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const organizationIDInDenyList = "organization ID is in deny list"
+
+func newConsumerWithProcessingModes(
+	brokerCfg broker.Configuration, storageCfg storage.Configuration,
+) (*consumer.KafkaConsumer, *storage.MemoryStorage) {
+	memoryStorage := storage.NewMemoryStorage(storageCfg)
+	brokerCfg.Topic = testTopicName
+
+	return &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       memoryStorage,
+	}, memoryStorage
+}
+
+func TestProcessMessageOrganizationInDenyList(t *testing.T) {
+	mockConsumer, memoryStorage := newConsumerWithProcessingModes(broker.Configuration{
+		OrgDenylist: mapset.NewSetWith(testdata.OrgID),
+	}, storage.Configuration{})
+
+	err := consumerProcessMessage(mockConsumer, testdata.ConsumerMessage)
+	assert.EqualError(t, err, organizationIDInDenyList)
+
+	_, _, _, _, err = memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestProcessMessageOrganizationNotInDenyList(t *testing.T) {
+	mockConsumer, memoryStorage := newConsumerWithProcessingModes(broker.Configuration{
+		OrgDenylist: mapset.NewSetWith(types.OrgID(123)), // in testdata, OrgID = 1
+	}, storage.Configuration{})
+
+	helpers.FailOnError(t, consumerProcessMessage(mockConsumer, testdata.ConsumerMessage))
+
+	_, _, _, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+}
+
+func TestProcessMessageDropProcessingMode(t *testing.T) {
+	mockConsumer, memoryStorage := newConsumerWithProcessingModes(broker.Configuration{
+		OrgProcessingModes: map[types.OrgID]string{testdata.OrgID: broker.ProcessingModeDrop},
+	}, storage.Configuration{})
+
+	// dropped message is not an error
+	helpers.FailOnError(t, consumerProcessMessage(mockConsumer, testdata.ConsumerMessage))
+
+	_, _, _, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	consumerErrors, err := memoryStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, consumerErrors)
+}
+
+func TestProcessMessageNoNotificationsProcessingMode(t *testing.T) {
+	for _, mode := range []string{broker.ProcessingModeStore, broker.ProcessingModeNoNotifications} {
+		t.Run(mode, func(t *testing.T) {
+			mockConsumer, memoryStorage := newConsumerWithProcessingModes(broker.Configuration{
+				OrgProcessingModes: map[types.OrgID]string{testdata.OrgID: mode},
+			}, storage.Configuration{})
+			mockProducer := &capturingProducer{}
+			consumer.SetRuleHitsChangesProducer(mockConsumer, &producer.RuleHitsChangesProducer{
+				KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+				Configuration: mockConsumer.Configuration,
+			})
+
+			helpers.FailOnError(t, consumerProcessMessage(mockConsumer, createConsumerMessage(testReport)))
+
+			// the report is stored in both modes
+			_, _, _, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+			helpers.FailOnError(t, err)
+
+			if mode == broker.ProcessingModeNoNotifications {
+				assert.Empty(t, mockProducer.messages)
+			} else {
+				assert.Len(t, mockProducer.messages, 1)
+			}
+		})
+	}
+}
+
+func TestProcessMessageLatestOnlyProcessingMode(t *testing.T) {
+	mockConsumer, memoryStorage := newConsumerWithProcessingModes(broker.Configuration{
+		OrgProcessingModes: map[types.OrgID]string{testdata.OrgID: broker.ProcessingModeLatestOnly},
+	}, storage.Configuration{
+		ReportHistoryLength:       10,
+		ReportHistoryDisabledOrgs: map[types.OrgID]bool{testdata.OrgID: true},
+	})
+
+	for i := 0; i < 2; i++ {
+		msg := reportMessage(int64(i), testdata.ClusterName, testdata.LastCheckedAt.Add(time.Duration(i)*time.Hour))
+		helpers.FailOnError(t, mockConsumer.HandleMessage(msg))
+	}
+
+	_, lastChecked, _, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)), lastChecked)
+
+	_, err = memoryStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
@@ -0,0 +1,278 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// RateLimitActionDrop drops messages exceeding the ingest rate limit
+	RateLimitActionDrop = "drop"
+	// RateLimitActionDefer sends messages exceeding the ingest rate limit
+	// to the retry topic, so they are processed later
+	RateLimitActionDefer = "defer"
+
+	// OrganizationRateLimit is the name of per organization rate limit
+	OrganizationRateLimit = "organization"
+	// ClusterRateLimit is the name of per cluster rate limit
+	ClusterRateLimit = "cluster"
+
+	// defaultRateLimitInterval is used when rate limits are configured
+	// without the interval
+	defaultRateLimitInterval = time.Minute
+)
+
+// RateLimitedError is returned when the message exceeded the ingest rate
+// limit of its organization or cluster
+type RateLimitedError struct {
+	Limit string
+	Key   string
+}
+
+// Error returns which rate limit has been exceeded
+func (err *RateLimitedError) Error() string {
+	return fmt.Sprintf("%s %s exceeded the ingest rate limit", err.Limit, err.Key)
+}
+
+// isRateLimited checks if the message has not been processed because of
+// rate limiting
+func isRateLimited(err error) bool {
+	var rateLimitedError *RateLimitedError
+	return errors.As(err, &rateLimitedError)
+}
+
+// tokenBucket contains number of messages that can be processed for one
+// organization or cluster right now
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimiter allows at most limit messages per interval for each key.
+// Unused tokens are accumulated up to the limit, so short bursts are
+// allowed.
+type rateLimiter struct {
+	limit       float64
+	interval    time.Duration
+	mutex       sync.Mutex
+	buckets     map[string]*tokenBucket
+	lastCleanup time.Time
+}
+
+// newRateLimiter constructs rate limiter, nil is returned when the limit
+// is not set
+func newRateLimiter(limit int, interval time.Duration) *rateLimiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	return &rateLimiter{
+		limit:    float64(limit),
+		interval: interval,
+		buckets:  make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes one token from the bucket of given key. False is returned
+// when the bucket is empty.
+func (limiter *rateLimiter) allow(key string, now time.Time) bool {
+	if limiter == nil {
+		return true
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.cleanup(now)
+
+	bucket, found := limiter.buckets[key]
+	if !found {
+		bucket = &tokenBucket{tokens: limiter.limit, updated: now}
+		limiter.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updated)
+	if elapsed > 0 {
+		bucket.tokens += limiter.limit * float64(elapsed) / float64(limiter.interval)
+		if bucket.tokens > limiter.limit {
+			bucket.tokens = limiter.limit
+		}
+		bucket.updated = now
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+	return true
+}
+
+// refund returns the token taken by allow into the bucket of given key
+func (limiter *rateLimiter) refund(key string) {
+	if limiter == nil {
+		return
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if bucket, found := limiter.buckets[key]; found && bucket.tokens+1 <= limiter.limit {
+		bucket.tokens++
+	}
+}
+
+// cleanup removes buckets that have been refilled completely, so the
+// limiter doesn't keep all organizations and clusters ever seen
+func (limiter *rateLimiter) cleanup(now time.Time) {
+	if now.Sub(limiter.lastCleanup) < limiter.interval {
+		return
+	}
+
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.updated) >= limiter.interval {
+			delete(limiter.buckets, key)
+		}
+	}
+	limiter.lastCleanup = now
+}
+
+// ingestRateLimiter limits number of messages processed for each
+// organization and each cluster
+type ingestRateLimiter struct {
+	organizations *rateLimiter
+	clusters      *rateLimiter
+	action        string
+}
+
+// newIngestRateLimiter constructs rate limiter configured in broker
+// configuration. Nil is returned when no rate limit is configured.
+func newIngestRateLimiter(brokerCfg broker.Configuration) (*ingestRateLimiter, error) {
+	if brokerCfg.OrgRateLimit <= 0 && brokerCfg.ClusterRateLimit <= 0 {
+		return nil, nil
+	}
+
+	action := brokerCfg.RateLimitAction
+	if action == "" {
+		action = RateLimitActionDrop
+	}
+	if action != RateLimitActionDrop && action != RateLimitActionDefer {
+		return nil, fmt.Errorf("unsupported rate limit action '%s'", action)
+	}
+
+	interval := brokerCfg.RateLimitInterval
+	if interval <= 0 {
+		interval = defaultRateLimitInterval
+	}
+
+	return &ingestRateLimiter{
+		organizations: newRateLimiter(brokerCfg.OrgRateLimit, interval),
+		clusters:      newRateLimiter(brokerCfg.ClusterRateLimit, interval),
+		action:        action,
+	}, nil
+}
+
+// allow takes one token of the organization and one token of the cluster.
+// The cluster token is taken only when the organization is not limited, and
+// the organization token is returned when the cluster is limited, so the
+// rejected message doesn't use up any limit.
+func (limiter *ingestRateLimiter) allow(orgID, clusterName string, now time.Time) error {
+	if !limiter.organizations.allow(orgID, now) {
+		return &RateLimitedError{Limit: OrganizationRateLimit, Key: orgID}
+	}
+
+	if !limiter.clusters.allow(clusterName, now) {
+		limiter.organizations.refund(orgID)
+		return &RateLimitedError{Limit: ClusterRateLimit, Key: clusterName}
+	}
+
+	return nil
+}
+
+// checkRateLimit returns RateLimitedError when the message exceeded the
+// ingest rate limit of its cluster or organization. It is called once for
+// each consumed message, retries of storage operations don't take tokens.
+func (consumer *KafkaConsumer) checkRateLimit(message *incomingMessage) error {
+	limiter := consumer.rateLimiter
+	if limiter == nil {
+		return nil
+	}
+
+	return limiter.allow(fmt.Sprint(*message.Organization), string(*message.ClusterName), time.Now())
+}
+
+// handleRateLimitedMessage drops the message that exceeded the ingest rate
+// limit, or sends it to the retry topic when the messages are deferred
+func (consumer *KafkaConsumer) handleRateLimitedMessage(
+	msg *sarama.ConsumerMessage,
+	requestID types.RequestID,
+	message incomingMessage,
+	err error,
+) {
+	var rateLimitedError *RateLimitedError
+	errors.As(err, &rateLimitedError)
+
+	action := "dropped"
+	if consumer.rateLimiter.action == RateLimitActionDefer && consumer.deferMessage(msg) {
+		action = "deferred"
+	}
+
+	metrics.RateLimitedMessages.WithLabelValues(rateLimitedError.Limit, action).Inc()
+
+	log.Warn().
+		Int64(offsetKey, msg.Offset).
+		Int32(partitionKey, msg.Partition).
+		Str(topicKey, msg.Topic).
+		Str("limit", rateLimitedError.Limit).
+		Str("key", rateLimitedError.Key).
+		Msgf("message has been %s because of rate limiting", action)
+
+	consumer.updatePayloadTracker(requestID, time.Now(), message.Organization, message.Account, producer.StatusRateLimited)
+}
+
+// deferMessage sends the message to the retry topic to be processed after
+// the rate limit interval. The retry attempt is not increased, because the
+// processing of the message didn't fail. False is returned when the retry
+// topic is not configured or the message can't be sent.
+func (consumer *KafkaConsumer) deferMessage(msg *sarama.ConsumerMessage) bool {
+	if consumer.retryProducer == nil {
+		log.Warn().Msg("retry topic is not configured, rate limited message can't be deferred")
+		return false
+	}
+
+	interval := consumer.Configuration.RateLimitInterval
+	if interval <= 0 {
+		interval = defaultRateLimitInterval
+	}
+
+	if err := consumer.retryProducer.SendRetry(msg, retryTopicAttempt(msg), time.Now().Add(interval)); err != nil {
+		log.Error().Err(err).Msg("Failed to send rate limited message to retry topic")
+		return false
+	}
+
+	return true
+}
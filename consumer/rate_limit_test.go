@@ -0,0 +1,187 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func createClusterConsumerMessage(clusterName types.ClusterName) string {
+	return `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(clusterName) + `",
+		"LastChecked": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `",
+		"Report": ` + testdata.ConsumerReport + `
+	}`
+}
+
+func newRateLimitedConsumer(t *testing.T, brokerCfg broker.Configuration) (*consumer.KafkaConsumer, *storage.MemoryStorage) {
+	limiter, err := consumer.NewIngestRateLimiter(brokerCfg)
+	helpers.FailOnError(t, err)
+
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       memoryStorage,
+	}
+	consumer.SetRateLimiter(mockConsumer, limiter)
+
+	return mockConsumer, memoryStorage
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := consumer.NewRateLimiter(2, time.Minute)
+	now := time.Now()
+
+	// burst up to the limit is allowed
+	assert.True(t, consumer.RateLimiterAllow(limiter, "a", now))
+	assert.True(t, consumer.RateLimiterAllow(limiter, "a", now))
+	assert.False(t, consumer.RateLimiterAllow(limiter, "a", now))
+
+	// other keys are not affected
+	assert.True(t, consumer.RateLimiterAllow(limiter, "b", now))
+
+	// one token is refilled after half of the interval
+	assert.True(t, consumer.RateLimiterAllow(limiter, "a", now.Add(30*time.Second)))
+	assert.False(t, consumer.RateLimiterAllow(limiter, "a", now.Add(30*time.Second)))
+
+	// tokens are not accumulated over the limit
+	later := now.Add(time.Hour)
+	assert.True(t, consumer.RateLimiterAllow(limiter, "a", later))
+	assert.True(t, consumer.RateLimiterAllow(limiter, "a", later))
+	assert.False(t, consumer.RateLimiterAllow(limiter, "a", later))
+}
+
+func TestRateLimiterNotConfigured(t *testing.T) {
+	limiter := consumer.NewRateLimiter(0, time.Minute)
+	assert.Nil(t, limiter)
+
+	for i := 0; i < 100; i++ {
+		assert.True(t, consumer.RateLimiterAllow(limiter, "a", time.Now()))
+	}
+}
+
+func TestIngestRateLimiterRejectedMessageUsesNoTokens(t *testing.T) {
+	limiter, err := consumer.NewIngestRateLimiter(broker.Configuration{
+		OrgRateLimit:     2,
+		ClusterRateLimit: 1,
+	})
+	helpers.FailOnError(t, err)
+	now := time.Now()
+
+	helpers.FailOnError(t, consumer.IngestRateLimiterAllow(limiter, "1", "a", now))
+
+	// the organization token is returned when the cluster is limited
+	assert.EqualError(t, consumer.IngestRateLimiterAllow(limiter, "1", "a", now), "cluster a exceeded the ingest rate limit")
+	helpers.FailOnError(t, consumer.IngestRateLimiterAllow(limiter, "1", "b", now))
+
+	// the cluster token is not taken when the organization is limited
+	assert.EqualError(t, consumer.IngestRateLimiterAllow(limiter, "1", "c", now), "organization 1 exceeded the ingest rate limit")
+	helpers.FailOnError(t, consumer.IngestRateLimiterAllow(limiter, "2", "c", now))
+}
+
+func TestNewIngestRateLimiter(t *testing.T) {
+	limiter, err := consumer.NewIngestRateLimiter(broker.Configuration{})
+	helpers.FailOnError(t, err)
+	assert.Nil(t, limiter)
+
+	_, err = consumer.NewIngestRateLimiter(broker.Configuration{
+		OrgRateLimit:    10,
+		RateLimitAction: "ignore",
+	})
+	assert.EqualError(t, err, "unsupported rate limit action 'ignore'")
+}
+
+func TestHandleMessageOrgRateLimitDrop(t *testing.T) {
+	mockConsumer, memoryStorage := newRateLimitedConsumer(t, broker.Configuration{
+		Topic:        testTopicName,
+		OrgRateLimit: 2,
+	})
+	dropped := metrics.RateLimitedMessages.WithLabelValues(consumer.OrganizationRateLimit, "dropped")
+	initValue := testutil.ToFloat64(dropped)
+
+	clusters := []types.ClusterName{
+		testdata.GetRandomClusterID(), testdata.GetRandomClusterID(), testdata.GetRandomClusterID(),
+	}
+	for i, clusterName := range clusters {
+		msg := &sarama.ConsumerMessage{Topic: testTopicName, Value: []byte(createClusterConsumerMessage(clusterName))}
+		err := mockConsumer.HandleMessage(msg)
+		if i < 2 {
+			helpers.FailOnError(t, err)
+		} else {
+			assert.EqualError(t, err, fmt.Sprintf("organization %d exceeded the ingest rate limit", testdata.OrgID))
+		}
+	}
+
+	reports, err := memoryStorage.ReadReportsForClusters(clusters)
+	helpers.FailOnError(t, err)
+	assert.Len(t, reports, 2)
+	assert.NotContains(t, reports, clusters[2])
+
+	assert.Equal(t, initValue+1, testutil.ToFloat64(dropped))
+
+	// rate limited messages are not consumer errors
+	consumerErrors, err := memoryStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, consumerErrors)
+}
+
+func TestHandleMessageClusterRateLimitDefer(t *testing.T) {
+	brokerCfg := broker.Configuration{
+		Topic:             testTopicName,
+		ClusterRateLimit:  1,
+		RateLimitInterval: time.Hour,
+		RateLimitAction:   consumer.RateLimitActionDefer,
+		RetryTopic:        testRetryTopic,
+	}
+	mockConsumer, _ := newRateLimitedConsumer(t, brokerCfg)
+	mockProducer := &capturingProducer{}
+	consumer.SetRetryProducer(mockConsumer, &producer.RetryProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: brokerCfg,
+	})
+	deferred := metrics.RateLimitedMessages.WithLabelValues(consumer.ClusterRateLimit, "deferred")
+	initValue := testutil.ToFloat64(deferred)
+
+	msg := &sarama.ConsumerMessage{Topic: testTopicName, Value: []byte(testdata.ConsumerMessage)}
+	helpers.FailOnError(t, mockConsumer.HandleMessage(msg))
+	assert.Error(t, mockConsumer.HandleMessage(msg))
+
+	assert.Equal(t, initValue+1, testutil.ToFloat64(deferred))
+
+	// deferring doesn't use up retry attempts
+	assert.Len(t, mockProducer.messages, 1)
+	assert.Equal(t, testRetryTopic, mockProducer.messages[0].Topic)
+	assert.Equal(t, "0", headerValue(mockProducer.messages[0], producer.RetryAttemptHeader))
+
+	notBefore, err := time.Parse(time.RFC3339Nano, headerValue(mockProducer.messages[0], producer.RetryNotBeforeHeader))
+	helpers.FailOnError(t, err)
+	assert.True(t, notBefore.After(time.Now().Add(59*time.Minute)))
+}
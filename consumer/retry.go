@@ -0,0 +1,251 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+)
+
+// postgres error classes that indicate a transient failure, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+var transientPostgresErrorClasses = map[pq.ErrorClass]bool{
+	"08": true, // connection exception
+	"40": true, // transaction rollback (serialization failure, deadlock)
+	"53": true, // insufficient resources
+	"57": true, // operator intervention (admin shutdown, cannot connect now)
+}
+
+// isTransientError returns true for errors caused by temporary
+// unavailability of the database or network. Processing of a message that
+// failed because of such error may succeed later. All other errors (parsing,
+// validation etc.) are permanent.
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) {
+		return true
+	}
+
+	var pqError *pq.Error
+	if errors.As(err, &pqError) {
+		return transientPostgresErrorClasses[pqError.Code.Class()]
+	}
+
+	var sqliteError sqlite3.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.Code == sqlite3.ErrBusy || sqliteError.Code == sqlite3.ErrLocked
+	}
+
+	return false
+}
+
+// topics returns list of topics the consumer subscribes to
+func (consumer *KafkaConsumer) topics() []string {
+	topics := []string{consumer.Configuration.Topic}
+	if consumer.Configuration.RetryTopic != "" {
+		topics = append(topics, consumer.Configuration.RetryTopic)
+	}
+	return topics
+}
+
+// retryBackoff returns time to wait before the given retry attempt. The
+// time is doubled for each attempt and limited by RetryMaxBackoff.
+func (consumer *KafkaConsumer) retryBackoff(attempt int) time.Duration {
+	backoff := consumer.Configuration.RetryInitialBackoff
+	maxBackoff := consumer.Configuration.RetryMaxBackoff
+
+	for i := 1; i < attempt && (maxBackoff <= 0 || backoff < maxBackoff); i++ {
+		backoff *= 2
+	}
+
+	if maxBackoff > 0 && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// shouldRetry returns true when the processing of a message failed because
+// of a transient error and the given retry attempt is allowed by
+// RetryMaxAttempts
+func (consumer *KafkaConsumer) shouldRetry(attempt int, err error) bool {
+	return attempt <= consumer.Configuration.RetryMaxAttempts && isTransientError(err)
+}
+
+// withRetries runs the storage step and runs it again while it fails
+// because of a transient error and the attempt is allowed by
+// RetryMaxAttempts. The error of the last attempt is returned when the
+// context is cancelled while waiting for the next one.
+func (consumer *KafkaConsumer) withRetries(
+	ctx context.Context, msg *sarama.ConsumerMessage, step func() error,
+) error {
+	err := step()
+	for attempt := 1; consumer.shouldRetry(attempt, err); attempt++ {
+		if !consumer.waitBeforeRetry(ctx, msg, attempt, err) {
+			return err
+		}
+		err = step()
+	}
+	return err
+}
+
+// waitBeforeRetry waits before the given retry attempt, the time between
+// attempts grows exponentially. False is returned when the context is
+// cancelled before that.
+func (consumer *KafkaConsumer) waitBeforeRetry(
+	ctx context.Context, msg *sarama.ConsumerMessage, attempt int, err error,
+) bool {
+	backoff := consumer.retryBackoff(attempt)
+
+	log.Warn().
+		Err(err).
+		Int64(offsetKey, msg.Offset).
+		Int32(partitionKey, msg.Partition).
+		Str(topicKey, msg.Topic).
+		Int("attempt", attempt).
+		Dur("backoff", backoff).
+		Msg("transient error while processing message, retrying")
+
+	metrics.ConsumingRetries.Inc()
+
+	select {
+	case <-time.After(backoff):
+		return true
+	case <-ctx.Done():
+		log.Warn().
+			Int64(offsetKey, msg.Offset).
+			Int32(partitionKey, msg.Partition).
+			Str(topicKey, msg.Topic).
+			Msg("consumer is stopping, message is not retried anymore")
+		return false
+	}
+}
+
+// retryTopicAttempt returns number of times the message has already been
+// sent to the retry topic
+func retryTopicAttempt(msg *sarama.ConsumerMessage) int {
+	for _, header := range msg.Headers {
+		if header != nil && string(header.Key) == producer.RetryAttemptHeader {
+			attempt, err := strconv.Atoi(string(header.Value))
+			if err != nil {
+				log.Warn().Err(err).Msg("invalid retry attempt header")
+				return 0
+			}
+			return attempt
+		}
+	}
+	return 0
+}
+
+// retryTopicNotBefore returns the time before which the message consumed
+// from the retry topic should not be processed
+func retryTopicNotBefore(msg *sarama.ConsumerMessage) time.Time {
+	for _, header := range msg.Headers {
+		if header != nil && string(header.Key) == producer.RetryNotBeforeHeader {
+			notBefore, err := time.Parse(time.RFC3339Nano, string(header.Value))
+			if err != nil {
+				log.Warn().Err(err).Msg("invalid retry not before header")
+				return time.Time{}
+			}
+			return notBefore
+		}
+	}
+	return time.Time{}
+}
+
+// sendToRetryTopic sends the message that failed because of a transient
+// error to the retry topic. False is returned when retry topic is not
+// configured, when the message has already been retried RetryTopicAttempts
+// times or when it can't be sent. Such message should be sent to the dead
+// letter queue instead.
+func (consumer *KafkaConsumer) sendToRetryTopic(msg *sarama.ConsumerMessage) bool {
+	if consumer.retryProducer == nil {
+		return false
+	}
+
+	attempt := retryTopicAttempt(msg) + 1
+	if attempt > consumer.Configuration.RetryTopicAttempts {
+		log.Warn().
+			Int64(offsetKey, msg.Offset).
+			Int("attempt", attempt).
+			Msg("message has been retried too many times")
+		return false
+	}
+
+	// the delay grows exponentially with each attempt too
+	notBefore := time.Now().Add(consumer.Configuration.RetryTopicDelay << uint(attempt-1))
+	if err := consumer.retryProducer.SendRetry(msg, attempt, notBefore); err != nil {
+		log.Error().Err(err).Msg("Failed to send message to retry topic")
+		return false
+	}
+
+	metrics.RetryTopicMessages.Inc()
+	return true
+}
+
+// waitForRetryTopicDelay waits until the message consumed from the retry
+// topic can be processed. False is returned when the context is cancelled
+// before that.
+func (consumer *KafkaConsumer) waitForRetryTopicDelay(ctx context.Context, msg *sarama.ConsumerMessage) bool {
+	if consumer.Configuration.RetryTopic == "" || msg.Topic != consumer.Configuration.RetryTopic {
+		return true
+	}
+
+	delay := time.Until(retryTopicNotBefore(msg))
+	if delay <= 0 {
+		return true
+	}
+
+	log.Info().
+		Int64(offsetKey, msg.Offset).
+		Dur("delay", delay).
+		Msg("waiting before processing message from retry topic")
+
+	select {
+	case <-time.After(delay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,401 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-operator-utils/tests/saramahelpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const testRetryTopic = "retry-topic"
+
+// failingStorage is an in-memory storage failing to write reports the given
+// number of times
+type failingStorage struct {
+	*storage.MemoryStorage
+	mutex          sync.Mutex
+	failures       int
+	err            error
+	writes         int
+	consumerErrors int
+}
+
+func (s *failingStorage) WriteReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	rules []types.ReportItem,
+	lastCheckedTime time.Time,
+	gatheredAt time.Time,
+	storedAtTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	s.mutex.Lock()
+	s.writes++
+	if s.failures != 0 {
+		s.failures--
+		s.mutex.Unlock()
+		return s.err
+	}
+	s.mutex.Unlock()
+
+	return s.MemoryStorage.WriteReportForCluster(
+		orgID, clusterName, report, rules, lastCheckedTime, gatheredAt, storedAtTime, kafkaOffset,
+	)
+}
+
+func (s *failingStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
+	s.mutex.Lock()
+	s.consumerErrors++
+	s.mutex.Unlock()
+
+	return s.MemoryStorage.WriteConsumerError(msg, consumerErr)
+}
+
+// failingRecommendationsStorage is an in-memory storage failing to write
+// recommendations the given number of times
+type failingRecommendationsStorage struct {
+	*failingStorage
+	recommendationFailures int
+	recommendationWrites   int
+}
+
+func (s *failingRecommendationsStorage) WriteRecommendationsForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	creationTime types.Timestamp,
+) error {
+	s.mutex.Lock()
+	s.recommendationWrites++
+	if s.recommendationFailures != 0 {
+		s.recommendationFailures--
+		s.mutex.Unlock()
+		return s.err
+	}
+	s.mutex.Unlock()
+
+	return s.MemoryStorage.WriteRecommendationsForCluster(orgID, clusterName, report, creationTime)
+}
+
+// capturingProducer is a sync producer remembering all produced messages
+type capturingProducer struct {
+	sarama.SyncProducer
+	messages []*sarama.ProducerMessage
+}
+
+func (p *capturingProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.messages = append(p.messages, msg)
+	return 0, int64(len(p.messages)), nil
+}
+
+func (p *capturingProducer) Close() error {
+	return nil
+}
+
+func newFailingStorage(failures int, err error) *failingStorage {
+	return &failingStorage{
+		MemoryStorage: storage.NewMemoryStorage(storage.Configuration{}),
+		failures:      failures,
+		err:           err,
+	}
+}
+
+func retryBrokerCfg() broker.Configuration {
+	return broker.Configuration{
+		Topic:               testTopicName,
+		RetryMaxAttempts:    3,
+		RetryInitialBackoff: time.Millisecond,
+		RetryMaxBackoff:     5 * time.Millisecond,
+		RetryTopic:          testRetryTopic,
+		RetryTopicDelay:     time.Minute,
+		RetryTopicAttempts:  2,
+	}
+}
+
+func headerValue(msg *sarama.ProducerMessage, key string) string {
+	for _, header := range msg.Headers {
+		if string(header.Key) == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func TestIsTransientError(t *testing.T) {
+	for _, err := range []error{
+		driver.ErrBadConn,
+		fmt.Errorf("wrapped: %w", driver.ErrBadConn),
+		&net.OpError{Op: "dial", Err: errors.New("connection refused")},
+		&pq.Error{Code: "57P01"},
+		&pq.Error{Code: "08006"},
+		sqlite3.Error{Code: sqlite3.ErrBusy},
+	} {
+		assert.True(t, consumer.IsTransientError(err), err.Error())
+	}
+
+	for _, err := range []error{
+		nil,
+		errors.New("unexpected end of JSON input"),
+		types.ErrOldReport,
+		&types.ItemNotFoundError{ItemID: testdata.ClusterName},
+		&pq.Error{Code: "23505"},
+		sqlite3.Error{Code: sqlite3.ErrConstraint},
+	} {
+		assert.False(t, consumer.IsTransientError(err), fmt.Sprint(err))
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	mockConsumer := &consumer.KafkaConsumer{Configuration: broker.Configuration{
+		RetryInitialBackoff: time.Second,
+		RetryMaxBackoff:     5 * time.Second,
+	}}
+
+	assert.Equal(t, time.Second, consumer.RetryBackoff(mockConsumer, 1))
+	assert.Equal(t, 2*time.Second, consumer.RetryBackoff(mockConsumer, 2))
+	assert.Equal(t, 4*time.Second, consumer.RetryBackoff(mockConsumer, 3))
+	assert.Equal(t, 5*time.Second, consumer.RetryBackoff(mockConsumer, 4))
+	assert.Equal(t, 5*time.Second, consumer.RetryBackoff(mockConsumer, 100))
+}
+
+func TestRetryTopicHeaders(t *testing.T) {
+	notBefore := testdata.LastCheckedAt.UTC()
+	msg := &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{
+		{Key: []byte(producer.RetryAttemptHeader), Value: []byte("2")},
+		{Key: []byte(producer.RetryNotBeforeHeader), Value: []byte(notBefore.Format(time.RFC3339Nano))},
+	}}
+	assert.Equal(t, 2, consumer.RetryTopicAttempt(msg))
+	assert.Equal(t, notBefore, consumer.RetryTopicNotBefore(msg))
+
+	msg = &sarama.ConsumerMessage{}
+	assert.Equal(t, 0, consumer.RetryTopicAttempt(msg))
+	assert.True(t, consumer.RetryTopicNotBefore(msg).IsZero())
+}
+
+func TestKafkaConsumer_HandleMessage_RetryTransientError(t *testing.T) {
+	mockStorage := newFailingStorage(2, driver.ErrBadConn)
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: retryBrokerCfg(),
+		Storage:       mockStorage,
+	}
+
+	err := mockConsumer.HandleMessage(&sarama.ConsumerMessage{Value: []byte(testdata.ConsumerMessage)})
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, 3, mockStorage.writes)
+	assert.Equal(t, 0, mockStorage.consumerErrors)
+	assert.Equal(t, uint64(1), mockConsumer.GetNumberOfSuccessfullyConsumedMessages())
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+// TestKafkaConsumer_HandleMessage_RetryRateLimitedOnce checks that the
+// ingest rate limit is checked just once for the message that is retried
+func TestKafkaConsumer_HandleMessage_RetryRateLimitedOnce(t *testing.T) {
+	brokerCfg := retryBrokerCfg()
+	brokerCfg.ClusterRateLimit = 1
+	brokerCfg.RateLimitInterval = time.Hour
+
+	limiter, err := consumer.NewIngestRateLimiter(brokerCfg)
+	helpers.FailOnError(t, err)
+
+	mockStorage := newFailingStorage(2, driver.ErrBadConn)
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+	consumer.SetRateLimiter(mockConsumer, limiter)
+
+	err = mockConsumer.HandleMessage(&sarama.ConsumerMessage{Value: []byte(testdata.ConsumerMessage)})
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, 3, mockStorage.writes)
+
+	count, err := mockStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+// TestKafkaConsumer_HandleMessage_RetryFailedStepOnly checks that only the
+// storage step that failed is retried, so the recommendations are written
+// even though the report has been stored already
+func TestKafkaConsumer_HandleMessage_RetryFailedStepOnly(t *testing.T) {
+	mockStorage := &failingRecommendationsStorage{
+		failingStorage:         newFailingStorage(0, driver.ErrBadConn),
+		recommendationFailures: 2,
+	}
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: retryBrokerCfg(),
+		Storage:       mockStorage,
+	}
+
+	err := mockConsumer.HandleMessage(&sarama.ConsumerMessage{Value: []byte(messageReportWithRuleHits)})
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, 1, mockStorage.writes)
+	assert.Equal(t, 3, mockStorage.recommendationWrites)
+	assert.Equal(t, 0, mockStorage.consumerErrors)
+
+	recommendations, err := mockStorage.ReadRecommendationsForClusters(
+		[]string{string(testdata.ClusterName)}, testdata.OrgID,
+	)
+	helpers.FailOnError(t, err)
+	assert.NotEmpty(t, recommendations)
+}
+
+// TestKafkaConsumer_HandleMessage_RetryStoppedByContext checks that the
+// consumer doesn't wait for the next attempt when it is stopping
+func TestKafkaConsumer_HandleMessage_RetryStoppedByContext(t *testing.T) {
+	mockStorage := newFailingStorage(-1, driver.ErrBadConn)
+
+	brokerCfg := retryBrokerCfg()
+	brokerCfg.RetryInitialBackoff = time.Hour
+	brokerCfg.RetryMaxBackoff = time.Hour
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := consumer.HandleMessageWithContext(
+		mockConsumer, ctx, &sarama.ConsumerMessage{Value: []byte(testdata.ConsumerMessage)},
+	)
+	assert.Equal(t, driver.ErrBadConn, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Minute))
+
+	assert.Equal(t, 1, mockStorage.writes)
+}
+
+func TestKafkaConsumer_HandleMessage_PermanentErrorNotRetried(t *testing.T) {
+	mockStorage := newFailingStorage(1, errors.New("invalid report"))
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: retryBrokerCfg(),
+		Storage:       mockStorage,
+	}
+
+	err := mockConsumer.HandleMessage(&sarama.ConsumerMessage{Value: []byte(testdata.ConsumerMessage)})
+	assert.EqualError(t, err, "invalid report")
+
+	assert.Equal(t, 1, mockStorage.writes)
+	assert.Equal(t, 1, mockStorage.consumerErrors)
+}
+
+func TestKafkaConsumer_HandleMessage_RetryTopic(t *testing.T) {
+	mockStorage := newFailingStorage(-1, driver.ErrBadConn)
+	mockProducer := &capturingProducer{}
+
+	brokerCfg := retryBrokerCfg()
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: brokerCfg,
+		Storage:       mockStorage,
+	}
+	consumer.SetRetryProducer(mockConsumer, &producer.RetryProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: brokerCfg,
+	})
+
+	// all retries failed, so the message is sent to the retry topic
+	// together with its headers
+	msg := &sarama.ConsumerMessage{
+		Topic: testTopicName,
+		Value: []byte(testdata.ConsumerMessage),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("request_id"), Value: []byte("request-1")},
+		},
+	}
+	err := mockConsumer.HandleMessage(msg)
+	assert.Equal(t, driver.ErrBadConn, err)
+
+	assert.Equal(t, 1+brokerCfg.RetryMaxAttempts, mockStorage.writes)
+	assert.Equal(t, 0, mockStorage.consumerErrors)
+	assert.Len(t, mockProducer.messages, 1)
+	assert.Equal(t, testRetryTopic, mockProducer.messages[0].Topic)
+	assert.Equal(t, "1", headerValue(mockProducer.messages[0], producer.RetryAttemptHeader))
+	assert.Equal(t, "request-1", headerValue(mockProducer.messages[0], "request_id"))
+
+	notBefore, err := time.Parse(time.RFC3339Nano, headerValue(mockProducer.messages[0], producer.RetryNotBeforeHeader))
+	helpers.FailOnError(t, err)
+	assert.True(t, notBefore.After(time.Now()))
+
+	// the message has been retried too many times, so it is reported as
+	// a consumer error
+	msg = &sarama.ConsumerMessage{
+		Topic: testRetryTopic,
+		Value: []byte(testdata.ConsumerMessage),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte(producer.RetryAttemptHeader), Value: []byte("2")},
+		},
+	}
+	err = mockConsumer.HandleMessage(msg)
+	assert.Equal(t, driver.ErrBadConn, err)
+
+	assert.Len(t, mockProducer.messages, 1)
+	assert.Equal(t, 1, mockStorage.consumerErrors)
+}
+
+func TestKafkaConsumer_ConsumeClaim_RetryTopicDelay(t *testing.T) {
+	mockStorage := newFailingStorage(0, nil)
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: retryBrokerCfg(),
+		Storage:       mockStorage,
+	}
+
+	const delay = 50 * time.Millisecond
+	msg := &sarama.ConsumerMessage{
+		Topic: testRetryTopic,
+		Value: []byte(testdata.ConsumerMessage),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte(producer.RetryAttemptHeader), Value: []byte("1")},
+			{Key: []byte(producer.RetryNotBeforeHeader), Value: []byte(time.Now().Add(delay).Format(time.RFC3339Nano))},
+		},
+	}
+
+	start := time.Now()
+	err := mockConsumer.ConsumeClaim(
+		&saramahelpers.MockConsumerGroupSession{},
+		saramahelpers.NewMockConsumerGroupClaim([]*sarama.ConsumerMessage{msg}),
+	)
+	helpers.FailOnError(t, err)
+
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(delay))
+	assert.Equal(t, 1, mockStorage.writes)
+	assert.Equal(t, uint64(1), mockConsumer.GetNumberOfSuccessfullyConsumedMessages())
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ruleSelector constructs rule selector in format "rule.module|ERROR_KEY"
+// from rule module and error key
+func ruleSelector(module types.RuleID, errorKey types.ErrorKey) types.RuleSelector {
+	return types.RuleSelector(strings.TrimSuffix(string(module), ".report") + "|" + string(errorKey))
+}
+
+// readPreviousRuleHits reads selectors of rules hitting the cluster before
+// the new report is stored. Nil is returned when rule hits changes are not
+// published for the organization or when it is not possible to read them.
+func (consumer *KafkaConsumer) readPreviousRuleHits(
+	msg *sarama.ConsumerMessage, message incomingMessage,
+) map[types.RuleSelector]bool {
+	if consumer.ruleHitsChangesProducer == nil {
+		return nil
+	}
+
+	if consumer.orgProcessingMode(*message.Organization) == broker.ProcessingModeNoNotifications {
+		logMessageInfo(consumer, msg, message, "Rule hits changes are not published for this organization")
+		return nil
+	}
+
+	previousHits := make(map[types.RuleSelector]bool)
+
+	rules, _, _, _, err := consumer.Storage.ReadReportForCluster(*message.Organization, *message.ClusterName)
+	if _, notFound := err.(*types.ItemNotFoundError); notFound {
+		// first report for this cluster
+		return previousHits
+	}
+	if err != nil {
+		logMessageError(consumer, msg, message, "Unable to read previous rule hits, changes won't be published", err)
+		return nil
+	}
+
+	for _, rule := range rules {
+		previousHits[ruleSelector(rule.Module, rule.ErrorKey)] = true
+	}
+
+	return previousHits
+}
+
+// computeRuleHitsChanges returns selectors of rules that started to hit the
+// cluster and selectors of rules that are no longer hitting the cluster
+func computeRuleHitsChanges(
+	previousHits map[types.RuleSelector]bool, currentHits []types.ReportItem,
+) (newHits, resolvedHits []types.RuleSelector) {
+	newHits = []types.RuleSelector{}
+	resolvedHits = []types.RuleSelector{}
+
+	current := make(map[types.RuleSelector]bool, len(currentHits))
+	for _, rule := range currentHits {
+		selector := ruleSelector(rule.Module, rule.ErrorKey)
+		current[selector] = true
+
+		if !previousHits[selector] {
+			newHits = append(newHits, selector)
+		}
+	}
+
+	for selector := range previousHits {
+		if !current[selector] {
+			resolvedHits = append(resolvedHits, selector)
+		}
+	}
+
+	sort.Slice(newHits, func(i, j int) bool { return newHits[i] < newHits[j] })
+	sort.Slice(resolvedHits, func(i, j int) bool { return resolvedHits[i] < resolvedHits[j] })
+
+	return newHits, resolvedHits
+}
+
+// sendRuleHitsChanges publishes rules that started or stopped hitting the
+// cluster. Nothing is published when there are no changes.
+func (consumer *KafkaConsumer) sendRuleHitsChanges(
+	msg *sarama.ConsumerMessage,
+	message incomingMessage,
+	previousHits map[types.RuleSelector]bool,
+	lastCheckedTime time.Time,
+) {
+	if consumer.ruleHitsChangesProducer == nil || previousHits == nil {
+		return
+	}
+
+	newHits, resolvedHits := computeRuleHitsChanges(previousHits, message.ParsedHits)
+	if len(newHits) == 0 && len(resolvedHits) == 0 {
+		logMessageInfo(consumer, msg, message, "No changes in rule hits")
+		return
+	}
+
+	err := consumer.ruleHitsChangesProducer.SendRuleHitsChanges(producer.RuleHitsChangesMessage{
+		OrgID:         *message.Organization,
+		ClusterName:   *message.ClusterName,
+		RequestID:     message.RequestID,
+		LastCheckedAt: lastCheckedTime.UTC().Format(time.RFC3339),
+		NewHits:       newHits,
+		ResolvedHits:  resolvedHits,
+	})
+	if err != nil {
+		logMessageError(consumer, msg, message, "Unable to publish rule hits changes", err)
+		return
+	}
+
+	logMessageInfo(consumer, msg, message, "Published rule hits changes")
+}
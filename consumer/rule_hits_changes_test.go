@@ -0,0 +1,177 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/producer"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestComputeRuleHitsChanges(t *testing.T) {
+	previousHits := map[types.RuleSelector]bool{
+		"ccx_rules_ocp.external.rules.rule_1|RULE_1": true,
+		"ccx_rules_ocp.external.rules.rule_2|RULE_2": true,
+	}
+	currentHits := []types.ReportItem{
+		{Module: "ccx_rules_ocp.external.rules.rule_2.report", ErrorKey: "RULE_2"},
+		{Module: "ccx_rules_ocp.external.rules.rule_3.report", ErrorKey: "RULE_3"},
+	}
+
+	newHits, resolvedHits := consumer.ComputeRuleHitsChanges(previousHits, currentHits)
+
+	assert.Equal(t, []types.RuleSelector{"ccx_rules_ocp.external.rules.rule_3|RULE_3"}, newHits)
+	assert.Equal(t, []types.RuleSelector{"ccx_rules_ocp.external.rules.rule_1|RULE_1"}, resolvedHits)
+}
+
+func TestComputeRuleHitsChangesFirstReport(t *testing.T) {
+	currentHits := []types.ReportItem{
+		{Module: "ccx_rules_ocp.external.rules.rule_2.report", ErrorKey: "RULE_2"},
+		{Module: "ccx_rules_ocp.external.rules.rule_1.report", ErrorKey: "RULE_1"},
+	}
+
+	newHits, resolvedHits := consumer.ComputeRuleHitsChanges(map[types.RuleSelector]bool{}, currentHits)
+
+	assert.Equal(t, []types.RuleSelector{
+		"ccx_rules_ocp.external.rules.rule_1|RULE_1",
+		"ccx_rules_ocp.external.rules.rule_2|RULE_2",
+	}, newHits)
+	assert.Empty(t, resolvedHits)
+}
+
+func TestComputeRuleHitsChangesNoChange(t *testing.T) {
+	previousHits := map[types.RuleSelector]bool{
+		"ccx_rules_ocp.external.rules.rule_1|RULE_1": true,
+	}
+	currentHits := []types.ReportItem{
+		{Module: "ccx_rules_ocp.external.rules.rule_1.report", ErrorKey: "RULE_1"},
+	}
+
+	newHits, resolvedHits := consumer.ComputeRuleHitsChanges(previousHits, currentHits)
+
+	assert.Empty(t, newHits)
+	assert.Empty(t, resolvedHits)
+}
+
+func TestKafkaConsumer_NewRuleHitsChangesProducer_Error(t *testing.T) {
+	producer.NewDeadLetterProducer = func(brokerCfg broker.Configuration) (*producer.DeadLetterProducer, error) {
+		return nil, nil
+	}
+
+	producer.NewPayloadTrackerProducer = func(brokerCfg broker.Configuration) (*producer.PayloadTrackerProducer, error) {
+		return nil, nil
+	}
+
+	originalConstructor := producer.NewRuleHitsChangesProducer
+	defer func() {
+		producer.NewRuleHitsChangesProducer = originalConstructor
+	}()
+
+	producer.NewRuleHitsChangesProducer = func(brokerCfg broker.Configuration) (*producer.RuleHitsChangesProducer, error) {
+		return nil, errors.New("error happened")
+	}
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockBroker := sarama.NewMockBroker(t, 0)
+	defer mockBroker.Close()
+
+	mockBroker.SetHandlerByMap(ira_helpers.GetHandlersMapForMockConsumer(t, mockBroker, testTopicName))
+
+	_, err := consumer.New(broker.Configuration{
+		Address: mockBroker.Addr(),
+		Topic:   testTopicName,
+		Enabled: true,
+	}, mockStorage)
+
+	assert.EqualError(t, err, "error happened")
+}
+
+func TestKafkaConsumer_ProcessMessage_PublishRuleHitsChanges(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	mockProducer := mocks.NewSyncProducer(t, nil)
+
+	var published []producer.RuleHitsChangesMessage
+	checker := func(val []byte) error {
+		var changes producer.RuleHitsChangesMessage
+		if err := json.Unmarshal(val, &changes); err != nil {
+			return err
+		}
+		published = append(published, changes)
+		return nil
+	}
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(checker)
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(checker)
+
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       mockStorage,
+	}
+	consumer.SetRuleHitsChangesProducer(mockConsumer, &producer.RuleHitsChangesProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: wrongBrokerCfg,
+	})
+	defer func() {
+		helpers.FailOnError(t, mockConsumer.Close())
+	}()
+
+	firstReport := `{"fingerprints": [], "info": [], "system": {}, "reports": [` +
+		`{"component": "ccx_rules_ocp.external.rules.rule_1.report", "key": "RULE_1", "details": {}},` +
+		`{"component": "ccx_rules_ocp.external.rules.rule_2.report", "key": "RULE_2", "details": {}}]}`
+	secondReport := `{"fingerprints": [], "info": [], "system": {}, "reports": [` +
+		`{"component": "ccx_rules_ocp.external.rules.rule_2.report", "key": "RULE_2", "details": {}},` +
+		`{"component": "ccx_rules_ocp.external.rules.rule_3.report", "key": "RULE_3", "details": {}}]}`
+
+	for i, report := range []string{firstReport, secondReport, secondReport} {
+		message := `{
+			"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+			"ClusterName": "` + string(testdata.ClusterName) + `",
+			"Report":` + report + `,
+			"LastChecked": "` + testdata.LastCheckedAt.Add(time.Duration(i)*time.Hour).UTC().Format(time.RFC3339) + `"
+		}`
+		helpers.FailOnError(t, consumerProcessMessage(mockConsumer, message))
+	}
+
+	// the third report doesn't change anything, so just two messages are expected
+	assert.Len(t, published, 2)
+
+	assert.Equal(t, []types.RuleSelector{
+		"ccx_rules_ocp.external.rules.rule_1|RULE_1",
+		"ccx_rules_ocp.external.rules.rule_2|RULE_2",
+	}, published[0].NewHits)
+	assert.Empty(t, published[0].ResolvedHits)
+
+	assert.Equal(t, testdata.OrgID, published[1].OrgID)
+	assert.Equal(t, testdata.ClusterName, published[1].ClusterName)
+	assert.Equal(t, []types.RuleSelector{"ccx_rules_ocp.external.rules.rule_3|RULE_3"}, published[1].NewHits)
+	assert.Equal(t, []types.RuleSelector{"ccx_rules_ocp.external.rules.rule_1|RULE_1"}, published[1].ResolvedHits)
+}
@@ -0,0 +1,293 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// Incoming messages are validated against JSON schemas stored in the
+// schemas directory. Only the subset of JSON schema keywords that is needed
+// to describe messages produced by rules engine is supported: type,
+// required, properties, additionalProperties, items, minLength, minimum,
+// maximum and format (date-time and uuid).
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+// schemaFileNames contains the JSON schema file for every supported
+// version of incoming messages
+var schemaFileNames = map[types.SchemaVersion]string{
+	1: "schemas/v1.json",
+}
+
+// messageSchemas contains parsed JSON schemas of incoming messages
+var messageSchemas = mustLoadMessageSchemas()
+
+// SchemaValidationError is returned when an incoming message does not
+// conform to the JSON schema of its version. It contains all violations
+// found in the message.
+type SchemaValidationError struct {
+	Version    types.SchemaVersion
+	Violations []string
+}
+
+// Error returns all violations found in the message
+func (err *SchemaValidationError) Error() string {
+	return fmt.Sprintf(
+		"message does not conform to schema version %d: %s",
+		err.Version, strings.Join(err.Violations, "; "),
+	)
+}
+
+// schemaTypes represents the type keyword, which can be either a string
+// or an array of strings
+type schemaTypes []string
+
+// UnmarshalJSON reads the type keyword in both of its forms
+func (typeNames *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*typeNames = schemaTypes{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*typeNames = multiple
+	return nil
+}
+
+// jsonSchema represents one (sub)schema
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	MinLength            *int                   `json:"minLength"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	Format               string                 `json:"format"`
+}
+
+// mustLoadMessageSchemas parses all embedded schemas. Schemas are part of
+// the binary, so any error here is a programming error.
+func mustLoadMessageSchemas() map[types.SchemaVersion]*jsonSchema {
+	schemas := make(map[types.SchemaVersion]*jsonSchema, len(schemaFileNames))
+
+	for version, fileName := range schemaFileNames {
+		content, err := schemaFiles.ReadFile(fileName)
+		if err != nil {
+			panic(fmt.Sprintf("unable to read schema %s: %v", fileName, err))
+		}
+
+		schema := &jsonSchema{}
+		if err := json.Unmarshal(content, schema); err != nil {
+			panic(fmt.Sprintf("unable to parse schema %s: %v", fileName, err))
+		}
+
+		schemas[version] = schema
+	}
+
+	return schemas
+}
+
+// validateMessage validates the incoming message against the JSON schema
+// with given version
+func validateMessage(messageValue []byte, schemaVersion types.SchemaVersion) error {
+	schema, found := messageSchemas[schemaVersion]
+	if !found {
+		return &UnsupportedSchemaVersionError{Version: schemaVersion}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(messageValue))
+	decoder.UseNumber()
+
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return err
+	}
+
+	violations := schema.validate("", document, nil)
+	if len(violations) > 0 {
+		return &SchemaValidationError{
+			Version:    schemaVersion,
+			Violations: violations,
+		}
+	}
+
+	return nil
+}
+
+// validate checks the value against the schema and appends all violations
+// to the list. Path is JSON pointer to the value.
+func (schema *jsonSchema) validate(path string, value interface{}, violations []string) []string {
+	if len(schema.Type) > 0 && !schema.Type.matches(value) {
+		return append(violations, fmt.Sprintf(
+			"%s: expected %s, got %s",
+			pointer(path), strings.Join(schema.Type, " or "), jsonType(value),
+		))
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		violations = schema.validateObject(path, typed, violations)
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range typed {
+				violations = schema.Items.validate(path+"/"+strconv.Itoa(i), item, violations)
+			}
+		}
+	case string:
+		violations = schema.validateString(path, typed, violations)
+	case json.Number:
+		violations = schema.validateNumber(path, typed, violations)
+	}
+
+	return violations
+}
+
+// validateObject checks required and all known properties of the object
+func (schema *jsonSchema) validateObject(path string, object map[string]interface{}, violations []string) []string {
+	for _, property := range schema.Required {
+		if _, found := object[property]; !found {
+			violations = append(violations, fmt.Sprintf(
+				"%s: missing required property '%s'", pointer(path), property,
+			))
+		}
+	}
+
+	// properties are checked in stable order, so the same message always
+	// produces the same error
+	properties := make([]string, 0, len(object))
+	for property := range object {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	for _, property := range properties {
+		propertySchema, found := schema.Properties[property]
+		if !found {
+			propertySchema = schema.AdditionalProperties
+		}
+		if propertySchema != nil {
+			violations = propertySchema.validate(path+"/"+property, object[property], violations)
+		}
+	}
+
+	return violations
+}
+
+// validateString checks length and format of the string
+func (schema *jsonSchema) validateString(path, value string, violations []string) []string {
+	if schema.MinLength != nil && utf8.RuneCountInString(value) < *schema.MinLength {
+		violations = append(violations, fmt.Sprintf(
+			"%s: string shorter than %d characters", pointer(path), *schema.MinLength,
+		))
+	}
+
+	var err error
+	switch schema.Format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339Nano, value)
+	case "uuid":
+		_, err = uuid.Parse(value)
+	}
+	if err != nil {
+		violations = append(violations, fmt.Sprintf(
+			"%s: '%s' is not a valid %s", pointer(path), value, schema.Format,
+		))
+	}
+
+	return violations
+}
+
+// validateNumber checks the range of the number
+func (schema *jsonSchema) validateNumber(path string, value json.Number, violations []string) []string {
+	number, err := value.Float64()
+	if err != nil {
+		return append(violations, fmt.Sprintf("%s: %s is not a valid number", pointer(path), value))
+	}
+
+	if schema.Minimum != nil && number < *schema.Minimum {
+		violations = append(violations, fmt.Sprintf(
+			"%s: %s is less than minimum %v", pointer(path), value, *schema.Minimum,
+		))
+	}
+	if schema.Maximum != nil && number > *schema.Maximum {
+		violations = append(violations, fmt.Sprintf(
+			"%s: %s is greater than maximum %v", pointer(path), value, *schema.Maximum,
+		))
+	}
+
+	return violations
+}
+
+// matches checks if the value has one of the types
+func (typeNames schemaTypes) matches(value interface{}) bool {
+	actual := jsonType(value)
+
+	for _, expected := range typeNames {
+		if expected == actual || (expected == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonType returns the name of JSON type of decoded value
+func jsonType(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := typed.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// pointer returns JSON pointer to be displayed in violation
+func pointer(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+func createVersionedConsumerMessage(version int, report string) string {
+	return `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"LastChecked": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `",
+		"Version": ` + fmt.Sprint(version) + `,
+		"Report": ` + report + `
+	}`
+}
+
+func TestParseMessageValidReportHits(t *testing.T) {
+	message, err := consumer.ParseMessage([]byte(createVersionedConsumerMessage(1, testReport)))
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, message.ParsedHits, 3)
+	assert.JSONEq(t, `{"description":"some details"}`, string(message.ParsedHits[0].TemplateData))
+}
+
+func TestParseMessageMalformedTemplateData(t *testing.T) {
+	const report = `{
+		"fingerprints": [], "info": [], "skips": [], "system": {},
+		"reports": [
+			{"component": "ccx_rules_ocp.external.rules.rule_1.report", "key": "RULE_1", "details": {}},
+			{"component": "ccx_rules_ocp.external.rules.rule_2.report", "key": "RULE_2", "details": "some details"},
+			{"component": "ccx_rules_ocp.external.rules.rule_3.report", "key": "RULE_3", "details": null}
+		]
+	}`
+
+	_, err := consumer.ParseMessage([]byte(createVersionedConsumerMessage(1, report)))
+	assert.EqualError(
+		t,
+		err,
+		"message does not conform to schema version 1: "+
+			"/Report/reports/1/details: expected object, got string; "+
+			"/Report/reports/2/details: expected object, got null",
+	)
+}
+
+func TestParseMessageAllViolationsReported(t *testing.T) {
+	message := `{
+		"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+		"ClusterName": "` + string(testdata.ClusterName) + `",
+		"LastChecked": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `",
+		"Version": 1,
+		"Report": {
+			"fingerprints": {}, "info": [{"info_id": "", "details": {"version": 4.9}}], "system": {},
+			"reports": [{"component": "ccx_rules_ocp.external.rules.rule_1.report", "details": {}}]
+		}
+	}`
+
+	_, err := consumer.ParseMessage([]byte(message))
+
+	var validationError *consumer.SchemaValidationError
+	if !errors.As(err, &validationError) {
+		t.Fatalf("expected schema validation error, got %+v", err)
+	}
+	assert.EqualValues(t, 1, validationError.Version)
+	assert.Equal(t, []string{
+		"/Report/fingerprints: expected array, got object",
+		"/Report/info/0: missing required property 'key'",
+		"/Report/info/0/details/version: expected string, got number",
+		"/Report/info/0/info_id: string shorter than 1 characters",
+		"/Report/reports/0: missing required property 'key'",
+	}, validationError.Violations)
+}
+
+func TestHandleMessageSchemaViolationStoredAsConsumerError(t *testing.T) {
+	const report = `{
+		"fingerprints": [], "info": [], "skips": [], "system": {},
+		"reports": [{"component": "ccx_rules_ocp.external.rules.rule_1.report", "key": "RULE_1", "details": "x"}]
+	}`
+
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mockConsumer := &consumer.KafkaConsumer{
+		Configuration: wrongBrokerCfg,
+		Storage:       memoryStorage,
+	}
+
+	msg := &sarama.ConsumerMessage{
+		Topic: testTopicName,
+		Value: []byte(createVersionedConsumerMessage(1, report)),
+	}
+	assert.Error(t, mockConsumer.HandleMessage(msg))
+
+	consumerErrors, err := memoryStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(
+		t,
+		"message does not conform to schema version 1: /Report/reports/0/details: expected object, got string",
+		consumerErrors[0].Error,
+	)
+
+	_, _, _, _, err = memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.Error(t, err, "report with malformed template data must not be stored")
+}
@@ -0,0 +1,162 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// pendingMessage is a message consumed from a claim that is being processed
+// by one of the workers
+type pendingMessage struct {
+	message *sarama.ConsumerMessage
+	done    bool
+}
+
+// offsetTracker keeps the messages in the order they were consumed and
+// makes it possible to find out which offset can be marked as processed,
+// i.e. which is the newest message whose all predecessors were processed too.
+type offsetTracker struct {
+	mutex   sync.Mutex
+	pending []*pendingMessage
+}
+
+// add registers newly consumed message
+func (tracker *offsetTracker) add(message *sarama.ConsumerMessage) *pendingMessage {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	item := &pendingMessage{message: message}
+	tracker.pending = append(tracker.pending, item)
+	return item
+}
+
+// complete marks the message as processed and returns the newest message
+// that can be marked in consumer group session. Nil is returned when some
+// older message is still being processed.
+func (tracker *offsetTracker) complete(item *pendingMessage) *sarama.ConsumerMessage {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	item.done = true
+
+	var markable *sarama.ConsumerMessage
+	for len(tracker.pending) > 0 && tracker.pending[0].done {
+		markable = tracker.pending[0].message
+		tracker.pending[0] = nil
+		tracker.pending = tracker.pending[1:]
+	}
+
+	return markable
+}
+
+// clusterNameFromMessage reads just the cluster name from the message so it
+// can be dispatched to a worker. Empty name is returned for messages that
+// can't be parsed, these are going to be reported by HandleMessage later.
+func clusterNameFromMessage(msg *sarama.ConsumerMessage) types.ClusterName {
+	var message struct {
+		ClusterName types.ClusterName `json:"ClusterName"`
+	}
+
+	messageValue, err := decompressMessageValue(msg.Value, messageEncoding(msg))
+	if err != nil {
+		return ""
+	}
+
+	if err := json.Unmarshal(messageValue, &message); err != nil {
+		return ""
+	}
+
+	return message.ClusterName
+}
+
+// workerForCluster selects the worker that processes all messages for given
+// cluster, so the messages for one cluster are processed in order
+func workerForCluster(clusterName types.ClusterName, workers int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(clusterName))
+	return int(hash.Sum32() % uint32(workers))
+}
+
+// consumeClaimConcurrently processes messages from the claim by pool of
+// workers. Messages for the same cluster are always handled by the same
+// worker in order they were consumed. Offset of a message is marked only
+// after all older messages from the claim are processed.
+func (consumer *KafkaConsumer) consumeClaimConcurrently(
+	session sarama.ConsumerGroupSession,
+	claim sarama.ConsumerGroupClaim,
+	latestMessageOffset types.KafkaOffset,
+) {
+	workers := consumer.Configuration.Workers
+	log.Info().Int("workers", workers).Msg("processing messages concurrently")
+
+	var (
+		tracker   offsetTracker
+		waitGroup sync.WaitGroup
+	)
+
+	queues := make([]chan *pendingMessage, workers)
+	for i := range queues {
+		queues[i] = make(chan *pendingMessage, workers)
+
+		waitGroup.Add(1)
+		go func(queue <-chan *pendingMessage) {
+			defer waitGroup.Done()
+
+			for item := range queue {
+				err := consumer.handleMessage(session.Context(), item.message)
+				if err != nil {
+					// already handled in HandleMessage, just log
+					log.Error().Err(err).Msg("Problem while handling the message")
+				}
+
+				if markable := tracker.complete(item); markable != nil {
+					session.MarkMessage(markable, "")
+				}
+			}
+		}(queues[i])
+	}
+
+	for message := range claim.Messages() {
+		if types.KafkaOffset(message.Offset) <= latestMessageOffset {
+			log.Warn().
+				Int64(offsetKey, message.Offset).
+				Msg("this offset was already processed by aggregator")
+		}
+
+		if !consumer.waitForRetryTopicDelay(session.Context(), message) {
+			break
+		}
+
+		item := tracker.add(message)
+		queues[workerForCluster(clusterNameFromMessage(message), workers)] <- item
+
+		if types.KafkaOffset(message.Offset) > latestMessageOffset {
+			latestMessageOffset = types.KafkaOffset(message.Offset)
+		}
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	waitGroup.Wait()
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package consumer_test
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-operator-utils/tests/saramahelpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// recordingSession is a consumer group session remembering marked offsets
+type recordingSession struct {
+	saramahelpers.MockConsumerGroupSession
+	mutex  sync.Mutex
+	marked []int64
+}
+
+func (session *recordingSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	session.marked = append(session.marked, msg.Offset)
+}
+
+// recordingStorage is an in-memory storage remembering the order in which
+// reports were written for each cluster
+type recordingStorage struct {
+	*storage.MemoryStorage
+	mutex   sync.Mutex
+	written map[types.ClusterName][]time.Time
+}
+
+func (s *recordingStorage) WriteReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	rules []types.ReportItem,
+	lastCheckedTime time.Time,
+	gatheredAt time.Time,
+	storedAtTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	// make the workers finish in random order
+	time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+
+	s.mutex.Lock()
+	s.written[clusterName] = append(s.written[clusterName], lastCheckedTime)
+	s.mutex.Unlock()
+
+	return s.MemoryStorage.WriteReportForCluster(
+		orgID, clusterName, report, rules, lastCheckedTime, gatheredAt, storedAtTime, kafkaOffset,
+	)
+}
+
+func TestKafkaConsumer_ConsumeClaim_Workers(t *testing.T) {
+	mockStorage := &recordingStorage{
+		MemoryStorage: storage.NewMemoryStorage(storage.Configuration{}),
+		written:       map[types.ClusterName][]time.Time{},
+	}
+
+	kafkaConsumer := consumer.KafkaConsumer{
+		Configuration: broker.Configuration{Workers: 4},
+		Storage:       mockStorage,
+	}
+
+	clusters := []types.ClusterName{
+		testdata.ClusterName, testdata.GetRandomClusterID(), testdata.GetRandomClusterID(),
+	}
+
+	var messages []*sarama.ConsumerMessage
+	lastChecked := testdata.LastCheckedAt.UTC()
+	for i := 0; i < 30; i++ {
+		lastChecked = lastChecked.Add(time.Minute)
+		messages = append(messages, &sarama.ConsumerMessage{
+			Offset: int64(i),
+			Value: []byte(`{
+				"OrgID": ` + fmt.Sprint(testdata.OrgID) + `,
+				"ClusterName": "` + string(clusters[i%len(clusters)]) + `",
+				"Report": ` + testdata.ConsumerReport + `,
+				"LastChecked": "` + lastChecked.Format(time.RFC3339) + `"
+			}`),
+		})
+	}
+
+	session := &recordingSession{}
+	err := kafkaConsumer.ConsumeClaim(session, saramahelpers.NewMockConsumerGroupClaim(messages))
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, uint64(len(messages)), kafkaConsumer.GetNumberOfSuccessfullyConsumedMessages())
+
+	// reports for each cluster are written in order
+	for _, cluster := range clusters {
+		written := mockStorage.written[cluster]
+		assert.Len(t, written, len(messages)/len(clusters))
+		for i := 1; i < len(written); i++ {
+			assert.True(t, written[i].After(written[i-1]))
+		}
+	}
+
+	// offsets are marked in order and the last one is marked at the end
+	assert.NotEmpty(t, session.marked)
+	for i := 1; i < len(session.marked); i++ {
+		assert.Greater(t, session.marked[i], session.marked[i-1])
+	}
+	assert.Equal(t, int64(len(messages)-1), session.marked[len(session.marked)-1])
+}
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := consumer.NewOffsetTracker()
+
+	first := tracker.Add(&sarama.ConsumerMessage{Offset: 1})
+	second := tracker.Add(&sarama.ConsumerMessage{Offset: 2})
+	third := tracker.Add(&sarama.ConsumerMessage{Offset: 3})
+
+	// the first message is still processed
+	assert.Nil(t, tracker.Complete(second))
+
+	marked := tracker.Complete(first)
+	assert.Equal(t, int64(2), marked.Offset)
+
+	marked = tracker.Complete(third)
+	assert.Equal(t, int64(3), marked.Offset)
+}
+
+func TestClusterNameFromMessage(t *testing.T) {
+	msg := &sarama.ConsumerMessage{Value: []byte(testdata.ConsumerMessage)}
+	assert.Equal(t, testdata.ClusterName, consumer.ClusterNameFromMessage(msg))
+
+	msg = &sarama.ConsumerMessage{Value: []byte("not a JSON")}
+	assert.Equal(t, types.ClusterName(""), consumer.ClusterNameFromMessage(msg))
+}
+
+func TestWorkerForCluster(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		cluster := testdata.GetRandomClusterID()
+		worker := consumer.WorkerForCluster(cluster, 4)
+
+		assert.GreaterOrEqual(t, worker, 0)
+		assert.Less(t, worker, 4)
+		assert.Equal(t, worker, consumer.WorkerForCluster(cluster, 4))
+	}
+}
@@ -16,6 +16,13 @@ limitations under the License.
 
 package main
 
+import (
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
 // Export for testing
 //
 // This source file contains name aliases of all package-private functions
@@ -27,22 +34,64 @@ package main
 // to see why this trick is needed.
 //nolint
 var (
-	CreateStorage       = createStorage
-	StartService        = startService
-	StopService         = stopService
-	CloseStorage        = closeStorage
-	PrepareDB           = prepareDB
-	StartConsumer       = startConsumer
-	StartServer         = startServer
-	PrintVersionInfo    = printVersionInfo
-	PrintHelp           = printHelp
-	PrintConfig         = printConfig
-	PrintEnv            = printEnv
-	GetDBForMigrations  = getDBForMigrations
-	PrintMigrationInfo  = printMigrationInfo
-	SetMigrationVersion = setMigrationVersion
-	PerformMigrations   = performMigrations
-	AutoMigratePtr      = &autoMigrate
-	Main                = main
-	FillInInfoParams    = fillInInfoParams
+	CreateStorage         = createStorage
+	StartService          = startService
+	StopService           = stopService
+	CloseStorage          = closeStorage
+	PrepareDB             = prepareDB
+	StartConsumer         = startConsumer
+	StartServer           = startServer
+	PrintVersionInfo      = printVersionInfo
+	PrintHelp             = printHelp
+	PrintConfig           = printConfig
+	PrintEnv              = printEnv
+	GetDBForMigrations    = getDBForMigrations
+	PrintMigrationInfo    = printMigrationInfo
+	SetMigrationVersion   = setMigrationVersion
+	PerformMigrations     = performMigrations
+	AutoMigratePtr        = &autoMigrate
+	Main                  = main
+	FillInInfoParams      = fillInInfoParams
+	CleanupExpiredRecords = cleanupExpiredRecords
+	RunJanitor            = runJanitor
+	StartJanitor          = startJanitor
+	Cleanup               = cleanup
 )
+
+// ReplayPollTimeoutPtr allows to shorten waiting for messages in tests
+var ReplayPollTimeoutPtr = &replayPollTimeout
+
+// ReplayPartition replays messages from the channel selected by arguments of
+// replay-dead-letters command
+func ReplayPartition(
+	handler consumer.Consumer, messages <-chan *sarama.ConsumerMessage, newest int64, args []string,
+) (succeeded, failed, skipped int, err error) {
+	params, err := parseReplayParams(args)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	var summary replaySummary
+	replayPartition(handler, messages, newest, params, &summary)
+	return summary.succeeded, summary.failed, summary.skipped, nil
+}
+
+// ParseReplayParams checks arguments of replay-dead-letters command
+func ParseReplayParams(args []string) error {
+	_, err := parseReplayParams(args)
+	return err
+}
+
+// ReplayConsumerErrors replays messages from consumer_error table selected by
+// arguments of replay-dead-letters command
+func ReplayConsumerErrors(
+	handler consumer.Consumer, dbStorage storage.Storage, args []string,
+) (succeeded, failed, skipped int, err error) {
+	params, err := parseReplayParams(args)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	summary, err := replayConsumerErrors(handler, dbStorage, params)
+	return summary.succeeded, summary.failed, summary.skipped, err
+}
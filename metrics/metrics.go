@@ -21,6 +21,12 @@ limitations under the License.
 //
 // consuming_errors - total number of errors during consuming messages from selected broker
 //
+// consuming_retries - total number of retries of messages failed because of a transient error
+//
+// retry_topic_messages - total number of messages sent to the retry topic
+//
+// rate_limited_messages - total number of messages dropped or deferred by ingest rate limits
+//
 // successful_messages_processing_time - time to process successfully message
 //
 // failed_messages_processing_time - time to process message fail
@@ -38,6 +44,10 @@ limitations under the License.
 // sql_queries_durations - SQL queries durations
 //
 // sql_recommendations_updates - number of insert and deletes in recommendations table
+//
+// retention_deleted_rows - total number of rows deleted by the retention policy cleanup
+//
+// allowed_organizations - number of organizations in the organization allow list
 package metrics
 
 import (
@@ -70,6 +80,26 @@ var FailedMessagesProcessingTime = promauto.NewHistogram(prometheus.HistogramOpt
 	Help: "Time to process message fail",
 })
 
+// ConsumingRetries shows the total number of retries of messages whose
+// processing failed because of a transient error
+var ConsumingRetries = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "consuming_retries",
+	Help: "The total number of retries of messages failed because of a transient error",
+})
+
+// RetryTopicMessages shows the total number of messages sent to the retry topic
+var RetryTopicMessages = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "retry_topic_messages",
+	Help: "The total number of messages sent to the retry topic",
+})
+
+// RateLimitedMessages shows the total number of messages that exceeded
+// ingest rate limit of their organization or cluster
+var RateLimitedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rate_limited_messages",
+	Help: "The total number of messages dropped or deferred by ingest rate limits",
+}, []string{"limit", "action"})
+
 // LastCheckedTimestampLagMinutes shows how slow we get messages from clusters
 var LastCheckedTimestampLagMinutes = promauto.NewHistogram(prometheus.HistogramOpts{
 	Name: "last_checked_timestamp_lag_minutes",
@@ -107,6 +137,20 @@ var SQLQueriesCounter = promauto.NewCounter(prometheus.CounterOpts{
 	Help: "Number of SQL queries",
 })
 
+// RetentionDeletedRows shows the total number of rows deleted from each
+// table because they were older than the retention period
+var RetentionDeletedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "retention_deleted_rows",
+	Help: "The total number of rows deleted by the retention policy cleanup",
+}, []string{"table"})
+
+// AllowedOrganizations shows the number of organizations in the currently
+// loaded organization allow list
+var AllowedOrganizations = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "allowed_organizations",
+	Help: "The number of organizations in the organization allow list",
+})
+
 // SQLQueriesDurations shows durations for sql queries (without parameters).
 var SQLQueriesDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Name: "sql_queries_durations",
@@ -133,6 +177,9 @@ func AddMetricsWithNamespace(namespace string) {
 
 	prometheus.Unregister(ConsumedMessages)
 	prometheus.Unregister(ConsumingErrors)
+	prometheus.Unregister(ConsumingRetries)
+	prometheus.Unregister(RetryTopicMessages)
+	prometheus.Unregister(RateLimitedMessages)
 	prometheus.Unregister(SuccessfulMessagesProcessingTime)
 	prometheus.Unregister(FailedMessagesProcessingTime)
 	prometheus.Unregister(LastCheckedTimestampLagMinutes)
@@ -141,6 +188,8 @@ func AddMetricsWithNamespace(namespace string) {
 	prometheus.Unregister(FeedbackOnRules)
 	prometheus.Unregister(SQLQueriesCounter)
 	prometheus.Unregister(SQLQueriesDurations)
+	prometheus.Unregister(RetentionDeletedRows)
+	prometheus.Unregister(AllowedOrganizations)
 	// prometheus.Unregister(SQLRecommendationsDeletes)
 	// prometheus.Unregister(SQLRecommendationsInserts)
 
@@ -154,6 +203,21 @@ func AddMetricsWithNamespace(namespace string) {
 		Name:      "consuming_errors",
 		Help:      "The total number of errors during consuming messages from Kafka",
 	})
+	ConsumingRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consuming_retries",
+		Help:      "The total number of retries of messages failed because of a transient error",
+	})
+	RetryTopicMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retry_topic_messages",
+		Help:      "The total number of messages sent to the retry topic",
+	})
+	RateLimitedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_messages",
+		Help:      "The total number of messages dropped or deferred by ingest rate limits",
+	}, []string{"limit", "action"})
 	SuccessfulMessagesProcessingTime = promauto.NewHistogram(prometheus.HistogramOpts{
 		Namespace: namespace,
 		Name:      "successful_messages_processing_time",
@@ -194,6 +258,16 @@ func AddMetricsWithNamespace(namespace string) {
 		Name:      "sql_queries_durations",
 		Help:      "SQL queries durations",
 	}, []string{"query"})
+	RetentionDeletedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retention_deleted_rows",
+		Help:      "The total number of rows deleted by the retention policy cleanup",
+	}, []string{"table"})
+	AllowedOrganizations = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "allowed_organizations",
+		Help:      "The number of organizations in the organization allow list",
+	})
 	/*
 		SQLRecommendationsDeletes = promauto.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: namespace,
@@ -1260,3 +1260,114 @@ func TestMigration31(t *testing.T) {
 	// default value on stepdown
 	assert.Equal(t, userID, types.UserID("-1"))
 }
+
+func TestMigration33(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	if dbDriver == types.DBDriverSQLite3 {
+		// sqlite is no longer supported
+		return
+	}
+
+	err := migration.SetDBVersion(db, dbDriver, 32)
+	helpers.FailOnError(t, err)
+
+	for _, table := range []string{"cluster_rule_toggle", "rule_disable"} {
+		_, err = db.Exec(`SELECT expires_at FROM ` + table)
+		assert.NotNil(t, err)
+	}
+
+	// migrate to 33
+	err = migration.SetDBVersion(db, dbDriver, 33)
+	helpers.FailOnError(t, err)
+
+	for _, table := range []string{"cluster_rule_toggle", "rule_disable"} {
+		_, err = db.Exec(`SELECT expires_at FROM ` + table)
+		helpers.FailOnError(t, err)
+	}
+
+	// and back to 32
+	err = migration.SetDBVersion(db, dbDriver, 32)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT expires_at FROM rule_disable`)
+	assert.NotNil(t, err)
+}
+
+func TestMigration34(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	if dbDriver == types.DBDriverSQLite3 {
+		// sqlite is no longer supported
+		return
+	}
+
+	err := migration.SetDBVersion(db, dbDriver, 33)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT id FROM audit_event`)
+	assert.NotNil(t, err)
+
+	// migrate to 34
+	err = migration.SetDBVersion(db, dbDriver, 34)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO audit_event (org_id, user_id, action, cluster_id, rule_id, error_key, details, created_at)
+		VALUES (1, '1', 'vote', '', '', '', '{}', $1)
+	`, time.Now())
+	helpers.FailOnError(t, err)
+
+	var id int64
+	err = db.QueryRow(`SELECT id FROM audit_event`).Scan(&id)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	// and back to 33
+	err = migration.SetDBVersion(db, dbDriver, 33)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT id FROM audit_event`)
+	assert.NotNil(t, err)
+}
+
+func TestMigration35(t *testing.T) {
+	db, dbDriver, closer := prepareDBAndInfo(t)
+	defer closer()
+
+	if dbDriver == types.DBDriverSQLite3 {
+		// sqlite is no longer supported
+		return
+	}
+
+	err := migration.SetDBVersion(db, dbDriver, 34)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`
+		INSERT INTO report_info (org_id, cluster_id, version_info)
+		VALUES ($1, $2, $3)
+	`, testdata.OrgID, testdata.ClusterName, testdata.ClusterVersion)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT info FROM report_info`)
+	assert.NotNil(t, err)
+
+	// migrate to 35
+	err = migration.SetDBVersion(db, dbDriver, 35)
+	helpers.FailOnError(t, err)
+
+	// existing records get empty info
+	var info string
+	err = db.QueryRow(`SELECT info FROM report_info WHERE cluster_id = $1`, testdata.ClusterName).Scan(&info)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "{}", info)
+
+	// and back to 34
+	err = migration.SetDBVersion(db, dbDriver, 34)
+	helpers.FailOnError(t, err)
+
+	_, err = db.Exec(`SELECT info FROM report_info`)
+	assert.NotNil(t, err)
+}
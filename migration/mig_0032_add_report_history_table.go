@@ -0,0 +1,52 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0032AddReportHistoryTable adds a table where the last N reports
+// for each cluster are kept, so it is possible to see how results evolved
+var mig0032AddReportHistoryTable = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`
+			CREATE TABLE report_history (
+				org_id          INTEGER NOT NULL,
+				cluster         VARCHAR NOT NULL,
+				report          VARCHAR NOT NULL,
+				reported_at     TIMESTAMP,
+				last_checked_at TIMESTAMP NOT NULL,
+				kafka_offset    BIGINT NOT NULL DEFAULT 0,
+				gathered_at     TIMESTAMP,
+				PRIMARY KEY(org_id, cluster, last_checked_at)
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE INDEX report_history_cluster_idx
+			ON report_history (cluster)
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE report_history`)
+		return err
+	},
+}
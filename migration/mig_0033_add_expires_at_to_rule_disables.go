@@ -0,0 +1,48 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0033AddExpiresAtToRuleDisables adds an optional expiration time to
+// rules disabled for one cluster and to rules disabled system-wide. NULL
+// means that the rule is disabled until it's enabled again.
+var mig0033AddExpiresAtToRuleDisables = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`ALTER TABLE cluster_rule_toggle ADD COLUMN expires_at TIMESTAMP`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`ALTER TABLE rule_disable ADD COLUMN expires_at TIMESTAMP`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverPostgres {
+			_, err := tx.Exec(`
+				ALTER TABLE cluster_rule_toggle DROP COLUMN IF EXISTS expires_at;
+				ALTER TABLE rule_disable DROP COLUMN IF EXISTS expires_at;
+			`)
+			return err
+		}
+
+		return fmt.Errorf(driverUnsupportedErr, driver)
+	},
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"database/sql"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0034AddAuditEventTable adds append-only table with changes made by
+// users (votes, rule toggles, system-wide disables, justifications and
+// ratings), so it is possible to find out who changed what and when
+var mig0034AddAuditEventTable = Migration{
+	StepUp: func(tx *sql.Tx, driver types.DBDriver) error {
+		idColumn := "BIGSERIAL PRIMARY KEY"
+		if driver == types.DBDriverSQLite3 {
+			idColumn = "INTEGER PRIMARY KEY AUTOINCREMENT"
+		}
+
+		// #nosec G202
+		_, err := tx.Exec(`
+			CREATE TABLE audit_event (
+				id          ` + idColumn + `,
+				org_id      INTEGER NOT NULL,
+				user_id     VARCHAR NOT NULL,
+				action      VARCHAR NOT NULL,
+				cluster_id  VARCHAR NOT NULL,
+				rule_id     VARCHAR NOT NULL,
+				error_key   VARCHAR NOT NULL,
+				details     VARCHAR NOT NULL,
+				created_at  TIMESTAMP NOT NULL
+			)`)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			CREATE INDEX audit_event_org_id_idx
+			ON audit_event (org_id, id)
+		`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`DROP TABLE audit_event`)
+		return err
+	},
+}
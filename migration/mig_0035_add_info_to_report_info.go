@@ -0,0 +1,41 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mig0035AddInfoToReportInfo adds details of all info items of the latest
+// report (platform, cluster ID, nodes etc.) to report_info table. They are
+// stored as JSON object keyed by info ID, only the version of the cluster
+// was stored before.
+var mig0035AddInfoToReportInfo = Migration{
+	StepUp: func(tx *sql.Tx, _ types.DBDriver) error {
+		_, err := tx.Exec(`ALTER TABLE report_info ADD COLUMN info VARCHAR NOT NULL DEFAULT '{}'`)
+		return err
+	},
+	StepDown: func(tx *sql.Tx, driver types.DBDriver) error {
+		if driver == types.DBDriverPostgres {
+			_, err := tx.Exec(`ALTER TABLE report_info DROP COLUMN IF EXISTS info`)
+			return err
+		}
+
+		return fmt.Errorf(driverUnsupportedErr, driver)
+	},
+}
@@ -48,4 +48,8 @@ var migrations = []Migration{
 	mig0029DropClusterRuleToggleUserIDColumn,
 	mig0030DropRuleDisableUserIDColumn,
 	mig0031AlterConstraintDropUserAdvisorRatings,
+	mig0032AddReportHistoryTable,
+	mig0033AddExpiresAtToRuleDisables,
+	mig0034AddAuditEventTable,
+	mig0035AddInfoToReportInfo,
 }
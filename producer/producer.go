@@ -35,6 +35,9 @@ const (
 	StatusSuccess = "success"
 	// StatusError is reported when the handling of a payload fails for any reason.
 	StatusError = "error"
+	// StatusRateLimited is reported when the payload is dropped or deferred
+	// because its organization or cluster exceeded the ingest rate limit.
+	StatusRateLimited = "rate_limited"
 )
 
 // Producer represents any producer
@@ -72,9 +75,18 @@ func New(brokerCfg broker.Configuration) (*KafkaProducer, error) {
 // partition ID and offset of new message or an error value in case of any
 // problem on broker side.
 func (producer *KafkaProducer) produceMessage(jsonBytes []byte, topic string) (int32, int64, error) {
+	return producer.produceMessageWithHeaders(jsonBytes, topic, nil)
+}
+
+// produceMessageWithHeaders produces message with given record headers to
+// selected topic. Headers are supported by Kafka 0.11 and newer.
+func (producer *KafkaProducer) produceMessageWithHeaders(
+	jsonBytes []byte, topic string, headers []sarama.RecordHeader,
+) (int32, int64, error) {
 	producerMsg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.ByteEncoder(jsonBytes),
+		Topic:   topic,
+		Value:   sarama.ByteEncoder(jsonBytes),
+		Headers: headers,
 	}
 
 	partition, offset, err := producer.Producer.SendMessage(producerMsg)
@@ -40,6 +40,7 @@ var (
 		Topic:                "consumer-topic",
 		PayloadTrackerTopic:  "payload-tracker-topic",
 		DeadLetterQueueTopic: "dlq-topic",
+		RuleHitsChangesTopic: "rule-hits-changes-topic",
 		Group:                "test-group",
 	}
 	// Base UNIX time plus approximately 50 years (not long before year 2020).
@@ -215,3 +216,127 @@ func TestProducerSendDeadLetterMessageNil(t *testing.T) {
 	err := deadLetterProducer.SendDeadLetter(nil)
 	assert.NoError(t, err, "sending dead letter failed")
 }
+
+// TestRuleHitsChangesProducerNew checks that creating new RuleHitsChangesProducer works fine
+func TestRuleHitsChangesProducerNew(t *testing.T) {
+	mockBroker := sarama.NewMockBroker(t, 0)
+	defer mockBroker.Close()
+
+	mockBroker.SetHandlerByMap(ira_helpers.GetHandlersMapForMockConsumer(t, mockBroker, brokerCfg.RuleHitsChangesTopic))
+
+	prod, err := producer.NewRuleHitsChangesProducer(
+		broker.Configuration{
+			Address:              mockBroker.Addr(),
+			Topic:                brokerCfg.Topic,
+			Enabled:              brokerCfg.Enabled,
+			RuleHitsChangesTopic: brokerCfg.RuleHitsChangesTopic,
+		})
+	helpers.FailOnError(t, err)
+
+	helpers.FailOnError(t, prod.Close())
+}
+
+// TestRuleHitsChangesProducerNotConfigured checks that no producer is
+// constructed when the topic is not configured
+func TestRuleHitsChangesProducerNotConfigured(t *testing.T) {
+	prod, err := producer.NewRuleHitsChangesProducer(broker.Configuration{})
+	helpers.FailOnError(t, err)
+	assert.Nil(t, prod)
+}
+
+// TestProducerSendRuleHitsChanges calls the SendRuleHitsChanges function using a mock Sarama producer.
+func TestProducerSendRuleHitsChanges(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(val []byte) error {
+		expected := `{"org_id":1,"cluster_id":"` + string(testdata.ClusterName) + `","last_checked_at":"2020-01-01T00:00:00Z",` +
+			`"new_hits":["rule.module|ERROR_KEY"],"resolved_hits":[]}`
+		if string(val) != expected {
+			return errors.New("unexpected message: " + string(val))
+		}
+		return nil
+	})
+
+	ruleHitsChangesProducer := producer.RuleHitsChangesProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: brokerCfg,
+	}
+	defer func() {
+		helpers.FailOnError(t, ruleHitsChangesProducer.Close())
+	}()
+
+	err := ruleHitsChangesProducer.SendRuleHitsChanges(producer.RuleHitsChangesMessage{
+		OrgID:         1,
+		ClusterName:   testdata.ClusterName,
+		LastCheckedAt: "2020-01-01T00:00:00Z",
+		NewHits:       []types.RuleSelector{"rule.module|ERROR_KEY"},
+		ResolvedHits:  []types.RuleSelector{},
+	})
+	assert.NoError(t, err, "sending rule hits changes failed")
+}
+
+// TestProducerSendRuleHitsChangesWithError checks that errors
+// from the underlying producer are correctly returned.
+func TestProducerSendRuleHitsChangesWithError(t *testing.T) {
+	const producerErrorMessage = "unable to send the message"
+
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageAndFail(errors.New(producerErrorMessage))
+
+	ruleHitsChangesProducer := producer.RuleHitsChangesProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: brokerCfg,
+	}
+	defer func() {
+		helpers.FailOnError(t, ruleHitsChangesProducer.Close())
+	}()
+
+	err := ruleHitsChangesProducer.SendRuleHitsChanges(producer.RuleHitsChangesMessage{})
+	assert.EqualError(t, err, producerErrorMessage)
+}
+
+// TestRetryProducerNotConfigured checks that no producer is constructed when
+// the retry topic is not configured
+func TestRetryProducerNotConfigured(t *testing.T) {
+	prod, err := producer.NewRetryProducer(broker.Configuration{})
+	helpers.FailOnError(t, err)
+	assert.Nil(t, prod)
+}
+
+// TestProducerSendRetry calls the SendRetry function using a mock Sarama producer.
+func TestProducerSendRetry(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+	mockProducer.ExpectSendMessageWithCheckerFunctionAndSucceed(func(val []byte) error {
+		if string(val) != testdata.ConsumerMessage {
+			return errors.New("unexpected message: " + string(val))
+		}
+		return nil
+	})
+
+	retryProducer := producer.RetryProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: broker.Configuration{RetryTopic: "retry-topic"},
+	}
+	defer func() {
+		helpers.FailOnError(t, retryProducer.Close())
+	}()
+
+	msg := &sarama.ConsumerMessage{Value: []byte(testdata.ConsumerMessage)}
+	err := retryProducer.SendRetry(msg, 1, testTimestamp)
+	assert.NoError(t, err, "sending message to retry topic failed")
+}
+
+// TestProducerSendRetryMessageNil checks that the SendRetry function verifies the parameter is not nil.
+func TestProducerSendRetryMessageNil(t *testing.T) {
+	mockProducer := mocks.NewSyncProducer(t, nil)
+
+	retryProducer := producer.RetryProducer{
+		KafkaProducer: producer.KafkaProducer{Producer: mockProducer},
+		Configuration: broker.Configuration{RetryTopic: "retry-topic"},
+	}
+	defer func() {
+		helpers.FailOnError(t, retryProducer.Close())
+	}()
+
+	err := retryProducer.SendRetry(nil, 1, testTimestamp)
+	assert.NoError(t, err, "sending message to retry topic failed")
+}
@@ -0,0 +1,108 @@
+/*
+Copyright © 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package producer contains functions that can be used to produce (that is
+// send) messages to properly configured Kafka broker.
+package producer
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+)
+
+const (
+	// RetryAttemptHeader is a header of messages in retry topic containing
+	// number of the retry attempt
+	RetryAttemptHeader = "retry_attempt"
+	// RetryNotBeforeHeader is a header of messages in retry topic containing
+	// time in RFC3339 format before which the message should not be processed
+	RetryNotBeforeHeader = "retry_not_before"
+)
+
+// RetryProducer is a producer for topic with messages whose processing
+// failed because of a transient error
+type RetryProducer struct {
+	KafkaProducer KafkaProducer
+	Configuration broker.Configuration
+}
+
+// NewRetryProducer constructs producer for retry topic.
+// It is implemented as variable in order to allow monkey patching in unit tests.
+var NewRetryProducer = func(brokerCfg broker.Configuration) (*RetryProducer, error) {
+	if brokerCfg.RetryTopic == "" {
+		return nil, nil
+	}
+
+	p, err := New(brokerCfg)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to create a new retry producer")
+		return nil, err
+	}
+	return &RetryProducer{
+		KafkaProducer: *p,
+		Configuration: brokerCfg,
+	}, nil
+}
+
+// SendRetry sends the message to the retry topic. Headers of the original
+// message (content encoding, request ID etc.) are kept, number of the
+// attempt and the time before which the message should not be processed
+// again are stored in message headers too.
+func (producer *RetryProducer) SendRetry(msg *sarama.ConsumerMessage, attempt int, notBefore time.Time) error {
+	if msg == nil {
+		log.Warn().Msg("message to be produced in retry topic is empty, skipping")
+		return nil
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(msg.Headers)+2)
+	for _, header := range msg.Headers {
+		// retry headers of the previous attempt are replaced
+		if header == nil || string(header.Key) == RetryAttemptHeader || string(header.Key) == RetryNotBeforeHeader {
+			continue
+		}
+		headers = append(headers, *header)
+	}
+	headers = append(headers,
+		sarama.RecordHeader{Key: []byte(RetryAttemptHeader), Value: []byte(strconv.Itoa(attempt))},
+		sarama.RecordHeader{Key: []byte(RetryNotBeforeHeader), Value: []byte(notBefore.UTC().Format(time.RFC3339Nano))},
+	)
+
+	partitionID, offset, err := producer.KafkaProducer.produceMessageWithHeaders(
+		msg.Value, producer.Configuration.RetryTopic, headers,
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to produce message to retry topic")
+		return err
+	}
+
+	log.Info().Msgf("message has been produced to retry topic with partition ID %d and offset %d", partitionID, offset)
+	return nil
+}
+
+// Close allow the Sarama producer to be gracefully closed
+func (producer *RetryProducer) Close() error {
+	if err := producer.KafkaProducer.Close(); err != nil {
+		log.Error().Err(err).Msg("unable to close retry producer")
+		return err
+	}
+
+	return nil
+}
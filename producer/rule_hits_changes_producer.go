@@ -0,0 +1,92 @@
+/*
+Copyright © 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package producer contains functions that can be used to produce (that is
+// send) messages to properly configured Kafka broker.
+package producer
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RuleHitsChangesProducer is a producer for topic with changes of rule hits
+type RuleHitsChangesProducer struct {
+	KafkaProducer KafkaProducer
+	Configuration broker.Configuration
+}
+
+// NewRuleHitsChangesProducer constructs producer for rule hits changes topic.
+// It is implemented as variable in order to allow monkey patching in unit tests.
+var NewRuleHitsChangesProducer = func(brokerCfg broker.Configuration) (*RuleHitsChangesProducer, error) {
+	if brokerCfg.RuleHitsChangesTopic == "" {
+		return nil, nil
+	}
+
+	p, err := New(brokerCfg)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to create a new rule hits changes producer")
+		return nil, err
+	}
+	return &RuleHitsChangesProducer{
+		KafkaProducer: *p,
+		Configuration: brokerCfg,
+	}, nil
+}
+
+// RuleHitsChangesMessage represents content of messages sent to the rule
+// hits changes topic in Kafka. Rules are identified by rule selectors in
+// format "rule.module|ERROR_KEY".
+type RuleHitsChangesMessage struct {
+	OrgID         types.OrgID          `json:"org_id"`
+	ClusterName   types.ClusterName    `json:"cluster_id"`
+	RequestID     types.RequestID      `json:"request_id,omitempty"`
+	LastCheckedAt string               `json:"last_checked_at"`
+	NewHits       []types.RuleSelector `json:"new_hits"`
+	ResolvedHits  []types.RuleSelector `json:"resolved_hits"`
+}
+
+// SendRuleHitsChanges publishes rules that started or stopped hitting the
+// cluster to the rule hits changes Kafka topic
+func (producer *RuleHitsChangesProducer) SendRuleHitsChanges(changes RuleHitsChangesMessage) error {
+	jsonBytes, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	partitionID, offset, err := producer.KafkaProducer.produceMessage(jsonBytes, producer.Configuration.RuleHitsChangesTopic)
+	if err != nil {
+		log.Error().Err(err).Msg("unable to produce message to rule hits changes topic")
+		return err
+	}
+
+	log.Info().Msgf("rule hits changes have been produced with partition ID %d and offset %d", partitionID, offset)
+	return nil
+}
+
+// Close allow the Sarama producer to be gracefully closed
+func (producer *RuleHitsChangesProducer) Close() error {
+	if err := producer.KafkaProducer.Close(); err != nil {
+		log.Error().Err(err).Msg("unable to close rule hits changes producer")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,362 @@
+/*
+Copyright © 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/broker"
+	"github.com/RedHatInsights/insights-results-aggregator/conf"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// Sources of messages to be replayed
+const (
+	replaySourceTopic = "topic"
+	replaySourceDB    = "db"
+)
+
+// replayPollTimeout is the time to wait for the next message from dead
+// letter queue topic. Reading of the partition is finished when no message
+// arrives in this time, for example when the last messages were deleted.
+var replayPollTimeout = 10 * time.Second
+
+// replayParams contains parameters of replay-dead-letters command
+type replayParams struct {
+	source    string
+	from      time.Time
+	to        time.Time
+	orgID     types.OrgID
+	errorText string
+}
+
+// replaySummary contains number of replayed messages
+type replaySummary struct {
+	succeeded int
+	failed    int
+	skipped   int
+}
+
+// messageOrganization is a helper struct used to unmarshal just the
+// organization from the message
+type messageOrganization struct {
+	OrgID types.OrgID `json:"OrgID"`
+}
+
+// parseReplayParams parses arguments of replay-dead-letters command
+func parseReplayParams(args []string) (replayParams, error) {
+	var (
+		params   replayParams
+		from, to string
+		orgID    uint64
+	)
+
+	flags := flag.NewFlagSet("replay-dead-letters", flag.ContinueOnError)
+	flags.StringVar(&params.source, "source", replaySourceTopic,
+		`where to read the messages from: "topic" for dead letter queue topic, "db" for consumer_error table`)
+	flags.StringVar(&from, "from", "", "replay only messages that failed at this time (RFC3339) or later")
+	flags.StringVar(&to, "to", "", "replay only messages that failed at this time (RFC3339) or earlier")
+	flags.Uint64Var(&orgID, "org", 0, "replay only messages for this organization")
+	flags.StringVar(&params.errorText, "error", "", `replay only messages whose error contains this text ("db" source only)`)
+
+	if err := flags.Parse(args); err != nil {
+		return params, err
+	}
+
+	if params.source != replaySourceTopic && params.source != replaySourceDB {
+		return params, fmt.Errorf("unknown source %q", params.source)
+	}
+
+	if params.source == replaySourceTopic && params.errorText != "" {
+		return params, errors.New("messages in dead letter queue topic don't contain errors, use db source to filter by error")
+	}
+
+	var err error
+	if from != "" {
+		if params.from, err = time.Parse(time.RFC3339, from); err != nil {
+			return params, err
+		}
+	}
+	if to != "" {
+		if params.to, err = time.Parse(time.RFC3339, to); err != nil {
+			return params, err
+		}
+	}
+
+	params.orgID = types.OrgID(orgID)
+
+	return params, nil
+}
+
+// messageSelected checks whether the message failed in the time range and
+// whether it belongs to the organization selected by parameters. Compressed
+// messages are decompressed before their organization is checked.
+func (params replayParams) messageSelected(msg *sarama.ConsumerMessage, failedAt time.Time) bool {
+	if !params.from.IsZero() && failedAt.Before(params.from) {
+		return false
+	}
+	if !params.to.IsZero() && failedAt.After(params.to) {
+		return false
+	}
+
+	if params.orgID != 0 {
+		value, err := consumer.DecompressedMessageValue(msg)
+		if err != nil {
+			log.Warn().Err(err).Int64("offset", msg.Offset).Msg("unable to decompress message")
+			return false
+		}
+
+		var organization messageOrganization
+		if err := json.Unmarshal(value, &organization); err != nil || organization.OrgID != params.orgID {
+			return false
+		}
+	}
+
+	return true
+}
+
+// replayMessage passes the message to the consumer and updates the summary.
+// It returns true when the message has been processed successfully.
+func replayMessage(handler consumer.Consumer, msg *sarama.ConsumerMessage, summary *replaySummary) bool {
+	if err := handler.HandleMessage(msg); err != nil {
+		log.Error().Err(err).
+			Str("topic", msg.Topic).
+			Int32("partition", msg.Partition).
+			Int64("offset", msg.Offset).
+			Msg("replayed message failed")
+		summary.failed++
+		return false
+	}
+	summary.succeeded++
+	return true
+}
+
+// replayConsumerErrors replays messages stored in consumer_error table.
+// Records of successfully replayed messages are deleted. Records of messages
+// that failed again are kept and updated with the new error by the consumer.
+func replayConsumerErrors(
+	handler consumer.Consumer, dbStorage storage.Storage, params replayParams,
+) (replaySummary, error) {
+	var summary replaySummary
+
+	// the time range is checked by storage
+	consumerErrors, err := dbStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{
+		ConsumedFrom: params.from,
+		ConsumedTo:   params.to,
+		ErrorText:    params.errorText,
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	for i := range consumerErrors {
+		consumerError := &consumerErrors[i]
+
+		msg := &sarama.ConsumerMessage{
+			Topic:     consumerError.Topic,
+			Partition: consumerError.Partition,
+			Offset:    consumerError.Offset,
+			Key:       consumerError.Key,
+			Value:     consumerError.Message,
+			Timestamp: consumerError.ProducedAt,
+		}
+
+		if !params.messageSelected(msg, consumerError.ConsumedAt) {
+			summary.skipped++
+			continue
+		}
+
+		if !replayMessage(handler, msg, &summary) {
+			continue
+		}
+
+		err := dbStorage.DeleteConsumerError(consumerError.Topic, consumerError.Partition, consumerError.Offset)
+		if err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, nil
+}
+
+// replayDeadLetterQueueTopic replays all messages that are in the dead
+// letter queue topic when the command starts
+func replayDeadLetterQueueTopic(
+	handler consumer.Consumer, brokerCfg broker.Configuration, params replayParams,
+) (replaySummary, error) {
+	var summary replaySummary
+
+	topic := brokerCfg.DeadLetterQueueTopic
+	if topic == "" {
+		return summary, errors.New("dead letter queue topic is not configured")
+	}
+
+	saramaConfig, err := broker.SaramaConfigFromBrokerConfig(brokerCfg)
+	if err != nil {
+		return summary, err
+	}
+
+	client, err := sarama.NewClient([]string{brokerCfg.Address}, saramaConfig)
+	if err != nil {
+		return summary, err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			log.Error().Err(err).Msg("unable to close Kafka client")
+		}
+	}()
+
+	kafkaConsumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return summary, err
+	}
+	defer func() {
+		if err := kafkaConsumer.Close(); err != nil {
+			log.Error().Err(err).Msg("unable to close Kafka consumer")
+		}
+	}()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, partition := range partitions {
+		oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return summary, err
+		}
+		// offset of the message that will be produced next
+		newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return summary, err
+		}
+		if oldest >= newest {
+			continue
+		}
+
+		partitionConsumer, err := kafkaConsumer.ConsumePartition(topic, partition, oldest)
+		if err != nil {
+			return summary, err
+		}
+
+		replayPartition(handler, partitionConsumer.Messages(), newest, params, &summary)
+
+		if err := partitionConsumer.Close(); err != nil {
+			log.Error().Err(err).Msg("unable to close Kafka partition consumer")
+		}
+	}
+
+	return summary, nil
+}
+
+// replayPartition replays messages from one partition of dead letter queue
+// topic until the message preceding the newest offset is reached. Messages
+// produced after the command started (for example the ones that failed
+// again) are not replayed. Reading is finished when no message arrives in
+// replayPollTimeout too, because the message with the last offset might
+// not exist anymore.
+func replayPartition(
+	handler consumer.Consumer,
+	messages <-chan *sarama.ConsumerMessage,
+	newest int64,
+	params replayParams,
+	summary *replaySummary,
+) {
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			if params.messageSelected(msg, msg.Timestamp) {
+				replayMessage(handler, msg, summary)
+			} else {
+				summary.skipped++
+			}
+
+			if msg.Offset >= newest-1 {
+				return
+			}
+		case <-time.After(replayPollTimeout):
+			log.Warn().Int64("newest_offset", newest).Msg("no more messages in dead letter queue partition")
+			return
+		}
+	}
+}
+
+// replayDeadLetters function handles replay-dead-letters command. Messages
+// that the consumer was not able to process are read from dead letter queue
+// topic or from consumer_error table and processed again.
+func replayDeadLetters(args []string) int {
+	params, err := parseReplayParams(args)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid arguments of replay-dead-letters command")
+		return ExitStatusError
+	}
+
+	if exitCode := prepareDB(); exitCode != ExitStatusOK {
+		log.Info().Msgf(databasePreparationMessage, exitCode)
+		return exitCode
+	}
+
+	dbStorage, err := createStorage()
+	if err != nil {
+		return ExitStatusPrepareDbError
+	}
+	defer closeStorage(dbStorage)
+
+	brokerCfg := conf.GetBrokerConfiguration()
+
+	handler, err := consumer.NewMessageHandler(brokerCfg, dbStorage)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to construct consumer")
+		return ExitStatusConsumerError
+	}
+	defer func() {
+		if err := handler.Close(); err != nil {
+			log.Error().Err(err).Msg("Consumer stop error")
+		}
+	}()
+
+	var summary replaySummary
+	if params.source == replaySourceDB {
+		summary, err = replayConsumerErrors(handler, dbStorage, params)
+	} else {
+		summary, err = replayDeadLetterQueueTopic(handler, brokerCfg, params)
+	}
+
+	fmt.Printf("Replayed messages: %d succeeded, %d failed, %d skipped by filters\n",
+		summary.succeeded, summary.failed, summary.skipped)
+
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to replay dead letters")
+		return ExitStatusReplayError
+	}
+
+	return ExitStatusOK
+}
@@ -0,0 +1,172 @@
+/*
+Copyright © 2022 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	main "github.com/RedHatInsights/insights-results-aggregator"
+	"github.com/RedHatInsights/insights-results-aggregator/consumer"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+func TestParseReplayParams(t *testing.T) {
+	helpers.FailOnError(t, main.ParseReplayParams(nil))
+	helpers.FailOnError(t, main.ParseReplayParams([]string{
+		"-source", "db", "-from", "2022-01-01T00:00:00Z", "-to", "2022-01-02T00:00:00Z",
+		"-org", "1", "-error", "bad connection",
+	}))
+
+	assert.Error(t, main.ParseReplayParams([]string{"-source", "file"}))
+	assert.Error(t, main.ParseReplayParams([]string{"-from", "yesterday"}))
+	assert.Error(t, main.ParseReplayParams([]string{"-org", "-1"}))
+	// errors are stored in consumer_error table only
+	assert.Error(t, main.ParseReplayParams([]string{"-source", "topic", "-error", "bad connection"}))
+}
+
+func TestReplayConsumerErrors(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+	handler := &consumer.KafkaConsumer{Storage: memoryStorage}
+
+	otherOrgMessage := strings.Replace(
+		testdata.ConsumerMessage, fmt.Sprintf(`"OrgID": %v`, testdata.OrgID), `"OrgID": 999`, 1,
+	)
+	for offset, message := range []string{testdata.ConsumerMessage, otherOrgMessage, `{"OrgID": 1}`} {
+		err := memoryStorage.WriteConsumerError(&sarama.ConsumerMessage{
+			Topic:  "topic",
+			Offset: int64(offset),
+			Value:  []byte(message),
+		}, errors.New("driver: bad connection"))
+		helpers.FailOnError(t, err)
+	}
+
+	succeeded, failed, skipped, err := main.ReplayConsumerErrors(handler, memoryStorage, []string{
+		"-source", "db", "-org", fmt.Sprint(testdata.OrgID), "-error", "bad connection",
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 2, succeeded+failed)
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, 1, skipped)
+
+	count, err := memoryStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	// the record of the message that failed again contains the new error,
+	// the skipped record is untouched
+	consumerErrors, err := memoryStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 2)
+	assert.Equal(t, int64(1), consumerErrors[0].Offset)
+	assert.Equal(t, int64(2), consumerErrors[1].Offset)
+	assert.NotEqual(t, "driver: bad connection", consumerErrors[1].Error)
+
+	// nothing is selected by the time range
+	succeeded, failed, skipped, err = main.ReplayConsumerErrors(handler, memoryStorage, []string{
+		"-source", "db", "-to", time.Now().Add(-time.Hour).Format(time.RFC3339),
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, succeeded+failed+skipped)
+}
+
+// failingHandler is a consumer that fails to process any message without
+// writing the consumer error, for example when it is stopped
+type failingHandler struct {
+	consumer.KafkaConsumer
+}
+
+func (*failingHandler) HandleMessage(*sarama.ConsumerMessage) error {
+	return errors.New("consumer is stopping")
+}
+
+// TestReplayConsumerErrorsFailedKept checks that the record of the message
+// is not deleted when the message is not processed successfully
+func TestReplayConsumerErrorsFailedKept(t *testing.T) {
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+
+	err := memoryStorage.WriteConsumerError(&sarama.ConsumerMessage{
+		Topic: "topic",
+		Value: []byte(testdata.ConsumerMessage),
+	}, errors.New("driver: bad connection"))
+	helpers.FailOnError(t, err)
+
+	succeeded, failed, skipped, err := main.ReplayConsumerErrors(&failingHandler{}, memoryStorage, []string{
+		"-source", "db",
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, succeeded)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, 0, skipped)
+
+	consumerError, err := memoryStorage.ReadConsumerError("topic", 0, 0)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "driver: bad connection", consumerError.Error)
+}
+
+// gzipMessage returns the message compressed by gzip
+func gzipMessage(t *testing.T, message string) []byte {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write([]byte(message))
+	helpers.FailOnError(t, err)
+	helpers.FailOnError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+// TestReplayPartitionMissingLastMessage checks that replay of the partition
+// is finished even when the message with the last offset doesn't exist and
+// that compressed messages are selected by their organization too
+func TestReplayPartitionMissingLastMessage(t *testing.T) {
+	oldTimeout := *main.ReplayPollTimeoutPtr
+	*main.ReplayPollTimeoutPtr = 10 * time.Millisecond
+	defer func() { *main.ReplayPollTimeoutPtr = oldTimeout }()
+
+	memoryStorage := storage.NewMemoryStorage(storage.Configuration{})
+	handler := &consumer.KafkaConsumer{Storage: memoryStorage}
+
+	otherOrgMessage := strings.Replace(
+		testdata.ConsumerMessage, fmt.Sprintf(`"OrgID": %v`, testdata.OrgID), `"OrgID": 999`, 1,
+	)
+
+	messages := make(chan *sarama.ConsumerMessage, 2)
+	messages <- &sarama.ConsumerMessage{Offset: 0, Value: gzipMessage(t, testdata.ConsumerMessage)}
+	messages <- &sarama.ConsumerMessage{Offset: 1, Value: gzipMessage(t, otherOrgMessage)}
+
+	// messages with offsets 2-4 were deleted, the channel stays open
+	succeeded, failed, skipped, err := main.ReplayPartition(handler, messages, 5, []string{
+		"-org", fmt.Sprint(testdata.OrgID),
+	})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, 0, failed)
+	assert.Equal(t, 1, skipped)
+
+	count, err := memoryStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/RedHatInsights/insights-results-aggregator/conf"
 	"github.com/RedHatInsights/insights-results-aggregator/migration"
 	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 )
 
 var (
@@ -49,13 +50,17 @@ func startServer() error {
 
 	// try to retrieve the actual DB migration version
 	// and add it into the `params` map
-	currentVersion, err := migration.GetDBVersion(dbStorage.GetConnection())
-	if err != nil {
-		const msg = "Unable to retrieve DB migration version"
-		log.Error().Err(err).Msg(msg)
-		serverInstance.InfoParams["DB_version"] = msg
+	if sqlStorage, ok := dbStorage.(*storage.DBStorage); ok {
+		currentVersion, err := migration.GetDBVersion(sqlStorage.GetConnection())
+		if err != nil {
+			const msg = "Unable to retrieve DB migration version"
+			log.Error().Err(err).Msg(msg)
+			serverInstance.InfoParams["DB_version"] = msg
+		} else {
+			serverInstance.InfoParams["DB_version"] = strconv.Itoa(int(currentVersion))
+		}
 	} else {
-		serverInstance.InfoParams["DB_version"] = strconv.Itoa(int(currentVersion))
+		serverInstance.InfoParams["DB_version"] = "not available for in-memory storage"
 	}
 
 	err = serverInstance.Start(finishServerInstanceInitialization)
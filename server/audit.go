@@ -0,0 +1,143 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// names of query parameters used to filter audit events
+const (
+	auditUserIDParam   = "user_id"
+	auditClusterParam  = "cluster"
+	auditRuleIDParam   = "rule_id"
+	auditErrorKeyParam = "error_key"
+)
+
+// auditUserID returns the identifier of the user who made the request. It's
+// taken from the identity stored in request's context by the authentication
+// middleware, the fallback (usually the user from request's path) is used
+// when the authentication is disabled. When neither of them is known, the
+// placeholder user is returned, the same as for tokens without user.
+func auditUserID(request *http.Request, fallback types.UserID) types.UserID {
+	identity, ok := request.Context().Value(types.ContextKeyUser).(Identity)
+	if ok && identity.User.UserID != "" {
+		return identity.User.UserID
+	}
+
+	if fallback != "" {
+		return fallback
+	}
+
+	return unknownUserID
+}
+
+// withAuditUser fills the user who made the request into the events
+// describing the change. The events are written by the storage together with
+// the change itself, so the change and its audit log can't diverge.
+func withAuditUser(
+	request *http.Request, fallbackUserID types.UserID, events ...storage.AuditEvent,
+) []storage.AuditEvent {
+	userID := auditUserID(request, fallbackUserID)
+	for i := range events {
+		events[i].UserID = userID
+	}
+
+	return events
+}
+
+// withExpiration adds the expiration of rule disable to details of audit
+// event, the details are returned unchanged when the disable doesn't expire
+func withExpiration(details map[string]string, expiresAt time.Time) map[string]string {
+	if !expiresAt.IsZero() {
+		details[expiresAtParam] = expiresAt.UTC().Format(time.RFC3339)
+	}
+
+	return details
+}
+
+// readAuditEventsFilter reads the filter of audit events from request's
+// path and query. If it's not possible, it writes http error to the writer
+// and returns false
+func (server *HTTPServer) readAuditEventsFilter(
+	writer http.ResponseWriter, request *http.Request,
+) (storage.AuditEventsFilter, bool) {
+	var filter storage.AuditEventsFilter
+
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		return filter, false
+	}
+
+	successful = checkPermissions(writer, request, orgID, server.Config.Auth)
+	if !successful {
+		return filter, false
+	}
+
+	query := request.URL.Query()
+	filter = storage.AuditEventsFilter{
+		OrgID:    orgID,
+		UserID:   types.UserID(strings.TrimSpace(query.Get(auditUserIDParam))),
+		RuleID:   types.RuleID(strings.TrimSpace(query.Get(auditRuleIDParam))),
+		ErrorKey: types.ErrorKey(strings.TrimSpace(query.Get(auditErrorKeyParam))),
+	}
+
+	if cluster := strings.TrimSpace(query.Get(auditClusterParam)); cluster != "" {
+		clusterID, err := validateClusterName(cluster)
+		if err != nil {
+			handleServerError(writer, err)
+			return filter, false
+		}
+		filter.ClusterID = clusterID
+	}
+
+	return filter, true
+}
+
+// listOfAuditEvents returns one page of changes made by users of the
+// organization, optionally limited to one user, cluster or rule
+func (server *HTTPServer) listOfAuditEvents(writer http.ResponseWriter, request *http.Request) {
+	filter, successful := server.readAuditEventsFilter(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	options, successful := readListingOptions(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	events, nextCursor, err := server.Storage.ReadAuditEvents(filter, options)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read audit events")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, buildListingResponse("events", events, nextCursor))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustReadAuditEvents returns all audit events of the organization
+func mustReadAuditEvents(t *testing.T, mockStorage storage.Storage, orgID types.OrgID) []storage.AuditEvent {
+	events, _, err := mockStorage.ReadAuditEvents(storage.AuditEventsFilter{OrgID: orgID}, storage.ListingOptions{})
+	helpers.FailOnError(t, err)
+	return events
+}
+
+func TestListOfAuditEvents(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	createdAt := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	helpers.FailOnError(t, mockStorage.WriteAuditEvents([]storage.AuditEvent{
+		{
+			OrgID:     testdata.OrgID,
+			UserID:    "1",
+			Action:    storage.AuditActionDisableRule,
+			ClusterID: testdata.ClusterName,
+			RuleID:    testdata.Rule1ID,
+			ErrorKey:  testdata.ErrorKey1,
+			CreatedAt: createdAt,
+		},
+		{
+			OrgID:     testdata.OrgID,
+			UserID:    "2",
+			Action:    storage.AuditActionRateRule,
+			RuleID:    testdata.Rule1ID,
+			ErrorKey:  testdata.ErrorKey1,
+			Details:   map[string]string{"rating": "1"},
+			CreatedAt: createdAt,
+		},
+		{
+			OrgID:     testdata.Org2ID,
+			UserID:    "1",
+			Action:    storage.AuditActionRateRule,
+			CreatedAt: createdAt,
+		},
+	}))
+
+	_, cursor, err := mockStorage.ReadAuditEvents(
+		storage.AuditEventsFilter{OrgID: testdata.OrgID}, storage.ListingOptions{Limit: 1},
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AuditEventsEndpoint + "?limit=1",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"events":[{
+			"id":1,"org_id":1,"user_id":"1","action":"disable_rule","cluster":"` + string(testdata.ClusterName) + `",
+			"rule_id":"` + string(testdata.Rule1ID) + `","error_key":"` + string(testdata.ErrorKey1) + `",
+			"created_at":"2022-03-01T12:00:00Z"
+		}],"next_cursor":"` + cursor + `","status":"ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AuditEventsEndpoint + "?user_id=2",
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"events":[{
+			"id":2,"org_id":1,"user_id":"2","action":"rate_rule",
+			"rule_id":"` + string(testdata.Rule1ID) + `","error_key":"` + string(testdata.ErrorKey1) + `",
+			"details":{"rating":"1"},"created_at":"2022-03-01T12:00:00Z"
+		}],"status":"ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.AuditEventsEndpoint + "?cluster=%v",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.GetRandomClusterID()},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"events":[],"status":"ok"}`,
+	})
+}
+
+func TestListOfAuditEventsBadParams(t *testing.T) {
+	for query, expectedError := range map[string]string{
+		"?cluster=not-uuid": "Error during parsing param 'cluster' with value 'not-uuid'. Error: 'invalid UUID length: 8'",
+		"?sort_by=cluster":  "Error during validating param 'sort_by' with value 'cluster'. Error: 'unsupported sort field, expected one of: id'",
+	} {
+		helpers.AssertAPIRequest(t, storage.NewMemoryStorage(storage.Configuration{}), nil, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.AuditEventsEndpoint + query,
+			EndpointArgs: []interface{}{testdata.OrgID},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"status":"` + expectedError + `"}`,
+		})
+	}
+}
+
+// TestAuditEventsWrittenByChanges checks that changes made through REST API
+// are recorded together with the user from request's path when the
+// authentication is disabled, or the placeholder user when the path doesn't
+// contain any
+func TestAuditEventsWrittenByChanges(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mustWriteEmptyReports(t, mockStorage, testdata.OrgID, testdata.ClusterName)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.LikeRuleEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint,
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.Rating,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body:         `{"rule": "rule_module|error_key", "rating": -1}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	events := mustReadAuditEvents(t, mockStorage, testdata.OrgID)
+	assert.Len(t, events, 3)
+
+	assert.Equal(t, storage.AuditActionVote, events[0].Action)
+	assert.Equal(t, testdata.UserID, events[0].UserID)
+	assert.Equal(t, testdata.ClusterName, events[0].ClusterID)
+	assert.Equal(t, map[string]string{"vote": "1", "message": ""}, events[0].Details)
+
+	assert.Equal(t, storage.AuditActionDisableRule, events[1].Action)
+	assert.Equal(t, types.UserID("0"), events[1].UserID)
+	assert.Equal(t, testdata.Rule1ID, events[1].RuleID)
+
+	assert.Equal(t, storage.AuditActionRateRule, events[2].Action)
+	assert.Equal(t, types.RuleID("rule_module"), events[2].RuleID)
+	assert.Equal(t, map[string]string{"rating": "-1"}, events[2].Details)
+}
+
+// TestChangeWithoutUserAudited checks that changes made through endpoints
+// without user in their path are accepted when the authentication is
+// disabled and they are audited with the placeholder user
+func TestChangeWithoutUserAudited(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mustWriteEmptyReports(t, mockStorage, testdata.OrgID, testdata.ClusterName)
+
+	for _, request := range []helpers.APIRequest{
+		{
+			Method:       http.MethodPut,
+			Endpoint:     server.DisableRuleForClusterEndpoint,
+			EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID},
+		},
+		{
+			Method:       http.MethodPut,
+			Endpoint:     server.DisableRuleSystemWide,
+			EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID},
+			Body:         `{"justification": "***justification***"}`,
+		},
+		{
+			Method:       http.MethodPost,
+			Endpoint:     server.Rating,
+			EndpointArgs: []interface{}{testdata.OrgID},
+			Body:         `{"rule": "rule_module|error_key", "rating": -1}`,
+		},
+	} {
+		request := request
+		helpers.AssertAPIRequest(t, mockStorage, nil, &request, &helpers.APIResponse{
+			StatusCode: http.StatusOK,
+		})
+	}
+
+	events := mustReadAuditEvents(t, mockStorage, testdata.OrgID)
+	assert.Len(t, events, 3)
+	for _, event := range events {
+		assert.Equal(t, types.UserID("0"), event.UserID, event.Action)
+	}
+}
+
+// TestAuditEventsUserFromIdentity checks that the user is taken from the
+// identity of the authenticated request
+func TestAuditEventsUserFromIdentity(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+
+	helpers.AssertAPIRequest(t, mockStorage, &configAuth, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleSystemWide,
+		EndpointArgs: []interface{}{testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID},
+		Body:         `{"justification": "***justification***"}`,
+		XRHIdentity: helpers.MakeXRHTokenString(t, &types.Token{
+			Identity: ctypes.Identity{
+				OrgID: testdata.OrgID,
+				User:  ctypes.User{UserID: "42"},
+			},
+		}),
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+	})
+
+	events := mustReadAuditEvents(t, mockStorage, testdata.OrgID)
+	assert.Len(t, events, 1)
+	assert.Equal(t, storage.AuditActionDisableRuleSystemWide, events[0].Action)
+	assert.Equal(t, types.UserID("42"), events[0].UserID)
+	assert.Equal(t, map[string]string{"justification": "***justification***"}, events[0].Details)
+}
@@ -34,6 +34,8 @@ import (
 const (
 	// #nosec G101
 	malformedTokenMessage = "Malformed authentication token"
+	// unknownUserID is the placeholder user of requests without user
+	unknownUserID = "0"
 )
 
 // Identity contains internal user info
@@ -115,7 +117,7 @@ func (server *HTTPServer) Authentication(next http.Handler, noAuthURLs []string)
 		}
 
 		if tk.Identity.User.UserID == "" {
-			tk.Identity.User.UserID = "0"
+			tk.Identity.User.UserID = unknownUserID
 		}
 
 		// Everything went well, proceed with the request and set the
@@ -25,6 +25,8 @@ type Configuration struct {
 	Auth                         bool   `mapstructure:"auth" toml:"auth"`
 	AuthType                     string `mapstructure:"auth_type" toml:"auth_type"`
 	MaximumFeedbackMessageLength int    `mapstructure:"maximum_feedback_message_length" toml:"maximum_feedback_message_length"`
-	// OrgOverviewLimitHours is temporary until request param parsing, but lets make it atleast configurable
+	// OrgOverviewLimitHours is the default time window of the list of
+	// clusters for organization, it's used when since query parameter is
+	// not set
 	OrgOverviewLimitHours int64 `mapstructure:"org_overview_limit_hours" toml:"org_overview_limit_hours"`
 }
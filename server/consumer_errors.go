@@ -0,0 +1,199 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+const (
+	// ConsumerErrorsResponse constant defines the name of response field
+	ConsumerErrorsResponse = "consumer_errors"
+	// ConsumerErrorResponse constant defines the name of response field
+	ConsumerErrorResponse = "consumer_error"
+
+	// DefaultConsumerErrorsLimit is the number of consumer errors returned
+	// when limit query parameter is not set
+	DefaultConsumerErrorsLimit = 100
+	// MaxConsumerErrorsLimit is the maximum number of consumer errors
+	// returned in one response
+	MaxConsumerErrorsLimit = 1000
+
+	// names of query parameters used to filter consumer errors
+	topicParam      = "topic"
+	partitionParam  = "partition"
+	offsetFromParam = "offset_from"
+	offsetToParam   = "offset_to"
+	fromParam       = "from"
+	toParam         = "to"
+	errorParam      = "error"
+	limitParam      = "limit"
+	offsetParam     = "offset"
+
+	// notSet is used as default value of optional numeric query parameters
+	notSet = -1
+)
+
+// ConsumerErrorDetail represents one consumer error including the key and
+// the raw message that the consumer was not able to process
+type ConsumerErrorDetail struct {
+	storage.ConsumerError
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+// readConsumerErrorsFilter reads the filter for consumer errors from
+// request's query. If it's not possible, it writes http error to the writer
+// and returns false
+func readConsumerErrorsFilter(
+	writer http.ResponseWriter, request *http.Request,
+) (storage.ConsumerErrorsFilter, bool) {
+	filter := storage.ConsumerErrorsFilter{
+		Topic:     strings.TrimSpace(request.URL.Query().Get(topicParam)),
+		ErrorText: request.URL.Query().Get(errorParam),
+	}
+
+	partition, successful := readNonNegativeIntQueryParam(writer, request, partitionParam, 32, notSet)
+	if !successful {
+		return filter, false
+	}
+	if partition != notSet {
+		partition32 := int32(partition)
+		filter.Partition = &partition32
+	}
+
+	offsetFrom, successful := readNonNegativeIntQueryParam(writer, request, offsetFromParam, 64, notSet)
+	if !successful {
+		return filter, false
+	}
+	if offsetFrom != notSet {
+		filter.OffsetFrom = &offsetFrom
+	}
+
+	offsetTo, successful := readNonNegativeIntQueryParam(writer, request, offsetToParam, 64, notSet)
+	if !successful {
+		return filter, false
+	}
+	if offsetTo != notSet {
+		filter.OffsetTo = &offsetTo
+	}
+
+	filter.ConsumedFrom, successful = readTimeQueryParam(writer, request, fromParam)
+	if !successful {
+		return filter, false
+	}
+
+	filter.ConsumedTo, successful = readTimeQueryParam(writer, request, toParam)
+	if !successful {
+		return filter, false
+	}
+
+	limit, successful := readNonNegativeIntQueryParam(writer, request, limitParam, 64, DefaultConsumerErrorsLimit)
+	if !successful {
+		return filter, false
+	}
+	if limit == 0 || limit > MaxConsumerErrorsLimit {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  limitParam,
+			ParamValue: request.URL.Query().Get(limitParam),
+			ErrString:  "limit must be between 1 and 1000",
+		})
+		return filter, false
+	}
+	filter.Limit = int(limit)
+
+	offset, successful := readNonNegativeIntQueryParam(writer, request, offsetParam, 64, 0)
+	if !successful {
+		return filter, false
+	}
+	filter.Offset = int(offset)
+
+	return filter, true
+}
+
+// listOfConsumerErrors returns a page of messages that the consumer was not
+// able to process. Messages can be filtered by topic, partition, offset
+// range, time when they were consumed and by a substring of the error.
+// Raw messages are not part of the response.
+func (server *HTTPServer) listOfConsumerErrors(writer http.ResponseWriter, request *http.Request) {
+	filter, successful := readConsumerErrorsFilter(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	consumerErrors, err := server.Storage.ReadConsumerErrors(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read consumer errors")
+		handleServerError(writer, err)
+		return
+	}
+
+	response := responses.BuildOkResponse()
+	response[ConsumerErrorsResponse] = consumerErrors
+	response[limitParam] = filter.Limit
+	response[offsetParam] = filter.Offset
+
+	err = responses.SendOK(writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// readConsumerError returns the consumer error including the raw message
+// for given topic, partition and offset
+func (server *HTTPServer) readConsumerError(writer http.ResponseWriter, request *http.Request) {
+	topic, err := getRouterParam(request, topicParam)
+	if err != nil {
+		handleServerError(writer, err)
+		return
+	}
+
+	partition, successful := readNonNegativeIntRouterParam(writer, request, partitionParam, 32)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	offset, successful := readNonNegativeIntRouterParam(writer, request, offsetParam, 64)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	consumerError, err := server.Storage.ReadConsumerError(topic, int32(partition), offset)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read consumer error")
+		handleServerError(writer, err)
+		return
+	}
+
+	detail := ConsumerErrorDetail{
+		ConsumerError: consumerError,
+		Key:           string(consumerError.Key),
+		Message:       string(consumerError.Message),
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ConsumerErrorResponse, detail))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+)
+
+const consumerErrorsTopic = "ccx.ocp.results"
+
+func writeConsumerErrors(t *testing.T, mockStorage storage.Storage) {
+	for offset, consumerErr := range []string{
+		"unexpected end of JSON input",
+		"improper report structure, missing key reports",
+		"driver: bad connection",
+	} {
+		err := mockStorage.WriteConsumerError(&sarama.ConsumerMessage{
+			Topic:     consumerErrorsTopic,
+			Partition: 0,
+			Offset:    int64(offset),
+			Key:       []byte("key"),
+			Value:     []byte(`{"OrgID": 1}`),
+			Timestamp: time.Now().Add(-time.Hour).UTC(),
+		}, errors.New(consumerErr))
+		helpers.FailOnError(t, err)
+	}
+}
+
+func TestListOfConsumerErrorsBadParams(t *testing.T) {
+	for query, status := range map[string]string{
+		"?partition=-1": "Error during parsing param 'partition' with value '-1'. Error: 'non-negative integer expected'",
+		"?partition=4294967296": "Error during parsing param 'partition' with value '4294967296'. " +
+			"Error: 'non-negative integer expected'",
+		"?offset_from=x": "Error during parsing param 'offset_from' with value 'x'. Error: 'non-negative integer expected'",
+		"?from=yesterday": "Error during parsing param 'from' with value 'yesterday'. " +
+			"Error: 'timestamp in RFC3339 format expected'",
+		"?limit=0":    "Error during parsing param 'limit' with value '0'. Error: 'limit must be between 1 and 1000'",
+		"?limit=1001": "Error during parsing param 'limit' with value '1001'. Error: 'limit must be between 1 and 1000'",
+	} {
+		expectedBody, err := json.Marshal(map[string]string{"status": status})
+		helpers.FailOnError(t, err)
+
+		helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+			Method:   http.MethodGet,
+			Endpoint: server.ConsumerErrorsEndpoint + query,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       string(expectedBody),
+		})
+	}
+}
+
+func TestListOfConsumerErrors(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeConsumerErrors(t, mockStorage)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+
+	expectedBody := func(page []storage.ConsumerError, limit, offset int) string {
+		body, err := json.Marshal(map[string]interface{}{
+			"status":          "ok",
+			"consumer_errors": page,
+			"limit":           limit,
+			"offset":          offset,
+		})
+		helpers.FailOnError(t, err)
+		return string(body)
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       expectedBody(consumerErrors, server.DefaultConsumerErrorsLimit, 0),
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ConsumerErrorsEndpoint + "?topic=%v&partition=0&offset_from=1&error=%v&limit=1",
+		EndpointArgs: []interface{}{consumerErrorsTopic, "report"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       expectedBody(consumerErrors[1:2], 1, 0),
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint + "?limit=2&offset=2",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       expectedBody(consumerErrors[2:], 2, 2),
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ConsumerErrorsEndpoint + "?partition=1",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       expectedBody([]storage.ConsumerError{}, server.DefaultConsumerErrorsLimit, 0),
+	})
+}
+
+func TestReadConsumerError(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeConsumerErrors(t, mockStorage)
+
+	consumerError, err := mockStorage.ReadConsumerError(consumerErrorsTopic, 0, 1)
+	helpers.FailOnError(t, err)
+
+	expectedBody, err := json.Marshal(map[string]interface{}{
+		"status": "ok",
+		"consumer_error": server.ConsumerErrorDetail{
+			ConsumerError: consumerError,
+			Key:           "key",
+			Message:       `{"OrgID": 1}`,
+		},
+	})
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ConsumerErrorEndpoint,
+		EndpointArgs: []interface{}{consumerErrorsTopic, 0, 1},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(expectedBody),
+	})
+}
+
+func TestReadNonExistingConsumerError(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ConsumerErrorEndpoint,
+		EndpointArgs: []interface{}{consumerErrorsTopic, 0, 42},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body:       `{"status":"Item with ID ccx.ocp.results/0/42 was not found in the storage"}`,
+	})
+}
+
+func TestReadConsumerErrorBadOffset(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ConsumerErrorEndpoint,
+		EndpointArgs: []interface{}{consumerErrorsTopic, 0, "first"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status":"Error during parsing param 'offset' with value 'first'. Error: 'non-negative integer expected'"}`,
+	})
+}
@@ -31,6 +31,11 @@ const (
 	DeleteOrganizationsEndpoint = "organizations/{organizations}"
 	// DeleteClustersEndpoint deletes all {clusters}(comma separated array). DEBUG only
 	DeleteClustersEndpoint = "clusters/{clusters}"
+	// ConsumerErrorsEndpoint returns messages that the consumer was not able to process. DEBUG only
+	ConsumerErrorsEndpoint = "consumer_errors"
+	// ConsumerErrorEndpoint returns raw message that the consumer was not able to process
+	// identified by {topic}, {partition} and {offset}. DEBUG only
+	ConsumerErrorEndpoint = "consumer_errors/{topic}/{partition}/{offset}"
 	// OrganizationsEndpoint returns all organizations
 	OrganizationsEndpoint = "organizations"
 	// ReportEndpoint returns report for provided {organization}, {cluster}, and {user_id}
@@ -45,6 +50,10 @@ const (
 	// ReportForListOfClustersPayloadEndpoint returns the latest reports for the given list of clusters
 	// Reports that are going to be returned are specified by list of cluster IDs that is part of request body
 	ReportForListOfClustersPayloadEndpoint = "organizations/{org_id}/clusters/reports"
+	// ReportHistoryEndpoint returns a timeline of reports stored for provided {organization} and {cluster}
+	ReportHistoryEndpoint = "organizations/{org_id}/clusters/{cluster}/reports/history"
+	// ReportDiffEndpoint returns differences between two reports stored in the history for provided {organization} and {cluster}
+	ReportDiffEndpoint = "organizations/{org_id}/clusters/{cluster}/reports/diff"
 	// LikeRuleEndpoint likes rule with {rule_id} for {cluster} using current user(from auth header)
 	LikeRuleEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/organizations/{org_id}/users/{user_id}/like"
 	// DislikeRuleEndpoint dislikes rule with {rule_id} for {cluster} using current user(from auth header)
@@ -59,6 +68,10 @@ const (
 	DisableRuleForClusterEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/organizations/{org_id}/disable"
 	// EnableRuleForClusterEndpoint re-enables a rule for specified cluster
 	EnableRuleForClusterEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/organizations/{org_id}/enable"
+	// DisableRulesForClustersEndpoint disables rules for clusters given in the request body
+	DisableRulesForClustersEndpoint = "clusters/rules/organizations/{org_id}/users/{user_id}/disable"
+	// EnableRulesForClustersEndpoint re-enables rules for clusters given in the request body
+	EnableRulesForClustersEndpoint = "clusters/rules/organizations/{org_id}/users/{user_id}/enable"
 	// DisableRuleFeedbackEndpoint accepts a feedback from user when (s)he disables a rule
 	DisableRuleFeedbackEndpoint = "clusters/{cluster}/rules/{rule_id}/error_key/{error_key}/organizations/{org_id}/users/{user_id}/disable_feedback"
 	// ListOfDisabledRules returns a list of rules disabled from current account
@@ -89,12 +102,17 @@ const (
 	RecommendationsListEndpoint = "recommendations/organizations/{org_id}/users/{user_id}/list"
 	// ClustersRecommendationsListEndpoint receives a list of clusters in POST body and returns a list of clusters with lists of hitting recommendations
 	ClustersRecommendationsListEndpoint = "clusters/organizations/{org_id}/users/{user_id}/recommendations"
+	// RecommendationsSummaryEndpoint returns recommendations hitting any cluster of the organization aggregated per rule
+	RecommendationsSummaryEndpoint = "organizations/{org_id}/recommendations/summary"
 
 	// Rating accepts a list of ratings in the request body and store them in the database for the given user
 	Rating = "rules/organizations/{org_id}/rating"
 	// GetRating retrieves the rating for a specific rule and user
 	GetRating = "rules/{rule_selector}/organizations/{org_id}/rating"
 
+	// AuditEventsEndpoint returns changes (votes, rule toggles, ratings etc.) made by users of the organization
+	AuditEventsEndpoint = "organizations/{org_id}/audit"
+
 	// InfoEndpoint returns basic information about Insights Aggregator
 	// version, utils repository version, commit hash etc.
 	InfoEndpoint = "info"
@@ -110,6 +128,8 @@ func (server *HTTPServer) addDebugEndpointsToRouter(router *mux.Router) {
 	router.HandleFunc(apiPrefix+DeleteOrganizationsEndpoint, server.deleteOrganizations).Methods(http.MethodDelete)
 	router.HandleFunc(apiPrefix+DeleteClustersEndpoint, server.deleteClusters).Methods(http.MethodDelete)
 	router.HandleFunc(apiPrefix+GetVoteOnRuleEndpoint, server.getVoteOnRule).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ConsumerErrorsEndpoint, server.listOfConsumerErrors).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+ConsumerErrorEndpoint, server.readConsumerError).Methods(http.MethodGet)
 
 	// endpoints for pprof - needed for profiling, ie. usually in debug mode
 	router.PathPrefix("/debug/pprof/").Handler(http.DefaultServeMux)
@@ -135,12 +155,15 @@ func (server *HTTPServer) addEndpointsToRouter(router *mux.Router) {
 	router.HandleFunc(apiPrefix+ClustersForOrganizationEndpoint, server.listOfClustersForOrganization).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+ReportForListOfClustersEndpoint, server.reportForListOfClusters).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+ReportForListOfClustersPayloadEndpoint, server.reportForListOfClustersPayload).Methods(http.MethodPost)
+	router.HandleFunc(apiPrefix+ReportHistoryEndpoint, server.readReportHistoryForCluster).Methods(http.MethodGet, http.MethodOptions)
+	router.HandleFunc(apiPrefix+ReportDiffEndpoint, server.readReportDiffForCluster).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc(apiPrefix+ListOfDisabledRules, server.listOfDisabledRules).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+ListOfDisabledRulesForClusters, server.listOfDisabledRulesForClusters).Methods(http.MethodPost, http.MethodOptions)
 	router.HandleFunc(apiPrefix+ListOfDisabledRulesFeedback, server.listOfReasons).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+ListOfDisabledClusters, server.listOfDisabledClusters).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+Rating, server.setRuleRating).Methods(http.MethodPost)
 	router.HandleFunc(apiPrefix+GetRating, server.getRuleRating).Methods(http.MethodGet)
+	router.HandleFunc(apiPrefix+AuditEventsEndpoint, server.listOfAuditEvents).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+RuleClusterDetailEndpoint, server.RuleClusterDetailEndpoint).Methods(http.MethodGet)
 	router.HandleFunc(apiPrefix+InfoEndpoint, server.infoMap).Methods(http.MethodGet, http.MethodOptions)
 
@@ -168,6 +191,10 @@ func (server *HTTPServer) addRuleEnableDisableEndpointsToRouter(router *mux.Rout
 	router.HandleFunc(apiPrefix+EnableRuleForClusterEndpoint, server.enableRuleForCluster).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+DisableRuleFeedbackEndpoint, server.saveDisableFeedback).Methods(http.MethodPost)
 
+	// bulk disable functionality for several clusters
+	router.HandleFunc(apiPrefix+DisableRulesForClustersEndpoint, server.disableRulesForClusters).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc(apiPrefix+EnableRulesForClustersEndpoint, server.enableRulesForClusters).Methods(http.MethodPut, http.MethodOptions)
+
 	// system-wide (acknowledge) disable functionality
 	router.HandleFunc(apiPrefix+EnableRuleSystemWide, server.enableRuleSystemWide).Methods(http.MethodPut, http.MethodOptions)
 	router.HandleFunc(apiPrefix+DisableRuleSystemWide, server.disableRuleSystemWide).Methods(http.MethodPut, http.MethodOptions)
@@ -181,4 +208,5 @@ func (server *HTTPServer) addRuleEnableDisableEndpointsToRouter(router *mux.Rout
 func (server *HTTPServer) addInsightsAdvisorEndpointsToRouter(router *mux.Router, apiPrefix string) {
 	router.HandleFunc(apiPrefix+RecommendationsListEndpoint, server.getRecommendations).Methods(http.MethodPost, http.MethodOptions)
 	router.HandleFunc(apiPrefix+ClustersRecommendationsListEndpoint, server.getClustersRecommendationsList).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc(apiPrefix+RecommendationsSummaryEndpoint, server.getRecommendationsSummary).Methods(http.MethodGet, http.MethodPost, http.MethodOptions)
 }
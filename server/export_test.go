@@ -35,4 +35,5 @@ var (
 	ValidateClusterID         = validateClusterID
 	ConstructClusterNames     = constructClusterNames
 	FillInGeneratedReports    = fillInGeneratedReports
+	DiffReports               = diffReports
 )
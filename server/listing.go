@@ -0,0 +1,102 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+)
+
+const (
+	// MaxListingLimit is the maximum number of items returned in one page
+	// of organizations or clusters listing
+	MaxListingLimit = 1000
+
+	// NextCursorResponse constant defines the name of response field with
+	// the cursor of the next page
+	NextCursorResponse = "next_cursor"
+
+	// names of query parameters used to paginate, sort and filter listings
+	cursorParam    = "cursor"
+	sortByParam    = "sort_by"
+	sortOrderParam = "sort_order"
+	filterParam    = "filter"
+
+	// names of query parameters with the time window of listed clusters
+	sinceParam = "since"
+	untilParam = "until"
+
+	sortOrderAsc  = "asc"
+	sortOrderDesc = "desc"
+)
+
+// readListingOptions reads pagination, sorting and filtering of listings
+// from request's query. All parameters are optional, complete listing in
+// the default order is returned when none of them is set. If it's not
+// possible to read them, it writes http error to the writer and returns
+// false
+func readListingOptions(writer http.ResponseWriter, request *http.Request) (storage.ListingOptions, bool) {
+	query := request.URL.Query()
+	options := storage.ListingOptions{
+		Cursor: strings.TrimSpace(query.Get(cursorParam)),
+		SortBy: strings.TrimSpace(query.Get(sortByParam)),
+		Filter: query.Get(filterParam),
+	}
+
+	limit, successful := readNonNegativeIntQueryParam(writer, request, limitParam, 64, 0)
+	if !successful {
+		return options, false
+	}
+	if query.Get(limitParam) != "" && (limit == 0 || limit > MaxListingLimit) {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  limitParam,
+			ParamValue: query.Get(limitParam),
+			ErrString:  fmt.Sprintf("limit must be between 1 and %d", MaxListingLimit),
+		})
+		return options, false
+	}
+	options.Limit = int(limit)
+
+	switch sortOrder := strings.TrimSpace(query.Get(sortOrderParam)); sortOrder {
+	case "", sortOrderAsc:
+	case sortOrderDesc:
+		options.Descending = true
+	default:
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  sortOrderParam,
+			ParamValue: sortOrder,
+			ErrString:  "sort order must be either asc or desc",
+		})
+		return options, false
+	}
+
+	return options, true
+}
+
+// buildListingResponse returns the response with one page of the listing,
+// cursor of the next page is added when there are more items
+func buildListingResponse(dataField string, data interface{}, nextCursor string) map[string]interface{} {
+	response := responses.BuildOkResponseWithData(dataField, data)
+	if nextCursor != "" {
+		response[NextCursorResponse] = nextCursor
+	}
+
+	return response
+}
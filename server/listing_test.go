@@ -0,0 +1,176 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func mustWriteEmptyReports(t *testing.T, mockStorage storage.Storage, orgID types.OrgID, clusters ...types.ClusterName) {
+	for _, clusterName := range clusters {
+		helpers.FailOnError(t, mockStorage.WriteReportForCluster(
+			orgID, clusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+			testdata.LastCheckedAt, time.Now(), time.Now(), testdata.KafkaOffset,
+		))
+	}
+}
+
+func TestListOfOrganizationsPagination(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mustWriteEmptyReports(t, mockStorage, testdata.OrgID, testdata.GetRandomClusterID())
+	mustWriteEmptyReports(t, mockStorage, testdata.Org2ID, testdata.GetRandomClusterID())
+
+	_, cursor, err := mockStorage.ListOfOrgsPage(storage.ListingOptions{Limit: 1})
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.OrganizationsEndpoint + "?limit=1",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"organizations":[1],"next_cursor":"` + cursor + `","status":"ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.OrganizationsEndpoint + "?limit=1&cursor=" + cursor,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"organizations":[2],"status":"ok"}`,
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.OrganizationsEndpoint + "?sort_order=desc",
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"organizations":[2,1],"status":"ok"}`,
+	})
+}
+
+func TestListOfClustersForOrganizationFilterAndSort(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mustWriteEmptyReports(t, mockStorage, testdata.OrgID, testdata.ClusterName, testdata.GetRandomClusterID())
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ClustersForOrganizationEndpoint + "?filter=%v&sort_by=reported_at",
+		EndpointArgs: []interface{}{testdata.OrgID, string(testdata.ClusterName)[:8]},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"clusters":["` + string(testdata.ClusterName) + `"],"status":"ok"}`,
+	})
+}
+
+func TestListOfClustersForOrganizationBadListingOptions(t *testing.T) {
+	for query, expectedError := range map[string]string{
+		"?limit=0":           "Error during parsing param 'limit' with value '0'. Error: 'limit must be between 1 and 1000'",
+		"?limit=1001":        "Error during parsing param 'limit' with value '1001'. Error: 'limit must be between 1 and 1000'",
+		"?sort_order=up":     "Error during parsing param 'sort_order' with value 'up'. Error: 'sort order must be either asc or desc'",
+		"?sort_by=unknown":   "Error during validating param 'sort_by' with value 'unknown'. Error: 'unsupported sort field, expected one of: cluster, reported_at, last_checked_at'",
+		"?cursor=not-cursor": "Error during validating param 'cursor' with value 'not-cursor'. Error: 'invalid cursor'",
+	} {
+		helpers.AssertAPIRequest(t, storage.NewMemoryStorage(storage.Configuration{}), nil, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.ClustersForOrganizationEndpoint + query,
+			EndpointArgs: []interface{}{testdata.OrgID},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"status":"` + expectedError + `"}`,
+		})
+	}
+}
+
+func TestRuleClusterDetailEndpointPagination(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	clusters := []types.ClusterName{
+		"aaaaaaaa-0000-0000-0000-000000000000",
+		"bbbbbbbb-0000-0000-0000-000000000000",
+	}
+	for _, clusterName := range clusters {
+		helpers.FailOnError(t, mockStorage.WriteRecommendationsForCluster(
+			testdata.OrgID, clusterName, testdata.Report2Rules, types.Timestamp(testdata.LastCheckedAt.Format(time.RFC3339)),
+		))
+	}
+
+	_, cursor, err := mockStorage.ListOfClustersForOrgSpecificRulePage(
+		testdata.OrgID, types.RuleSelector(testdata.Rule1CompositeID), nil, storage.ListingOptions{Limit: 1},
+	)
+	helpers.FailOnError(t, err)
+
+	lastSeen := testdata.LastCheckedAt.Format(time.RFC3339)
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RuleClusterDetailEndpoint + "?limit=1",
+		EndpointArgs: []interface{}{testdata.Rule1CompositeID, testdata.OrgID, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"clusters":[{"cluster":"` + string(clusters[0]) + `","cluster_name":"","last_checked_at":"` + lastSeen +
+			`","impacted":"` + lastSeen + `","meta":{"cluster_version":""}}],"next_cursor":"` + cursor + `","status":"ok"}`,
+	})
+}
+
+func TestListOfClustersForOrganizationTimeWindow(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	// OrgOverviewLimitHours is 2 in the test configuration
+	helpers.FailOnError(t, mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+		testdata.LastCheckedAt, testdata.LastCheckedAt, time.Now().Add(-3*time.Hour), testdata.KafkaOffset,
+	))
+
+	for query, expectedClusters := range map[string]string{
+		"":                            `[]`,
+		"?since=4h":                   `["` + string(testdata.ClusterName) + `"]`,
+		"?since=4h&until=1h":          `["` + string(testdata.ClusterName) + `"]`,
+		"?since=4h&until=200m":        `[]`,
+		"?since=2000-01-01T00:00:00Z": `["` + string(testdata.ClusterName) + `"]`,
+		"?since=2000-01-01T00:00:00Z&until=2000-01-02T00:00:00Z": `[]`,
+	} {
+		helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.ClustersForOrganizationEndpoint + query,
+			EndpointArgs: []interface{}{testdata.OrgID},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusOK,
+			Body:       `{"clusters":` + expectedClusters + `,"status":"ok"}`,
+		})
+	}
+}
+
+func TestListOfClustersForOrganizationBadTimeWindow(t *testing.T) {
+	for query, expectedError := range map[string]string{
+		"?since=yesterday":   "Error during parsing param 'since' with value 'yesterday'. Error: 'timestamp in RFC3339 format or non-negative duration expected'",
+		"?until=-1h":         "Error during parsing param 'until' with value '-1h'. Error: 'timestamp in RFC3339 format or non-negative duration expected'",
+		"?since=1h&until=2h": "Error during parsing param 'until' with value '2h'. Error: 'until must not be before since'",
+	} {
+		helpers.AssertAPIRequest(t, storage.NewMemoryStorage(storage.Configuration{}), nil, &helpers.APIRequest{
+			Method:       http.MethodGet,
+			Endpoint:     server.ClustersForOrganizationEndpoint + query,
+			EndpointArgs: []interface{}{testdata.OrgID},
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"status":"` + expectedError + `"}`,
+		})
+	}
+}
@@ -16,9 +16,12 @@ package server
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/RedHatInsights/insights-operator-utils/responses"
 	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 )
 
 func (server *HTTPServer) setRuleRating(writer http.ResponseWriter, request *http.Request) {
@@ -44,8 +47,16 @@ func (server *HTTPServer) setRuleRating(writer http.ResponseWriter, request *htt
 		return
 	}
 
+	auditEvents := withAuditUser(request, "", storage.AuditEvent{
+		OrgID:    orgID,
+		Action:   storage.AuditActionRateRule,
+		RuleID:   ruleID,
+		ErrorKey: errorKey,
+		Details:  map[string]string{"rating": strconv.Itoa(int(rating.Rating))},
+	})
+
 	// Store to the db
-	err = server.Storage.RateOnRule(orgID, ruleID, errorKey, rating.Rating)
+	err = server.Storage.RateOnRule(orgID, ruleID, errorKey, rating.Rating, auditEvents...)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to store rating")
 		handleServerError(writer, err)
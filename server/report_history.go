@@ -0,0 +1,240 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+const (
+	// ReportHistoryResponse constant defines the name of response field
+	ReportHistoryResponse = "history"
+	// ReportDiffResponse constant defines the name of response field
+	ReportDiffResponse = "diff"
+
+	// names of query parameters used to select reports to be compared
+	diffFromParam = "from"
+	diffToParam   = "to"
+)
+
+// ChangedRule represents a rule that hits the cluster in both compared
+// reports, but with different template data
+type ChangedRule struct {
+	Module          types.RuleID    `json:"component"`
+	ErrorKey        types.ErrorKey  `json:"key"`
+	OldTemplateData json.RawMessage `json:"old_details"`
+	NewTemplateData json.RawMessage `json:"new_details"`
+}
+
+// ReportDiff represents differences between two reports stored for one
+// cluster. From is empty when the newer report is the first one stored.
+type ReportDiff struct {
+	From        types.Timestamp    `json:"from"`
+	To          types.Timestamp    `json:"to"`
+	Appeared    []types.ReportItem `json:"appeared"`
+	Disappeared []types.ReportItem `json:"disappeared"`
+	Changed     []ChangedRule      `json:"changed"`
+}
+
+// readReportHistoryForCluster returns a timeline of reports stored for
+// given cluster. Each item of the timeline contains the time when the
+// cluster was checked and list of rules that were hitting at that time.
+func (server *HTTPServer) readReportHistoryForCluster(writer http.ResponseWriter, request *http.Request) {
+	clusterName, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	history, err := server.Storage.ReadReportHistoryForCluster(orgID, clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read report history for cluster")
+		handleServerError(writer, err)
+		return
+	}
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportHistoryResponse, history))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// readReportDiffForCluster compares two reports stored in the history for
+// given cluster and returns rules that appeared, disappeared, or changed
+// its template data. Reports are selected by `from` and `to` query
+// parameters, the report that was current at given time (i.e. the latest one
+// checked at that time or before) is used. When `to` is not set, the latest
+// report is used. When `from` is not set, the report preceding the
+// `to` one is used.
+func (server *HTTPServer) readReportDiffForCluster(writer http.ResponseWriter, request *http.Request) {
+	clusterName, successful := readClusterName(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	from, successful := readTimeQueryParam(writer, request, diffFromParam)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	to, successful := readTimeQueryParam(writer, request, diffToParam)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	history, err := server.Storage.ReadReportHistoryForCluster(orgID, clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read report history for cluster")
+		handleServerError(writer, err)
+		return
+	}
+
+	toIndex := len(history) - 1
+	if !to.IsZero() {
+		toIndex = findReportInHistory(history, to)
+		if toIndex < 0 {
+			handleServerError(writer, &types.ItemNotFoundError{
+				ItemID: fmt.Sprintf("%v/%v/%v", orgID, clusterName, to.UTC().Format(time.RFC3339)),
+			})
+			return
+		}
+	}
+
+	var older storage.ReportHistoryItem
+	if from.IsZero() {
+		if toIndex > 0 {
+			older = history[toIndex-1]
+		}
+	} else {
+		fromIndex := findReportInHistory(history, from)
+		if fromIndex < 0 {
+			handleServerError(writer, &types.ItemNotFoundError{
+				ItemID: fmt.Sprintf("%v/%v/%v", orgID, clusterName, from.UTC().Format(time.RFC3339)),
+			})
+			return
+		}
+		older = history[fromIndex]
+	}
+
+	diff := diffReports(older, history[toIndex])
+
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportDiffResponse, diff))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// findReportInHistory returns index of report that was current at given
+// time, i.e. the latest report checked at that time or before, or -1 when
+// there is no such report in the history. History is expected to be ordered
+// from the oldest report to the newest one.
+func findReportInHistory(history []storage.ReportHistoryItem, lastChecked time.Time) int {
+	found := -1
+	for i, item := range history {
+		itemLastChecked, err := time.Parse(time.RFC3339, string(item.LastCheckedAt))
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to parse timestamp stored in report history")
+			continue
+		}
+
+		if itemLastChecked.After(lastChecked) {
+			break
+		}
+		found = i
+	}
+
+	return found
+}
+
+// diffReports computes rules that appeared, disappeared, or changed
+// template data between older and newer report
+func diffReports(older, newer storage.ReportHistoryItem) ReportDiff {
+	diff := ReportDiff{
+		From:        older.LastCheckedAt,
+		To:          newer.LastCheckedAt,
+		Appeared:    []types.ReportItem{},
+		Disappeared: []types.ReportItem{},
+		Changed:     []ChangedRule{},
+	}
+
+	olderRules := make(map[string]types.ReportItem, len(older.HitRules))
+	for _, rule := range older.HitRules {
+		olderRules[string(rule.Module)+"|"+string(rule.ErrorKey)] = rule
+	}
+
+	newerRules := make(map[string]bool, len(newer.HitRules))
+	for _, rule := range newer.HitRules {
+		key := string(rule.Module) + "|" + string(rule.ErrorKey)
+		newerRules[key] = true
+
+		olderRule, found := olderRules[key]
+		switch {
+		case !found:
+			diff.Appeared = append(diff.Appeared, rule)
+		case !sameTemplateData(olderRule.TemplateData, rule.TemplateData):
+			diff.Changed = append(diff.Changed, ChangedRule{
+				Module:          rule.Module,
+				ErrorKey:        rule.ErrorKey,
+				OldTemplateData: olderRule.TemplateData,
+				NewTemplateData: rule.TemplateData,
+			})
+		}
+	}
+
+	for _, rule := range older.HitRules {
+		if !newerRules[string(rule.Module)+"|"+string(rule.ErrorKey)] {
+			diff.Disappeared = append(diff.Disappeared, rule)
+		}
+	}
+
+	return diff
+}
+
+// sameTemplateData compares template data semantically, so different
+// formatting of the same JSON is not considered to be a change
+func sameTemplateData(first, second json.RawMessage) bool {
+	var firstValue, secondValue interface{}
+
+	if json.Unmarshal(first, &firstValue) != nil || json.Unmarshal(second, &secondValue) != nil {
+		return string(first) == string(second)
+	}
+
+	return reflect.DeepEqual(firstValue, secondValue)
+}
@@ -0,0 +1,306 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func TestReadReportHistoryBadOrgID(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint,
+		EndpointArgs: []interface{}{"non-int", testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'org_id' with value 'non-int'. Error: 'unsigned integer expected'"
+		}`,
+	})
+}
+
+func TestReadReportHistoryBadClusterName(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.BadClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body:       `{"status": "Error during parsing param 'cluster' with value 'aaaa'. Error: 'invalid UUID length: 4'"}`,
+	})
+}
+
+func TestReadNonExistingReportHistory(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body: fmt.Sprintf(
+			`{"status":"Item with ID %v/%v was not found in the storage"}`, testdata.OrgID, testdata.ClusterName,
+		),
+	})
+}
+
+func TestReadReportHistory(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report0Rules,
+		testdata.ReportEmptyRulesParsed,
+		testdata.LastCheckedAt,
+		testdata.LastCheckedAt,
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportHistoryEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"history": [
+				{
+					"last_checked_at": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `",
+					"reported_at": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `",
+					"gathered_at": "` + testdata.LastCheckedAt.UTC().Format(time.RFC3339) + `",
+					"rules": []
+				}
+			]
+		}`,
+	})
+}
+
+func TestDiffReports(t *testing.T) {
+	older := storage.ReportHistoryItem{
+		LastCheckedAt: "2020-01-01T00:00:00Z",
+		HitRules: []types.ReportItem{
+			{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: json.RawMessage(`{"a": 1}`)},
+			{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: json.RawMessage(`{"b": 2}`)},
+			{Module: testdata.Rule3ID, ErrorKey: testdata.ErrorKey3, TemplateData: json.RawMessage(`{"c": 3}`)},
+		},
+	}
+	newer := storage.ReportHistoryItem{
+		LastCheckedAt: "2020-01-02T00:00:00Z",
+		HitRules: []types.ReportItem{
+			// the same template data, just formatted differently
+			{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: json.RawMessage(`{"a":1}`)},
+			{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, TemplateData: json.RawMessage(`{"b": 42}`)},
+			{Module: testdata.Rule4ID, ErrorKey: testdata.ErrorKey4, TemplateData: json.RawMessage(`{}`)},
+		},
+	}
+
+	diff := server.DiffReports(older, newer)
+
+	assert.Equal(t, older.LastCheckedAt, diff.From)
+	assert.Equal(t, newer.LastCheckedAt, diff.To)
+	assert.Equal(t, []types.ReportItem{newer.HitRules[2]}, diff.Appeared)
+	assert.Equal(t, []types.ReportItem{older.HitRules[2]}, diff.Disappeared)
+	assert.Equal(t, []server.ChangedRule{{
+		Module:          testdata.Rule2ID,
+		ErrorKey:        testdata.ErrorKey2,
+		OldTemplateData: json.RawMessage(`{"b": 2}`),
+		NewTemplateData: json.RawMessage(`{"b": 42}`),
+	}}, diff.Changed)
+}
+
+func TestDiffReportsNoPreviousReport(t *testing.T) {
+	newer := storage.ReportHistoryItem{
+		LastCheckedAt: "2020-01-02T00:00:00Z",
+		HitRules: []types.ReportItem{
+			{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, TemplateData: json.RawMessage(`{}`)},
+		},
+	}
+
+	diff := server.DiffReports(storage.ReportHistoryItem{}, newer)
+
+	assert.Equal(t, types.Timestamp(""), diff.From)
+	assert.Equal(t, newer.HitRules, diff.Appeared)
+	assert.Empty(t, diff.Disappeared)
+	assert.Empty(t, diff.Changed)
+}
+
+func TestReadReportDiffBadTimestamp(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportDiffEndpoint + "?from=%v",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, "yesterday"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{
+			"status": "Error during parsing param 'from' with value 'yesterday'. Error: 'timestamp in RFC3339 format expected'"
+		}`,
+	})
+}
+
+func TestReadNonExistingReportDiff(t *testing.T) {
+	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportDiffEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+		Body: fmt.Sprintf(
+			`{"status":"Item with ID %v/%v was not found in the storage"}`, testdata.OrgID, testdata.ClusterName,
+		),
+	})
+}
+
+func TestReadReportDiff(t *testing.T) {
+	mockStorage, closer := helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	firstChecked := testdata.LastCheckedAt.UTC()
+	secondChecked := firstChecked.Add(time.Hour)
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report0Rules, testdata.ReportEmptyRulesParsed,
+		firstChecked, firstChecked, firstChecked, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed,
+		secondChecked, secondChecked, secondChecked, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	// previous vs. current report
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportDiffEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"diff": {
+				"from": "` + firstChecked.Format(time.RFC3339) + `",
+				"to": "` + secondChecked.Format(time.RFC3339) + `",
+				"appeared": [
+					{
+						"component": "` + string(testdata.Rule1ID) + `",
+						"key": "` + testdata.ErrorKey1 + `",
+						"details": ` + helpers.ToJSONString(testdata.Rule1ExtraData) + `
+					},
+					{
+						"component": "` + string(testdata.Rule2ID) + `",
+						"key": "` + testdata.ErrorKey2 + `",
+						"details": ` + helpers.ToJSONString(testdata.Rule2ExtraData) + `
+					}
+				],
+				"disappeared": [],
+				"changed": []
+			}
+		}`,
+	})
+
+	// explicitly selected reports in reversed order
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ReportDiffEndpoint + "?from=%v&to=%v",
+		EndpointArgs: []interface{}{
+			testdata.OrgID, testdata.ClusterName,
+			secondChecked.Format(time.RFC3339), firstChecked.Format(time.RFC3339),
+		},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"diff": {
+				"from": "` + secondChecked.Format(time.RFC3339) + `",
+				"to": "` + firstChecked.Format(time.RFC3339) + `",
+				"appeared": [],
+				"disappeared": [
+					{
+						"component": "` + string(testdata.Rule1ID) + `",
+						"key": "` + testdata.ErrorKey1 + `",
+						"details": ` + helpers.ToJSONString(testdata.Rule1ExtraData) + `
+					},
+					{
+						"component": "` + string(testdata.Rule2ID) + `",
+						"key": "` + testdata.ErrorKey2 + `",
+						"details": ` + helpers.ToJSONString(testdata.Rule2ExtraData) + `
+					}
+				],
+				"changed": []
+			}
+		}`,
+	})
+
+	// reports that were current at given times
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ReportDiffEndpoint + "?from=%v&to=%v",
+		EndpointArgs: []interface{}{
+			testdata.OrgID, testdata.ClusterName,
+			firstChecked.Add(time.Minute).Format(time.RFC3339), secondChecked.Add(time.Hour).Format(time.RFC3339),
+		},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"diff": {
+				"from": "` + firstChecked.Format(time.RFC3339) + `",
+				"to": "` + secondChecked.Format(time.RFC3339) + `",
+				"appeared": [
+					{
+						"component": "` + string(testdata.Rule1ID) + `",
+						"key": "` + testdata.ErrorKey1 + `",
+						"details": ` + helpers.ToJSONString(testdata.Rule1ExtraData) + `
+					},
+					{
+						"component": "` + string(testdata.Rule2ID) + `",
+						"key": "` + testdata.ErrorKey2 + `",
+						"details": ` + helpers.ToJSONString(testdata.Rule2ExtraData) + `
+					}
+				],
+				"disappeared": [],
+				"changed": []
+			}
+		}`,
+	})
+
+	// no report was stored at that time
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodGet,
+		Endpoint: server.ReportDiffEndpoint + "?to=%v",
+		EndpointArgs: []interface{}{
+			testdata.OrgID, testdata.ClusterName, firstChecked.Add(-time.Hour).Format(time.RFC3339),
+		},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusNotFound,
+	})
+}
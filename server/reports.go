@@ -21,12 +21,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/RedHatInsights/insights-operator-utils/responses"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -39,6 +41,15 @@ const (
 	orgIDStr = "orgID"
 	// userIDstr used in log messages
 	userIDstr = "userID"
+	// filterDisabledParam is the name of query parameter that controls
+	// filtering of disabled rules from cluster recommendations
+	filterDisabledParam = "filter_disabled"
+	// totalRiskParam is the name of the total risk of rules in the request
+	// body of recommendations summary
+	totalRiskParam = "total_risk"
+	// minTotalRisk and maxTotalRisk is the range of total risk of rules
+	minTotalRisk = 1
+	maxTotalRisk = 4
 )
 
 // validateClusterID function checks if the cluster ID is a valid UUID.
@@ -305,6 +316,102 @@ func (server *HTTPServer) getRecommendations(writer http.ResponseWriter, request
 	}
 }
 
+// getRecommendationsSummary retrieves recommendations hitting any cluster of
+// the organization aggregated per rule selector. Unlike getRecommendations
+// it doesn't need the list of clusters in the request body. When the total
+// risk of rules is sent in the request body, recommendations are aggregated
+// by the total risk too.
+func (server *HTTPServer) getRecommendationsSummary(writer http.ResponseWriter, request *http.Request) {
+	tStart := time.Now()
+
+	orgID, ok := readOrgID(writer, request)
+	if !ok {
+		// everything has been handled
+		return
+	}
+	log.Info().Int(orgIDStr, int(orgID)).Msg("getRecommendationsSummary")
+
+	totalRisks, ok := readTotalRisksFromBody(writer, request)
+	if !ok {
+		// everything has been handled
+		return
+	}
+
+	summary, err := server.Storage.ReadRecommendationsSummary(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Errors retrieving recommendations summary")
+		handleServerError(writer, err)
+		return
+	}
+
+	response := responses.BuildOkResponseWithData("recommendations", summary)
+	if totalRisks != nil {
+		response["recommendations_by_total_risk"] = aggregateByTotalRisk(summary, totalRisks)
+	}
+
+	log.Info().Uint32(orgIDStr, uint32(orgID)).Msgf(
+		"getRecommendationsSummary took %s", time.Since(tStart),
+	)
+	err = responses.SendOK(writer, response)
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// readTotalRisksFromBody reads total risk of rule selectors from the
+// optional request body. Nil map is returned when there's no body. If the
+// body is not valid, it writes http error to the writer and returns false
+func readTotalRisksFromBody(
+	writer http.ResponseWriter, request *http.Request,
+) (map[types.RuleID]int, bool) {
+	if request.ContentLength <= 0 {
+		return nil, true
+	}
+
+	var totalRisks map[types.RuleID]int
+	err := json.NewDecoder(request.Body).Decode(&totalRisks)
+	if err != nil {
+		handleServerError(writer, err)
+		return nil, false
+	}
+
+	for ruleID, totalRisk := range totalRisks {
+		if totalRisk < minTotalRisk || totalRisk > maxTotalRisk {
+			handleServerError(writer, &types.ValidationError{
+				ParamName:  totalRiskParam,
+				ParamValue: totalRisk,
+				ErrString: fmt.Sprintf(
+					"total risk of rule %v must be between %d and %d", ruleID, minTotalRisk, maxTotalRisk,
+				),
+			})
+			return nil, false
+		}
+	}
+
+	return totalRisks, true
+}
+
+// aggregateByTotalRisk fills the total risk of summary items and returns the
+// number of recommendations per total risk. Rule selectors with unknown total
+// risk are not counted.
+func aggregateByTotalRisk(summary []storage.RecommendationSummary, totalRisks map[types.RuleID]int) map[int]int {
+	recommendationsByTotalRisk := make(map[int]int, maxTotalRisk)
+	for totalRisk := minTotalRisk; totalRisk <= maxTotalRisk; totalRisk++ {
+		recommendationsByTotalRisk[totalRisk] = 0
+	}
+
+	for i := range summary {
+		totalRisk, found := totalRisks[summary[i].RuleID]
+		if !found {
+			continue
+		}
+		summary[i].TotalRisk = totalRisk
+		recommendationsByTotalRisk[totalRisk]++
+	}
+
+	return recommendationsByTotalRisk
+}
+
 // getClustersRecommendationsList retrieves all recommendations hitting for all clusters specified in the request body
 func (server *HTTPServer) getClustersRecommendationsList(writer http.ResponseWriter, request *http.Request) {
 	tStart := time.Now()
@@ -323,6 +430,12 @@ func (server *HTTPServer) getClustersRecommendationsList(writer http.ResponseWri
 	}
 	log.Info().Int(orgIDStr, int(orgID)).Msg("getClustersRecommendationsList")
 
+	filterDisabled, ok := readBoolQueryParam(writer, request, filterDisabledParam, true)
+	if !ok {
+		// everything has been handled
+		return
+	}
+
 	var listOfClusters []string
 	err := json.NewDecoder(request.Body).Decode(&listOfClusters)
 	if err != nil {
@@ -338,11 +451,110 @@ func (server *HTTPServer) getClustersRecommendationsList(writer http.ResponseWri
 		return
 	}
 
+	disabledRules, err := server.readDisabledRulesForClusters(listOfClusters, orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Errors retrieving disabled rules")
+		handleServerError(writer, err)
+		return
+	}
+
+	response := make(map[types.ClusterName]ClusterRecommendations, len(clustersRecommendations))
+	for clusterName, clusterRecommendations := range clustersRecommendations {
+		response[clusterName] = filterClusterRecommendations(
+			clusterName, clusterRecommendations, disabledRules, filterDisabled,
+		)
+	}
+
 	log.Info().Uint32(orgIDStr, uint32(orgID)).Msgf(
 		"getClustersRecommendationsList took %s", time.Since(tStart),
 	)
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData("clusters", clustersRecommendations))
+	err = responses.SendOK(writer, responses.BuildOkResponseWithData("clusters", response))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
 }
+
+// ClusterRecommendations is the list of recommendations for one cluster
+// along with the number of active and disabled rules hitting the cluster
+type ClusterRecommendations struct {
+	types.ClusterRecommendationList
+	ActiveCount   int `json:"active_count"`
+	DisabledCount int `json:"disabled_count"`
+}
+
+// disabledRuleKey identifies rule disabled for a cluster, empty cluster
+// name means the rule is disabled system-wide
+type disabledRuleKey struct {
+	clusterName types.ClusterName
+	ruleID      types.RuleID
+}
+
+// readDisabledRulesForClusters reads rules disabled by users for given
+// clusters and rules disabled for the whole organization. Rule IDs are
+// returned in plugin_name|error_key format used by recommendations.
+func (server *HTTPServer) readDisabledRulesForClusters(
+	listOfClusters []string, orgID types.OrgID,
+) (map[disabledRuleKey]bool, error) {
+	disabledForClusters, err := server.Storage.ListOfDisabledRulesForClusters(listOfClusters, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	disabledSystemWide, err := server.Storage.ListOfSystemWideDisabledRules(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	disabledRules := make(map[disabledRuleKey]bool, len(disabledForClusters)+len(disabledSystemWide))
+	for _, rule := range disabledForClusters {
+		key := disabledRuleKey{rule.ClusterID, recommendationRuleID(rule.RuleID, rule.ErrorKey)}
+		disabledRules[key] = true
+	}
+	for _, rule := range disabledSystemWide {
+		key := disabledRuleKey{"", recommendationRuleID(rule.RuleID, rule.ErrorKey)}
+		disabledRules[key] = true
+	}
+
+	return disabledRules, nil
+}
+
+// recommendationRuleID returns rule ID in plugin_name|error_key format.
+// Toggles can be stored with or without the .report suffix, so it's removed.
+func recommendationRuleID(ruleID types.RuleID, errorKey types.ErrorKey) types.RuleID {
+	ruleFQDN := strings.TrimSuffix(string(ruleID), ".report")
+	return types.RuleID(ruleFQDN + "|" + string(errorKey))
+}
+
+// filterClusterRecommendations counts active and disabled rules hitting the
+// cluster and removes the disabled ones from the list when requested
+func filterClusterRecommendations(
+	clusterName types.ClusterName,
+	clusterRecommendations types.ClusterRecommendationList,
+	disabledRules map[disabledRuleKey]bool,
+	filterDisabled bool,
+) ClusterRecommendations {
+	result := ClusterRecommendations{ClusterRecommendationList: clusterRecommendations}
+	recommendations := make([]types.RuleID, 0, len(clusterRecommendations.Recommendations))
+
+	for _, ruleID := range clusterRecommendations.Recommendations {
+		// empty rule ID is returned for clusters without recommendations
+		if ruleID == "" {
+			recommendations = append(recommendations, ruleID)
+			continue
+		}
+
+		if disabledRules[disabledRuleKey{clusterName, ruleID}] || disabledRules[disabledRuleKey{"", ruleID}] {
+			result.DisabledCount++
+			if filterDisabled {
+				continue
+			}
+		} else {
+			result.ActiveCount++
+		}
+
+		recommendations = append(recommendations, ruleID)
+	}
+
+	result.Recommendations = recommendations
+	return result
+}
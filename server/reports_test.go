@@ -18,12 +18,15 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	ctypes "github.com/RedHatInsights/insights-results-types"
 
 	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
@@ -222,3 +225,213 @@ func TestReadReportsForClustersPayloadNoClusters(t *testing.T) {
 		Body:       helpers.ToJSONString(expectedResponse),
 	})
 }
+
+func TestGetRecommendationsSummary(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	createdAt := types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339))
+
+	for _, clusterName := range []types.ClusterName{testdata.GetRandomClusterID(), testdata.GetRandomClusterID()} {
+		helpers.FailOnError(t, mockStorage.WriteRecommendationsForCluster(
+			testdata.OrgID, clusterName, testdata.Report2Rules, createdAt,
+		))
+	}
+	helpers.FailOnError(t, mockStorage.DisableRuleSystemWide(
+		testdata.OrgID, testdata.Rule2ID, testdata.ErrorKey2, "justification",
+	))
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RecommendationsSummaryEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"recommendations":[{"rule_id":"` + string(testdata.Rule1CompositeID) +
+			`","impacted_clusters":2,"impacted_since":"` + string(createdAt) + `"}],"status":"ok"}`,
+	})
+}
+
+// TestGetRecommendationsSummaryByTotalRisk checks that recommendations are
+// aggregated by total risk of rules sent in the request body
+func TestGetRecommendationsSummaryByTotalRisk(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	createdAt := types.Timestamp(testdata.LastCheckedAt.UTC().Format(time.RFC3339))
+
+	helpers.FailOnError(t, mockStorage.WriteRecommendationsForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, createdAt,
+	))
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.RecommendationsSummaryEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID},
+		Body: `{"` + string(testdata.Rule1CompositeID) + `": 3, "` +
+			string(testdata.Rule2CompositeID) + `": 3, "other.rule|OTHER": 1}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"recommendations":[` +
+			`{"rule_id":"` + string(testdata.Rule1CompositeID) + `","impacted_clusters":1,"impacted_since":"` + string(createdAt) + `","total_risk":3},` +
+			`{"rule_id":"` + string(testdata.Rule2CompositeID) + `","impacted_clusters":1,"impacted_since":"` + string(createdAt) + `","total_risk":3},` +
+			`{"rule_id":"` + string(testdata.Rule3CompositeID) + `","impacted_clusters":1,"impacted_since":"` + string(createdAt) + `"}],` +
+			`"recommendations_by_total_risk":{"1":0,"2":0,"3":2,"4":0},"status":"ok"}`,
+	})
+}
+
+func TestGetRecommendationsSummaryBadTotalRisk(t *testing.T) {
+	for _, body := range []string{`{"rule.module|ERROR_KEY": 5}`, `["rule.module|ERROR_KEY"]`} {
+		helpers.AssertAPIRequest(t, storage.NewMemoryStorage(storage.Configuration{}), nil, &helpers.APIRequest{
+			Method:       http.MethodPost,
+			Endpoint:     server.RecommendationsSummaryEndpoint,
+			EndpointArgs: []interface{}{testdata.OrgID},
+			Body:         body,
+		}, &helpers.APIResponse{
+			StatusCode: http.StatusBadRequest,
+		})
+	}
+}
+
+func TestGetRecommendationsSummaryBadOrgID(t *testing.T) {
+	helpers.AssertAPIRequest(t, storage.NewMemoryStorage(storage.Configuration{}), nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.RecommendationsSummaryEndpoint,
+		EndpointArgs: []interface{}{"string"},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+	})
+}
+
+func mustPrepareClusterRecommendations(t *testing.T, mockStorage storage.Storage) types.ClusterRecommendationList {
+	helpers.FailOnError(t, mockStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed,
+		testdata.LastCheckedAt, time.Now(), time.Now(), testdata.KafkaOffset,
+	))
+	helpers.FailOnError(t, mockStorage.WriteRecommendationsForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, types.Timestamp(testdata.LastCheckedAt.Format(time.RFC3339)),
+	))
+
+	// rule 1 is disabled for the cluster, rule 2 for the whole organization
+	helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, storage.RuleToggleDisable,
+	))
+	helpers.FailOnError(t, mockStorage.DisableRuleSystemWide(
+		testdata.OrgID, testdata.Rule2ID+".report", testdata.ErrorKey2, "justification",
+	))
+
+	clusterMap, err := mockStorage.ReadClusterListRecommendations([]string{string(testdata.ClusterName)}, testdata.OrgID)
+	helpers.FailOnError(t, err)
+
+	return clusterMap[testdata.ClusterName]
+}
+
+func clustersRecommendationsResponse(t *testing.T, recommendations server.ClusterRecommendations) string {
+	body, err := json.Marshal(map[string]interface{}{
+		"clusters": map[types.ClusterName]server.ClusterRecommendations{testdata.ClusterName: recommendations},
+		"status":   "ok",
+	})
+	helpers.FailOnError(t, err)
+
+	return string(body)
+}
+
+func TestGetClustersRecommendationsListFilterDisabled(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	clusterRecommendations := mustPrepareClusterRecommendations(t, mockStorage)
+	reqBody, _ := json.Marshal([]types.ClusterName{testdata.ClusterName})
+
+	expected := server.ClusterRecommendations{
+		ClusterRecommendationList: clusterRecommendations,
+		ActiveCount:               1,
+		DisabledCount:             2,
+	}
+	expected.Recommendations = []ctypes.RuleID{testdata.Rule3CompositeID}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ClustersRecommendationsListEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         reqBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       clustersRecommendationsResponse(t, expected),
+	})
+}
+
+func TestGetClustersRecommendationsListWithoutFiltering(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	clusterRecommendations := mustPrepareClusterRecommendations(t, mockStorage)
+	reqBody, _ := json.Marshal([]types.ClusterName{testdata.ClusterName})
+
+	expected := server.ClusterRecommendations{
+		ClusterRecommendationList: clusterRecommendations,
+		ActiveCount:               1,
+		DisabledCount:             2,
+	}
+	assert.Len(t, expected.Recommendations, 3)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ClustersRecommendationsListEndpoint + "?filter_disabled=false",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         reqBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       clustersRecommendationsResponse(t, expected),
+	})
+}
+
+// TestGetClustersRecommendationsListWithClusterInfo checks that details of
+// all info items gathered for the cluster are returned in its metadata
+func TestGetClustersRecommendationsListWithClusterInfo(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	clusterRecommendations := mustPrepareClusterRecommendations(t, mockStorage)
+	reqBody, _ := json.Marshal([]types.ClusterName{testdata.ClusterName})
+
+	helpers.FailOnError(t, mockStorage.WriteReportInfoForCluster(testdata.OrgID, testdata.ClusterName, []types.InfoItem{
+		{
+			InfoID:  "version_info|CLUSTER_VERSION_INFO",
+			Details: map[string]string{"version": "4.9"},
+		},
+		{
+			InfoID:  "nodes_info|CLUSTER_NODES_INFO",
+			Details: map[string]string{"masters": "3", "workers": "5"},
+		},
+	}, testdata.LastCheckedAt))
+
+	expected := server.ClusterRecommendations{
+		ClusterRecommendationList: clusterRecommendations,
+		ActiveCount:               1,
+		DisabledCount:             2,
+	}
+	expected.Recommendations = []ctypes.RuleID{testdata.Rule3CompositeID}
+	expected.Meta = types.ClusterMetadata{
+		Version: "4.9",
+		Info: map[types.RuleID]map[string]string{
+			"version_info|CLUSTER_VERSION_INFO": {"version": "4.9"},
+			"nodes_info|CLUSTER_NODES_INFO":     {"masters": "3", "workers": "5"},
+		},
+	}
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ClustersRecommendationsListEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         reqBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       clustersRecommendationsResponse(t, expected),
+	})
+}
+
+func TestGetClustersRecommendationsListBadFilterDisabled(t *testing.T) {
+	reqBody, _ := json.Marshal([]types.ClusterName{testdata.ClusterName})
+
+	helpers.AssertAPIRequest(t, storage.NewMemoryStorage(storage.Configuration{}), nil, &helpers.APIRequest{
+		Method:       http.MethodPost,
+		Endpoint:     server.ClustersRecommendationsListEndpoint + "?filter_disabled=maybe",
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         reqBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{"status":"Error during parsing param 'filter_disabled' with value 'maybe'. ` +
+			`Error: 'boolean value expected'"}`,
+	})
+}
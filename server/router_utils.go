@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	httputils "github.com/RedHatInsights/insights-operator-utils/http"
 	"github.com/rs/zerolog/log"
@@ -35,6 +37,7 @@ var (
 	getRouterParam            = httputils.GetRouterParam
 	getRouterPositiveIntParam = httputils.GetRouterPositiveIntParam
 	readClusterName           = httputils.ReadClusterName
+	validateClusterName       = httputils.ValidateClusterName
 	readOrganizationID        = httputils.ReadOrganizationID
 	checkPermissions          = httputils.CheckPermissions
 	readClusterNames          = httputils.ReadClusterNames
@@ -94,6 +97,186 @@ func readClusterListFromPath(writer http.ResponseWriter, request *http.Request)
 	return clusterList, true
 }
 
+// readTimeQueryParam retrieves optional timestamp in RFC3339 format from
+// request's query. Zero time is returned when the parameter is not set.
+// If it's not possible to parse the parameter, it writes http error to the
+// writer and returns false
+func readTimeQueryParam(writer http.ResponseWriter, request *http.Request, paramName string) (time.Time, bool) {
+	value := strings.TrimSpace(request.URL.Query().Get(paramName))
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  paramName,
+			ParamValue: value,
+			ErrString:  "timestamp in RFC3339 format expected",
+		})
+		return time.Time{}, false
+	}
+
+	return timestamp, true
+}
+
+// readTimeOrDurationQueryParam retrieves optional point in time from
+// request's query. It's either timestamp in RFC3339 format or non-negative
+// duration (like 24h) that is subtracted from now. Zero time is returned
+// when the parameter is not set. If it's not possible to parse the
+// parameter, it writes http error to the writer and returns false
+func readTimeOrDurationQueryParam(
+	writer http.ResponseWriter, request *http.Request, paramName string, now time.Time,
+) (time.Time, bool) {
+	value := strings.TrimSpace(request.URL.Query().Get(paramName))
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, true
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  paramName,
+			ParamValue: value,
+			ErrString:  "timestamp in RFC3339 format or non-negative duration expected",
+		})
+		return time.Time{}, false
+	}
+
+	return now.Add(-duration), true
+}
+
+// expirationExpected is the error returned when expiration time of rule
+// disable can't be parsed
+const expirationExpected = "timestamp in the future in RFC3339 format or positive duration expected"
+
+// parseExpiration parses expiration time of rule disable. It's either
+// timestamp in RFC3339 format or positive duration (like 24h) that is added
+// to now, the result must be in the future.
+func parseExpiration(value string, now time.Time) (time.Time, bool) {
+	expiresAt, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return time.Time{}, false
+		}
+		expiresAt = now.Add(duration)
+	}
+
+	return expiresAt, expiresAt.After(now)
+}
+
+// readExpirationQueryParam retrieves optional expiration time of rule
+// disable from request's query. Zero time is returned when the parameter is
+// not set. If it's not possible to parse the parameter, it writes http error
+// to the writer and returns false
+func readExpirationQueryParam(
+	writer http.ResponseWriter, request *http.Request, paramName string, now time.Time,
+) (time.Time, bool) {
+	value := strings.TrimSpace(request.URL.Query().Get(paramName))
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	expiresAt, ok := parseExpiration(value, now)
+	if !ok {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  paramName,
+			ParamValue: value,
+			ErrString:  expirationExpected,
+		})
+		return time.Time{}, false
+	}
+
+	return expiresAt, true
+}
+
+// parseNonNegativeInt parses non-negative integer that fits into given
+// number of bits
+func parseNonNegativeInt(paramName, value string, bitSize int) (int64, error) {
+	number, err := strconv.ParseInt(value, 10, bitSize)
+	if err != nil || number < 0 {
+		return 0, &RouterParsingError{
+			ParamName:  paramName,
+			ParamValue: value,
+			ErrString:  "non-negative integer expected",
+		}
+	}
+
+	return number, nil
+}
+
+// readNonNegativeIntRouterParam retrieves non-negative integer that fits
+// into given number of bits from request's path
+// if it's not possible, it writes http error to the writer and returns false
+func readNonNegativeIntRouterParam(
+	writer http.ResponseWriter, request *http.Request, paramName string, bitSize int,
+) (int64, bool) {
+	value, err := getRouterParam(request, paramName)
+	if err != nil {
+		handleServerError(writer, err)
+		return 0, false
+	}
+
+	number, err := parseNonNegativeInt(paramName, value, bitSize)
+	if err != nil {
+		handleServerError(writer, err)
+		return 0, false
+	}
+
+	return number, true
+}
+
+// readNonNegativeIntQueryParam retrieves optional non-negative integer that
+// fits into given number of bits from request's query. The default value is
+// returned when the parameter is not set. If it's not possible to parse the
+// parameter, it writes http error to the writer and returns false
+func readNonNegativeIntQueryParam(
+	writer http.ResponseWriter, request *http.Request, paramName string, bitSize int, defaultValue int64,
+) (int64, bool) {
+	value := strings.TrimSpace(request.URL.Query().Get(paramName))
+	if value == "" {
+		return defaultValue, true
+	}
+
+	number, err := parseNonNegativeInt(paramName, value, bitSize)
+	if err != nil {
+		handleServerError(writer, err)
+		return 0, false
+	}
+
+	return number, true
+}
+
+// readBoolQueryParam retrieves optional boolean from request's query. The
+// default value is returned when the parameter is not set. If it's not
+// possible to parse the parameter, it writes http error to the writer and
+// returns false
+func readBoolQueryParam(
+	writer http.ResponseWriter, request *http.Request, paramName string, defaultValue bool,
+) (value, ok bool) {
+	rawValue := strings.TrimSpace(request.URL.Query().Get(paramName))
+	if rawValue == "" {
+		return defaultValue, true
+	}
+
+	value, err := strconv.ParseBool(rawValue)
+	if err != nil {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  paramName,
+			ParamValue: rawValue,
+			ErrString:  "boolean value expected",
+		})
+		return false, false
+	}
+
+	return value, true
+}
+
 func readRuleIDWithErrorKey(writer http.ResponseWriter, request *http.Request) (types.RuleID, types.ErrorKey, bool) {
 	ruleIDWithErrorKey, err := getRouterParam(request, "rule_id")
 	if err != nil {
@@ -27,7 +27,12 @@ import (
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
-const accountStr = "account"
+const (
+	accountStr = "account"
+	// expiresAtParam is the optional query parameter of disable endpoints
+	// with the time when the rule is enabled again
+	expiresAtParam = "expires_at"
+)
 
 // disableRuleForCluster disables a rule for specified cluster, excluding it from reports
 func (server *HTTPServer) disableRuleForCluster(writer http.ResponseWriter, request *http.Request) {
@@ -59,7 +64,34 @@ func (server *HTTPServer) toggleRuleForCluster(writer http.ResponseWriter, reque
 		return
 	}
 
-	err := server.Storage.ToggleRuleForCluster(clusterID, ruleID, errorKey, orgID, toggleRule)
+	var (
+		err       error
+		expiresAt time.Time
+	)
+	auditEvent := storage.AuditEvent{
+		OrgID:     orgID,
+		Action:    storage.AuditActionEnableRule,
+		ClusterID: clusterID,
+		RuleID:    ruleID,
+		ErrorKey:  errorKey,
+	}
+	if toggleRule == storage.RuleToggleDisable {
+		expiresAt, successful = readExpirationQueryParam(writer, request, expiresAtParam, time.Now())
+		if !successful {
+			// everything has been handled already
+			return
+		}
+
+		auditEvent.Action = storage.AuditActionDisableRule
+		auditEvent.Details = withExpiration(map[string]string{}, expiresAt)
+		err = server.Storage.DisableRuleForClusterUntil(
+			clusterID, ruleID, errorKey, orgID, expiresAt, withAuditUser(request, "", auditEvent)...,
+		)
+	} else {
+		err = server.Storage.ToggleRuleForCluster(
+			clusterID, ruleID, errorKey, orgID, toggleRule, withAuditUser(request, "", auditEvent)...,
+		)
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to toggle rule for selected cluster")
 		handleServerError(writer, err)
@@ -320,7 +352,18 @@ func (server HTTPServer) saveDisableFeedback(writer http.ResponseWriter, request
 		return
 	}
 
-	err = server.Storage.AddFeedbackOnRuleDisable(clusterID, ruleID, errorKey, orgID, userID, feedback)
+	auditEvents := withAuditUser(request, userID, storage.AuditEvent{
+		OrgID:     orgID,
+		Action:    storage.AuditActionDisableFeedback,
+		ClusterID: clusterID,
+		RuleID:    ruleID,
+		ErrorKey:  errorKey,
+		Details:   map[string]string{"justification": feedback},
+	})
+
+	err = server.Storage.AddFeedbackOnRuleDisable(
+		clusterID, ruleID, errorKey, orgID, userID, feedback, auditEvents...,
+	)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -345,7 +388,7 @@ func (server HTTPServer) getFeedbackAndTogglesOnRule(
 		log.Error().Err(err).Msg("Rule toggle was not found")
 		rule.Disabled = false
 	} else {
-		rule.Disabled = ruleToggle.Disabled == storage.RuleToggleDisable
+		rule.Disabled = ruleToggle.IsDisabled(time.Now())
 		rule.DisabledAt = types.Timestamp(ruleToggle.DisabledAt.Time.UTC().Format(time.RFC3339))
 	}
 
@@ -380,9 +423,16 @@ func (server HTTPServer) enableRuleSystemWide(writer http.ResponseWriter, reques
 		return
 	}
 
+	auditEvents := withAuditUser(request, "", storage.AuditEvent{
+		OrgID:    selector.OrgID,
+		Action:   storage.AuditActionEnableRuleSystemWide,
+		RuleID:   selector.RuleID,
+		ErrorKey: selector.ErrorKey,
+	})
+
 	// try to enable rule
 	err := server.Storage.EnableRuleSystemWide(
-		selector.OrgID, selector.RuleID, selector.ErrorKey,
+		selector.OrgID, selector.RuleID, selector.ErrorKey, auditEvents...,
 	)
 
 	// handle any storage error
@@ -413,6 +463,13 @@ func (server HTTPServer) disableRuleSystemWide(writer http.ResponseWriter, reque
 		return
 	}
 
+	// read optional expiration of the disable
+	expiresAt, successful := readExpirationQueryParam(writer, request, expiresAtParam, time.Now())
+	if !successful {
+		// everything has been handled
+		return
+	}
+
 	// read justification from request body
 	justification, err := server.getJustificationFromBody(request)
 	if err != nil {
@@ -420,9 +477,17 @@ func (server HTTPServer) disableRuleSystemWide(writer http.ResponseWriter, reque
 		return
 	}
 
+	auditEvents := withAuditUser(request, "", storage.AuditEvent{
+		OrgID:    selector.OrgID,
+		Action:   storage.AuditActionDisableRuleSystemWide,
+		RuleID:   selector.RuleID,
+		ErrorKey: selector.ErrorKey,
+		Details:  withExpiration(map[string]string{"justification": justification}, expiresAt),
+	})
+
 	// try to disable rule
-	err = server.Storage.DisableRuleSystemWide(
-		selector.OrgID, selector.RuleID, selector.ErrorKey, justification,
+	err = server.Storage.DisableRuleSystemWideUntil(
+		selector.OrgID, selector.RuleID, selector.ErrorKey, justification, expiresAt, auditEvents...,
 	)
 
 	// handle any storage error
@@ -459,9 +524,17 @@ func (server HTTPServer) updateRuleSystemWide(writer http.ResponseWriter, reques
 		return
 	}
 
+	auditEvents := withAuditUser(request, "", storage.AuditEvent{
+		OrgID:    selector.OrgID,
+		Action:   storage.AuditActionUpdateJustification,
+		RuleID:   selector.RuleID,
+		ErrorKey: selector.ErrorKey,
+		Details:  map[string]string{"justification": justification},
+	})
+
 	// try to update rule disable justification
 	err = server.Storage.UpdateDisabledRuleJustification(
-		selector.OrgID, selector.RuleID, selector.ErrorKey, justification,
+		selector.OrgID, selector.RuleID, selector.ErrorKey, justification, auditEvents...,
 	)
 
 	// handle any storage error
@@ -0,0 +1,300 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/responses"
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// MaxRuleToggleBatchSize is the maximum number of rules toggled for clusters
+// in one request
+const MaxRuleToggleBatchSize = 1000
+
+// ruleToggleBatchStatusOK is the status of successfully toggled item
+const ruleToggleBatchStatusOK = "ok"
+
+// RuleToggleBatchRequest is the body of requests that enable or disable
+// rules for several clusters. Either Items or Clusters with RuleSelector
+// are expected.
+type RuleToggleBatchRequest struct {
+	Items        []RuleToggleBatchRequestItem `json:"items,omitempty"`
+	Clusters     []types.ClusterName          `json:"clusters,omitempty"`
+	RuleSelector types.RuleSelector           `json:"rule_selector,omitempty"`
+	// Justification is used for all items that don't have their own
+	// justification, it's ignored when rules are enabled
+	Justification string `json:"justification,omitempty"`
+	// ExpiresAt is the time when the disabled rules are enabled again, it's
+	// either timestamp in RFC3339 format or duration (like 24h). It's
+	// ignored when rules are enabled.
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// RuleToggleBatchRequestItem represents one rule enabled or disabled for one
+// cluster
+type RuleToggleBatchRequestItem struct {
+	ClusterID     types.ClusterName `json:"cluster"`
+	RuleID        types.RuleID      `json:"rule_id"`
+	ErrorKey      types.ErrorKey    `json:"error_key"`
+	Justification string            `json:"justification,omitempty"`
+}
+
+// RuleToggleBatchResult is the result of enabling or disabling one rule for
+// one cluster. Status is "ok" or the reason why the item was not stored.
+type RuleToggleBatchResult struct {
+	ClusterID types.ClusterName `json:"cluster"`
+	RuleID    types.RuleID      `json:"rule_id"`
+	ErrorKey  types.ErrorKey    `json:"error_key"`
+	Status    string            `json:"status"`
+}
+
+// disableRulesForClusters disables rules for clusters given in the request
+// body
+func (server *HTTPServer) disableRulesForClusters(writer http.ResponseWriter, request *http.Request) {
+	server.toggleRulesForClusters(writer, request, storage.RuleToggleDisable)
+}
+
+// enableRulesForClusters enables rules for clusters given in the request
+// body
+func (server *HTTPServer) enableRulesForClusters(writer http.ResponseWriter, request *http.Request) {
+	server.toggleRulesForClusters(writer, request, storage.RuleToggleEnable)
+}
+
+// toggleRulesForClusters contains shared functionality for bulk
+// enable/disable. Items that can't be toggled are reported in results, all
+// other items are stored in a single transaction.
+func (server *HTTPServer) toggleRulesForClusters(
+	writer http.ResponseWriter, request *http.Request, ruleToggle storage.RuleToggle,
+) {
+	orgID, successful := readOrgID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	userID, successful := readUserID(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	if !checkPermissions(writer, request, orgID, server.Config.Auth) {
+		// everything has been handled already
+		return
+	}
+
+	items, expiresAt, successful := readRuleToggleBatchRequest(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	results := make([]RuleToggleBatchResult, len(items))
+	validItems := make([]storage.RuleToggleBatchItem, 0, len(items))
+
+	for i, item := range items {
+		results[i] = RuleToggleBatchResult{
+			ClusterID: item.ClusterID,
+			RuleID:    item.RuleID,
+			ErrorKey:  item.ErrorKey,
+			Status:    ruleToggleBatchStatusOK,
+		}
+
+		if reason := validateRuleToggleBatchItem(item); reason != "" {
+			results[i].Status = reason
+			continue
+		}
+
+		if ruleToggle == storage.RuleToggleEnable {
+			item.Justification = ""
+		}
+
+		validItems = append(validItems, storage.RuleToggleBatchItem{
+			ClusterID:     item.ClusterID,
+			RuleID:        item.RuleID,
+			ErrorKey:      item.ErrorKey,
+			Justification: item.Justification,
+			ExpiresAt:     expiresAt,
+		})
+	}
+
+	log.Info().Int(orgIDStr, int(orgID)).Msgf(
+		"toggling %d rules for clusters, %d rejected", len(validItems), len(items)-len(validItems),
+	)
+
+	if len(validItems) > 0 {
+		auditEvents := withAuditUser(request, userID, ruleToggleAuditEvents(orgID, validItems, ruleToggle)...)
+		unknownClusters, err := server.Storage.ToggleRulesForClusters(orgID, userID, validItems, ruleToggle, auditEvents...)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to toggle rules for clusters")
+			handleServerError(writer, err)
+			return
+		}
+
+		// clusters are checked by storage, because organizations of all
+		// clusters are read at once
+		for i := range results {
+			if results[i].Status == ruleToggleBatchStatusOK && unknownClusters[results[i].ClusterID] {
+				results[i].Status = (&types.ItemNotFoundError{ItemID: results[i].ClusterID}).Error()
+			}
+		}
+	}
+
+	err := responses.SendOK(writer, responses.BuildOkResponseWithData("results", results))
+	if err != nil {
+		log.Error().Err(err).Msg(responseDataError)
+	}
+}
+
+// ruleToggleAuditEvents returns audit events describing rules toggled for
+// clusters, one event is returned for each toggled rule
+func ruleToggleAuditEvents(
+	orgID types.OrgID, items []storage.RuleToggleBatchItem, ruleToggle storage.RuleToggle,
+) []storage.AuditEvent {
+	action := storage.AuditActionEnableRule
+	if ruleToggle == storage.RuleToggleDisable {
+		action = storage.AuditActionDisableRule
+	}
+
+	events := make([]storage.AuditEvent, 0, len(items))
+	for _, item := range items {
+		event := storage.AuditEvent{
+			OrgID:     orgID,
+			Action:    action,
+			ClusterID: item.ClusterID,
+			RuleID:    item.RuleID,
+			ErrorKey:  item.ErrorKey,
+		}
+		if ruleToggle == storage.RuleToggleDisable {
+			event.Details = withExpiration(map[string]string{"justification": item.Justification}, item.ExpiresAt)
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// readRuleToggleBatchRequest reads the body of bulk enable/disable requests
+// and returns the list of items to be toggled together with optional
+// expiration time. Justification of the request is filled in items without
+// their own justification. If it's not possible to read the body, it writes
+// http error to the writer and returns false
+func readRuleToggleBatchRequest(
+	writer http.ResponseWriter, request *http.Request,
+) ([]RuleToggleBatchRequestItem, time.Time, bool) {
+	// check if there's any body provided in the request sent by client
+	if request.ContentLength <= 0 {
+		handleServerError(writer, &NoBodyError{})
+		return nil, time.Time{}, false
+	}
+
+	var body RuleToggleBatchRequest
+	err := json.NewDecoder(request.Body).Decode(&body)
+	if err != nil {
+		handleServerError(writer, err)
+		return nil, time.Time{}, false
+	}
+
+	var expiresAt time.Time
+	if body.ExpiresAt != "" {
+		var ok bool
+		expiresAt, ok = parseExpiration(body.ExpiresAt, time.Now())
+		if !ok {
+			handleServerError(writer, &types.ValidationError{
+				ParamName:  expiresAtParam,
+				ParamValue: body.ExpiresAt,
+				ErrString:  expirationExpected,
+			})
+			return nil, time.Time{}, false
+		}
+	}
+
+	items := body.Items
+
+	switch {
+	case len(body.Items) > 0 && (len(body.Clusters) > 0 || body.RuleSelector != ""):
+		handleServerError(writer, &types.ValidationError{
+			ParamName:  "items",
+			ParamValue: len(body.Items),
+			ErrString:  "items can't be combined with clusters and rule_selector",
+		})
+		return nil, time.Time{}, false
+	case len(body.Items) == 0:
+		ruleID, errorKey, err := getRuleAndErrorKeyFromRuleID(string(body.RuleSelector))
+		if err != nil {
+			handleServerError(writer, &types.ValidationError{
+				ParamName:  "rule_selector",
+				ParamValue: body.RuleSelector,
+				ErrString:  "rule ID and error key separated by | expected",
+			})
+			return nil, time.Time{}, false
+		}
+
+		if len(body.Clusters) == 0 {
+			handleServerError(writer, &types.ValidationError{
+				ParamName:  "clusters",
+				ParamValue: "[]",
+				ErrString:  "at least one cluster expected",
+			})
+			return nil, time.Time{}, false
+		}
+
+		items = make([]RuleToggleBatchRequestItem, len(body.Clusters))
+		for i, clusterID := range body.Clusters {
+			items[i] = RuleToggleBatchRequestItem{ClusterID: clusterID, RuleID: ruleID, ErrorKey: errorKey}
+		}
+	}
+
+	if len(items) > MaxRuleToggleBatchSize {
+		handleServerError(writer, &types.ValidationError{
+			ParamName:  "items",
+			ParamValue: len(items),
+			ErrString:  fmt.Sprintf("at most %d items can be toggled at once", MaxRuleToggleBatchSize),
+		})
+		return nil, time.Time{}, false
+	}
+
+	for i := range items {
+		if items[i].Justification == "" {
+			items[i].Justification = body.Justification
+		}
+	}
+
+	return items, expiresAt, true
+}
+
+// validateRuleToggleBatchItem checks that the cluster name, rule ID and
+// error key are valid. The reason is returned for invalid items.
+// Organizations of clusters are checked by storage.
+func validateRuleToggleBatchItem(item RuleToggleBatchRequestItem) (reason string) {
+	if _, err := validateClusterName(string(item.ClusterID)); err != nil {
+		return err.Error()
+	}
+
+	ruleSelector := string(item.RuleID) + "|" + string(item.ErrorKey)
+	if _, _, err := getRuleAndErrorKeyFromRuleID(ruleSelector); err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
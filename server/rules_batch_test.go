@@ -0,0 +1,230 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func mustMarshalRuleToggleBatchRequest(t *testing.T, body server.RuleToggleBatchRequest) []byte {
+	reqBody, err := json.Marshal(body)
+	helpers.FailOnError(t, err)
+	return reqBody
+}
+
+func TestDisableRulesForClustersItems(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	cluster, otherOrgCluster := testdata.GetRandomClusterID(), testdata.GetRandomClusterID()
+	mustWriteEmptyReports(t, mockStorage, testdata.OrgID, cluster)
+	mustWriteEmptyReports(t, mockStorage, testdata.Org2ID, otherOrgCluster)
+
+	reqBody := mustMarshalRuleToggleBatchRequest(t, server.RuleToggleBatchRequest{
+		Items: []server.RuleToggleBatchRequestItem{
+			{ClusterID: cluster, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Justification: "maintenance"},
+			{ClusterID: cluster, RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2},
+			{ClusterID: otherOrgCluster, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+			{ClusterID: "foo", RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+			{ClusterID: cluster, RuleID: "rule/1", ErrorKey: testdata.ErrorKey1},
+		},
+		Justification: "default justification",
+	})
+
+	expectedResult := `{"cluster":"%v","rule_id":"%v","error_key":"%v","status":"%v"}`
+	expected := `{"results":[` + strings.Join([]string{
+		fmt.Sprintf(expectedResult, cluster, testdata.Rule1ID, testdata.ErrorKey1, "ok"),
+		fmt.Sprintf(expectedResult, cluster, testdata.Rule2ID, testdata.ErrorKey2, "ok"),
+		fmt.Sprintf(expectedResult, otherOrgCluster, testdata.Rule1ID, testdata.ErrorKey1,
+			"Item with ID "+otherOrgCluster+" was not found in the storage"),
+		fmt.Sprintf(expectedResult, "foo", testdata.Rule1ID, testdata.ErrorKey1,
+			"Error during parsing param 'cluster' with value 'foo'. Error: 'invalid UUID length: 3'"),
+		fmt.Sprintf(expectedResult, cluster, "rule/1", testdata.ErrorKey1,
+			"invalid rule ID, each part of ID must contain only from latin characters, number, underscores or dots"),
+	}, ",") + `],"status":"ok"}`
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRulesForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         reqBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       expected,
+	})
+
+	disabledRules, err := mockStorage.ListOfDisabledRulesForClusters(
+		[]string{string(cluster), string(otherOrgCluster)}, testdata.OrgID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disabledRules, 2)
+
+	feedback, err := mockStorage.GetUserFeedbackOnRuleDisable(cluster, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "maintenance", feedback.Message)
+
+	feedback, err = mockStorage.GetUserFeedbackOnRuleDisable(cluster, testdata.Rule2ID, testdata.ErrorKey2, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "default justification", feedback.Message)
+}
+
+func TestEnableRulesForClustersRuleSelector(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	clusters := []types.ClusterName{testdata.GetRandomClusterID(), testdata.GetRandomClusterID()}
+	mustWriteEmptyReports(t, mockStorage, testdata.OrgID, clusters...)
+
+	for _, clusterName := range clusters {
+		helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+			clusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, storage.RuleToggleDisable,
+		))
+	}
+
+	reqBody := mustMarshalRuleToggleBatchRequest(t, server.RuleToggleBatchRequest{
+		Clusters:      clusters,
+		RuleSelector:  types.RuleSelector(testdata.Rule1CompositeID),
+		Justification: "ignored when rules are enabled",
+	})
+
+	expectedResult := `{"cluster":"%v","rule_id":"%v","error_key":"%v","status":"ok"}`
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.EnableRulesForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         reqBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{"results":[` +
+			fmt.Sprintf(expectedResult, clusters[0], testdata.Rule1ID, testdata.ErrorKey1) + `,` +
+			fmt.Sprintf(expectedResult, clusters[1], testdata.Rule1ID, testdata.ErrorKey1) +
+			`],"status":"ok"}`,
+	})
+
+	disabledRules, err := mockStorage.ListOfDisabledRulesForClusters(
+		[]string{string(clusters[0]), string(clusters[1])}, testdata.OrgID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disabledRules)
+
+	_, err = mockStorage.GetUserFeedbackOnRuleDisable(clusters[0], testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDisableRulesForClustersWithExpiration(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	cluster := testdata.GetRandomClusterID()
+	mustWriteEmptyReports(t, mockStorage, testdata.OrgID, cluster)
+
+	reqBody := mustMarshalRuleToggleBatchRequest(t, server.RuleToggleBatchRequest{
+		Clusters:     []types.ClusterName{cluster},
+		RuleSelector: types.RuleSelector(testdata.Rule1CompositeID),
+		ExpiresAt:    "2h",
+	})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRulesForClustersEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+		Body:         reqBody,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: fmt.Sprintf(`{"results":[{"cluster":"%v","rule_id":"%v","error_key":"%v","status":"ok"}],"status":"ok"}`,
+			cluster, testdata.Rule1ID, testdata.ErrorKey1),
+	})
+
+	toggledRule, err := mockStorage.GetFromClusterRuleToggle(cluster, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.True(t, toggledRule.IsDisabled(time.Now()))
+	assert.False(t, toggledRule.IsDisabled(time.Now().Add(3*time.Hour)))
+}
+
+func TestToggleRulesForClustersBadRequest(t *testing.T) {
+	tooManyClusters := make([]types.ClusterName, server.MaxRuleToggleBatchSize+1)
+	for i := range tooManyClusters {
+		tooManyClusters[i] = testdata.ClusterName
+	}
+
+	for name, testCase := range map[string]struct {
+		body     []byte
+		expected string
+	}{
+		"no body": {
+			body:     nil,
+			expected: `{"status":"client didn't provide request body"}`,
+		},
+		"items with clusters": {
+			body: mustMarshalRuleToggleBatchRequest(t, server.RuleToggleBatchRequest{
+				Items:    []server.RuleToggleBatchRequestItem{{ClusterID: testdata.ClusterName}},
+				Clusters: []types.ClusterName{testdata.ClusterName},
+			}),
+			expected: `{"status":"Error during validating param 'items' with value '1'. ` +
+				`Error: 'items can't be combined with clusters and rule_selector'"}`,
+		},
+		"bad rule selector": {
+			body: mustMarshalRuleToggleBatchRequest(t, server.RuleToggleBatchRequest{
+				Clusters:     []types.ClusterName{testdata.ClusterName},
+				RuleSelector: "rule",
+			}),
+			expected: `{"status":"Error during validating param 'rule_selector' with value 'rule'. ` +
+				`Error: 'rule ID and error key separated by | expected'"}`,
+		},
+		"no clusters": {
+			body: mustMarshalRuleToggleBatchRequest(t, server.RuleToggleBatchRequest{
+				RuleSelector: types.RuleSelector(testdata.Rule1CompositeID),
+			}),
+			expected: `{"status":"Error during validating param 'clusters' with value '[]'. ` +
+				`Error: 'at least one cluster expected'"}`,
+		},
+		"expiration in the past": {
+			body: mustMarshalRuleToggleBatchRequest(t, server.RuleToggleBatchRequest{
+				Clusters:     []types.ClusterName{testdata.ClusterName},
+				RuleSelector: types.RuleSelector(testdata.Rule1CompositeID),
+				ExpiresAt:    "2000-01-01T00:00:00Z",
+			}),
+			expected: `{"status":"Error during validating param 'expires_at' with value '2000-01-01T00:00:00Z'. ` +
+				`Error: 'timestamp in the future in RFC3339 format or positive duration expected'"}`,
+		},
+		"too many clusters": {
+			body: mustMarshalRuleToggleBatchRequest(t, server.RuleToggleBatchRequest{
+				Clusters:     tooManyClusters,
+				RuleSelector: types.RuleSelector(testdata.Rule1CompositeID),
+			}),
+			expected: `{"status":"Error during validating param 'items' with value '1001'. ` +
+				`Error: 'at most 1000 items can be toggled at once'"}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			helpers.AssertAPIRequest(t, storage.NewMemoryStorage(storage.Configuration{}), nil, &helpers.APIRequest{
+				Method:       http.MethodPut,
+				Endpoint:     server.DisableRulesForClustersEndpoint,
+				EndpointArgs: []interface{}{testdata.OrgID, testdata.UserID},
+				Body:         testCase.body,
+			}, &helpers.APIResponse{
+				StatusCode: http.StatusBadRequest,
+				Body:       testCase.expected,
+			})
+		})
+	}
+}
@@ -103,14 +103,20 @@ func (server *HTTPServer) mainEndpoint(writer http.ResponseWriter, _ *http.Reque
 	}
 }
 
-func (server *HTTPServer) listOfOrganizations(writer http.ResponseWriter, _ *http.Request) {
-	organizations, err := server.Storage.ListOfOrgs()
+func (server *HTTPServer) listOfOrganizations(writer http.ResponseWriter, request *http.Request) {
+	options, successful := readListingOptions(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	organizations, nextCursor, err := server.Storage.ListOfOrgsPage(options)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to get list of organizations")
 		handleServerError(writer, err)
 		return
 	}
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData("organizations", organizations))
+	err = responses.SendOK(writer, buildListingResponse("organizations", organizations, nextCursor))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
@@ -123,21 +129,65 @@ func (server *HTTPServer) listOfClustersForOrganization(writer http.ResponseWrit
 		return
 	}
 
-	// TODO get limit from request param instead of hardcoded config param
-	timeLimit := time.Now().Add(-time.Duration(server.Config.OrgOverviewLimitHours) * time.Hour)
+	since, until, successful := server.readClustersTimeWindow(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
+
+	options, successful := readListingOptions(writer, request)
+	if !successful {
+		// everything has been handled already
+		return
+	}
 
-	clusters, err := server.Storage.ListOfClustersForOrg(organizationID, timeLimit)
+	clusters, nextCursor, err := server.Storage.ListOfClustersForOrgPage(organizationID, since, until, options)
 	if err != nil {
 		log.Error().Err(err).Msg("Unable to get list of clusters")
 		handleServerError(writer, err)
 		return
 	}
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData(clustersStr, clusters))
+	err = responses.SendOK(writer, buildListingResponse(clustersStr, clusters, nextCursor))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
 }
 
+// readClustersTimeWindow reads the time window for the list of clusters
+// from since and until query parameters. Clusters reported in the last
+// OrgOverviewLimitHours are listed when since is not set, zero until means
+// that the window is not limited from above. If it's not possible to read
+// the window, it writes http error to the writer and returns false
+func (server *HTTPServer) readClustersTimeWindow(
+	writer http.ResponseWriter, request *http.Request,
+) (since, until time.Time, successful bool) {
+	now := time.Now()
+
+	since, successful = readTimeOrDurationQueryParam(writer, request, sinceParam, now)
+	if !successful {
+		return since, until, false
+	}
+	if since.IsZero() {
+		since = now.Add(-time.Duration(server.Config.OrgOverviewLimitHours) * time.Hour)
+	}
+
+	until, successful = readTimeOrDurationQueryParam(writer, request, untilParam, now)
+	if !successful {
+		return since, until, false
+	}
+
+	if !until.IsZero() && until.Before(since) {
+		handleServerError(writer, &RouterParsingError{
+			ParamName:  untilParam,
+			ParamValue: request.URL.Query().Get(untilParam),
+			ErrString:  "until must not be before since",
+		})
+		return since, until, false
+	}
+
+	return since, until, true
+}
+
 func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, request *http.Request) {
 	clusterName, successful := readClusterName(writer, request)
 	if !successful {
@@ -190,8 +240,9 @@ func (server *HTTPServer) readReportForCluster(writer http.ResponseWriter, reque
 }
 
 // readReportForCluster method retrieves metainformations for report stored in
-// database and return the retrieved info to requester via response payload.
-// The payload has type types.ReportResponseMetainfo
+// database together with metadata of the cluster gathered by info rules and
+// return the retrieved info to requester via response payload. The payload
+// has type types.ReportResponseMetainfo
 func (server *HTTPServer) readReportMetainfoForCluster(writer http.ResponseWriter, request *http.Request) {
 	clusterName, successful := readClusterName(writer, request)
 	if !successful {
@@ -214,12 +265,22 @@ func (server *HTTPServer) readReportMetainfoForCluster(writer http.ResponseWrite
 		return
 	}
 
+	metadata, err := server.Storage.ReadReportInfoForCluster(orgID, clusterName)
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to read info report for cluster")
+		handleServerError(writer, err)
+		return
+	}
+
 	hitRulesCount := getHitRulesCount(reports)
 
-	response := ctypes.ReportResponseMetainfo{
-		Count:         hitRulesCount,
-		LastCheckedAt: lastChecked,
-		StoredAt:      storedAt,
+	response := types.ReportResponseMetainfo{
+		ReportResponseMetainfo: ctypes.ReportResponseMetainfo{
+			Count:         hitRulesCount,
+			LastCheckedAt: lastChecked,
+			StoredAt:      storedAt,
+		},
+		Meta: metadata,
 	}
 
 	err = responses.SendOK(writer, responses.BuildOkResponseWithData(ReportResponseMetainfo, response))
@@ -352,13 +413,13 @@ func (server *HTTPServer) deleteClusters(writer http.ResponseWriter, request *ht
 
 func (server *HTTPServer) addVersionToClusters(orgID types.OrgID, clusters []ctypes.HittingClustersData) error {
 	for index := range clusters {
-		version, err := server.Storage.ReadReportInfoForCluster(orgID, clusters[index].Cluster)
+		metadata, err := server.Storage.ReadReportInfoForCluster(orgID, clusters[index].Cluster)
 
 		if err != nil {
 			return fmt.Errorf("unable to gather version for %s: %w", clusters[index].Cluster, err)
 		}
 
-		clusters[index].Meta = ctypes.ClusterMetadata{Version: version}
+		clusters[index].Meta = ctypes.ClusterMetadata{Version: metadata.Version}
 	}
 
 	return nil
@@ -510,22 +571,29 @@ func (server *HTTPServer) RuleClusterDetailEndpoint(writer http.ResponseWriter,
 	if !successful {
 		return
 	}
+	options, successful := readListingOptions(writer, request)
+	if !successful {
+		return
+	}
 	log.Info().
 		Int(orgIDStr, int(orgID)).
 		Str(userIDstr, string(userID)).
 		Msgf("GET clusters detail for rule %s", selector)
 
 	var clusters []ctypes.HittingClustersData
+	var nextCursor string
 	var err error
 
 	if request.ContentLength > 0 {
 		if activeClusters, successful := readClusterListFromBody(writer, request); successful {
-			clusters, err = server.Storage.ListOfClustersForOrgSpecificRule(orgID, selector, activeClusters)
+			clusters, nextCursor, err = server.Storage.ListOfClustersForOrgSpecificRulePage(
+				orgID, selector, activeClusters, options,
+			)
 		} else {
 			return
 		}
 	} else {
-		clusters, err = server.Storage.ListOfClustersForOrgSpecificRule(orgID, selector, nil)
+		clusters, nextCursor, err = server.Storage.ListOfClustersForOrgSpecificRulePage(orgID, selector, nil, options)
 	}
 
 	if err != nil {
@@ -542,7 +610,7 @@ func (server *HTTPServer) RuleClusterDetailEndpoint(writer http.ResponseWriter,
 		return
 	}
 
-	err = responses.SendOK(writer, responses.BuildOkResponseWithData(clustersStr, clusters))
+	err = responses.SendOK(writer, buildListingResponse(clustersStr, clusters, nextCursor))
 	if err != nil {
 		log.Error().Err(err).Msg(responseDataError)
 	}
@@ -23,7 +23,9 @@ import (
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 
 	"github.com/RedHatInsights/insights-results-aggregator/server"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
 func TestReadReportMetainfoForClusterNonIntOrgID(t *testing.T) {
@@ -103,7 +105,8 @@ func TestReadExistingEmptyReportMetainfo(t *testing.T) {
 			"metainfo": {
 				"count": -1,
 				"last_checked_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
-				"stored_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `"
+				"stored_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+				"meta": {"cluster_version": ""}
 			}
 		}`,
 	})
@@ -150,7 +153,61 @@ func TestReadReportMetainfo(t *testing.T) {
 			"metainfo": {
 				"count": 3,
 				"last_checked_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
-				"stored_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `"
+				"stored_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+				"meta": {"cluster_version": ""}
+			}
+		}`,
+	})
+}
+
+// TestReadReportMetainfoWithClusterInfo checks that details of all info items
+// gathered for the cluster are returned together with the metainfo
+func TestReadReportMetainfoWithClusterInfo(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		testdata.Report3Rules,
+		testdata.Report3RulesParsed,
+		testdata.LastCheckedAt,
+		time.Now(),
+		testdata.LastCheckedAt,
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteReportInfoForCluster(testdata.OrgID, testdata.ClusterName, []types.InfoItem{
+		{
+			InfoID:  "version_info|CLUSTER_VERSION_INFO",
+			Details: map[string]string{"version": "4.9"},
+		},
+		{
+			InfoID:  "platform_info|CLUSTER_PLATFORM_INFO",
+			Details: map[string]string{"platform": "AWS"},
+		},
+	}, testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodGet,
+		Endpoint:     server.ReportMetainfoEndpoint,
+		EndpointArgs: []interface{}{testdata.OrgID, testdata.ClusterName, testdata.UserID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body: `{
+			"status":"ok",
+			"metainfo": {
+				"count": 3,
+				"last_checked_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+				"stored_at": "` + testdata.LastCheckedAt.Format(time.RFC3339) + `",
+				"meta": {
+					"cluster_version": "4.9",
+					"info": {
+						"version_info|CLUSTER_VERSION_INFO": {"version": "4.9"},
+						"platform_info|CLUSTER_PLATFORM_INFO": {"platform": "AWS"}
+					}
+				}
 			}
 		}`,
 	})
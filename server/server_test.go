@@ -373,8 +373,10 @@ func TestRuleFeedbackVote_DBError(t *testing.T) {
 			sqlmock.NewRows([]string{"cluster"}).AddRow(testdata.ClusterName),
 		)
 
-	expects.ExpectPrepare("INSERT INTO").
+	expects.ExpectBegin()
+	expects.ExpectExec("INSERT INTO").
 		WillReturnError(fmt.Errorf(errStr))
+	expects.ExpectRollback()
 
 	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
 		Method:       http.MethodPut,
@@ -649,6 +651,64 @@ func TestRuleToggle(t *testing.T) {
 	}
 }
 
+func TestRuleDisableWithExpiration(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+	mustWriteEmptyReports(t, mockStorage, testdata.OrgID, testdata.ClusterName)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.DisableRuleForClusterEndpoint + "?expires_at=" + expiresAt.Format(time.RFC3339),
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	toggledRule, err := mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleDisable, toggledRule.Disabled)
+	assert.True(t, toggledRule.ExpiresAt.Time.Equal(expiresAt))
+
+	// expiration is ignored when the rule is enabled
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:       http.MethodPut,
+		Endpoint:     server.EnableRuleForClusterEndpoint + "?expires_at=xyzzy",
+		EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID},
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"status": "ok"}`,
+	})
+
+	toggledRule, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleEnable, toggledRule.Disabled)
+	assert.False(t, toggledRule.ExpiresAt.Valid)
+}
+
+func TestRuleDisableWithBadExpiration(t *testing.T) {
+	for _, expiresAt := range []string{"xyzzy", "-1h", "2000-01-01T00:00:00Z"} {
+		t.Run(expiresAt, func(t *testing.T) {
+			mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+			mustWriteEmptyReports(t, mockStorage, testdata.OrgID, testdata.ClusterName)
+
+			helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+				Method:       http.MethodPut,
+				Endpoint:     server.DisableRuleForClusterEndpoint + "?expires_at=" + expiresAt,
+				EndpointArgs: []interface{}{testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID},
+			}, &helpers.APIResponse{
+				StatusCode: http.StatusBadRequest,
+				Body: `{"status":"Error during parsing param 'expires_at' with value '` + expiresAt + `'. ` +
+					`Error: 'timestamp in the future in RFC3339 format or positive duration expected'"}`,
+			})
+
+			_, err := mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+			assert.IsType(t, &types.ItemNotFoundError{}, err)
+		})
+	}
+}
+
 func TestHTTPServer_deleteOrganizationsOK(t *testing.T) {
 	helpers.AssertAPIRequest(t, nil, nil, &helpers.APIRequest{
 		Method:       http.MethodDelete,
@@ -962,6 +1022,43 @@ func TestHTTPServer_DisableRuleSystemWide(t *testing.T) {
 	})
 }
 
+func TestHTTPServer_DisableRuleSystemWideWithExpiration(t *testing.T) {
+	mockStorage := storage.NewMemoryStorage(storage.Configuration{})
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodPut,
+		Endpoint: server.DisableRuleSystemWide + "?expires_at=24h",
+		EndpointArgs: []interface{}{
+			testdata.Rule1ID,
+			testdata.ErrorKey1,
+			testdata.OrgID,
+		},
+		Body: `{"justification": "***justification***"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusOK,
+		Body:       `{"justification":"***justification***","status":"ok"}`,
+	})
+
+	_, found, err := mockStorage.ReadDisabledRule(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.True(t, found)
+
+	helpers.AssertAPIRequest(t, mockStorage, nil, &helpers.APIRequest{
+		Method:   http.MethodPut,
+		Endpoint: server.DisableRuleSystemWide + "?expires_at=-24h",
+		EndpointArgs: []interface{}{
+			testdata.Rule1ID,
+			testdata.ErrorKey1,
+			testdata.OrgID,
+		},
+		Body: `{"justification": "***justification***"}`,
+	}, &helpers.APIResponse{
+		StatusCode: http.StatusBadRequest,
+		Body: `{"status":"Error during parsing param 'expires_at' with value '-24h'. ` +
+			`Error: 'timestamp in the future in RFC3339 format or positive duration expected'"}`,
+	})
+}
+
 func TestHTTPServer_DisableRuleSystemWideWrongOrgID(t *testing.T) {
 	mockStorage, closer := helpers.MustGetMockStorage(t, true)
 	defer closer()
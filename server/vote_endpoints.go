@@ -16,10 +16,12 @@ package server
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/RedHatInsights/insights-operator-utils/responses"
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -69,7 +71,21 @@ func (server *HTTPServer) voteOnRule(writer http.ResponseWriter, request *http.R
 		return
 	}
 
-	err := server.Storage.VoteOnRule(clusterID, ruleID, errorKey, orgID, userID, userVote, voteMessage)
+	auditEvents := withAuditUser(request, userID, storage.AuditEvent{
+		OrgID:     orgID,
+		Action:    storage.AuditActionVote,
+		ClusterID: clusterID,
+		RuleID:    ruleID,
+		ErrorKey:  errorKey,
+		Details: map[string]string{
+			"vote":    strconv.Itoa(int(userVote)),
+			"message": voteMessage,
+		},
+	})
+
+	err := server.Storage.VoteOnRule(
+		clusterID, ruleID, errorKey, orgID, userID, userVote, voteMessage, auditEvents...,
+	)
 	if err != nil {
 		handleServerError(writer, err)
 		return
@@ -0,0 +1,267 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// AuditAction is the kind of change recorded in the audit log
+type AuditAction string
+
+// Changes made by users that are recorded in the audit log
+const (
+	// AuditActionVote is a like, dislike or reset of the vote on rule
+	AuditActionVote AuditAction = "vote"
+	// AuditActionDisableRule is a rule disabled for one cluster
+	AuditActionDisableRule AuditAction = "disable_rule"
+	// AuditActionEnableRule is a rule enabled for one cluster
+	AuditActionEnableRule AuditAction = "enable_rule"
+	// AuditActionDisableFeedback is a feedback (justification) on rule
+	// disabled for one cluster
+	AuditActionDisableFeedback AuditAction = "disable_feedback"
+	// AuditActionDisableRuleSystemWide is a rule disabled for all clusters
+	// of the organization
+	AuditActionDisableRuleSystemWide AuditAction = "disable_rule_system_wide"
+	// AuditActionEnableRuleSystemWide is a rule enabled for all clusters of
+	// the organization
+	AuditActionEnableRuleSystemWide AuditAction = "enable_rule_system_wide"
+	// AuditActionUpdateJustification is an update of the justification of
+	// the rule disabled system-wide
+	AuditActionUpdateJustification AuditAction = "update_justification"
+	// AuditActionRateRule is a rating of the rule
+	AuditActionRateRule AuditAction = "rate_rule"
+)
+
+// AuditEvent represents one record from audit_event table, i.e. one change
+// made by a user. Cluster, rule and error key are empty when the change is
+// not related to them, details contain action specific values like the vote
+// or the justification.
+type AuditEvent struct {
+	ID        int64             `json:"id"`
+	OrgID     types.OrgID       `json:"org_id"`
+	UserID    types.UserID      `json:"user_id"`
+	Action    AuditAction       `json:"action"`
+	ClusterID types.ClusterName `json:"cluster,omitempty"`
+	RuleID    types.RuleID      `json:"rule_id,omitempty"`
+	ErrorKey  types.ErrorKey    `json:"error_key,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// AuditEventsFilter selects audit events to be read from the storage. Zero
+// values mean that the events are not filtered by given attribute, only the
+// organization is mandatory.
+type AuditEventsFilter struct {
+	OrgID     types.OrgID
+	UserID    types.UserID
+	ClusterID types.ClusterName
+	RuleID    types.RuleID
+	ErrorKey  types.ErrorKey
+}
+
+// matches returns true when the audit event is selected by the filter
+func (filter AuditEventsFilter) matches(event *AuditEvent) bool {
+	if event.OrgID != filter.OrgID {
+		return false
+	}
+	if filter.UserID != "" && event.UserID != filter.UserID {
+		return false
+	}
+	if filter.ClusterID != "" && event.ClusterID != filter.ClusterID {
+		return false
+	}
+	if filter.RuleID != "" && event.RuleID != filter.RuleID {
+		return false
+	}
+	return filter.ErrorKey == "" || event.ErrorKey == filter.ErrorKey
+}
+
+// conditions returns SQL conditions and their arguments for the filter
+func (filter AuditEventsFilter) conditions() ([]string, []interface{}) {
+	conditions := []string{"org_id = $1"}
+	args := []interface{}{filter.OrgID}
+
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != "" {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.ClusterID != "" {
+		addCondition("cluster_id = $%d", filter.ClusterID)
+	}
+	if filter.RuleID != "" {
+		addCondition("rule_id = $%d", filter.RuleID)
+	}
+	if filter.ErrorKey != "" {
+		addCondition("error_key = $%d", filter.ErrorKey)
+	}
+
+	return conditions, args
+}
+
+// WriteAuditEvents appends the events to the audit log. Events without the
+// time of creation are stored with the current time.
+func (storage DBStorage) WriteAuditEvents(events []AuditEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	return storage.changeWithAuditEvents(events, func(*sql.Tx) error {
+		return nil
+	})
+}
+
+// changeWithAuditEvents performs the change and appends the events
+// describing it to the audit log in one transaction, so neither of them is
+// stored without the other one
+func (storage DBStorage) changeWithAuditEvents(events []AuditEvent, change func(tx *sql.Tx) error) error {
+	tx, err := storage.connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = func(tx *sql.Tx) error {
+		if err := change(tx); err != nil {
+			return err
+		}
+
+		return insertAuditEvents(tx, events)
+	}(tx)
+	if err != nil {
+		finishTransaction(tx, err)
+		return err
+	}
+
+	// the caller needs to know that neither the change nor its audit events
+	// were stored
+	return tx.Commit()
+}
+
+// insertAuditEvents inserts the events into audit_event table. Events
+// without the time of creation are stored with the current time.
+func insertAuditEvents(tx *sql.Tx, events []AuditEvent) error {
+	now := time.Now().UTC()
+
+	for i := range events {
+		event := &events[i]
+
+		details, err := json.Marshal(event.Details)
+		if err != nil {
+			return err
+		}
+
+		createdAt := event.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO audit_event
+			(org_id, user_id, action, cluster_id, rule_id, error_key, details, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			event.OrgID,
+			event.UserID,
+			event.Action,
+			event.ClusterID,
+			event.RuleID,
+			event.ErrorKey,
+			string(details),
+			createdAt.UTC(),
+		)
+		if err != nil {
+			log.Error().Err(err).Msgf("Unable to write audit event %v", event.Action)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadAuditEvents returns one page of audit events selected by the filter,
+// the events are ordered by their ID, i.e. by the time they were written.
+// Cursor of the next page is returned too, it's empty when there are no more
+// events.
+func (storage DBStorage) ReadAuditEvents(
+	filter AuditEventsFilter, options ListingOptions,
+) ([]AuditEvent, string, error) {
+	events := make([]AuditEvent, 0)
+
+	conditions, args := filter.conditions()
+
+	query, args, err := auditEventsListing.buildQuery(`
+		SELECT id, org_id, user_id, action, cluster_id, rule_id, error_key, details, created_at
+		  FROM audit_event`, conditions, args, options,
+	)
+	if err != nil {
+		return events, "", err
+	}
+
+	rows, err := storage.connection.Query(query, args...)
+
+	err = types.ConvertDBError(err, filter.OrgID)
+	if err != nil {
+		return events, "", err
+	}
+	defer closeRows(rows)
+
+	items := make([]listingItem, 0)
+	for rows.Next() {
+		var (
+			event   AuditEvent
+			details string
+		)
+
+		err = rows.Scan(
+			&event.ID,
+			&event.OrgID,
+			&event.UserID,
+			&event.Action,
+			&event.ClusterID,
+			&event.RuleID,
+			&event.ErrorKey,
+			&details,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return make([]AuditEvent, 0), "", err
+		}
+
+		if err := json.Unmarshal([]byte(details), &event.Details); err != nil {
+			log.Error().Err(err).Msgf("Unable to parse details of audit event %v", event.ID)
+		}
+
+		events = append(events, event)
+		items = append(items, listingItem{id: strconv.FormatInt(event.ID, 10)})
+	}
+
+	if err := rows.Err(); err != nil {
+		return make([]AuditEvent, 0), "", err
+	}
+
+	count, nextCursor := nextListingCursor(items, options)
+	return events[:count], nextCursor, nil
+}
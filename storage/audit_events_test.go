@@ -0,0 +1,238 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// auditEventActions returns actions of the events in the order they are
+// returned
+func auditEventActions(events []storage.AuditEvent) []storage.AuditAction {
+	actions := make([]storage.AuditAction, 0, len(events))
+	for _, event := range events {
+		actions = append(actions, event.Action)
+	}
+	return actions
+}
+
+// TestWriteAndReadAuditEvents checks that audit events are read in the
+// order they were written and that details are kept
+func TestWriteAndReadAuditEvents(t *testing.T) {
+	createdAt := time.Date(2022, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			err := mockStorage.WriteAuditEvents([]storage.AuditEvent{
+				{
+					OrgID:     testdata.OrgID,
+					UserID:    testdata.UserID,
+					Action:    storage.AuditActionVote,
+					ClusterID: testdata.ClusterName,
+					RuleID:    testdata.Rule1ID,
+					ErrorKey:  testdata.ErrorKey1,
+					Details:   map[string]string{"vote": "1"},
+					CreatedAt: createdAt,
+				},
+				{
+					OrgID:    testdata.OrgID,
+					UserID:   testdata.UserID,
+					Action:   storage.AuditActionRateRule,
+					RuleID:   testdata.Rule1ID,
+					ErrorKey: testdata.ErrorKey1,
+				},
+			})
+			helpers.FailOnError(t, err)
+
+			events, cursor, err := mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID}, storage.ListingOptions{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Empty(t, cursor)
+			assert.Len(t, events, 2)
+
+			// timestamps read from the database might be in local time zone
+			events[0].CreatedAt = events[0].CreatedAt.UTC()
+			assert.Equal(t, storage.AuditEvent{
+				ID:        events[0].ID,
+				OrgID:     testdata.OrgID,
+				UserID:    testdata.UserID,
+				Action:    storage.AuditActionVote,
+				ClusterID: testdata.ClusterName,
+				RuleID:    testdata.Rule1ID,
+				ErrorKey:  testdata.ErrorKey1,
+				Details:   map[string]string{"vote": "1"},
+				CreatedAt: createdAt,
+			}, events[0])
+
+			assert.Equal(t, storage.AuditActionRateRule, events[1].Action)
+			assert.Greater(t, events[1].ID, events[0].ID)
+			assert.False(t, events[1].CreatedAt.IsZero())
+		})
+	}
+}
+
+// TestReadAuditEventsFilterAndPagination checks that audit events can be
+// filtered by user, cluster and rule and read page by page
+func TestReadAuditEventsFilterAndPagination(t *testing.T) {
+	events := []storage.AuditEvent{
+		{OrgID: testdata.OrgID, UserID: "1", Action: storage.AuditActionDisableRule, ClusterID: testdata.ClusterName, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+		{OrgID: testdata.OrgID, UserID: "2", Action: storage.AuditActionEnableRule, ClusterID: testdata.ClusterName, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+		{OrgID: testdata.OrgID, UserID: "1", Action: storage.AuditActionDisableRuleSystemWide, RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2},
+		{OrgID: testdata.Org2ID, UserID: "1", Action: storage.AuditActionVote, ClusterID: testdata.ClusterName, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+	}
+
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			helpers.FailOnError(t, mockStorage.WriteAuditEvents(events))
+
+			read, cursor, err := mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID}, storage.ListingOptions{Limit: 2},
+			)
+			helpers.FailOnError(t, err)
+			assert.NotEmpty(t, cursor)
+			assert.Equal(t, []storage.AuditAction{
+				storage.AuditActionDisableRule, storage.AuditActionEnableRule,
+			}, auditEventActions(read))
+
+			read, cursor, err = mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID}, storage.ListingOptions{Limit: 2, Cursor: cursor},
+			)
+			helpers.FailOnError(t, err)
+			assert.Empty(t, cursor)
+			assert.Equal(t, []storage.AuditAction{
+				storage.AuditActionDisableRuleSystemWide,
+			}, auditEventActions(read))
+
+			read, _, err = mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID}, storage.ListingOptions{Descending: true},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []storage.AuditAction{
+				storage.AuditActionDisableRuleSystemWide, storage.AuditActionEnableRule, storage.AuditActionDisableRule,
+			}, auditEventActions(read))
+
+			read, _, err = mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID, UserID: "1"}, storage.ListingOptions{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []storage.AuditAction{
+				storage.AuditActionDisableRule, storage.AuditActionDisableRuleSystemWide,
+			}, auditEventActions(read))
+
+			read, _, err = mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID, ClusterID: testdata.ClusterName}, storage.ListingOptions{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []storage.AuditAction{
+				storage.AuditActionDisableRule, storage.AuditActionEnableRule,
+			}, auditEventActions(read))
+
+			read, _, err = mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID, RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2},
+				storage.ListingOptions{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []storage.AuditAction{
+				storage.AuditActionDisableRuleSystemWide,
+			}, auditEventActions(read))
+
+			read, _, err = mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID}, storage.ListingOptions{Filter: "system"},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []storage.AuditAction{
+				storage.AuditActionDisableRuleSystemWide,
+			}, auditEventActions(read))
+		})
+	}
+}
+
+// TestAuditEventsWrittenWithChange checks that audit events passed to the
+// change are written together with it
+func TestAuditEventsWrittenWithChange(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			writeReportForCluster(t, mockStorage, testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed)
+
+			err := mockStorage.ToggleRuleForCluster(
+				testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, storage.RuleToggleDisable,
+				storage.AuditEvent{
+					OrgID:     testdata.OrgID,
+					UserID:    testdata.UserID,
+					Action:    storage.AuditActionDisableRule,
+					ClusterID: testdata.ClusterName,
+					RuleID:    testdata.Rule1ID,
+					ErrorKey:  testdata.ErrorKey1,
+				},
+			)
+			helpers.FailOnError(t, err)
+
+			events, _, err := mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID}, storage.ListingOptions{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []storage.AuditAction{storage.AuditActionDisableRule}, auditEventActions(events))
+			assert.Equal(t, testdata.UserID, events[0].UserID)
+		})
+	}
+}
+
+// TestDBStorageChangeRolledBackWhenAuditFails checks that the change is not
+// stored when its audit events can't be written
+func TestDBStorageChangeRolledBackWhenAuditFails(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	writeReportForCluster(t, mockStorage, testdata.OrgID, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed)
+
+	connection := storage.GetConnection(mockStorage.(*storage.DBStorage))
+	_, err := connection.Exec("DROP TABLE audit_event;")
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, storage.RuleToggleDisable,
+		storage.AuditEvent{
+			OrgID:     testdata.OrgID,
+			UserID:    testdata.UserID,
+			Action:    storage.AuditActionDisableRule,
+			ClusterID: testdata.ClusterName,
+			RuleID:    testdata.Rule1ID,
+			ErrorKey:  testdata.ErrorKey1,
+		},
+	)
+	assert.Error(t, err)
+
+	_, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// clusterLastCheckedEntry is one item stored in clustersLastCheckedCache
+type clusterLastCheckedEntry struct {
+	clusterName types.ClusterName
+	lastChecked time.Time
+	storedAt    time.Time
+}
+
+// clustersLastCheckedCache is a cache of timestamps when the clusters were
+// last checked. It is safe for concurrent use. When maxSize is set, the
+// least recently used clusters are evicted once the cache is full. When ttl
+// is set, items older than ttl are treated as missing.
+type clustersLastCheckedCache struct {
+	mutex   sync.Mutex
+	maxSize int
+	ttl     time.Duration
+	items   map[types.ClusterName]*list.Element
+	lru     *list.List
+}
+
+// newClustersLastCheckedCache creates a new cache. Zero maxSize means that
+// the cache is unbounded, zero ttl means that items never expire.
+func newClustersLastCheckedCache(maxSize int, ttl time.Duration) *clustersLastCheckedCache {
+	return &clustersLastCheckedCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		items:   map[types.ClusterName]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// get returns the last checked timestamp for given cluster if it is stored
+// in the cache and not expired
+func (cache *clustersLastCheckedCache) get(clusterName types.ClusterName) (time.Time, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	element, found := cache.items[clusterName]
+	if !found {
+		return time.Time{}, false
+	}
+
+	entry := element.Value.(*clusterLastCheckedEntry)
+	if cache.ttl > 0 && time.Since(entry.storedAt) > cache.ttl {
+		cache.removeElement(element)
+		return time.Time{}, false
+	}
+
+	cache.lru.MoveToFront(element)
+	return entry.lastChecked, true
+}
+
+// set stores the last checked timestamp for given cluster, evicting the
+// least recently used cluster when the cache is full
+func (cache *clustersLastCheckedCache) set(clusterName types.ClusterName, lastChecked time.Time) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, found := cache.items[clusterName]; found {
+		entry := element.Value.(*clusterLastCheckedEntry)
+		entry.lastChecked = lastChecked
+		entry.storedAt = time.Now()
+		cache.lru.MoveToFront(element)
+		return
+	}
+
+	cache.items[clusterName] = cache.lru.PushFront(&clusterLastCheckedEntry{
+		clusterName: clusterName,
+		lastChecked: lastChecked,
+		storedAt:    time.Now(),
+	})
+
+	if cache.maxSize > 0 && cache.lru.Len() > cache.maxSize {
+		cache.removeElement(cache.lru.Back())
+	}
+}
+
+// remove deletes given cluster from the cache
+func (cache *clustersLastCheckedCache) remove(clusterName types.ClusterName) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if element, found := cache.items[clusterName]; found {
+		cache.removeElement(element)
+	}
+}
+
+// len returns the number of clusters stored in the cache, including the
+// expired ones that were not removed yet
+func (cache *clustersLastCheckedCache) len() int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.lru.Len()
+}
+
+// snapshot returns copy of all non-expired items stored in the cache
+func (cache *clustersLastCheckedCache) snapshot() map[types.ClusterName]time.Time {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	result := make(map[types.ClusterName]time.Time, len(cache.items))
+	for clusterName, element := range cache.items {
+		entry := element.Value.(*clusterLastCheckedEntry)
+		if cache.ttl > 0 && time.Since(entry.storedAt) > cache.ttl {
+			continue
+		}
+		result[clusterName] = entry.lastChecked
+	}
+
+	return result
+}
+
+// removeElement removes the element from both the list and the map, mutex
+// has to be locked by the caller
+func (cache *clustersLastCheckedCache) removeElement(element *list.Element) {
+	entry := cache.lru.Remove(element).(*clusterLastCheckedEntry)
+	delete(cache.items, entry.clusterName)
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func writeReportForClusterAt(
+	mockStorage storage.Storage, clusterName types.ClusterName, lastChecked time.Time,
+) error {
+	return mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		clusterName,
+		testdata.Report0Rules,
+		testdata.ReportEmptyRulesParsed,
+		lastChecked,
+		lastChecked,
+		time.Now(),
+		testdata.KafkaOffset,
+	)
+}
+
+func TestDBStorageClustersLastCheckedCacheIsBounded(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	storage.SetClustersLastCheckedCache(dbStorage, 1, 0, false)
+
+	helpers.FailOnError(t, writeReportForClusterAt(mockStorage, testdata.ClusterName, testdata.LastCheckedAt))
+	helpers.FailOnError(t, writeReportForClusterAt(mockStorage, testdata.GetRandomClusterID(), testdata.LastCheckedAt))
+
+	clustersLastChecked := storage.GetClustersLastChecked(dbStorage)
+	assert.Len(t, clustersLastChecked, 1)
+	assert.NotContains(t, clustersLastChecked, testdata.ClusterName)
+}
+
+func TestDBStorageClustersLastCheckedCacheTTL(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	storage.SetClustersLastCheckedCache(dbStorage, 0, time.Millisecond, false)
+
+	helpers.FailOnError(t, writeReportForClusterAt(mockStorage, testdata.ClusterName, testdata.LastCheckedAt))
+
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Empty(t, storage.GetClustersLastChecked(dbStorage))
+}
+
+func TestDBStorageClustersLastCheckedDBFallback(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	helpers.FailOnError(t, writeReportForClusterAt(mockStorage, testdata.ClusterName, testdata.LastCheckedAt))
+
+	// the cache is empty, but the timestamp is read from the report table
+	storage.SetClustersLastCheckedCache(dbStorage, 10, 0, true)
+
+	err := writeReportForClusterAt(mockStorage, testdata.ClusterName, testdata.LastCheckedAt)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	assert.Contains(t, storage.GetClustersLastChecked(dbStorage), testdata.ClusterName)
+}
+
+func TestDBStorageClustersLastCheckedNoDBFallback(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	helpers.FailOnError(t, writeReportForClusterAt(mockStorage, testdata.ClusterName, testdata.LastCheckedAt))
+
+	storage.SetClustersLastCheckedCache(dbStorage, 10, 0, false)
+
+	// the report is discarded inside the transaction without an error
+	err := writeReportForClusterAt(mockStorage, testdata.ClusterName, testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageInitPreloadsOnlyNewestClusters(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	newerCluster := testdata.GetRandomClusterID()
+
+	helpers.FailOnError(t, writeReportForClusterAt(mockStorage, testdata.ClusterName, testdata.LastCheckedAt))
+	helpers.FailOnError(t, writeReportForClusterAt(mockStorage, newerCluster, testdata.LastCheckedAt.Add(time.Hour)))
+
+	storage.SetClustersLastCheckedCache(dbStorage, 1, 0, false)
+
+	err := mockStorage.Init()
+	helpers.FailOnError(t, err)
+
+	clustersLastChecked := storage.GetClustersLastChecked(dbStorage)
+	assert.Len(t, clustersLastChecked, 1)
+	assert.Contains(t, clustersLastChecked, newerCluster)
+}
+
+func TestDBStorageDeleteReportsForClusterClearsLastChecked(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	helpers.FailOnError(t, writeReportForClusterAt(mockStorage, testdata.ClusterName, testdata.LastCheckedAt))
+	helpers.FailOnError(t, mockStorage.DeleteReportsForCluster(testdata.ClusterName))
+
+	assert.NotContains(t, storage.GetClustersLastChecked(dbStorage), testdata.ClusterName)
+}
+
+func TestDBStorageClustersLastCheckedConcurrentWrites(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	storage.SetClustersLastCheckedCache(dbStorage, 5, time.Minute, false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			storage.SetClustersLastChecked(dbStorage, testdata.GetRandomClusterID(), time.Now())
+			_ = storage.GetClustersLastChecked(dbStorage)
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, storage.GetClustersLastChecked(dbStorage), 5)
+}
@@ -16,6 +16,12 @@ limitations under the License.
 
 package storage
 
+import (
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
 // Configuration represents configuration of data storage
 type Configuration struct {
 	Driver           string `mapstructure:"db_driver" toml:"db_driver"`
@@ -27,4 +33,50 @@ type Configuration struct {
 	PGPort           int    `mapstructure:"pg_port" toml:"pg_port"`
 	PGDBName         string `mapstructure:"pg_db_name" toml:"pg_db_name"`
 	PGParams         string `mapstructure:"pg_params" toml:"pg_params"`
+	// ReportHistoryLength is the number of reports kept in the history for
+	// each cluster, zero value turns the history off
+	ReportHistoryLength int `mapstructure:"report_history_length" toml:"report_history_length"`
+	// ClustersLastCheckedCacheSize is the maximum number of clusters kept in
+	// the cache of last checked timestamps, zero value means unbounded cache
+	ClustersLastCheckedCacheSize int `mapstructure:"clusters_last_checked_cache_size" toml:"clusters_last_checked_cache_size"`
+	// ClustersLastCheckedCacheTTL is the time after which the cached
+	// timestamps expire, zero value means that they never expire
+	ClustersLastCheckedCacheTTL time.Duration `mapstructure:"clusters_last_checked_cache_ttl" toml:"clusters_last_checked_cache_ttl"`
+	// ClustersLastCheckedDBFallback enables reading of last checked
+	// timestamps from database for clusters that are not cached
+	ClustersLastCheckedDBFallback bool `mapstructure:"clusters_last_checked_db_fallback" toml:"clusters_last_checked_db_fallback"`
+	// CompressReports enables storing of reports in report table in
+	// compressed form
+	CompressReports bool `mapstructure:"compress_reports" toml:"compress_reports"`
+	// ReportHistoryDisabledOrgs contains organizations whose reports are
+	// never stored in the history. It is filled in from per organization
+	// processing modes in [processing] section of the service configuration.
+	ReportHistoryDisabledOrgs map[types.OrgID]bool `mapstructure:"-" toml:"-"`
+}
+
+// RetentionConfiguration represents retention policy of tables that grow
+// over time. Records older than the retention period of their table are
+// deleted periodically, zero period means that records of the table are
+// kept forever.
+type RetentionConfiguration struct {
+	// Enabled turns the periodic cleanup on
+	Enabled bool `mapstructure:"enabled" toml:"enabled"`
+	// Interval is the time between two cleanups
+	Interval time.Duration `mapstructure:"interval" toml:"interval"`
+	// ExpiredDisablesInterval is the time between two cleanups of expired
+	// rule disables, these are cleaned up even when Enabled is not set
+	ExpiredDisablesInterval time.Duration `mapstructure:"expired_disables_interval" toml:"expired_disables_interval"`
+	// ConsumerError is the retention period of consumer_error table
+	ConsumerError time.Duration `mapstructure:"consumer_error" toml:"consumer_error"`
+	// Report is the retention period of report table, report_info records
+	// of deleted reports are deleted too
+	Report time.Duration `mapstructure:"report" toml:"report"`
+	// RuleHit is the retention period of rule_hit table, rule hits are
+	// deleted when the report of their cluster is older than this period,
+	// so it must not be longer than the retention period of reports
+	RuleHit time.Duration `mapstructure:"rule_hit" toml:"rule_hit"`
+	// Recommendation is the retention period of recommendation table
+	Recommendation time.Duration `mapstructure:"recommendation" toml:"recommendation"`
+	// ReportHistory is the retention period of report_history table
+	ReportHistory time.Duration `mapstructure:"report_history" toml:"report_history"`
 }
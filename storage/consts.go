@@ -25,8 +25,11 @@ const (
 	selectorsKey = "selectors"
 	// key for recommendations' creation time
 	createdAtKey = "created_at"
-	// closeStatementError error string
-	closeStatementError = "Unable to close statement"
 	// inClauseError when constructing IN clause fails
 	inClauseError = "error constructing WHERE IN clause"
+	// maximum number of bind parameters in one Postgres statement
+	postgresMaxStatementParameters = 65535
+	// maximum number of bind parameters in one SQLite statement
+	// (SQLITE_MAX_VARIABLE_NUMBER of the bundled SQLite)
+	sqliteMaxStatementParameters = 999
 )
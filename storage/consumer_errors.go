@@ -0,0 +1,238 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ConsumerError represents one record from consumer_error table, i.e.
+// a message that the consumer was not able to process. Key and message are
+// not part of JSON representation because they can be quite large.
+type ConsumerError struct {
+	Topic      string    `json:"topic"`
+	Partition  int32     `json:"partition"`
+	Offset     int64     `json:"offset"`
+	Key        []byte    `json:"-"`
+	ProducedAt time.Time `json:"produced_at"`
+	ConsumedAt time.Time `json:"consumed_at"`
+	Message    []byte    `json:"-"`
+	Error      string    `json:"error"`
+}
+
+// ConsumerErrorsFilter selects consumer errors to be read from the storage.
+// Zero values (nil pointers) mean that the records are not filtered by given
+// attribute.
+type ConsumerErrorsFilter struct {
+	Topic     string
+	Partition *int32
+	// OffsetFrom and OffsetTo limit the offset of the message, both bounds
+	// are inclusive
+	OffsetFrom *int64
+	OffsetTo   *int64
+	// ConsumedFrom and ConsumedTo limit the time when the message was
+	// consumed, both bounds are inclusive
+	ConsumedFrom time.Time
+	ConsumedTo   time.Time
+	// ErrorText is a substring of the error
+	ErrorText string
+	// Limit is the maximum number of returned records, Offset is the number
+	// of records to be skipped
+	Limit  int
+	Offset int
+}
+
+// matches returns true when the consumer error is selected by the filter
+func (filter ConsumerErrorsFilter) matches(consumerError *ConsumerError) bool {
+	if filter.Topic != "" && consumerError.Topic != filter.Topic {
+		return false
+	}
+	if filter.Partition != nil && consumerError.Partition != *filter.Partition {
+		return false
+	}
+	if filter.OffsetFrom != nil && consumerError.Offset < *filter.OffsetFrom {
+		return false
+	}
+	if filter.OffsetTo != nil && consumerError.Offset > *filter.OffsetTo {
+		return false
+	}
+	if !filter.ConsumedFrom.IsZero() && consumerError.ConsumedAt.Before(filter.ConsumedFrom) {
+		return false
+	}
+	if !filter.ConsumedTo.IsZero() && consumerError.ConsumedAt.After(filter.ConsumedTo) {
+		return false
+	}
+	return strings.Contains(consumerError.Error, filter.ErrorText)
+}
+
+// paginate returns the page of consumer errors selected by the filter
+func (filter ConsumerErrorsFilter) paginate(consumerErrors []ConsumerError) []ConsumerError {
+	if filter.Offset >= len(consumerErrors) {
+		return []ConsumerError{}
+	}
+	consumerErrors = consumerErrors[filter.Offset:]
+
+	if filter.Limit > 0 && filter.Limit < len(consumerErrors) {
+		consumerErrors = consumerErrors[:filter.Limit]
+	}
+	return consumerErrors
+}
+
+// whereClause returns SQL condition and its arguments for the filter
+func (filter ConsumerErrorsFilter) whereClause() (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Topic != "" {
+		addCondition("topic = $%d", filter.Topic)
+	}
+	if filter.Partition != nil {
+		addCondition("partition = $%d", *filter.Partition)
+	}
+	if filter.OffsetFrom != nil {
+		addCondition("topic_offset >= $%d", *filter.OffsetFrom)
+	}
+	if filter.OffsetTo != nil {
+		addCondition("topic_offset <= $%d", *filter.OffsetTo)
+	}
+	if !filter.ConsumedFrom.IsZero() {
+		addCondition("consumed_at >= $%d", filter.ConsumedFrom.UTC())
+	}
+	if !filter.ConsumedTo.IsZero() {
+		addCondition("consumed_at <= $%d", filter.ConsumedTo.UTC())
+	}
+	if filter.ErrorText != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(filter.ErrorText)
+		addCondition(`error LIKE $%d ESCAPE '\'`, "%"+escaped+"%")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// ReadConsumerErrors reads consumer errors selected by the filter ordered by
+// the time they were consumed
+func (storage DBStorage) ReadConsumerErrors(filter ConsumerErrorsFilter) ([]ConsumerError, error) {
+	where, args := filter.whereClause()
+
+	// #nosec G202
+	query := `
+		SELECT topic, partition, topic_offset, key, produced_at, consumed_at, message, error
+		  FROM consumer_error
+		 WHERE ` + where + `
+		 ORDER BY consumed_at, topic, partition, topic_offset`
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if filter.Offset > 0 {
+		if filter.Limit <= 0 && storage.dbDriverType == types.DBDriverSQLite3 {
+			// OFFSET can't be used without LIMIT in SQLite
+			query += " LIMIT -1"
+		}
+		args = append(args, filter.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	rows, err := storage.connection.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	consumerErrors := make([]ConsumerError, 0)
+	for rows.Next() {
+		var consumerError ConsumerError
+
+		err := rows.Scan(
+			&consumerError.Topic,
+			&consumerError.Partition,
+			&consumerError.Offset,
+			&consumerError.Key,
+			&consumerError.ProducedAt,
+			&consumerError.ConsumedAt,
+			&consumerError.Message,
+			&consumerError.Error,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		consumerErrors = append(consumerErrors, consumerError)
+	}
+
+	return consumerErrors, rows.Err()
+}
+
+// ReadConsumerError reads the consumer error for message with given topic,
+// partition and offset
+func (storage DBStorage) ReadConsumerError(topic string, partition int32, offset int64) (ConsumerError, error) {
+	consumerError := ConsumerError{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    offset,
+	}
+
+	err := storage.connection.QueryRow(`
+		SELECT key, produced_at, consumed_at, message, error
+		  FROM consumer_error
+		 WHERE topic = $1 AND partition = $2 AND topic_offset = $3;`,
+		topic, partition, offset,
+	).Scan(
+		&consumerError.Key,
+		&consumerError.ProducedAt,
+		&consumerError.ConsumedAt,
+		&consumerError.Message,
+		&consumerError.Error,
+	)
+	if err != nil {
+		return consumerError, types.ConvertDBError(err, []interface{}{topic, partition, offset})
+	}
+
+	return consumerError, nil
+}
+
+// DeleteConsumerError deletes the consumer error for message with given
+// topic, partition and offset
+func (storage DBStorage) DeleteConsumerError(topic string, partition int32, offset int64) error {
+	result, err := storage.connection.Exec(`
+		DELETE FROM consumer_error
+		 WHERE topic = $1 AND partition = $2 AND topic_offset = $3;`,
+		topic, partition, offset,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v/%v/%v", topic, partition, offset)}
+	}
+
+	return nil
+}
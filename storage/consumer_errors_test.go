@@ -0,0 +1,191 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/Shopify/sarama"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func writeConsumerErrors(t *testing.T, mockStorage storage.Storage) {
+	for offset, consumerErr := range []string{
+		"unexpected end of JSON input",
+		"driver: bad connection",
+		"improper report structure, missing key reports",
+	} {
+		err := mockStorage.WriteConsumerError(&sarama.ConsumerMessage{
+			Topic:     "topic",
+			Partition: 1,
+			Offset:    int64(offset),
+			Key:       []byte("key"),
+			Value:     []byte("value"),
+			Timestamp: time.Now().Add(-time.Hour).UTC(),
+		}, errors.New(consumerErr))
+		helpers.FailOnError(t, err)
+	}
+}
+
+func checkReadAndDeleteConsumerErrors(t *testing.T, mockStorage storage.Storage) {
+	start := time.Now().Add(-time.Minute)
+	writeConsumerErrors(t, mockStorage)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 3)
+	assert.Equal(t, "topic", consumerErrors[0].Topic)
+	assert.Equal(t, int32(1), consumerErrors[0].Partition)
+	assert.Equal(t, []byte("key"), consumerErrors[0].Key)
+	assert.Equal(t, []byte("value"), consumerErrors[0].Message)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{ErrorText: "report"})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, int64(2), consumerErrors[0].Offset)
+	assert.Equal(t, "improper report structure, missing key reports", consumerErrors[0].Error)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{
+		ConsumedFrom: start,
+		ConsumedTo:   time.Now().Add(time.Minute),
+	})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 3)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{
+		ConsumedTo: start,
+	})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, consumerErrors)
+
+	var (
+		partition  int32 = 1
+		otherPart  int32 = 2
+		offsetFrom int64 = 1
+		offsetTo   int64 = 1
+	)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{
+		Topic:      "topic",
+		Partition:  &partition,
+		OffsetFrom: &offsetFrom,
+		OffsetTo:   &offsetTo,
+	})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, "driver: bad connection", consumerErrors[0].Error)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{Partition: &otherPart})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, consumerErrors)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{Topic: "other"})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, consumerErrors)
+
+	// pagination
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{Limit: 2})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 2)
+	assert.Equal(t, int64(0), consumerErrors[0].Offset)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{Limit: 2, Offset: 2})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 1)
+	assert.Equal(t, int64(2), consumerErrors[0].Offset)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{Offset: 1})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 2)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{Offset: 5})
+	helpers.FailOnError(t, err)
+	assert.Empty(t, consumerErrors)
+
+	consumerError, err := mockStorage.ReadConsumerError("topic", 1, 1)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []byte("value"), consumerError.Message)
+	assert.Equal(t, "driver: bad connection", consumerError.Error)
+
+	helpers.FailOnError(t, mockStorage.DeleteConsumerError("topic", 1, 1))
+
+	_, err = mockStorage.ReadConsumerError("topic", 1, 1)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	err = mockStorage.DeleteConsumerError("topic", 1, 1)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	consumerErrors, err = mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 2)
+}
+
+func TestDBStorageReadAndDeleteConsumerErrors(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	checkReadAndDeleteConsumerErrors(t, mockStorage)
+}
+
+func TestMemoryStorageReadAndDeleteConsumerErrors(t *testing.T) {
+	checkReadAndDeleteConsumerErrors(t, newMemoryStorage())
+}
+
+// checkWriteConsumerErrorAgain checks that the record of the message that
+// failed again is updated instead of inserted twice
+func checkWriteConsumerErrorAgain(t *testing.T, mockStorage storage.Storage) {
+	writeConsumerErrors(t, mockStorage)
+
+	before, err := mockStorage.ReadConsumerError("topic", 1, 0)
+	helpers.FailOnError(t, err)
+
+	err = mockStorage.WriteConsumerError(&sarama.ConsumerMessage{
+		Topic:     "topic",
+		Partition: 1,
+		Offset:    0,
+		Key:       []byte("key"),
+		Value:     []byte("value"),
+		Timestamp: time.Now().Add(-time.Hour).UTC(),
+	}, errors.New("driver: bad connection"))
+	helpers.FailOnError(t, err)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 3)
+
+	consumerError, err := mockStorage.ReadConsumerError("topic", 1, 0)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, "driver: bad connection", consumerError.Error)
+	assert.Equal(t, []byte("value"), consumerError.Message)
+	assert.False(t, consumerError.ConsumedAt.Before(before.ConsumedAt))
+}
+
+func TestDBStorageWriteConsumerErrorAgain(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	checkWriteConsumerErrorAgain(t, mockStorage)
+}
+
+func TestMemoryStorageWriteConsumerErrorAgain(t *testing.T) {
+	checkWriteConsumerErrorAgain(t, newMemoryStorage())
+}
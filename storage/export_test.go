@@ -50,11 +50,24 @@ func GetConnection(storage *DBStorage) *sql.DB {
 }
 
 func GetClustersLastChecked(storage *DBStorage) map[types.ClusterName]time.Time {
-	return storage.clustersLastChecked
+	return storage.clustersLastChecked.snapshot()
 }
 
 func SetClustersLastChecked(storage *DBStorage, cluster types.ClusterName, lastChecked time.Time) {
-	storage.clustersLastChecked[cluster] = lastChecked
+	storage.clustersLastChecked.set(cluster, lastChecked)
+}
+
+func SetClustersLastCheckedCache(storage *DBStorage, maxSize int, ttl time.Duration, dbFallback bool) {
+	storage.clustersLastChecked = newClustersLastCheckedCache(maxSize, ttl)
+	storage.clustersLastCheckedDBFallback = dbFallback
+}
+
+func SetReportHistoryLength(storage *DBStorage, length int) {
+	storage.reportHistoryLength = length
+}
+
+func SetReportHistoryDisabledOrgs(storage *DBStorage, orgs map[types.OrgID]bool) {
+	storage.reportHistoryDisabledOrgs = orgs
 }
 
 func InsertRecommendations(
@@ -79,3 +92,11 @@ func InsertRecommendations(
 	_ = tx.Commit()
 	return inserted, nil
 }
+
+func SetMaxStatementParameters(storage *DBStorage, maxStatementParameters int) {
+	storage.maxStatementParameters = maxStatementParameters
+}
+
+func SetCompressReports(storage *DBStorage, compressReports bool) {
+	storage.compressReports = compressReports
+}
@@ -21,29 +21,38 @@ import (
 
 	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
 	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 	ctypes "github.com/RedHatInsights/insights-results-types"
 )
 
 func TestWriteReportInfoForCluster(t *testing.T) {
-	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
-	defer closer()
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			checkWriteReportInfoForCluster(t, mockStorage)
+		})
+	}
+}
 
+func checkWriteReportInfoForCluster(t *testing.T, mockStorage storage.Storage) {
 	expectations := []struct {
-		input   []types.InfoItem
-		version types.Version
-		err     error
+		input    []types.InfoItem
+		metadata types.ClusterMetadata
+		err      error
 	}{
 		{
-			input:   nil,
-			version: "",
-			err:     nil,
+			input:    nil,
+			metadata: types.ClusterMetadata{},
+			err:      nil,
 		},
 		{
-			input:   []types.InfoItem{},
-			version: "",
-			err:     nil,
+			input:    []types.InfoItem{},
+			metadata: types.ClusterMetadata{},
+			err:      nil,
 		},
 		{
 			input: []types.InfoItem{
@@ -54,8 +63,13 @@ func TestWriteReportInfoForCluster(t *testing.T) {
 					},
 				},
 			},
-			version: "",
-			err:     nil,
+			metadata: types.ClusterMetadata{
+				Version: "",
+				Info: map[types.RuleID]map[string]string{
+					"An info ID": {"version": "1.0"},
+				},
+			},
+			err: nil,
 		},
 		{
 			input: []types.InfoItem{
@@ -66,8 +80,63 @@ func TestWriteReportInfoForCluster(t *testing.T) {
 					},
 				},
 			},
-			version: "1.0",
-			err:     nil,
+			metadata: types.ClusterMetadata{
+				Version: "1.0",
+				Info: map[types.RuleID]map[string]string{
+					"version_info|CLUSTER_VERSION_INFO": {"version": "1.0"},
+				},
+			},
+			err: nil,
+		},
+		{
+			input: []types.InfoItem{
+				{
+					InfoID: "version_info|CLUSTER_VERSION_INFO",
+					Details: map[string]string{
+						"version": "4.9",
+					},
+				},
+				{
+					InfoID: "platform_info|CLUSTER_PLATFORM_INFO",
+					Details: map[string]string{
+						"platform": "AWS",
+					},
+				},
+				{
+					InfoID: "nodes_info|CLUSTER_NODES_INFO",
+					Details: map[string]string{
+						"masters": "3",
+						"workers": "5",
+					},
+				},
+			},
+			metadata: types.ClusterMetadata{
+				Version: "4.9",
+				Info: map[types.RuleID]map[string]string{
+					"version_info|CLUSTER_VERSION_INFO":   {"version": "4.9"},
+					"platform_info|CLUSTER_PLATFORM_INFO": {"platform": "AWS"},
+					"nodes_info|CLUSTER_NODES_INFO":       {"masters": "3", "workers": "5"},
+				},
+			},
+			err: nil,
+		},
+		{
+			// the stored version is kept when the report doesn't contain any
+			input: []types.InfoItem{
+				{
+					InfoID: "platform_info|CLUSTER_PLATFORM_INFO",
+					Details: map[string]string{
+						"platform": "GCP",
+					},
+				},
+			},
+			metadata: types.ClusterMetadata{
+				Version: "4.9",
+				Info: map[types.RuleID]map[string]string{
+					"platform_info|CLUSTER_PLATFORM_INFO": {"platform": "GCP"},
+				},
+			},
+			err: nil,
 		},
 	}
 
@@ -80,10 +149,10 @@ func TestWriteReportInfoForCluster(t *testing.T) {
 		)
 		helpers.FailOnError(t, err)
 
-		version, err := mockStorage.ReadReportInfoForCluster(
+		metadata, err := mockStorage.ReadReportInfoForCluster(
 			testdata.OrgID, testdata.ClusterName,
 		)
-		assert.Equal(t, test.version, version)
+		assert.Equal(t, test.metadata, metadata)
 		assert.Equal(t, test.err, err)
 	}
 }
@@ -113,7 +182,7 @@ func TestDBStorageReadClusterListRecommendationsNoRecommendationsWithVersion(t *
 	res, err := mockStorage.ReadClusterListRecommendations([]string{string(testdata.ClusterName)}, testdata.OrgID)
 	helpers.FailOnError(t, err)
 
-	expectedMeta := ctypes.ClusterMetadata{Version: ""}
+	expectedMeta := types.ClusterMetadata{Version: ""}
 
 	assert.True(t, res[testdata.ClusterName].CreatedAt.Equal(testdata.LastCheckedAt))
 	assert.Equal(t, res[testdata.ClusterName].Meta, expectedMeta)
@@ -154,7 +223,12 @@ func TestDBStorageReadClusterListRecommendationsWithVersion(t *testing.T) {
 	res, err := mockStorage.ReadClusterListRecommendations([]string{string(testdata.ClusterName)}, testdata.OrgID)
 	helpers.FailOnError(t, err)
 
-	expectedMeta := ctypes.ClusterMetadata{Version: testdata.ClusterVersion}
+	expectedMeta := types.ClusterMetadata{
+		Version: testdata.ClusterVersion,
+		Info: map[types.RuleID]map[string]string{
+			"version_info|CLUSTER_VERSION_INFO": {"version": string(testdata.ClusterVersion)},
+		},
+	}
 	assert.True(t, res[testdata.ClusterName].CreatedAt.Equal(testdata.LastCheckedAt))
 	assert.Equal(t, res[testdata.ClusterName].Meta, expectedMeta)
 }
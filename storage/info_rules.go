@@ -16,11 +16,13 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/rs/zerolog/log"
+
 	"github.com/RedHatInsights/insights-results-aggregator/types"
-	ctypes "github.com/RedHatInsights/insights-results-types"
 )
 
 const (
@@ -53,61 +55,111 @@ func (storage DBStorage) WriteReportInfoForCluster(
 	return err
 }
 
+// clusterMetadataFromInfo returns metadata of the cluster gathered by given
+// info items. False is returned when there are no info items, so there is
+// nothing to be stored.
+func clusterMetadataFromInfo(infoItems []types.InfoItem) (types.ClusterMetadata, bool) {
+	if len(infoItems) == 0 {
+		return types.ClusterMetadata{}, false
+	}
+
+	metadata := types.ClusterMetadata{
+		Info: make(map[types.RuleID]map[string]string, len(infoItems)),
+	}
+	for _, info := range infoItems {
+		if info.InfoID == versionInfoKey {
+			metadata.Version = types.Version(info.Details["version"])
+		}
+
+		details := make(map[string]string, len(info.Details))
+		for name, value := range info.Details {
+			details[name] = value
+		}
+		metadata.Info[info.InfoID] = details
+	}
+
+	return metadata, true
+}
+
+// reportInfoValues returns values of version_info and info columns of
+// report_info table for given info items
+func reportInfoValues(infoItems []types.InfoItem) (types.Version, string, bool, error) {
+	metadata, found := clusterMetadataFromInfo(infoItems)
+	if !found {
+		return "", "", false, nil
+	}
+
+	info, err := json.Marshal(metadata.Info)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	return metadata.Version, string(info), true, nil
+}
+
 func (storage DBStorage) updateInfoReport(
 	tx *sql.Tx,
 	orgID types.OrgID,
 	clusterName types.ClusterName,
 	infoRules []types.InfoItem,
 ) error {
+	version, info, found, err := reportInfoValues(infoRules)
+	if err != nil || !found {
+		return err
+	}
+
 	// Get the UPSERT query for writing an info report into the database.
 	infoUpsertQuery := storage.getReportInfoUpsertQuery()
 
-	for _, info := range infoRules {
-		if info.InfoID != versionInfoKey {
-			continue
-		}
-
-		_, err := tx.Exec(infoUpsertQuery, orgID, clusterName, info.Details["version"])
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	_, err = tx.Exec(infoUpsertQuery, orgID, clusterName, version, info)
+	return err
 }
 
-// ReadReportInfoForCluster retrieve the Version for a given cluster and org id
+// ReadReportInfoForCluster retrieve the version and details of all info
+// items for a given cluster and org id. Empty metadata are returned when
+// no info report is stored for the cluster.
 func (storage *DBStorage) ReadReportInfoForCluster(
 	orgID types.OrgID,
 	clusterName types.ClusterName,
-) (types.Version, error) {
-	var version types.Version
-
-	err := storage.connection.QueryRow(
-		`
-SELECT 
-	COALESCE ( 
-		( 
-			SELECT version_info 
-			FROM report_info 
-			WHERE org_id = $1 AND cluster_id = $2 
-		), '') 
-		AS version_info;
-		`,
+) (types.ClusterMetadata, error) {
+	var (
+		metadata types.ClusterMetadata
+		info     string
+	)
+
+	err := storage.connection.QueryRow(`
+		SELECT version_info, info
+		  FROM report_info
+		 WHERE org_id = $1 AND cluster_id = $2;`,
 		orgID, clusterName,
-	).Scan(&version)
+	).Scan(&metadata.Version, &info)
+	if err == sql.ErrNoRows {
+		return types.ClusterMetadata{}, nil
+	}
 
 	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
-	return version, err
+	if err != nil {
+		return metadata, err
+	}
+
+	if err := json.Unmarshal([]byte(info), &metadata.Info); err != nil {
+		log.Error().Err(err).Str(clusterKey, string(clusterName)).Msg("Unable to parse info report")
+	}
+	if len(metadata.Info) == 0 {
+		metadata.Info = nil
+	}
+
+	return metadata, nil
 }
 
-func (storage DBStorage) fillInMetadata(orgID types.OrgID, clusterMap ctypes.ClusterRecommendationMap) {
+func (storage DBStorage) fillInMetadata(orgID types.OrgID, clusterMap types.ClusterRecommendationMap) {
 	for cluster, recommendationList := range clusterMap {
-		version, err := storage.ReadReportInfoForCluster(orgID, cluster)
+		metadata, err := storage.ReadReportInfoForCluster(orgID, cluster)
 		if err != nil {
 			continue
 		}
 
-		recommendationList.Meta = ctypes.ClusterMetadata{Version: version}
+		recommendationList.Meta = metadata
 		clusterMap[cluster] = recommendationList
 	}
 }
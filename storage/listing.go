@@ -0,0 +1,393 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// Names of fields that listings can be sorted by. Each listing supports
+// only some of them, the first one listed for the listing is the default.
+const (
+	// SortByOrgID sorts organizations by their ID
+	SortByOrgID = "org_id"
+	// SortByCluster sorts clusters by their name
+	SortByCluster = "cluster"
+	// SortByReportedAt sorts clusters by the time when their latest report
+	// was stored
+	SortByReportedAt = "reported_at"
+	// SortByLastCheckedAt sorts clusters by the time when they were checked
+	// for the last time
+	SortByLastCheckedAt = "last_checked_at"
+	// SortByLastSeen sorts clusters by the time when the rule was seen
+	// hitting the cluster for the last time
+	SortByLastSeen = "last_seen"
+	// SortByImpactedSince sorts clusters by the time since the rule has been
+	// hitting the cluster
+	SortByImpactedSince = "impacted_since"
+	// SortByEventID sorts audit events by their ID, i.e. by the time they
+	// were written
+	SortByEventID = "id"
+)
+
+// ListingOptions represents pagination, sorting and filtering of listings.
+// Zero value returns the complete listing in the default order.
+type ListingOptions struct {
+	// Limit is the maximum number of returned items, zero means that the
+	// number of items is not limited
+	Limit int
+	// Cursor is the cursor returned with the previous page, empty cursor
+	// means the first page
+	Cursor string
+	// SortBy is the name of the field the items are sorted by, empty value
+	// means the default sort field of the listing
+	SortBy string
+	// Descending reverses the sort order
+	Descending bool
+	// Filter is a substring that must be contained in the identifier of
+	// each returned item (case insensitive)
+	Filter string
+}
+
+// listingCursor identifies the last item of the previous page. It's sent to
+// clients in an opaque base64 encoded form.
+type listingCursor struct {
+	SortValue string `json:"v,omitempty"`
+	ID        string `json:"id"`
+}
+
+// encodeListingCursor returns the cursor in the form sent to clients
+func encodeListingCursor(cursor listingCursor) string {
+	// marshalling of the structure with two strings can't fail
+	encoded, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeListingCursor decodes the cursor sent by a client, nil is returned
+// for an empty cursor
+func decodeListingCursor(cursor string) (*listingCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	invalidCursorError := &types.ValidationError{
+		ParamName:  "cursor",
+		ParamValue: cursor,
+		ErrString:  "invalid cursor",
+	}
+
+	encoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalidCursorError
+	}
+
+	var decoded listingCursor
+	if err := json.Unmarshal(encoded, &decoded); err != nil || decoded.ID == "" {
+		return nil, invalidCursorError
+	}
+
+	return &decoded, nil
+}
+
+// listingSortField describes a field the listing can be sorted by
+type listingSortField struct {
+	// name is the name of the field used in ListingOptions.SortBy
+	name string
+	// column is the database column of the field, empty for the default
+	// sort field that is the identifier itself
+	column string
+	// isTime marks columns that are compared as timestamps, other columns
+	// are compared in the form they are read from the database
+	isTime bool
+}
+
+// listing describes a listing whose items are identified by idColumn and
+// can be sorted by sortFields
+type listing struct {
+	idColumn string
+	// filterExpression is the expression compared with the filter, the
+	// identifier is used when it's empty
+	filterExpression string
+	sortFields       []listingSortField
+}
+
+// sortField returns the sort field requested by the options
+func (l listing) sortField(options ListingOptions) (listingSortField, error) {
+	if options.SortBy == "" {
+		return l.sortFields[0], nil
+	}
+
+	names := make([]string, 0, len(l.sortFields))
+	for _, field := range l.sortFields {
+		if field.name == options.SortBy {
+			return field, nil
+		}
+		names = append(names, field.name)
+	}
+
+	return listingSortField{}, &types.ValidationError{
+		ParamName:  "sort_by",
+		ParamValue: options.SortBy,
+		ErrString:  "unsupported sort field, expected one of: " + strings.Join(names, ", "),
+	}
+}
+
+// buildQuery appends filtering, keyset pagination, ordering and limit to the
+// query selecting the listing items. Conditions and args are conditions of
+// the listing itself and their arguments. One more item than the limit is
+// selected, so it's possible to find out if there is the next page.
+func (l listing) buildQuery(
+	selectFrom string, conditions []string, args []interface{}, options ListingOptions,
+) (string, []interface{}, error) {
+	field, err := l.sortField(options)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cursor, err := decodeListingCursor(options.Cursor)
+	if err != nil {
+		return "", nil, err
+	}
+
+	placeholder := func(arg interface{}) string {
+		args = append(args, arg)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if options.Filter != "" {
+		filterExpression := l.filterExpression
+		if filterExpression == "" {
+			filterExpression = l.idColumn
+		}
+		conditions = append(conditions, fmt.Sprintf(
+			`LOWER(%s) LIKE %s ESCAPE '\'`, filterExpression, placeholder(likePattern(options.Filter)),
+		))
+	}
+
+	direction, operator := "ASC", ">"
+	if options.Descending {
+		direction, operator = "DESC", "<"
+	}
+
+	orderBy := l.idColumn + " " + direction
+
+	if field.column == "" {
+		if cursor != nil {
+			conditions = append(conditions, fmt.Sprintf("%s %s %s", l.idColumn, operator, placeholder(cursor.ID)))
+		}
+	} else {
+		orderBy = field.column + " " + direction + ", " + orderBy
+
+		if cursor != nil {
+			sortValue, err := field.cursorValue(cursor)
+			if err != nil {
+				return "", nil, err
+			}
+			value := placeholder(sortValue)
+			conditions = append(conditions, fmt.Sprintf(
+				"(%s %s %s OR (%s = %s AND %s %s %s))",
+				field.column, operator, value, field.column, value, l.idColumn, operator, placeholder(cursor.ID),
+			))
+		}
+	}
+
+	query := selectFrom
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY " + orderBy
+
+	if options.Limit > 0 {
+		query += " LIMIT " + placeholder(options.Limit+1)
+	}
+
+	return query, args, nil
+}
+
+// cursorValue returns the sort value from the cursor in the form that can
+// be compared with the column
+func (field listingSortField) cursorValue(cursor *listingCursor) (interface{}, error) {
+	if !field.isTime {
+		return cursor.SortValue, nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, cursor.SortValue)
+	if err != nil {
+		return nil, &types.ValidationError{
+			ParamName:  "cursor",
+			ParamValue: cursor.SortValue,
+			ErrString:  "invalid cursor",
+		}
+	}
+
+	return timestamp, nil
+}
+
+// likePattern returns LIKE pattern matching strings containing the filter
+func likePattern(filter string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(filter))
+	return "%" + escaped + "%"
+}
+
+// listingItem is one item of the listing read from the storage
+type listingItem struct {
+	id        string
+	sortValue string
+}
+
+// nextListingCursor returns the number of items that belong to the page and
+// the cursor of the next page. Empty cursor is returned for the last page.
+func nextListingCursor(items []listingItem, options ListingOptions) (int, string) {
+	if options.Limit <= 0 || len(items) <= options.Limit {
+		return len(items), ""
+	}
+
+	last := items[options.Limit-1]
+	return options.Limit, encodeListingCursor(listingCursor{SortValue: last.sortValue, ID: last.id})
+}
+
+// timeSortValue converts the timestamp into the sort value stored in cursors
+func timeSortValue(timestamp time.Time) string {
+	return timestamp.UTC().Format(time.RFC3339Nano)
+}
+
+// paginateListing filters, sorts and paginates listing items held in
+// memory the same way as buildQuery does in the database. Indexes of items
+// that belong to the page are returned along with the cursor of the next
+// page.
+func paginateListing(
+	l listing, items []listingItem, filterValues []string, options ListingOptions,
+) ([]int, string, error) {
+	field, err := l.sortField(options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cursor, err := decodeListingCursor(options.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// sort values of timestamps are compared in fixed width form
+	sortKey := func(item listingItem) string {
+		if !field.isTime {
+			return item.sortValue
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, item.sortValue)
+		if err != nil {
+			return item.sortValue
+		}
+		return timestamp.UTC().Format("2006-01-02T15:04:05.000000000")
+	}
+
+	less := func(a, b listingItem) bool {
+		if field.column != "" && sortKey(a) != sortKey(b) {
+			return sortKey(a) < sortKey(b)
+		}
+		return compareIDs(a.id, b.id) < 0
+	}
+	if options.Descending {
+		ascending := less
+		less = func(a, b listingItem) bool { return ascending(b, a) }
+	}
+
+	filter := strings.ToLower(options.Filter)
+	indexes := make([]int, 0, len(items))
+	for i, item := range items {
+		if filter != "" && !strings.Contains(strings.ToLower(filterValues[i]), filter) {
+			continue
+		}
+		if cursor != nil && !less(listingItem{id: cursor.ID, sortValue: cursor.SortValue}, item) {
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return less(items[indexes[i]], items[indexes[j]])
+	})
+
+	page := make([]listingItem, len(indexes))
+	for i, index := range indexes {
+		page[i] = items[index]
+	}
+	count, nextCursor := nextListingCursor(page, options)
+
+	return indexes[:count], nextCursor, nil
+}
+
+// compareIDs compares identifiers the same way as the database does,
+// numeric identifiers are compared as numbers
+func compareIDs(a, b string) int {
+	if len(a) != len(b) && isNumeric(a) && isNumeric(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// isNumeric checks if the string contains digits only
+func isNumeric(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+// orgsListing is the listing of organizations
+var orgsListing = listing{
+	idColumn:         "org_id",
+	filterExpression: "CAST(org_id AS TEXT)",
+	sortFields:       []listingSortField{{name: SortByOrgID}},
+}
+
+// clustersListing is the listing of clusters of one organization
+var clustersListing = listing{
+	idColumn: "cluster",
+	sortFields: []listingSortField{
+		{name: SortByCluster},
+		{name: SortByReportedAt, column: "reported_at", isTime: true},
+		{name: SortByLastCheckedAt, column: "last_checked_at", isTime: true},
+	},
+}
+
+// ruleClustersListing is the listing of clusters hit by one rule
+var ruleClustersListing = listing{
+	idColumn: "cluster_id",
+	sortFields: []listingSortField{
+		{name: SortByCluster},
+		{name: SortByLastSeen, column: "created_at"},
+		{name: SortByImpactedSince, column: "impacted_since"},
+	},
+}
+
+// auditEventsListing is the listing of audit events of one organization,
+// the filter is matched with the action
+var auditEventsListing = listing{
+	idColumn:         "id",
+	filterExpression: "action",
+	sortFields:       []listingSortField{{name: SortByEventID}},
+}
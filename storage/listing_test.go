@@ -0,0 +1,227 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// listingStorages returns all storages that implement listings, so the same
+// test can check that they behave the same way
+func listingStorages(t *testing.T) map[string]func() (storage.Storage, func()) {
+	return map[string]func() (storage.Storage, func()){
+		"db": func() (storage.Storage, func()) {
+			return ira_helpers.MustGetMockStorage(t, true)
+		},
+		"memory": func() (storage.Storage, func()) {
+			return storage.NewMemoryStorage(storage.Configuration{}), func() {}
+		},
+	}
+}
+
+// TestListOfOrgsPage checks that organizations can be read page by page
+// using the returned cursor
+func TestListOfOrgsPage(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			for _, orgID := range []types.OrgID{2, 10, 1} {
+				writeReportForCluster(t, mockStorage, orgID, testdata.GetRandomClusterID(), testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed)
+			}
+
+			orgs, cursor, err := mockStorage.ListOfOrgsPage(storage.ListingOptions{Limit: 2})
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []types.OrgID{1, 2}, orgs)
+			assert.NotEmpty(t, cursor)
+
+			orgs, cursor, err = mockStorage.ListOfOrgsPage(storage.ListingOptions{Limit: 2, Cursor: cursor})
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []types.OrgID{10}, orgs)
+			assert.Empty(t, cursor)
+
+			orgs, _, err = mockStorage.ListOfOrgsPage(storage.ListingOptions{Descending: true})
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []types.OrgID{10, 2, 1}, orgs)
+
+			orgs, _, err = mockStorage.ListOfOrgsPage(storage.ListingOptions{Filter: "1"})
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []types.OrgID{1, 10}, orgs)
+		})
+	}
+}
+
+// TestListOfClustersForOrgPage checks sorting, filtering and pagination of
+// clusters of one organization
+func TestListOfClustersForOrgPage(t *testing.T) {
+	clusters := []types.ClusterName{
+		"aaaaaaaa-0000-0000-0000-000000000000",
+		"bbbbbbbb-0000-0000-0000-000000000000",
+		"cccccccc-0000-0000-0000-000000000000",
+	}
+	// the last cluster was checked first
+	lastChecked := []time.Time{
+		testdata.LastCheckedAt.Add(time.Hour),
+		testdata.LastCheckedAt.Add(2 * time.Hour),
+		testdata.LastCheckedAt,
+	}
+	timeLimit := time.Now().Add(-time.Hour)
+
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			for i, clusterName := range clusters {
+				helpers.FailOnError(t, mockStorage.WriteReportForCluster(
+					testdata.OrgID, clusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+					lastChecked[i], lastChecked[i], time.Now(), testdata.KafkaOffset,
+				))
+			}
+
+			options := storage.ListingOptions{Limit: 1, SortBy: storage.SortByLastCheckedAt, Descending: true}
+			var result []types.ClusterName
+			for {
+				page, cursor, err := mockStorage.ListOfClustersForOrgPage(testdata.OrgID, timeLimit, time.Time{}, options)
+				helpers.FailOnError(t, err)
+				result = append(result, page...)
+				if cursor == "" {
+					break
+				}
+				options.Cursor = cursor
+			}
+			assert.Equal(t, []types.ClusterName{clusters[1], clusters[0], clusters[2]}, result)
+
+			result, cursor, err := mockStorage.ListOfClustersForOrgPage(
+				testdata.OrgID, timeLimit, time.Time{}, storage.ListingOptions{Filter: "BBB"},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []types.ClusterName{clusters[1]}, result)
+			assert.Empty(t, cursor)
+		})
+	}
+}
+
+// TestListOfClustersForOrgSpecificRulePage checks pagination of clusters
+// hit by one rule
+func TestListOfClustersForOrgSpecificRulePage(t *testing.T) {
+	clusters := []types.ClusterName{
+		"aaaaaaaa-0000-0000-0000-000000000000",
+		"bbbbbbbb-0000-0000-0000-000000000000",
+		"cccccccc-0000-0000-0000-000000000000",
+	}
+	selector := types.RuleSelector(testdata.Rule1CompositeID)
+
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			for _, clusterName := range clusters {
+				helpers.FailOnError(t, mockStorage.WriteRecommendationsForCluster(
+					testdata.OrgID, clusterName, testdata.Report3Rules, RecommendationCreatedAtTimestamp,
+				))
+			}
+
+			options := storage.ListingOptions{Limit: 2, SortBy: storage.SortByLastSeen, Descending: true}
+			page, cursor, err := mockStorage.ListOfClustersForOrgSpecificRulePage(testdata.OrgID, selector, nil, options)
+			helpers.FailOnError(t, err)
+			assert.Len(t, page, 2)
+			assert.Equal(t, clusters[2], page[0].Cluster)
+			assert.Equal(t, clusters[1], page[1].Cluster)
+
+			options.Cursor = cursor
+			page, cursor, err = mockStorage.ListOfClustersForOrgSpecificRulePage(testdata.OrgID, selector, nil, options)
+			helpers.FailOnError(t, err)
+			assert.Len(t, page, 1)
+			assert.Equal(t, clusters[0], page[0].Cluster)
+			assert.Empty(t, cursor)
+
+			// empty first page means that the rule doesn't hit any cluster
+			_, _, err = mockStorage.ListOfClustersForOrgSpecificRulePage(
+				testdata.OrgID, selector, nil, storage.ListingOptions{Filter: "ddd"},
+			)
+			assert.IsType(t, &types.ItemNotFoundError{}, err)
+		})
+	}
+}
+
+// TestListingInvalidOptions checks that invalid sort field and cursor are
+// reported as validation errors
+func TestListingInvalidOptions(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			_, _, err := mockStorage.ListOfOrgsPage(storage.ListingOptions{SortBy: storage.SortByReportedAt})
+			assert.EqualError(t, err, "Error during validating param 'sort_by' with value 'reported_at'. Error: 'unsupported sort field, expected one of: org_id'")
+			assert.IsType(t, &types.ValidationError{}, err)
+
+			_, _, err = mockStorage.ListOfClustersForOrgPage(
+				testdata.OrgID, time.Now(), time.Time{}, storage.ListingOptions{Cursor: "not a cursor"},
+			)
+			assert.IsType(t, &types.ValidationError{}, err)
+		})
+	}
+}
+
+// TestListOfClustersForOrgPageTimeWindow checks that only clusters reported
+// in the given time window are listed
+func TestListOfClustersForOrgPageTimeWindow(t *testing.T) {
+	now := time.Now()
+	clusters := []types.ClusterName{
+		"aaaaaaaa-0000-0000-0000-000000000000",
+		"bbbbbbbb-0000-0000-0000-000000000000",
+		"cccccccc-0000-0000-0000-000000000000",
+	}
+	reportedAt := []time.Time{now.Add(-3 * time.Hour), now.Add(-2 * time.Hour), now.Add(-time.Hour)}
+
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			for i, clusterName := range clusters {
+				helpers.FailOnError(t, mockStorage.WriteReportForCluster(
+					testdata.OrgID, clusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed,
+					testdata.LastCheckedAt, testdata.LastCheckedAt, reportedAt[i], testdata.KafkaOffset,
+				))
+			}
+
+			result, _, err := mockStorage.ListOfClustersForOrgPage(
+				testdata.OrgID, now.Add(-150*time.Minute), time.Time{}, storage.ListingOptions{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, clusters[1:], result)
+
+			result, _, err = mockStorage.ListOfClustersForOrgPage(
+				testdata.OrgID, now.Add(-4*time.Hour), now.Add(-90*time.Minute), storage.ListingOptions{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, clusters[:2], result)
+		})
+	}
+}
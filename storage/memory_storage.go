@@ -0,0 +1,1997 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/rs/zerolog/log"
+
+	ctypes "github.com/RedHatInsights/insights-results-types"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// MemoryDriver is the value of db_driver configuration option that selects
+// the in-memory storage
+const MemoryDriver = "memory"
+
+// memoryRuleHit represents one record from rule_hit table
+type memoryRuleHit struct {
+	ruleFQDN     types.RuleID
+	errorKey     types.ErrorKey
+	templateData string
+	createdAt    time.Time
+}
+
+// memoryReport represents one record from report table together with its
+// rule hits
+type memoryReport struct {
+	orgID       types.OrgID
+	report      types.ClusterReport
+	reportedAt  time.Time
+	lastChecked time.Time
+	gatheredAt  time.Time
+	kafkaOffset types.KafkaOffset
+	ruleHits    []memoryRuleHit
+}
+
+// memoryReportInfo represents one record from report_info table
+type memoryReportInfo struct {
+	orgID    types.OrgID
+	metadata types.ClusterMetadata
+}
+
+// memoryClusterKey identifies cluster in given organization
+type memoryClusterKey struct {
+	orgID       types.OrgID
+	clusterName types.ClusterName
+}
+
+// memoryRecommendation represents one record from recommendation table
+type memoryRecommendation struct {
+	ruleID        types.RuleID
+	createdAt     types.Timestamp
+	impactedSince types.Timestamp
+}
+
+// memoryHistoryItem represents one record from report_history table
+type memoryHistoryItem struct {
+	report      types.ClusterReport
+	reportedAt  time.Time
+	lastChecked time.Time
+	gatheredAt  time.Time
+	kafkaOffset types.KafkaOffset
+}
+
+// memoryFeedbackKey identifies user feedback on rule for cluster
+type memoryFeedbackKey struct {
+	clusterID types.ClusterName
+	ruleID    types.RuleID
+	errorKey  types.ErrorKey
+	userID    types.UserID
+}
+
+// memoryFeedback represents one record from cluster_rule_user_feedback or
+// cluster_user_rule_disable_feedback tables
+type memoryFeedback struct {
+	orgID    types.OrgID
+	feedback UserFeedbackOnRule
+}
+
+// memoryToggleKey identifies rule toggle for cluster
+type memoryToggleKey struct {
+	clusterID types.ClusterName
+	ruleID    types.RuleID
+	errorKey  types.ErrorKey
+}
+
+// memoryToggle represents one record from cluster_rule_toggle table
+type memoryToggle struct {
+	orgID      types.OrgID
+	disabled   RuleToggle
+	disabledAt sql.NullTime
+	enabledAt  sql.NullTime
+	updatedAt  sql.NullTime
+	expiresAt  sql.NullTime
+}
+
+// isDisabled checks if the toggle disables the rule and the disable has not
+// expired yet
+func (toggle memoryToggle) isDisabled(now time.Time) bool {
+	return toggle.disabled == RuleToggleDisable && !ruleDisableExpired(toggle.expiresAt, now)
+}
+
+// memorySystemWideDisable represents one record from rule_disable table
+type memorySystemWideDisable struct {
+	rule      ctypes.SystemWideRuleDisable
+	expiresAt sql.NullTime
+}
+
+// memoryRuleKey identifies rule in given organization
+type memoryRuleKey struct {
+	orgID    types.OrgID
+	ruleID   types.RuleID
+	errorKey types.ErrorKey
+}
+
+// memoryConsumerError represents one record from consumer_error table
+type memoryConsumerError struct {
+	topic      string
+	partition  int32
+	offset     int64
+	key        []byte
+	producedAt time.Time
+	consumedAt time.Time
+	message    []byte
+	err        string
+}
+
+// MemoryStorage is an implementation of Storage interface that keeps all
+// data in memory. It behaves the same way as DBStorage, but it does not
+// need any database, so it is useful for demos and for integration tests.
+// All data are lost when the process is terminated.
+type MemoryStorage struct {
+	mutex sync.RWMutex
+	// reportHistoryLength is the number of reports kept in the history
+	// for each cluster (zero means that the history is not stored)
+	reportHistoryLength int
+	// reportHistoryDisabledOrgs contains organizations whose reports are
+	// not stored in the history
+	reportHistoryDisabledOrgs map[types.OrgID]bool
+
+	reports            map[types.ClusterName]*memoryReport
+	reportInfos        map[types.ClusterName]memoryReportInfo
+	recommendations    map[memoryClusterKey][]memoryRecommendation
+	reportHistory      map[memoryClusterKey][]memoryHistoryItem
+	feedbacks          map[memoryFeedbackKey]memoryFeedback
+	disableFeedbacks   map[memoryFeedbackKey]memoryFeedback
+	toggles            map[memoryToggleKey]memoryToggle
+	ratings            map[memoryRuleKey]types.UserVote
+	systemWideDisables map[memoryRuleKey]memorySystemWideDisable
+	consumerErrors     []memoryConsumerError
+	auditEvents        []AuditEvent
+}
+
+// NewMemoryStorage function creates and initializes a new instance of
+// in-memory storage
+func NewMemoryStorage(configuration Configuration) *MemoryStorage {
+	return &MemoryStorage{
+		reportHistoryLength:       configuration.ReportHistoryLength,
+		reportHistoryDisabledOrgs: configuration.ReportHistoryDisabledOrgs,
+		reports:                   map[types.ClusterName]*memoryReport{},
+		reportInfos:               map[types.ClusterName]memoryReportInfo{},
+		recommendations:           map[memoryClusterKey][]memoryRecommendation{},
+		reportHistory:             map[memoryClusterKey][]memoryHistoryItem{},
+		feedbacks:                 map[memoryFeedbackKey]memoryFeedback{},
+		disableFeedbacks:          map[memoryFeedbackKey]memoryFeedback{},
+		toggles:                   map[memoryToggleKey]memoryToggle{},
+		ratings:                   map[memoryRuleKey]types.UserVote{},
+		systemWideDisables:        map[memoryRuleKey]memorySystemWideDisable{},
+	}
+}
+
+// Init does nothing, because in-memory storage does not need any
+// initialization
+func (storage *MemoryStorage) Init() error {
+	return nil
+}
+
+// Close does nothing, stored data are kept as the storage might be shared
+// between several parts of the service
+func (storage *MemoryStorage) Close() error {
+	return nil
+}
+
+// formatTimestamp converts time to the format returned by DBStorage
+func formatTimestamp(t time.Time) types.Timestamp {
+	return types.Timestamp(t.UTC().Format(time.RFC3339))
+}
+
+// nullTime converts time to sql.NullTime, zero time is converted to NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// ruleHitsToRulesOnReport converts stored rule hits to the structure
+// returned by storage
+func ruleHitsToRulesOnReport(ruleHits []memoryRuleHit) []types.RuleOnReport {
+	report := make([]types.RuleOnReport, 0, len(ruleHits))
+
+	for _, ruleHit := range ruleHits {
+		report = append(report, types.RuleOnReport{
+			Module:       ruleHit.ruleFQDN,
+			ErrorKey:     ruleHit.errorKey,
+			TemplateData: parseTemplateData([]byte(ruleHit.templateData)),
+			CreatedAt:    formatTimestamp(ruleHit.createdAt),
+		})
+	}
+
+	return report
+}
+
+// ListOfOrgs reads list of all organizations that have at least one cluster report
+func (storage *MemoryStorage) ListOfOrgs() ([]types.OrgID, error) {
+	orgs, _, err := storage.ListOfOrgsPage(ListingOptions{})
+	return orgs, err
+}
+
+// ListOfOrgsPage reads one page of the list of organizations that have at
+// least one cluster report
+func (storage *MemoryStorage) ListOfOrgsPage(options ListingOptions) ([]types.OrgID, string, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	orgs := make([]types.OrgID, 0)
+	items := make([]listingItem, 0)
+	filterValues := make([]string, 0)
+	found := make(map[types.OrgID]bool)
+
+	for _, report := range storage.reports {
+		if !found[report.orgID] {
+			found[report.orgID] = true
+			orgs = append(orgs, report.orgID)
+			items = append(items, listingItem{id: fmt.Sprint(report.orgID)})
+			filterValues = append(filterValues, fmt.Sprint(report.orgID))
+		}
+	}
+
+	indexes, nextCursor, err := paginateListing(orgsListing, items, filterValues, options)
+	if err != nil {
+		return make([]types.OrgID, 0), "", err
+	}
+
+	page := make([]types.OrgID, 0, len(indexes))
+	for _, index := range indexes {
+		page = append(page, orgs[index])
+	}
+
+	return page, nextCursor, nil
+}
+
+// ListOfClustersForOrg reads list of all clusters fro given organization
+func (storage *MemoryStorage) ListOfClustersForOrg(orgID types.OrgID, timeLimit time.Time) ([]types.ClusterName, error) {
+	clusters, _, err := storage.ListOfClustersForOrgPage(orgID, timeLimit, time.Time{}, ListingOptions{})
+	return clusters, err
+}
+
+// ListOfClustersForOrgPage reads one page of the list of clusters for given
+// organization reported between since and until (inclusive), zero until
+// means no upper limit
+func (storage *MemoryStorage) ListOfClustersForOrgPage(
+	orgID types.OrgID, since, until time.Time, options ListingOptions,
+) ([]types.ClusterName, string, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	sortField, err := clustersListing.sortField(options)
+	if err != nil {
+		return make([]types.ClusterName, 0), "", err
+	}
+
+	clusters := make([]types.ClusterName, 0)
+	items := make([]listingItem, 0)
+	filterValues := make([]string, 0)
+
+	for clusterName, report := range storage.reports {
+		if report.orgID != orgID || report.reportedAt.Before(since) {
+			continue
+		}
+		if !until.IsZero() && report.reportedAt.After(until) {
+			continue
+		}
+
+		item := listingItem{id: string(clusterName)}
+		switch sortField.name {
+		case SortByReportedAt:
+			item.sortValue = timeSortValue(report.reportedAt)
+		case SortByLastCheckedAt:
+			item.sortValue = timeSortValue(report.lastChecked)
+		}
+
+		clusters = append(clusters, clusterName)
+		items = append(items, item)
+		filterValues = append(filterValues, string(clusterName))
+	}
+
+	indexes, nextCursor, err := paginateListing(clustersListing, items, filterValues, options)
+	if err != nil {
+		return make([]types.ClusterName, 0), "", err
+	}
+
+	page := make([]types.ClusterName, 0, len(indexes))
+	for _, index := range indexes {
+		page = append(page, clusters[index])
+	}
+
+	return page, nextCursor, nil
+}
+
+// ListOfClustersForOrgSpecificRule returns list of all clusters for given organization that are affect by given rule
+func (storage *MemoryStorage) ListOfClustersForOrgSpecificRule(
+	orgID types.OrgID,
+	ruleID types.RuleSelector,
+	activeClusters []string,
+) ([]ctypes.HittingClustersData, error) {
+	results, _, err := storage.ListOfClustersForOrgSpecificRulePage(orgID, ruleID, activeClusters, ListingOptions{})
+	return results, err
+}
+
+// ListOfClustersForOrgSpecificRulePage returns one page of the list of
+// clusters for given organization that are affected by given rule
+func (storage *MemoryStorage) ListOfClustersForOrgSpecificRulePage(
+	orgID types.OrgID,
+	ruleID types.RuleSelector,
+	activeClusters []string,
+	options ListingOptions,
+) ([]ctypes.HittingClustersData, string, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	sortField, err := ruleClustersListing.sortField(options)
+	if err != nil {
+		return make([]ctypes.HittingClustersData, 0), "", err
+	}
+
+	results := make([]ctypes.HittingClustersData, 0)
+	items := make([]listingItem, 0)
+	filterValues := make([]string, 0)
+
+	active := make(map[types.ClusterName]bool, len(activeClusters))
+	for _, cluster := range activeClusters {
+		active[types.ClusterName(cluster)] = true
+	}
+
+	for key, recommendations := range storage.recommendations {
+		if key.orgID != orgID || (len(active) > 0 && !active[key.clusterName]) {
+			continue
+		}
+
+		for _, recommendation := range recommendations {
+			if recommendation.ruleID == types.RuleID(ruleID) {
+				item := listingItem{id: string(key.clusterName)}
+				switch sortField.name {
+				case SortByLastSeen:
+					item.sortValue = string(recommendation.createdAt)
+				case SortByImpactedSince:
+					item.sortValue = string(recommendation.impactedSince)
+				}
+
+				results = append(results, ctypes.HittingClustersData{
+					Cluster:       key.clusterName,
+					LastSeen:      string(recommendation.createdAt),
+					ImpactedSince: string(recommendation.impactedSince),
+				})
+				items = append(items, item)
+				filterValues = append(filterValues, string(key.clusterName))
+			}
+		}
+	}
+
+	indexes, nextCursor, err := paginateListing(ruleClustersListing, items, filterValues, options)
+	if err != nil {
+		return make([]ctypes.HittingClustersData, 0), "", err
+	}
+
+	if len(indexes) == 0 && options.Cursor == "" {
+		return make([]ctypes.HittingClustersData, 0), "", &types.ItemNotFoundError{ItemID: ruleID}
+	}
+
+	page := make([]ctypes.HittingClustersData, 0, len(indexes))
+	for _, index := range indexes {
+		page = append(page, results[index])
+	}
+
+	return page, nextCursor, nil
+}
+
+// GetOrgIDByClusterID reads OrgID for specified cluster
+func (storage *MemoryStorage) GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	report, found := storage.reports[cluster]
+	if !found {
+		log.Error().Err(sql.ErrNoRows).Msg("GetOrgIDByClusterID")
+		return 0, sql.ErrNoRows
+	}
+
+	return report.orgID, nil
+}
+
+// ReadOrgIDsForClusters read organization IDs for given list of cluster names.
+func (storage *MemoryStorage) ReadOrgIDsForClusters(clusterNames []types.ClusterName) ([]types.OrgID, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	ids := make([]types.OrgID, 0)
+	found := make(map[types.OrgID]bool)
+
+	for _, clusterName := range clusterNames {
+		report, exists := storage.reports[clusterName]
+		if exists && !found[report.orgID] {
+			found[report.orgID] = true
+			ids = append(ids, report.orgID)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, nil
+}
+
+// ReadReportsForClusters function reads reports for given list of cluster
+// names.
+func (storage *MemoryStorage) ReadReportsForClusters(
+	clusterNames []types.ClusterName,
+) (map[types.ClusterName]types.ClusterReport, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	reports := make(map[types.ClusterName]types.ClusterReport)
+
+	for _, clusterName := range clusterNames {
+		if report, exists := storage.reports[clusterName]; exists {
+			reports[clusterName] = report.report
+		}
+	}
+
+	return reports, nil
+}
+
+// ReadReportForCluster reads result (health status) for selected cluster
+func (storage *MemoryStorage) ReadReportForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, types.Timestamp, types.Timestamp, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	report, found := storage.reports[clusterName]
+	if !found || report.orgID != orgID {
+		var zeroTime time.Time
+		err := types.ConvertDBError(sql.ErrNoRows, []interface{}{orgID, clusterName})
+		return []types.RuleOnReport{}, formatTimestamp(zeroTime), formatTimestamp(zeroTime), "", err
+	}
+
+	var gatheredAt types.Timestamp
+	if !report.gatheredAt.IsZero() {
+		gatheredAt = formatTimestamp(report.gatheredAt)
+	}
+
+	return ruleHitsToRulesOnReport(report.ruleHits),
+		formatTimestamp(report.lastChecked),
+		formatTimestamp(report.reportedAt),
+		gatheredAt,
+		nil
+}
+
+// ReadSingleRuleTemplateData reads template data for a single rule
+func (storage *MemoryStorage) ReadSingleRuleTemplateData(
+	orgID types.OrgID, clusterName types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey,
+) (interface{}, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	if report, found := storage.reports[clusterName]; found && report.orgID == orgID {
+		for _, ruleHit := range report.ruleHits {
+			if ruleHit.ruleFQDN == ruleID && ruleHit.errorKey == errorKey {
+				return parseTemplateData([]byte(ruleHit.templateData)), nil
+			}
+		}
+	}
+
+	return nil, types.ConvertDBError(sql.ErrNoRows, []interface{}{orgID, clusterName, ruleID, errorKey})
+}
+
+// ReadReportForClusterByClusterName reads result (health status) for selected cluster for given organization
+func (storage *MemoryStorage) ReadReportForClusterByClusterName(
+	clusterName types.ClusterName,
+) ([]types.RuleOnReport, types.Timestamp, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	report, found := storage.reports[clusterName]
+	if !found {
+		return []types.RuleOnReport{}, "", &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v", clusterName),
+		}
+	}
+
+	return ruleHitsToRulesOnReport(report.ruleHits), formatTimestamp(report.lastChecked), nil
+}
+
+// GetLatestKafkaOffset returns latest kafka offset from stored reports
+func (storage *MemoryStorage) GetLatestKafkaOffset() (types.KafkaOffset, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	var offset types.KafkaOffset
+	for _, report := range storage.reports {
+		if report.kafkaOffset > offset {
+			offset = report.kafkaOffset
+		}
+	}
+
+	return offset, nil
+}
+
+// WriteReportForCluster writes result (health status) for selected cluster for given organization
+func (storage *MemoryStorage) WriteReportForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	rules []types.ReportItem,
+	lastCheckedTime time.Time,
+	gatheredAt time.Time,
+	storedAtTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	// Skip writing the report if it isn't newer than a report
+	// that is already stored for the same cluster.
+	oldReport, exists := storage.reports[clusterName]
+	if exists && !lastCheckedTime.After(oldReport.lastChecked) {
+		return types.ErrOldReport
+	}
+
+	// rules that were already hitting the cluster keep their created_at
+	ruleKeyCreatedAt := make(map[string]time.Time)
+	if exists && oldReport.orgID == orgID {
+		for _, ruleHit := range oldReport.ruleHits {
+			ruleKeyCreatedAt[string(ruleHit.ruleFQDN)+string(ruleHit.errorKey)] = ruleHit.createdAt
+		}
+	}
+
+	now := time.Now().UTC()
+	ruleHits := make([]memoryRuleHit, 0, len(rules))
+	for _, rule := range rules {
+		createdAt, found := ruleKeyCreatedAt[string(rule.Module)+string(rule.ErrorKey)]
+		if !found {
+			createdAt = now
+		}
+
+		ruleHits = append(ruleHits, memoryRuleHit{
+			ruleFQDN:     rule.Module,
+			errorKey:     rule.ErrorKey,
+			templateData: string(rule.TemplateData),
+			createdAt:    createdAt,
+		})
+	}
+
+	storage.reports[clusterName] = &memoryReport{
+		orgID:       orgID,
+		report:      report,
+		reportedAt:  storedAtTime,
+		lastChecked: lastCheckedTime,
+		gatheredAt:  gatheredAt,
+		kafkaOffset: kafkaOffset,
+		ruleHits:    ruleHits,
+	}
+
+	if storage.reportHistoryLength > 0 && !storage.reportHistoryDisabledOrgs[orgID] {
+		storage.insertReportHistory(memoryClusterKey{orgID, clusterName}, memoryHistoryItem{
+			report:      report,
+			reportedAt:  storedAtTime,
+			lastChecked: lastCheckedTime,
+			gatheredAt:  gatheredAt,
+			kafkaOffset: kafkaOffset,
+		})
+	}
+
+	metrics.WrittenReports.Inc()
+
+	return nil
+}
+
+// insertReportHistory stores the report into the history and removes the
+// oldest reports so that at most reportHistoryLength reports remain there.
+// It has to be called with the mutex locked.
+func (storage *MemoryStorage) insertReportHistory(key memoryClusterKey, item memoryHistoryItem) {
+	history := storage.reportHistory[key]
+
+	replaced := false
+	for i := range history {
+		if history[i].lastChecked.Equal(item.lastChecked) {
+			history[i] = item
+			replaced = true
+		}
+	}
+	if !replaced {
+		history = append(history, item)
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].lastChecked.Before(history[j].lastChecked)
+	})
+
+	if len(history) > storage.reportHistoryLength {
+		history = history[len(history)-storage.reportHistoryLength:]
+	}
+
+	storage.reportHistory[key] = history
+}
+
+// ReadReportHistoryForCluster reads all reports stored in the history for
+// given cluster. Reports are ordered from the oldest one to the newest one.
+func (storage *MemoryStorage) ReadReportHistoryForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]ReportHistoryItem, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	history := make([]ReportHistoryItem, 0)
+
+	for _, stored := range storage.reportHistory[memoryClusterKey{orgID, clusterName}] {
+		var hitRules reportHitRules
+
+		err := json.Unmarshal([]byte(stored.report), &hitRules)
+		if err != nil {
+			log.Error().Err(err).Str(clusterKey, string(clusterName)).Msg(
+				"Unable to parse report stored in history",
+			)
+			return history, err
+		}
+
+		item := ReportHistoryItem{
+			LastCheckedAt: formatTimestamp(stored.lastChecked),
+			ReportedAt:    formatTimestamp(stored.reportedAt),
+			HitRules:      hitRules.HitRules,
+		}
+		if !stored.gatheredAt.IsZero() {
+			item.GatheredAt = formatTimestamp(stored.gatheredAt)
+		}
+		if item.HitRules == nil {
+			item.HitRules = []types.ReportItem{}
+		}
+
+		history = append(history, item)
+	}
+
+	if len(history) == 0 {
+		return history, &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v/%v", orgID, clusterName),
+		}
+	}
+
+	return history, nil
+}
+
+// WriteReportInfoForCluster writes the relevant report info for selected cluster for hiven organization
+func (storage *MemoryStorage) WriteReportInfoForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	info []types.InfoItem,
+	lastCheckedTime time.Time,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	if metadata, found := clusterMetadataFromInfo(info); found {
+		// the stored version is kept when the new info report doesn't
+		// contain any, the same as in DBStorage
+		if stored, exists := storage.reportInfos[clusterName]; exists && metadata.Version == "" {
+			metadata.Version = stored.metadata.Version
+		}
+		storage.reportInfos[clusterName] = memoryReportInfo{
+			orgID:    orgID,
+			metadata: metadata,
+		}
+	}
+
+	return nil
+}
+
+// ReadReportInfoForCluster retrieve the version and details of all info
+// items for a given cluster and org id
+func (storage *MemoryStorage) ReadReportInfoForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+) (types.ClusterMetadata, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return storage.readReportInfoForCluster(orgID, clusterName), nil
+}
+
+// readReportInfoForCluster returns metadata of given cluster or empty
+// metadata if they are not known. It has to be called with the mutex locked.
+func (storage *MemoryStorage) readReportInfoForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+) types.ClusterMetadata {
+	info, found := storage.reportInfos[clusterName]
+	if !found || info.orgID != orgID {
+		return types.ClusterMetadata{}
+	}
+
+	return info.metadata
+}
+
+// WriteRecommendationsForCluster writes hitting rules in received report for selected cluster
+func (storage *MemoryStorage) WriteRecommendationsForCluster(
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	stringReport types.ClusterReport,
+	creationTime types.Timestamp,
+) error {
+	var report types.ReportRules
+	err := json.Unmarshal([]byte(stringReport), &report)
+	if err != nil {
+		return err
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	key := memoryClusterKey{orgID, clusterName}
+
+	// recommendations that were already hitting the cluster keep their
+	// impacted_since timestamp
+	impactedSinceMap := make(map[types.RuleID]types.Timestamp)
+	for _, recommendation := range storage.recommendations[key] {
+		impactedSinceMap[recommendation.ruleID] = recommendation.impactedSince
+	}
+
+	recommendations := make([]memoryRecommendation, 0, len(report.HitRules))
+	for _, rule := range report.HitRules {
+		ruleFqdn := strings.TrimSuffix(string(rule.Module), ".report")
+		ruleID := types.RuleID(ruleFqdn + "|" + string(rule.ErrorKey))
+
+		impactedSince, found := impactedSinceMap[ruleID]
+		if !found {
+			impactedSince = creationTime
+		}
+
+		recommendations = append(recommendations, memoryRecommendation{
+			ruleID:        ruleID,
+			createdAt:     creationTime,
+			impactedSince: impactedSince,
+		})
+	}
+
+	if len(recommendations) == 0 {
+		delete(storage.recommendations, key)
+	} else {
+		storage.recommendations[key] = recommendations
+	}
+
+	log.Info().
+		Int("Deleted", len(impactedSinceMap)).
+		Int("Inserted", len(recommendations)).
+		Int(organizationKey, int(orgID)).
+		Str(clusterKey, string(clusterName)).
+		Msg("Updated recommendation table")
+
+	return nil
+}
+
+// ReadRecommendationsForClusters reads all recommendations for given organization
+func (storage *MemoryStorage) ReadRecommendationsForClusters(
+	clusterList []string,
+	orgID types.OrgID,
+) (ctypes.RecommendationImpactedClusters, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	impactedClusters := make(ctypes.RecommendationImpactedClusters)
+
+	for _, cluster := range clusterList {
+		clusterName := types.ClusterName(cluster)
+		for _, recommendation := range storage.recommendations[memoryClusterKey{orgID, clusterName}] {
+			impactedClusters[recommendation.ruleID] = append(impactedClusters[recommendation.ruleID], clusterName)
+		}
+	}
+
+	return impactedClusters, nil
+}
+
+// ReadRecommendationsSummary aggregates all recommendations of given
+// organization per rule selector
+func (storage *MemoryStorage) ReadRecommendationsSummary(orgID types.OrgID) ([]RecommendationSummary, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	summaries := make(map[types.RuleID]*RecommendationSummary)
+
+	for key, recommendations := range storage.recommendations {
+		if key.orgID != orgID {
+			continue
+		}
+
+		for _, recommendation := range recommendations {
+			ruleFQDN, errorKey := splitRecommendationRuleID(recommendation.ruleID)
+			if storage.isRecommendationDisabled(orgID, key.clusterName, ruleFQDN, errorKey) {
+				continue
+			}
+
+			summary, found := summaries[recommendation.ruleID]
+			if !found {
+				summary = &RecommendationSummary{
+					RuleID:        recommendation.ruleID,
+					ImpactedSince: string(recommendation.impactedSince),
+				}
+				summaries[recommendation.ruleID] = summary
+			}
+
+			summary.ImpactedClusters++
+			if string(recommendation.impactedSince) < summary.ImpactedSince {
+				summary.ImpactedSince = string(recommendation.impactedSince)
+			}
+		}
+	}
+
+	result := make([]RecommendationSummary, 0, len(summaries))
+	for _, summary := range summaries {
+		result = append(result, *summary)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].RuleID < result[j].RuleID })
+
+	return result, nil
+}
+
+// isRecommendationDisabled checks if the rule is disabled for the cluster by
+// user or system-wide
+func (storage *MemoryStorage) isRecommendationDisabled(
+	orgID types.OrgID, clusterName types.ClusterName, ruleFQDN types.RuleID, errorKey types.ErrorKey,
+) bool {
+	now := time.Now()
+
+	for _, ruleID := range []types.RuleID{ruleFQDN, ruleFQDN + ".report"} {
+		toggle, found := storage.toggles[memoryToggleKey{clusterName, ruleID, errorKey}]
+		if found && toggle.orgID == orgID && toggle.isDisabled(now) {
+			return true
+		}
+
+		disabledRule, found := storage.systemWideDisables[memoryRuleKey{orgID, ruleID, errorKey}]
+		if found && !ruleDisableExpired(disabledRule.expiresAt, now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitRecommendationRuleID splits rule ID in plugin_name|error_key format
+// that is stored in recommendation table
+func splitRecommendationRuleID(ruleID types.RuleID) (types.RuleID, types.ErrorKey) {
+	parts := strings.SplitN(string(ruleID), "|", 2)
+	if len(parts) < 2 {
+		return ruleID, ""
+	}
+
+	return types.RuleID(parts[0]), types.ErrorKey(parts[1])
+}
+
+// ReadClusterListRecommendations retrieves cluster IDs and a list of hitting rules for each one
+func (storage *MemoryStorage) ReadClusterListRecommendations(
+	clusterList []string,
+	orgID types.OrgID,
+) (types.ClusterRecommendationMap, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	clusterMap := make(types.ClusterRecommendationMap)
+
+	for _, cluster := range clusterList {
+		clusterName := types.ClusterName(cluster)
+
+		report, found := storage.reports[clusterName]
+		if !found || report.orgID != orgID {
+			continue
+		}
+
+		recommendations := make([]ctypes.RuleID, 0)
+		for _, recommendation := range storage.recommendations[memoryClusterKey{orgID, clusterName}] {
+			recommendations = append(recommendations, recommendation.ruleID)
+		}
+		if len(recommendations) == 0 {
+			// DBStorage returns one empty rule ID for cluster without
+			// recommendations (LEFT JOIN), so the same is done there
+			recommendations = append(recommendations, "")
+		}
+
+		clusterMap[clusterName] = types.ClusterRecommendationList{
+			CreatedAt:       report.lastChecked,
+			Meta:            storage.readReportInfoForCluster(orgID, clusterName),
+			Recommendations: recommendations,
+		}
+	}
+
+	return clusterMap, nil
+}
+
+// WriteReportsBatch writes reports, recommendations and info reports for
+// several clusters. Error for each item is returned at the same index as the
+// item.
+func (storage *MemoryStorage) WriteReportsBatch(items []ReportBatchItem) []error {
+	return writeReportsOneByOne(storage, items)
+}
+
+// ReportsCount reads number of all stored reports
+func (storage *MemoryStorage) ReportsCount() (int, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return len(storage.reports), nil
+}
+
+// DeleteReportsForOrg deletes all reports related to the specified organization from the storage.
+func (storage *MemoryStorage) DeleteReportsForOrg(orgID types.OrgID) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	for clusterName, report := range storage.reports {
+		if report.orgID == orgID {
+			delete(storage.reports, clusterName)
+		}
+	}
+
+	for key := range storage.reportHistory {
+		if key.orgID == orgID {
+			delete(storage.reportHistory, key)
+		}
+	}
+
+	return nil
+}
+
+// DeleteReportsForCluster deletes all reports related to the specified cluster from the storage.
+func (storage *MemoryStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	delete(storage.reports, clusterName)
+
+	for key := range storage.reportHistory {
+		if key.clusterName == clusterName {
+			delete(storage.reportHistory, key)
+		}
+	}
+
+	return nil
+}
+
+// WriteConsumerError writes a report about a consumer error into the storage.
+// The error and the time of consuming are updated when the message failed
+// already before, for example when it is replayed from consumer_error table.
+func (storage *MemoryStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	// the updated record is moved to the end to keep errors ordered by the
+	// time they were consumed
+	for i, item := range storage.consumerErrors {
+		if item.topic == msg.Topic && item.partition == msg.Partition && item.offset == msg.Offset {
+			storage.consumerErrors = append(storage.consumerErrors[:i], storage.consumerErrors[i+1:]...)
+			item.consumedAt = time.Now().UTC()
+			item.err = consumerErr.Error()
+			storage.consumerErrors = append(storage.consumerErrors, item)
+			return nil
+		}
+	}
+
+	storage.consumerErrors = append(storage.consumerErrors, memoryConsumerError{
+		topic:      msg.Topic,
+		partition:  msg.Partition,
+		offset:     msg.Offset,
+		key:        msg.Key,
+		producedAt: msg.Timestamp,
+		consumedAt: time.Now().UTC(),
+		message:    msg.Value,
+		err:        consumerErr.Error(),
+	})
+
+	return nil
+}
+
+// ReadConsumerErrors reads consumer errors selected by the filter ordered by
+// the time they were consumed
+func (storage *MemoryStorage) ReadConsumerErrors(filter ConsumerErrorsFilter) ([]ConsumerError, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	consumerErrors := make([]ConsumerError, 0)
+	for _, item := range storage.consumerErrors {
+		consumerError := ConsumerError{
+			Topic:      item.topic,
+			Partition:  item.partition,
+			Offset:     item.offset,
+			Key:        item.key,
+			ProducedAt: item.producedAt,
+			ConsumedAt: item.consumedAt,
+			Message:    item.message,
+			Error:      item.err,
+		}
+		if filter.matches(&consumerError) {
+			consumerErrors = append(consumerErrors, consumerError)
+		}
+	}
+
+	// errors are appended in order they were consumed
+	return filter.paginate(consumerErrors), nil
+}
+
+// ReadConsumerError reads the consumer error for message with given topic,
+// partition and offset
+func (storage *MemoryStorage) ReadConsumerError(topic string, partition int32, offset int64) (ConsumerError, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for _, item := range storage.consumerErrors {
+		if item.topic == topic && item.partition == partition && item.offset == offset {
+			return ConsumerError{
+				Topic:      item.topic,
+				Partition:  item.partition,
+				Offset:     item.offset,
+				Key:        item.key,
+				ProducedAt: item.producedAt,
+				ConsumedAt: item.consumedAt,
+				Message:    item.message,
+				Error:      item.err,
+			}, nil
+		}
+	}
+
+	return ConsumerError{}, &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v/%v/%v", topic, partition, offset)}
+}
+
+// DeleteConsumerError deletes the consumer error for message with given
+// topic, partition and offset
+func (storage *MemoryStorage) DeleteConsumerError(topic string, partition int32, offset int64) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	for i, item := range storage.consumerErrors {
+		if item.topic == topic && item.partition == partition && item.offset == offset {
+			storage.consumerErrors = append(storage.consumerErrors[:i], storage.consumerErrors[i+1:]...)
+			return nil
+		}
+	}
+
+	return &types.ItemNotFoundError{ItemID: fmt.Sprintf("%v/%v/%v", topic, partition, offset)}
+}
+
+// WriteAuditEvents appends the events to the audit log. Events without the
+// time of creation are stored with the current time.
+func (storage *MemoryStorage) WriteAuditEvents(events []AuditEvent) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.appendAuditEvents(events)
+
+	return nil
+}
+
+// appendAuditEvents appends the events to the audit log, the caller needs
+// to hold the lock
+func (storage *MemoryStorage) appendAuditEvents(events []AuditEvent) {
+	now := time.Now().UTC()
+
+	for _, event := range events {
+		event.ID = int64(len(storage.auditEvents) + 1)
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = now
+		}
+
+		details := make(map[string]string, len(event.Details))
+		for name, value := range event.Details {
+			details[name] = value
+		}
+		event.Details = details
+
+		storage.auditEvents = append(storage.auditEvents, event)
+	}
+}
+
+// ReadAuditEvents returns one page of audit events selected by the filter,
+// the events are ordered by their ID, i.e. by the time they were written
+func (storage *MemoryStorage) ReadAuditEvents(
+	filter AuditEventsFilter, options ListingOptions,
+) ([]AuditEvent, string, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	events := make([]AuditEvent, 0)
+	items := make([]listingItem, 0)
+	filterValues := make([]string, 0)
+
+	for i := range storage.auditEvents {
+		event := storage.auditEvents[i]
+		if !filter.matches(&event) {
+			continue
+		}
+
+		events = append(events, event)
+		items = append(items, listingItem{id: strconv.FormatInt(event.ID, 10)})
+		filterValues = append(filterValues, string(event.Action))
+	}
+
+	indexes, nextCursor, err := paginateListing(auditEventsListing, items, filterValues, options)
+	if err != nil {
+		return make([]AuditEvent, 0), "", err
+	}
+
+	page := make([]AuditEvent, 0, len(indexes))
+	for _, index := range indexes {
+		page = append(page, events[index])
+	}
+
+	return page, nextCursor, nil
+}
+
+// DeleteExpiredRecords deletes records older than retention periods of
+// their tables. Tables with zero retention period are skipped. When dryRun
+// is set, expired records are just counted and nothing is deleted.
+func (storage *MemoryStorage) DeleteExpiredRecords(retention RetentionConfiguration, dryRun bool) ([]RetentionResult, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	now := time.Now().UTC()
+	results := make([]RetentionResult, 0, len(retentionSteps))
+
+	for _, step := range retentionSteps {
+		period := step.period(retention)
+		if period <= 0 {
+			continue
+		}
+
+		rows := storage.expireRecords(step.table, now.Add(-period), dryRun)
+		results = append(results, RetentionResult{Table: step.table, Rows: rows})
+	}
+
+	return results, nil
+}
+
+// DeleteExpiredRuleDisables deletes rules disabled for clusters and rules
+// disabled system-wide whose expiration time has passed. When dryRun is set,
+// expired disables are just counted and nothing is deleted.
+func (storage *MemoryStorage) DeleteExpiredRuleDisables(dryRun bool) ([]RetentionResult, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	now := time.Now()
+	toggles := RetentionResult{Table: ClusterRuleToggleTable}
+	systemWideDisables := RetentionResult{Table: RuleDisableTable}
+
+	for key, toggle := range storage.toggles {
+		if ruleDisableExpired(toggle.expiresAt, now) {
+			toggles.Rows++
+			if !dryRun {
+				delete(storage.toggles, key)
+			}
+		}
+	}
+
+	for key, disabledRule := range storage.systemWideDisables {
+		if ruleDisableExpired(disabledRule.expiresAt, now) {
+			systemWideDisables.Rows++
+			if !dryRun {
+				delete(storage.systemWideDisables, key)
+			}
+		}
+	}
+
+	return []RetentionResult{toggles, systemWideDisables}, nil
+}
+
+// expireRecords deletes (or just counts when dryRun is set) records from
+// given table that are older than the oldest time that is kept
+func (storage *MemoryStorage) expireRecords(table string, oldest time.Time, dryRun bool) int64 {
+	var rows int64
+
+	switch table {
+	case ConsumerErrorTable:
+		kept := make([]memoryConsumerError, 0, len(storage.consumerErrors))
+		for _, item := range storage.consumerErrors {
+			if item.consumedAt.Before(oldest) {
+				rows++
+			} else {
+				kept = append(kept, item)
+			}
+		}
+		if !dryRun {
+			storage.consumerErrors = kept
+		}
+	case RuleHitTable:
+		for _, report := range storage.reports {
+			if report.reportedAt.Before(oldest) {
+				rows += int64(len(report.ruleHits))
+				if !dryRun {
+					report.ruleHits = nil
+				}
+			}
+		}
+	case RecommendationTable:
+		for key, recommendations := range storage.recommendations {
+			kept := make([]memoryRecommendation, 0, len(recommendations))
+			for _, recommendation := range recommendations {
+				createdAt, err := time.Parse(time.RFC3339, string(recommendation.createdAt))
+				if err == nil && createdAt.Before(oldest) {
+					rows++
+				} else {
+					kept = append(kept, recommendation)
+				}
+			}
+			if !dryRun {
+				if len(kept) == 0 {
+					delete(storage.recommendations, key)
+				} else {
+					storage.recommendations[key] = kept
+				}
+			}
+		}
+	case ReportInfoTable:
+		for clusterName := range storage.reportInfos {
+			if report, found := storage.reports[clusterName]; found && report.reportedAt.Before(oldest) {
+				rows++
+				if !dryRun {
+					delete(storage.reportInfos, clusterName)
+				}
+			}
+		}
+	case ReportTable:
+		for clusterName, report := range storage.reports {
+			if report.reportedAt.Before(oldest) {
+				rows++
+				if !dryRun {
+					delete(storage.reports, clusterName)
+				}
+			}
+		}
+	case ReportHistoryTable:
+		for key, history := range storage.reportHistory {
+			kept := make([]memoryHistoryItem, 0, len(history))
+			for _, item := range history {
+				if item.lastChecked.Before(oldest) {
+					rows++
+				} else {
+					kept = append(kept, item)
+				}
+			}
+			if !dryRun {
+				if len(kept) == 0 {
+					delete(storage.reportHistory, key)
+				} else {
+					storage.reportHistory[key] = kept
+				}
+			}
+		}
+	}
+
+	return rows
+}
+
+// DoesClusterExist checks if cluster with this id exists
+func (storage *MemoryStorage) DoesClusterExist(clusterID types.ClusterName) (bool, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	_, found := storage.reports[clusterID]
+	return found, nil
+}
+
+// VoteOnRule likes or dislikes rule for cluster by user. If entry exists, it
+// overwrites it. Audit events are written together with the vote.
+func (storage *MemoryStorage) VoteOnRule(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	userID types.UserID,
+	userVote types.UserVote,
+	voteMessage string,
+	auditEvents ...AuditEvent,
+) error {
+	return storage.addOrUpdateUserFeedbackOnRuleForCluster(
+		clusterID, ruleID, errorKey, orgID, userID, &userVote, &voteMessage, auditEvents,
+	)
+}
+
+// AddOrUpdateFeedbackOnRule adds feedback on rule for cluster by user. If entry exists, it overwrites it
+func (storage *MemoryStorage) AddOrUpdateFeedbackOnRule(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	userID types.UserID,
+	message string,
+) error {
+	return storage.addOrUpdateUserFeedbackOnRuleForCluster(clusterID, ruleID, errorKey, orgID, userID, nil, &message, nil)
+}
+
+// addOrUpdateUserFeedbackOnRuleForCluster adds or updates feedback
+// will update user vote and messagePtr if the pointers are not nil
+func (storage *MemoryStorage) addOrUpdateUserFeedbackOnRuleForCluster(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	userID types.UserID,
+	userVotePtr *types.UserVote,
+	messagePtr *string,
+	auditEvents []AuditEvent,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	now := time.Now()
+	key := memoryFeedbackKey{clusterID, ruleID, errorKey, userID}
+
+	stored, found := storage.feedbacks[key]
+	if !found {
+		stored = memoryFeedback{
+			orgID: orgID,
+			feedback: UserFeedbackOnRule{
+				ClusterID: clusterID,
+				RuleID:    ruleID,
+				ErrorKey:  errorKey,
+				UserID:    userID,
+				UserVote:  types.UserVoteNone,
+				AddedAt:   now,
+			},
+		}
+	} else if userVotePtr == nil && messagePtr == nil {
+		// nothing to update
+		return nil
+	}
+
+	if userVotePtr != nil {
+		stored.feedback.UserVote = *userVotePtr
+	}
+	if messagePtr != nil {
+		stored.feedback.Message = *messagePtr
+	}
+	stored.feedback.UpdatedAt = now
+
+	storage.feedbacks[key] = stored
+	storage.appendAuditEvents(auditEvents)
+
+	metrics.FeedbackOnRules.Inc()
+
+	return nil
+}
+
+// GetUserFeedbackOnRule gets user feedback from storage
+func (storage *MemoryStorage) GetUserFeedbackOnRule(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	stored, found := storage.feedbacks[memoryFeedbackKey{clusterID, ruleID, errorKey, userID}]
+	if !found {
+		return nil, &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v/%v/%v", clusterID, ruleID, userID),
+		}
+	}
+
+	feedback := stored.feedback
+	return &feedback, nil
+}
+
+// GetUserFeedbackOnRuleDisable gets user feedback from storage
+func (storage *MemoryStorage) GetUserFeedbackOnRuleDisable(
+	clusterID types.ClusterName, ruleID types.RuleID, errorKey types.ErrorKey, userID types.UserID,
+) (*UserFeedbackOnRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	stored, found := storage.disableFeedbacks[memoryFeedbackKey{clusterID, ruleID, errorKey, userID}]
+	if !found {
+		return nil, &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v/%v/%v", clusterID, userID, ruleID),
+		}
+	}
+
+	feedback := stored.feedback
+	return &feedback, nil
+}
+
+// GetUserFeedbackOnRules gets user feedbacks for defined array of rule IDs from storage
+func (storage *MemoryStorage) GetUserFeedbackOnRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID,
+) (map[types.RuleID]types.UserVote, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	ruleIDs := make(map[types.RuleID]bool, len(rulesReport))
+	for _, rule := range rulesReport {
+		ruleIDs[rule.Module] = true
+	}
+
+	feedbacks := make(map[types.RuleID]types.UserVote)
+
+	for key, stored := range storage.feedbacks {
+		if key.clusterID == clusterID && key.userID == userID && ruleIDs[key.ruleID] {
+			feedbacks[key.ruleID] = stored.feedback.UserVote
+		}
+	}
+
+	return feedbacks, nil
+}
+
+// GetUserDisableFeedbackOnRules gets user disable feedbacks for defined array of rule IDs from storage
+func (storage *MemoryStorage) GetUserDisableFeedbackOnRules(
+	clusterID types.ClusterName, rulesReport []types.RuleOnReport, userID types.UserID,
+) (map[types.RuleID]UserFeedbackOnRule, error) {
+	feedbacks := make(map[types.RuleID]UserFeedbackOnRule)
+
+	for _, rule := range rulesReport {
+		feedback, err := storage.GetUserFeedbackOnRuleDisable(clusterID, rule.Module, rule.ErrorKey, userID)
+		if err == nil {
+			// since rules always hit only 1 error key, it's enough to select via module
+			feedbacks[rule.Module] = *feedback
+		}
+	}
+
+	return feedbacks, nil
+}
+
+// AddFeedbackOnRuleDisable adds feedback on rule disable. Audit events are
+// written together with the feedback.
+func (storage *MemoryStorage) AddFeedbackOnRuleDisable(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	userID types.UserID,
+	message string,
+	auditEvents ...AuditEvent,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	now := time.Now()
+	key := memoryFeedbackKey{clusterID, ruleID, errorKey, userID}
+
+	stored, found := storage.disableFeedbacks[key]
+	if !found {
+		stored = memoryFeedback{
+			orgID: orgID,
+			feedback: UserFeedbackOnRule{
+				ClusterID: clusterID,
+				RuleID:    ruleID,
+				ErrorKey:  errorKey,
+				UserID:    userID,
+				AddedAt:   now,
+			},
+		}
+	}
+
+	stored.feedback.Message = message
+	stored.feedback.UpdatedAt = now
+
+	storage.disableFeedbacks[key] = stored
+	storage.appendAuditEvents(auditEvents)
+
+	metrics.FeedbackOnRules.Inc()
+
+	return nil
+}
+
+// ListOfReasons function returns list of reasons for all disabled rules
+func (storage *MemoryStorage) ListOfReasons(userID types.UserID) ([]DisabledRuleReason, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	reasons := make([]DisabledRuleReason, 0)
+
+	for key, stored := range storage.disableFeedbacks {
+		if key.userID != userID {
+			continue
+		}
+
+		reasons = append(reasons, DisabledRuleReason{
+			ClusterID: key.clusterID,
+			RuleID:    key.ruleID,
+			ErrorKey:  key.errorKey,
+			Message:   stored.feedback.Message,
+			AddedAt:   nullTime(stored.feedback.AddedAt),
+			UpdatedAt: nullTime(stored.feedback.UpdatedAt),
+		})
+	}
+
+	sort.Slice(reasons, func(i, j int) bool {
+		return reasons[i].AddedAt.Time.Before(reasons[j].AddedAt.Time)
+	})
+
+	return reasons, nil
+}
+
+// ToggleRuleForCluster toggles rule for specified cluster. Audit events are
+// written together with the toggle.
+func (storage *MemoryStorage) ToggleRuleForCluster(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	ruleToggle RuleToggle,
+	auditEvents ...AuditEvent,
+) error {
+	return storage.toggleRuleForCluster(clusterID, ruleID, errorKey, orgID, ruleToggle, time.Time{}, auditEvents)
+}
+
+// DisableRuleForClusterUntil disables rule for specified cluster until
+// expiresAt. Zero expiresAt means the rule is disabled until it's enabled
+// again. Audit events are written together with the toggle.
+func (storage *MemoryStorage) DisableRuleForClusterUntil(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	expiresAt time.Time,
+	auditEvents ...AuditEvent,
+) error {
+	return storage.toggleRuleForCluster(clusterID, ruleID, errorKey, orgID, RuleToggleDisable, expiresAt, auditEvents)
+}
+
+// toggleRuleForCluster contains shared functionality of ToggleRuleForCluster
+// and DisableRuleForClusterUntil
+func (storage *MemoryStorage) toggleRuleForCluster(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	ruleToggle RuleToggle,
+	expiresAt time.Time,
+	auditEvents []AuditEvent,
+) error {
+	var enabledAt, disabledAt sql.NullTime
+
+	now := time.Now()
+	updatedAt := sql.NullTime{Time: now, Valid: true}
+
+	switch ruleToggle {
+	case RuleToggleDisable:
+		disabledAt = updatedAt
+	case RuleToggleEnable:
+		enabledAt = updatedAt
+		expiresAt = time.Time{}
+	default:
+		return fmt.Errorf("Unexpected rule toggle value")
+	}
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.toggles[memoryToggleKey{clusterID, ruleID, errorKey}] = memoryToggle{
+		orgID:      orgID,
+		disabled:   ruleToggle,
+		disabledAt: disabledAt,
+		enabledAt:  enabledAt,
+		updatedAt:  updatedAt,
+		expiresAt:  ruleDisableExpiresAt(expiresAt),
+	}
+	storage.appendAuditEvents(auditEvents)
+
+	return nil
+}
+
+// ToggleRulesForClusters toggles rules for clusters, either all items are
+// stored or none of them. Audit events are written together with the
+// toggles. Items and audit events of clusters that are not known or belong
+// to another organization are skipped and these clusters are returned.
+func (storage *MemoryStorage) ToggleRulesForClusters(
+	orgID types.OrgID,
+	userID types.UserID,
+	items []RuleToggleBatchItem,
+	ruleToggle RuleToggle,
+	auditEvents ...AuditEvent,
+) (map[types.ClusterName]bool, error) {
+	// the only possible error is checked before anything is stored
+	if _, _, err := ruleToggleTimes(ruleToggle, time.Now()); err != nil {
+		return nil, err
+	}
+
+	clusterOrgs := make(map[types.ClusterName]types.OrgID, len(items))
+	storage.mutex.RLock()
+	for _, clusterName := range ruleToggleBatchClusters(items) {
+		if report, found := storage.reports[clusterName]; found {
+			clusterOrgs[clusterName] = report.orgID
+		}
+	}
+	storage.mutex.RUnlock()
+
+	items, auditEvents, unknownClusters := skipClustersOfOtherOrgs(orgID, clusterOrgs, items, auditEvents)
+
+	for _, item := range items {
+		err := storage.toggleRuleForCluster(
+			item.ClusterID, item.RuleID, item.ErrorKey, orgID, ruleToggle, item.ExpiresAt, nil,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if item.Justification != "" {
+			err = storage.AddFeedbackOnRuleDisable(
+				item.ClusterID, item.RuleID, item.ErrorKey, orgID, userID, item.Justification,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return unknownClusters, storage.WriteAuditEvents(auditEvents)
+}
+
+// GetFromClusterRuleToggle gets a rule toggle for given cluster
+func (storage *MemoryStorage) GetFromClusterRuleToggle(
+	clusterID types.ClusterName, ruleID types.RuleID,
+) (*ClusterRuleToggle, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	var disabledRule *ClusterRuleToggle
+
+	// the most recently updated toggle is returned, the same as in DBStorage
+	for key, toggle := range storage.toggles {
+		if key.clusterID != clusterID || key.ruleID != ruleID {
+			continue
+		}
+
+		if disabledRule == nil || toggle.updatedAt.Time.After(disabledRule.UpdatedAt.Time) {
+			disabledRule = &ClusterRuleToggle{
+				ClusterID:  key.clusterID,
+				RuleID:     key.ruleID,
+				Disabled:   toggle.disabled,
+				DisabledAt: toggle.disabledAt,
+				EnabledAt:  toggle.enabledAt,
+				UpdatedAt:  toggle.updatedAt,
+				ExpiresAt:  toggle.expiresAt,
+			}
+		}
+	}
+
+	if disabledRule == nil {
+		return nil, &types.ItemNotFoundError{ItemID: ruleID}
+	}
+
+	return disabledRule, nil
+}
+
+// GetTogglesForRules gets enable/disable toggle for rules
+func (storage *MemoryStorage) GetTogglesForRules(
+	clusterID types.ClusterName,
+	rulesReport []types.RuleOnReport,
+	orgID types.OrgID,
+) (map[types.RuleID]bool, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	ruleIDs := make(map[types.RuleID]bool, len(rulesReport))
+	for _, rule := range rulesReport {
+		ruleIDs[rule.Module] = true
+	}
+
+	toggles := make(map[types.RuleID]bool)
+	now := time.Now()
+
+	for key, toggle := range storage.toggles {
+		if key.clusterID == clusterID && toggle.orgID == orgID &&
+			toggle.isDisabled(now) && ruleIDs[key.ruleID] {
+			toggles[key.ruleID] = true
+		}
+	}
+
+	return toggles, nil
+}
+
+// DeleteFromRuleClusterToggle deletes a rule toggle for given cluster. Only exposed in debug mode.
+func (storage *MemoryStorage) DeleteFromRuleClusterToggle(
+	clusterID types.ClusterName, ruleID types.RuleID,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	for key := range storage.toggles {
+		if key.clusterID == clusterID && key.ruleID == ruleID {
+			delete(storage.toggles, key)
+		}
+	}
+
+	return nil
+}
+
+// listOfDisabledRules returns all rules disabled in given organization for
+// clusters accepted by the filter. It has to be called with the mutex locked.
+func (storage *MemoryStorage) listOfDisabledRules(
+	orgID types.OrgID, clusterFilter func(types.ClusterName) bool,
+) []ctypes.DisabledRule {
+	disabledRules := make([]ctypes.DisabledRule, 0)
+	now := time.Now()
+
+	for key, toggle := range storage.toggles {
+		if toggle.orgID != orgID || !toggle.isDisabled(now) || !clusterFilter(key.clusterID) {
+			continue
+		}
+
+		disabledRules = append(disabledRules, ctypes.DisabledRule{
+			ClusterID:  key.clusterID,
+			RuleID:     key.ruleID,
+			ErrorKey:   key.errorKey,
+			Disabled:   ctypes.RuleToggle(toggle.disabled),
+			DisabledAt: toggle.disabledAt,
+			UpdatedAt:  toggle.updatedAt,
+		})
+	}
+
+	sort.Slice(disabledRules, func(i, j int) bool {
+		return disabledRules[i].DisabledAt.Time.Before(disabledRules[j].DisabledAt.Time)
+	})
+
+	return disabledRules
+}
+
+// ListOfDisabledRules function returns list of all rules disabled from a
+// specified account.
+func (storage *MemoryStorage) ListOfDisabledRules(orgID types.OrgID) ([]ctypes.DisabledRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	return storage.listOfDisabledRules(orgID, func(types.ClusterName) bool { return true }), nil
+}
+
+// ListOfDisabledRulesForClusters function returns list of all rules disabled from a
+// specified account for given list of clusters.
+func (storage *MemoryStorage) ListOfDisabledRulesForClusters(
+	clusterList []string,
+	orgID types.OrgID,
+) ([]ctypes.DisabledRule, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	clusters := make(map[types.ClusterName]bool, len(clusterList))
+	for _, cluster := range clusterList {
+		clusters[types.ClusterName(cluster)] = true
+	}
+
+	return storage.listOfDisabledRules(orgID, func(clusterID types.ClusterName) bool {
+		return clusters[clusterID]
+	}), nil
+}
+
+// ListOfDisabledClusters function returns list of all clusters disabled for a rule from a
+// specified account.
+func (storage *MemoryStorage) ListOfDisabledClusters(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+) ([]ctypes.DisabledClusterInfo, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	var disabledClusters []ctypes.DisabledClusterInfo
+	now := time.Now()
+
+	for key, toggle := range storage.toggles {
+		if toggle.orgID != orgID || key.ruleID != ruleID || key.errorKey != errorKey ||
+			!toggle.isDisabled(now) {
+			continue
+		}
+
+		// the latest feedback is used as justification
+		var latestFeedback *UserFeedbackOnRule
+		for feedbackKey, stored := range storage.disableFeedbacks {
+			if feedbackKey.clusterID != key.clusterID || stored.orgID != orgID ||
+				feedbackKey.ruleID != ruleID || feedbackKey.errorKey != errorKey {
+				continue
+			}
+
+			if latestFeedback == nil || stored.feedback.UpdatedAt.After(latestFeedback.UpdatedAt) {
+				feedback := stored.feedback
+				latestFeedback = &feedback
+			}
+		}
+
+		disabledCluster := ctypes.DisabledClusterInfo{
+			ClusterID:  key.clusterID,
+			DisabledAt: toggle.disabledAt.Time,
+		}
+		if latestFeedback != nil {
+			disabledCluster.Justification = latestFeedback.Message
+		}
+
+		disabledClusters = append(disabledClusters, disabledCluster)
+	}
+
+	sort.Slice(disabledClusters, func(i, j int) bool {
+		return disabledClusters[i].DisabledAt.After(disabledClusters[j].DisabledAt)
+	})
+
+	return disabledClusters, nil
+}
+
+// RateOnRule function stores the vote (rating) from a given user to a
+// rule+error key. Audit events are written together with the rating.
+func (storage *MemoryStorage) RateOnRule(
+	orgID types.OrgID,
+	ruleFqdn types.RuleID,
+	errorKey types.ErrorKey,
+	rating types.UserVote,
+	auditEvents ...AuditEvent,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.ratings[memoryRuleKey{orgID, ruleFqdn, errorKey}] = rating
+	storage.appendAuditEvents(auditEvents)
+
+	metrics.RatingOnRules.Inc()
+
+	return nil
+}
+
+// GetRuleRating retrieves rating for given rule and user
+func (storage *MemoryStorage) GetRuleRating(
+	orgID types.OrgID,
+	ruleSelector types.RuleSelector,
+) (types.RuleRating, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	for key, rating := range storage.ratings {
+		selector := string(key.ruleID) + "|" + string(key.errorKey)
+		if key.orgID == orgID && selector == string(ruleSelector) {
+			return types.RuleRating{Rule: selector, Rating: rating}, nil
+		}
+	}
+
+	return types.RuleRating{}, &types.ItemNotFoundError{
+		ItemID: fmt.Sprintf("%v/%v/rating", orgID, ruleSelector),
+	}
+}
+
+// DisableRuleSystemWide disables the selected rule for all clusters visible to
+// given user
+func (storage *MemoryStorage) DisableRuleSystemWide(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	justification string,
+) error {
+	return storage.DisableRuleSystemWideUntil(orgID, ruleID, errorKey, justification, time.Time{})
+}
+
+// DisableRuleSystemWideUntil disables the selected rule for all clusters
+// visible to given user until expiresAt. Zero expiresAt means the rule is
+// disabled until it's enabled again. Audit events are written together with
+// the disable.
+func (storage *MemoryStorage) DisableRuleSystemWideUntil(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	justification string,
+	expiresAt time.Time,
+	auditEvents ...AuditEvent,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	key := memoryRuleKey{orgID, ruleID, errorKey}
+
+	disabledRule, found := storage.systemWideDisables[key]
+	if !found {
+		disabledRule.rule = ctypes.SystemWideRuleDisable{
+			OrgID:    orgID,
+			RuleID:   ruleID,
+			ErrorKey: errorKey,
+		}
+	}
+
+	disabledRule.rule.Justification = justification
+	disabledRule.rule.CreatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	disabledRule.expiresAt = ruleDisableExpiresAt(expiresAt)
+
+	storage.systemWideDisables[key] = disabledRule
+	storage.appendAuditEvents(auditEvents)
+
+	return nil
+}
+
+// EnableRuleSystemWide enables the selected rule for all clusters visible to
+// given user. Audit events are written together with the change.
+func (storage *MemoryStorage) EnableRuleSystemWide(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	auditEvents ...AuditEvent,
+) error {
+	log.Info().Int("org_id", int(orgID)).Msgf("re-enabling rule %v|%v", ruleID, errorKey)
+
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	delete(storage.systemWideDisables, memoryRuleKey{orgID, ruleID, errorKey})
+	storage.appendAuditEvents(auditEvents)
+
+	return nil
+}
+
+// UpdateDisabledRuleJustification change justification for already disabled
+// rule. Audit events are written together with the change.
+func (storage *MemoryStorage) UpdateDisabledRuleJustification(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	justification string,
+	auditEvents ...AuditEvent,
+) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	key := memoryRuleKey{orgID, ruleID, errorKey}
+
+	if disabledRule, found := storage.systemWideDisables[key]; found {
+		disabledRule.rule.Justification = justification
+		disabledRule.rule.UpdatedAT = sql.NullTime{Time: time.Now(), Valid: true}
+		storage.systemWideDisables[key] = disabledRule
+	}
+	storage.appendAuditEvents(auditEvents)
+
+	return nil
+}
+
+// ReadDisabledRule function returns disabled rule (if disabled) from
+// storage. Expired disables are treated as enabled rules.
+func (storage *MemoryStorage) ReadDisabledRule(
+	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+) (ctypes.SystemWideRuleDisable, bool, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	disabledRule, found := storage.systemWideDisables[memoryRuleKey{orgID, ruleID, errorKey}]
+	if !found || ruleDisableExpired(disabledRule.expiresAt, time.Now()) {
+		return ctypes.SystemWideRuleDisable{}, false, nil
+	}
+
+	return disabledRule.rule, true, nil
+}
+
+// ListOfSystemWideDisabledRules function returns list of all rules that have been
+// disabled for all clusters by given user, expired disables are skipped
+func (storage *MemoryStorage) ListOfSystemWideDisabledRules(
+	orgID types.OrgID,
+) ([]ctypes.SystemWideRuleDisable, error) {
+	storage.mutex.RLock()
+	defer storage.mutex.RUnlock()
+
+	disabledRules := make([]ctypes.SystemWideRuleDisable, 0)
+	now := time.Now()
+
+	for key, disabledRule := range storage.systemWideDisables {
+		if key.orgID == orgID && !ruleDisableExpired(disabledRule.expiresAt, now) {
+			disabledRules = append(disabledRules, disabledRule.rule)
+		}
+	}
+
+	sort.Slice(disabledRules, func(i, j int) bool {
+		return disabledRules[i].CreatedAt.Time.Before(disabledRules[j].CreatedAt.Time)
+	})
+
+	return disabledRules, nil
+}
@@ -0,0 +1,401 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	ctypes "github.com/RedHatInsights/insights-results-types"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func newMemoryStorage() *storage.MemoryStorage {
+	return storage.NewMemoryStorage(storage.Configuration{
+		Driver:              storage.MemoryDriver,
+		ReportHistoryLength: 2,
+	})
+}
+
+func writeReportToMemoryStorage(
+	t *testing.T,
+	memoryStorage storage.Storage,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	rules []types.ReportItem,
+	lastChecked time.Time,
+) {
+	err := memoryStorage.WriteReportForCluster(
+		testdata.OrgID, clusterName, report, rules, lastChecked, lastChecked, lastChecked, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+
+	err = memoryStorage.WriteRecommendationsForCluster(
+		testdata.OrgID, clusterName, report, types.Timestamp(lastChecked.UTC().Format(time.RFC3339)),
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestMemoryStorageImplementsStorage(t *testing.T) {
+	var _ storage.Storage = newMemoryStorage()
+}
+
+func TestMemoryStorageInitClose(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	helpers.FailOnError(t, memoryStorage.Init())
+	helpers.FailOnError(t, memoryStorage.Close())
+}
+
+func TestMemoryStorageReadReportForClusterNotFound(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	_, _, _, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.EqualError(t, err, "Item with ID 1/"+string(testdata.ClusterName)+" was not found in the storage")
+
+	_, _, err = memoryStorage.ReadReportForClusterByClusterName(testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	_, err = memoryStorage.GetOrgIDByClusterID(testdata.ClusterName)
+	assert.Error(t, err)
+}
+
+func TestMemoryStorageWriteAndReadReport(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	writeReportToMemoryStorage(
+		t, memoryStorage, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt,
+	)
+
+	rules, lastChecked, reportedAt, gatheredAt, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	expectedTimestamp := types.Timestamp(testdata.LastCheckedAt.Format(time.RFC3339))
+	assert.Equal(t, expectedTimestamp, lastChecked)
+	assert.Equal(t, expectedTimestamp, reportedAt)
+	assert.Equal(t, expectedTimestamp, gatheredAt)
+	assert.Len(t, rules, 3)
+	assert.Equal(t, testdata.Rule1ID, rules[0].Module)
+	assert.Equal(t, types.ErrorKey(testdata.ErrorKey1), rules[0].ErrorKey)
+
+	// report for different organization must not be returned
+	_, _, _, _, err = memoryStorage.ReadReportForCluster(testdata.Org2ID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	orgID, err := memoryStorage.GetOrgIDByClusterID(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.OrgID, orgID)
+
+	orgs, err := memoryStorage.ListOfOrgs()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.OrgID{testdata.OrgID}, orgs)
+
+	clusters, err := memoryStorage.ListOfClustersForOrg(testdata.OrgID, testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{testdata.ClusterName}, clusters)
+
+	clusters, err = memoryStorage.ListOfClustersForOrg(testdata.OrgID, testdata.LastCheckedAt.Add(time.Hour))
+	helpers.FailOnError(t, err)
+	assert.Empty(t, clusters)
+
+	exists, err := memoryStorage.DoesClusterExist(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.True(t, exists)
+
+	count, err := memoryStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 1, count)
+
+	offset, err := memoryStorage.GetLatestKafkaOffset()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.KafkaOffset, offset)
+
+	reports, err := memoryStorage.ReadReportsForClusters([]types.ClusterName{testdata.ClusterName})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, reports[testdata.ClusterName])
+
+	templateData, err := memoryStorage.ReadSingleRuleTemplateData(
+		testdata.OrgID, testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1,
+	)
+	helpers.FailOnError(t, err)
+	assert.NotNil(t, templateData)
+}
+
+func TestMemoryStorageWriteOldReport(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	writeReportToMemoryStorage(
+		t, memoryStorage, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt,
+	)
+
+	err := memoryStorage.WriteReportForCluster(
+		testdata.OrgID, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed,
+		testdata.LastCheckedAt, testdata.LastCheckedAt, testdata.LastCheckedAt, testdata.KafkaOffset,
+	)
+	assert.Equal(t, types.ErrOldReport, err)
+
+	rules, _, _, _, err := memoryStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 3)
+}
+
+func TestMemoryStorageImpactedSinceIsPreserved(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	firstChecked := testdata.LastCheckedAt
+	secondChecked := firstChecked.Add(time.Hour)
+
+	writeReportToMemoryStorage(
+		t, memoryStorage, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed, firstChecked,
+	)
+	writeReportToMemoryStorage(
+		t, memoryStorage, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, secondChecked,
+	)
+
+	hittingClusters, err := memoryStorage.ListOfClustersForOrgSpecificRule(
+		testdata.OrgID, types.RuleSelector(testdata.Rule1CompositeID), nil,
+	)
+	helpers.FailOnError(t, err)
+	assert.Len(t, hittingClusters, 1)
+	assert.Equal(t, testdata.ClusterName, hittingClusters[0].Cluster)
+	assert.Equal(t, secondChecked.Format(time.RFC3339), hittingClusters[0].LastSeen)
+	assert.Equal(t, firstChecked.Format(time.RFC3339), hittingClusters[0].ImpactedSince)
+
+	hittingClusters, err = memoryStorage.ListOfClustersForOrgSpecificRule(
+		testdata.OrgID, types.RuleSelector(testdata.Rule3CompositeID), nil,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, secondChecked.Format(time.RFC3339), hittingClusters[0].ImpactedSince)
+
+	_, err = memoryStorage.ListOfClustersForOrgSpecificRule(
+		testdata.OrgID, types.RuleSelector(testdata.Rule1CompositeID), []string{string(testdata.GetRandomClusterID())},
+	)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestMemoryStorageReadClusterListRecommendations(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	writeReportToMemoryStorage(
+		t, memoryStorage, testdata.ClusterName, testdata.Report3Rules, testdata.Report3RulesParsed, testdata.LastCheckedAt,
+	)
+
+	err := memoryStorage.WriteReportInfoForCluster(testdata.OrgID, testdata.ClusterName, []types.InfoItem{{
+		InfoID:  "version_info|CLUSTER_VERSION_INFO",
+		Details: map[string]string{"version": "4.9"},
+	}}, testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+
+	res, err := memoryStorage.ReadClusterListRecommendations(
+		[]string{string(testdata.ClusterName), string(testdata.GetRandomClusterID())}, testdata.OrgID,
+	)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, res, 1)
+	assert.ElementsMatch(t, []ctypes.RuleID{
+		testdata.Rule1CompositeID, testdata.Rule2CompositeID, testdata.Rule3CompositeID,
+	}, res[testdata.ClusterName].Recommendations)
+	assert.True(t, res[testdata.ClusterName].CreatedAt.Equal(testdata.LastCheckedAt))
+	assert.Equal(t, types.Version("4.9"), res[testdata.ClusterName].Meta.Version)
+
+	impactedClusters, err := memoryStorage.ReadRecommendationsForClusters(
+		[]string{string(testdata.ClusterName)}, testdata.OrgID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []types.ClusterName{testdata.ClusterName}, impactedClusters[testdata.Rule1CompositeID])
+}
+
+func TestMemoryStorageDeleteReports(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	writeReportToMemoryStorage(
+		t, memoryStorage, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed, testdata.LastCheckedAt,
+	)
+	helpers.FailOnError(t, memoryStorage.DeleteReportsForCluster(testdata.ClusterName))
+
+	exists, err := memoryStorage.DoesClusterExist(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.False(t, exists)
+
+	_, err = memoryStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	writeReportToMemoryStorage(
+		t, memoryStorage, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed, testdata.LastCheckedAt,
+	)
+	helpers.FailOnError(t, memoryStorage.DeleteReportsForOrg(testdata.OrgID))
+
+	count, err := memoryStorage.ReportsCount()
+	helpers.FailOnError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestMemoryStorageReportHistoryIsLimited(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	lastChecked := testdata.LastCheckedAt
+	for i := 0; i < 3; i++ {
+		lastChecked = lastChecked.Add(time.Hour)
+		writeReportToMemoryStorage(
+			t, memoryStorage, testdata.ClusterName, testdata.Report2Rules, testdata.Report2RulesParsed, lastChecked,
+		)
+	}
+
+	history, err := memoryStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, history, 2)
+	assert.Equal(t, types.Timestamp(lastChecked.Format(time.RFC3339)), history[1].LastCheckedAt)
+	assert.Len(t, history[1].HitRules, 2)
+}
+
+func TestMemoryStorageToggleRuleForCluster(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	err := memoryStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, storage.RuleToggleDisable,
+	)
+	helpers.FailOnError(t, err)
+
+	toggle, err := memoryStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, storage.RuleToggleDisable, toggle.Disabled)
+	assert.True(t, toggle.DisabledAt.Valid)
+
+	rulesReport := []types.RuleOnReport{{Module: testdata.Rule1ID}, {Module: testdata.Rule2ID}}
+
+	toggles, err := memoryStorage.GetTogglesForRules(testdata.ClusterName, rulesReport, testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.RuleID]bool{testdata.Rule1ID: true}, toggles)
+
+	disabledRules, err := memoryStorage.ListOfDisabledRules(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disabledRules, 1)
+
+	disabledRules, err = memoryStorage.ListOfDisabledRulesForClusters(
+		[]string{string(testdata.GetRandomClusterID())}, testdata.OrgID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disabledRules)
+
+	err = memoryStorage.AddFeedbackOnRuleDisable(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, testdata.UserID, "justification",
+	)
+	helpers.FailOnError(t, err)
+
+	disabledClusters, err := memoryStorage.ListOfDisabledClusters(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disabledClusters, 1)
+	assert.Equal(t, "justification", disabledClusters[0].Justification)
+
+	err = memoryStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, storage.RuleToggleEnable,
+	)
+	helpers.FailOnError(t, err)
+
+	toggles, err = memoryStorage.GetTogglesForRules(testdata.ClusterName, rulesReport, testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, toggles)
+
+	err = memoryStorage.ToggleRuleForCluster(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, storage.RuleToggle(42),
+	)
+	assert.EqualError(t, err, "Unexpected rule toggle value")
+
+	helpers.FailOnError(t, memoryStorage.DeleteFromRuleClusterToggle(testdata.ClusterName, testdata.Rule1ID))
+
+	_, err = memoryStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestMemoryStorageDisableRuleSystemWide(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	_, found, err := memoryStorage.ReadDisabledRule(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.False(t, found)
+
+	err = memoryStorage.DisableRuleSystemWide(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, "first")
+	helpers.FailOnError(t, err)
+
+	err = memoryStorage.UpdateDisabledRuleJustification(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, "second")
+	helpers.FailOnError(t, err)
+
+	disabledRule, found, err := memoryStorage.ReadDisabledRule(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "second", disabledRule.Justification)
+	assert.True(t, disabledRule.UpdatedAT.Valid)
+
+	disabledRules, err := memoryStorage.ListOfSystemWideDisabledRules(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Len(t, disabledRules, 1)
+
+	err = memoryStorage.EnableRuleSystemWide(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+	helpers.FailOnError(t, err)
+
+	disabledRules, err = memoryStorage.ListOfSystemWideDisabledRules(testdata.OrgID)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, disabledRules)
+}
+
+func TestMemoryStorageFeedback(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	_, err := memoryStorage.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	err = memoryStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, testdata.UserID, types.UserVoteLike, "",
+	)
+	helpers.FailOnError(t, err)
+
+	// updating the message must not change the vote
+	err = memoryStorage.AddOrUpdateFeedbackOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, testdata.UserID, "message",
+	)
+	helpers.FailOnError(t, err)
+
+	feedback, err := memoryStorage.GetUserFeedbackOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.UserVoteLike, feedback.UserVote)
+	assert.Equal(t, "message", feedback.Message)
+
+	votes, err := memoryStorage.GetUserFeedbackOnRules(
+		testdata.ClusterName, []types.RuleOnReport{{Module: testdata.Rule1ID}}, testdata.UserID,
+	)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, map[types.RuleID]types.UserVote{testdata.Rule1ID: types.UserVoteLike}, votes)
+}
+
+func TestMemoryStorageRating(t *testing.T) {
+	memoryStorage := newMemoryStorage()
+
+	_, err := memoryStorage.GetRuleRating(testdata.OrgID, types.RuleSelector(testdata.Rule1CompositeID))
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	err = memoryStorage.RateOnRule(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, types.UserVoteDislike)
+	helpers.FailOnError(t, err)
+
+	rating, err := memoryStorage.GetRuleRating(testdata.OrgID, types.RuleSelector(testdata.Rule1CompositeID))
+	helpers.FailOnError(t, err)
+	assert.Equal(t, string(testdata.Rule1CompositeID), rating.Rule)
+	assert.Equal(t, types.UserVoteDislike, rating.Rating)
+}
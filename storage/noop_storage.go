@@ -48,14 +48,24 @@ func (*NoopStorage) ListOfClustersForOrg(types.OrgID, time.Time) ([]types.Cluste
 	return nil, nil
 }
 
+// ListOfOrgsPage noop
+func (*NoopStorage) ListOfOrgsPage(ListingOptions) ([]types.OrgID, string, error) {
+	return nil, "", nil
+}
+
+// ListOfClustersForOrgPage noop
+func (*NoopStorage) ListOfClustersForOrgPage(types.OrgID, time.Time, time.Time, ListingOptions) ([]types.ClusterName, string, error) {
+	return nil, "", nil
+}
+
 // ReadReportForCluster noop
 func (*NoopStorage) ReadReportForCluster(types.OrgID, types.ClusterName) ([]types.RuleOnReport, types.Timestamp, types.Timestamp, types.Timestamp, error) {
 	return []types.RuleOnReport{}, "", "", "", nil
 }
 
 // ReadReportInfoForCluster noop
-func (*NoopStorage) ReadReportInfoForCluster(types.OrgID, types.ClusterName) (types.Version, error) {
-	return "", nil
+func (*NoopStorage) ReadReportInfoForCluster(types.OrgID, types.ClusterName) (types.ClusterMetadata, error) {
+	return types.ClusterMetadata{}, nil
 }
 
 // ReadSingleRuleTemplateData noop
@@ -99,13 +109,18 @@ func (*NoopStorage) WriteRecommendationsForCluster(
 	return nil
 }
 
+// WriteReportsBatch noop
+func (*NoopStorage) WriteReportsBatch(items []ReportBatchItem) []error {
+	return make([]error, len(items))
+}
+
 // ReportsCount noop
 func (*NoopStorage) ReportsCount() (int, error) {
 	return 0, nil
 }
 
 // VoteOnRule noop
-func (*NoopStorage) VoteOnRule(types.ClusterName, types.RuleID, types.ErrorKey, types.OrgID, types.UserID, types.UserVote, string) error {
+func (*NoopStorage) VoteOnRule(types.ClusterName, types.RuleID, types.ErrorKey, types.OrgID, types.UserID, types.UserVote, string, ...AuditEvent) error {
 	return nil
 }
 
@@ -118,7 +133,7 @@ func (*NoopStorage) AddOrUpdateFeedbackOnRule(
 
 // AddFeedbackOnRuleDisable noop
 func (*NoopStorage) AddFeedbackOnRuleDisable(
-	types.ClusterName, types.RuleID, types.ErrorKey, types.OrgID, types.UserID, string,
+	types.ClusterName, types.RuleID, types.ErrorKey, types.OrgID, types.UserID, string, ...AuditEvent,
 ) error {
 	return nil
 }
@@ -187,13 +202,52 @@ func (*NoopStorage) WriteConsumerError(*sarama.ConsumerMessage, error) error {
 	return nil
 }
 
+// ReadConsumerErrors noop
+func (*NoopStorage) ReadConsumerErrors(ConsumerErrorsFilter) ([]ConsumerError, error) {
+	return nil, nil
+}
+
+// ReadConsumerError noop
+func (*NoopStorage) ReadConsumerError(string, int32, int64) (ConsumerError, error) {
+	return ConsumerError{}, nil
+}
+
+// DeleteConsumerError noop
+func (*NoopStorage) DeleteConsumerError(string, int32, int64) error {
+	return nil
+}
+
+// DeleteExpiredRecords noop
+func (*NoopStorage) DeleteExpiredRecords(RetentionConfiguration, bool) ([]RetentionResult, error) {
+	return nil, nil
+}
+
+// DeleteExpiredRuleDisables noop
+func (*NoopStorage) DeleteExpiredRuleDisables(bool) ([]RetentionResult, error) {
+	return nil, nil
+}
+
 // ToggleRuleForCluster noop
 func (*NoopStorage) ToggleRuleForCluster(
-	types.ClusterName, types.RuleID, types.ErrorKey, types.OrgID, RuleToggle,
+	types.ClusterName, types.RuleID, types.ErrorKey, types.OrgID, RuleToggle, ...AuditEvent,
 ) error {
 	return nil
 }
 
+// DisableRuleForClusterUntil noop
+func (*NoopStorage) DisableRuleForClusterUntil(
+	types.ClusterName, types.RuleID, types.ErrorKey, types.OrgID, time.Time, ...AuditEvent,
+) error {
+	return nil
+}
+
+// ToggleRulesForClusters noop
+func (*NoopStorage) ToggleRulesForClusters(
+	types.OrgID, types.UserID, []RuleToggleBatchItem, RuleToggle, ...AuditEvent,
+) (map[types.ClusterName]bool, error) {
+	return nil, nil
+}
+
 // DeleteFromRuleClusterToggle noop
 func (*NoopStorage) DeleteFromRuleClusterToggle(
 	types.ClusterName, types.RuleID) error {
@@ -293,6 +347,7 @@ func (*NoopStorage) RateOnRule(
 	types.RuleID,
 	types.ErrorKey,
 	types.UserVote,
+	...AuditEvent,
 ) error {
 	return nil
 }
@@ -317,10 +372,21 @@ func (*NoopStorage) DisableRuleSystemWide(
 	return nil
 }
 
+// DisableRuleSystemWideUntil disables the selected rule for all clusters
+// visible to given user until expiresAt
+func (*NoopStorage) DisableRuleSystemWideUntil(
+	orgID types.OrgID, ruleID types.RuleID,
+	errorKey types.ErrorKey, justification string, expiresAt time.Time,
+	auditEvents ...AuditEvent,
+) error {
+	return nil
+}
+
 // EnableRuleSystemWide enables the selected rule for all clusters visible to
 // given user
 func (*NoopStorage) EnableRuleSystemWide(
 	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
+	auditEvents ...AuditEvent,
 ) error {
 	return nil
 }
@@ -331,6 +397,7 @@ func (*NoopStorage) UpdateDisabledRuleJustification(
 	ruleID types.RuleID,
 	errorKey types.ErrorKey,
 	justification string,
+	auditEvents ...AuditEvent,
 ) error {
 	return nil
 }
@@ -366,9 +433,38 @@ func (*NoopStorage) ListOfClustersForOrgSpecificRule(
 	return nil, nil
 }
 
+// ListOfClustersForOrgSpecificRulePage noop
+func (*NoopStorage) ListOfClustersForOrgSpecificRulePage(
+	types.OrgID, types.RuleSelector, []string, ListingOptions,
+) ([]ctypes.HittingClustersData, string, error) {
+	return nil, "", nil
+}
+
+// ReadRecommendationsSummary noop
+func (*NoopStorage) ReadRecommendationsSummary(types.OrgID) ([]RecommendationSummary, error) {
+	return nil, nil
+}
+
 // ReadClusterListRecommendations retrieves cluster IDs and a list of hitting rules for each one
 func (*NoopStorage) ReadClusterListRecommendations(
 	clusterList []string, orgID types.OrgID,
-) (ctypes.ClusterRecommendationMap, error) {
+) (types.ClusterRecommendationMap, error) {
+	return nil, nil
+}
+
+// ReadReportHistoryForCluster noop
+func (*NoopStorage) ReadReportHistoryForCluster(
+	types.OrgID, types.ClusterName,
+) ([]ReportHistoryItem, error) {
 	return nil, nil
 }
+
+// WriteAuditEvents noop
+func (*NoopStorage) WriteAuditEvents([]AuditEvent) error {
+	return nil
+}
+
+// ReadAuditEvents noop
+func (*NoopStorage) ReadAuditEvents(AuditEventsFilter, ListingOptions) ([]AuditEvent, string, error) {
+	return nil, "", nil
+}
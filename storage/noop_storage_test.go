@@ -33,6 +33,8 @@ func TestNoopStorage_Methods(t *testing.T) {
 	_ = noopStorage.Close()
 	_, _ = noopStorage.ListOfOrgs()
 	_, _ = noopStorage.ListOfClustersForOrg(0, time.Now())
+	_, _, _ = noopStorage.ListOfOrgsPage(storage.ListingOptions{})
+	_, _, _ = noopStorage.ListOfClustersForOrgPage(0, time.Now(), time.Now(), storage.ListingOptions{})
 	_, _, _, _, _ = noopStorage.ReadReportForCluster(0, "")
 	_, _ = noopStorage.ReadReportInfoForCluster(0, "")
 	_, _, _ = noopStorage.ReadReportForClusterByClusterName("")
@@ -59,7 +61,14 @@ func TestNoopStorage_Methods_Cont(t *testing.T) {
 	_ = noopStorage.CreateRuleErrorKey(types.RuleErrorKey{})
 	_ = noopStorage.DeleteRuleErrorKey("", "")
 	_ = noopStorage.WriteConsumerError(nil, nil)
+	_, _ = noopStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	_, _ = noopStorage.ReadConsumerError("", 0, 0)
+	_ = noopStorage.DeleteConsumerError("", 0, 0)
+	_, _ = noopStorage.DeleteExpiredRecords(storage.RetentionConfiguration{}, false)
+	_, _ = noopStorage.DeleteExpiredRuleDisables(false)
 	_ = noopStorage.ToggleRuleForCluster("", "", "", 0, 0)
+	_ = noopStorage.DisableRuleForClusterUntil("", "", "", 0, time.Time{})
+	_, _ = noopStorage.ToggleRulesForClusters(0, "", nil, 0)
 	_ = noopStorage.DeleteFromRuleClusterToggle("", "")
 	_, _ = noopStorage.GetFromClusterRuleToggle("", "")
 	_, _ = noopStorage.GetTogglesForRules("", nil, types.OrgID(1))
@@ -75,6 +84,7 @@ func TestNoopStorage_Methods_Cont(t *testing.T) {
 	_, _ = noopStorage.ListOfReasons("")
 	_, _ = noopStorage.ListOfDisabledRulesForClusters([]string{}, types.OrgID(1))
 	_ = noopStorage.WriteRecommendationsForCluster(0, "", "", "")
+	_ = noopStorage.WriteReportsBatch([]storage.ReportBatchItem{})
 	_ = noopStorage.RateOnRule(types.OrgID(1), "", "", types.UserVote(1))
 	_, _ = noopStorage.GetRuleRating(types.OrgID(1), "id")
 }
@@ -84,13 +94,19 @@ func TestNoopStorage_Methods_Cont2(t *testing.T) {
 	orgID := types.OrgID(1)
 
 	_ = noopStorage.DisableRuleSystemWide(orgID, "", "", "")
+	_ = noopStorage.DisableRuleSystemWideUntil(orgID, "", "", "", time.Time{})
 	_ = noopStorage.EnableRuleSystemWide(orgID, "", "")
 	_ = noopStorage.UpdateDisabledRuleJustification(orgID, "", "", "justification")
 	_, _, _ = noopStorage.ReadDisabledRule(orgID, "", "")
 	_, _ = noopStorage.ListOfSystemWideDisabledRules(orgID)
 	_, _ = noopStorage.ListOfClustersForOrgSpecificRule(0, "", nil)
 	_, _ = noopStorage.ListOfClustersForOrgSpecificRule(0, "", []string{"a"})
+	_, _, _ = noopStorage.ListOfClustersForOrgSpecificRulePage(0, "", nil, storage.ListingOptions{})
 	_, _ = noopStorage.ReadRecommendationsForClusters([]string{}, types.OrgID(1))
+	_, _ = noopStorage.ReadRecommendationsSummary(types.OrgID(1))
 	_, _ = noopStorage.ReadClusterListRecommendations([]string{}, types.OrgID(1))
 	_, _ = noopStorage.ListOfDisabledClusters(orgID, "", "")
+	_, _ = noopStorage.ReadReportHistoryForCluster(orgID, "")
+	_ = noopStorage.WriteAuditEvents(nil)
+	_, _, _ = noopStorage.ReadAuditEvents(storage.AuditEventsFilter{}, storage.ListingOptions{})
 }
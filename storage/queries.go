@@ -32,18 +32,55 @@ func (storage DBStorage) getReportUpsertQuery() string {
 	`
 }
 
+// reportInfoUpsertConflict updates the stored info report. The stored
+// version is kept when the new info report doesn't contain any.
+const reportInfoUpsertConflict = `
+		ON CONFLICT (cluster_id)
+		DO UPDATE SET org_id = EXCLUDED.org_id, info = EXCLUDED.info,
+			version_info = CASE WHEN EXCLUDED.version_info = '' THEN report_info.version_info ELSE EXCLUDED.version_info END
+	`
+
 func (storage DBStorage) getReportInfoUpsertQuery() string {
+	return `
+		INSERT INTO report_info(org_id, cluster_id, version_info, info)
+		VALUES ($1, $2, $3, $4)` + reportInfoUpsertConflict
+}
+
+func (storage DBStorage) getReportHistoryUpsertQuery() string {
 	if storage.dbDriverType == types.DBDriverSQLite3 {
 		return `
-			INSERT OR REPLACE INTO report_info(org_id, cluster_id, version_info)
-			VALUES ($1, $2, $3)
+			INSERT OR REPLACE INTO report_history(org_id, cluster, report, reported_at, last_checked_at, kafka_offset, gathered_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
 		`
 	}
 
 	return `
-		INSERT INTO report_info(org_id, cluster_id, version_info)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (cluster_id)
-		DO UPDATE SET org_id = $1, version_info = $3
+		INSERT INTO report_history(org_id, cluster, report, reported_at, last_checked_at, kafka_offset, gathered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (org_id, cluster, last_checked_at)
+		DO UPDATE SET report = $3, reported_at = $4, kafka_offset = $6, gathered_at = $7
 	`
 }
+
+func (storage DBStorage) getReportsBatchUpsertQuery(reports int) string {
+	if storage.dbDriverType == types.DBDriverSQLite3 {
+		return `
+			INSERT OR REPLACE INTO report(org_id, cluster, report, reported_at, last_checked_at, kafka_offset, gathered_at)
+			VALUES ` + valuesPlaceholders(reports, 7)
+	}
+
+	return `
+		INSERT INTO report(org_id, cluster, report, reported_at, last_checked_at, kafka_offset, gathered_at)
+		VALUES ` + valuesPlaceholders(reports, 7) + `
+		ON CONFLICT (cluster)
+		DO UPDATE SET org_id = EXCLUDED.org_id, report = EXCLUDED.report, reported_at = EXCLUDED.reported_at,
+			last_checked_at = EXCLUDED.last_checked_at, kafka_offset = EXCLUDED.kafka_offset,
+			gathered_at = EXCLUDED.gathered_at
+	`
+}
+
+func (storage DBStorage) getReportInfoBatchUpsertQuery(infos int) string {
+	return `
+		INSERT INTO report_info(org_id, cluster_id, version_info, info)
+		VALUES ` + valuesPlaceholders(infos, 4) + reportInfoUpsertConflict
+}
@@ -25,12 +25,14 @@ import (
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
-// RateOnRule function stores the vote (rating) from a given user to a rule+error key
+// RateOnRule function stores the vote (rating) from a given user to a
+// rule+error key. Audit events are written in the same transaction.
 func (storage *DBStorage) RateOnRule(
 	orgID types.OrgID,
 	ruleFqdn types.RuleID,
 	errorKey types.ErrorKey,
 	rating types.UserVote,
+	auditEvents ...AuditEvent,
 ) error {
 	query := `
 		INSERT INTO advisor_ratings
@@ -40,21 +42,13 @@ func (storage *DBStorage) RateOnRule(
 		ON CONFLICT (org_id, rule_fqdn, error_key) DO UPDATE SET
 		last_updated_at = $5, rating = $6
 	`
-	statement, err := storage.connection.Prepare(query)
-	if err != nil {
-		log.Error().Err(err).Msg("RateOnRule Unable to prepare statement")
-	}
-
-	defer func() {
-		err := statement.Close()
-		if err != nil {
-			log.Error().Err(err).Msg(closeStatementError)
-		}
-	}()
 
 	now := time.Now()
 	ruleID := string(ruleFqdn) + "|" + string(errorKey)
-	_, err = statement.Exec(orgID, ruleFqdn, errorKey, now, now, rating, ruleID)
+	err := storage.changeWithAuditEvents(auditEvents, func(tx *sql.Tx) error {
+		_, err := tx.Exec(query, orgID, ruleFqdn, errorKey, now, now, rating, ruleID)
+		return err
+	})
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("RateOnRule")
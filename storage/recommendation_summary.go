@@ -0,0 +1,96 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// RecommendationSummary represents aggregated recommendations of one rule
+// selector in one organization
+type RecommendationSummary struct {
+	RuleID           types.RuleID `json:"rule_id"`
+	ImpactedClusters int          `json:"impacted_clusters"`
+	// ImpactedSince is the earliest time since the rule hits any of the
+	// impacted clusters
+	ImpactedSince string `json:"impacted_since"`
+	// TotalRisk is the severity of the rule. Rule content is not stored in
+	// the aggregator, so it is filled in only when it is known by the caller
+	TotalRisk int `json:"total_risk,omitempty"`
+}
+
+// ReadRecommendationsSummary aggregates all recommendations of given
+// organization per rule selector. Clusters where the rule is disabled by
+// user and rules disabled system-wide are not counted unless the disable has
+// expired, rules that don't impact any cluster are not returned.
+func (storage DBStorage) ReadRecommendationsSummary(orgID types.OrgID) ([]RecommendationSummary, error) {
+	summary := make([]RecommendationSummary, 0)
+
+	// org_id columns of cluster_rule_toggle and rule_disable tables are
+	// strings, so the organization is passed as separate parameter
+	query := `
+		SELECT rec.rule_id, COUNT(DISTINCT rec.cluster_id), MIN(rec.impacted_since)
+		  FROM recommendation rec
+		 WHERE rec.org_id = $1
+		   AND NOT EXISTS (
+				SELECT 1 FROM cluster_rule_toggle crt
+				 WHERE crt.org_id = $2
+				   AND crt.cluster_id = rec.cluster_id
+				   AND crt.rule_id IN (rec.rule_fqdn, rec.rule_fqdn || '.report')
+				   AND crt.error_key = rec.error_key
+				   AND crt.disabled = $3
+				   AND (crt.expires_at IS NULL OR crt.expires_at > $4)
+		       )
+		   AND NOT EXISTS (
+				SELECT 1 FROM rule_disable rd
+				 WHERE rd.org_id = $2
+				   AND rd.rule_id IN (rec.rule_fqdn, rec.rule_fqdn || '.report')
+				   AND rd.error_key = rec.error_key
+				   AND (rd.expires_at IS NULL OR rd.expires_at > $4)
+		       )
+		 GROUP BY rec.rule_id
+		 ORDER BY rec.rule_id;
+	`
+
+	rows, err := storage.connection.Query(query, orgID, orgID, RuleToggleDisable, time.Now().UTC())
+	err = types.ConvertDBError(err, orgID)
+	if err != nil {
+		return summary, err
+	}
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			item          RecommendationSummary
+			impactedSince sql.NullString
+		)
+
+		err = rows.Scan(&item.RuleID, &item.ImpactedClusters, &impactedSince)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadRecommendationsSummary")
+			return summary, err
+		}
+		item.ImpactedSince = impactedSince.String
+
+		summary = append(summary, item)
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// mustWriteRecommendationsForClusters writes recommendations of the report
+// for all clusters, each cluster is hit one hour later than the previous one
+func mustWriteRecommendationsForClusters(
+	t *testing.T, mockStorage storage.Storage, report types.ClusterReport, clusters ...types.ClusterName,
+) {
+	for i, clusterName := range clusters {
+		createdAt := types.Timestamp(testdata.LastCheckedAt.Add(time.Duration(i) * time.Hour).UTC().Format(time.RFC3339))
+		helpers.FailOnError(t, mockStorage.WriteRecommendationsForCluster(testdata.OrgID, clusterName, report, createdAt))
+	}
+}
+
+// TestReadRecommendationsSummary checks that recommendations are aggregated
+// per rule and clusters with the rule disabled are not counted
+func TestReadRecommendationsSummary(t *testing.T) {
+	clusters := []types.ClusterName{
+		testdata.GetRandomClusterID(), testdata.GetRandomClusterID(), testdata.GetRandomClusterID(),
+	}
+
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			mustWriteRecommendationsForClusters(t, mockStorage, testdata.Report3Rules, clusters[0])
+			mustWriteRecommendationsForClusters(t, mockStorage, testdata.Report2Rules, clusters...)
+			// the same cluster in other organization is not counted
+			helpers.FailOnError(t, mockStorage.WriteRecommendationsForCluster(
+				testdata.Org2ID, testdata.GetRandomClusterID(), testdata.Report3Rules, RecommendationCreatedAtTimestamp,
+			))
+
+			// rule 2 is disabled for the first cluster, so it's impacted since
+			// the second cluster was hit
+			helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+				clusters[0], testdata.Rule2ID, testdata.ErrorKey2, testdata.OrgID, storage.RuleToggleDisable,
+			))
+			// rule 3 is disabled for the only cluster it hits
+			helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+				clusters[0], testdata.Rule3ID, testdata.ErrorKey3, testdata.OrgID, storage.RuleToggleDisable,
+			))
+
+			summary, err := mockStorage.ReadRecommendationsSummary(testdata.OrgID)
+			helpers.FailOnError(t, err)
+
+			assert.Equal(t, []storage.RecommendationSummary{
+				{
+					RuleID:           testdata.Rule1CompositeID,
+					ImpactedClusters: 3,
+					ImpactedSince:    testdata.LastCheckedAt.UTC().Format(time.RFC3339),
+				},
+				{
+					RuleID:           testdata.Rule2CompositeID,
+					ImpactedClusters: 2,
+					ImpactedSince:    testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339),
+				},
+			}, summary)
+		})
+	}
+}
+
+// TestReadRecommendationsSummaryRuleDisabledSystemWide checks that rules
+// disabled system-wide are not part of the summary
+func TestReadRecommendationsSummaryRuleDisabledSystemWide(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			mustWriteRecommendationsForClusters(t, mockStorage, testdata.Report2Rules, testdata.GetRandomClusterID())
+			helpers.FailOnError(t, mockStorage.DisableRuleSystemWide(
+				testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, "justification",
+			))
+
+			summary, err := mockStorage.ReadRecommendationsSummary(testdata.OrgID)
+			helpers.FailOnError(t, err)
+
+			assert.Len(t, summary, 1)
+			assert.Equal(t, testdata.Rule2CompositeID, summary[0].RuleID)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// compressedReportPrefix marks reports stored in compressed form. The
+// report column is a text column, so compressed reports are base64 encoded.
+// Reports without the prefix are stored as plain JSON, which allows to
+// turn the compression on and off without migrating stored reports.
+const compressedReportPrefix = "zstd+base64:"
+
+// encoder and decoder are safe for concurrent use by EncodeAll and
+// DecodeAll methods
+var (
+	reportEncoder, _ = zstd.NewWriter(nil)
+	reportDecoder, _ = zstd.NewReader(nil)
+)
+
+// compressReport returns the report in the compressed form
+func compressReport(report types.ClusterReport) types.ClusterReport {
+	compressed := reportEncoder.EncodeAll([]byte(report), nil)
+	return types.ClusterReport(compressedReportPrefix + base64.StdEncoding.EncodeToString(compressed))
+}
+
+// decompressReport returns the report in plain JSON. Reports that are not
+// compressed are returned unchanged.
+func decompressReport(report types.ClusterReport) (types.ClusterReport, error) {
+	if !strings.HasPrefix(string(report), compressedReportPrefix) {
+		return report, nil
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(string(report), compressedReportPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	decompressed, err := reportDecoder.DecodeAll(compressed, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return types.ClusterReport(decompressed), nil
+}
+
+// storedReport returns the report in the form in which it is stored into
+// report table
+func (storage DBStorage) storedReport(report types.ClusterReport) types.ClusterReport {
+	if storage.compressReports {
+		return compressReport(report)
+	}
+	return report
+}
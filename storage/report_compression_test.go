@@ -0,0 +1,107 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func readStoredReport(t *testing.T, dbStorage *storage.DBStorage, clusterName types.ClusterName) string {
+	var report string
+
+	err := storage.GetConnection(dbStorage).QueryRow(
+		"SELECT report FROM report WHERE cluster = $1", clusterName,
+	).Scan(&report)
+	helpers.FailOnError(t, err)
+
+	return report
+}
+
+// TestDBStorageCompressedReports checks that compressed reports are stored
+// in compressed form and that both compressed and plain reports are read
+// transparently
+func TestDBStorageCompressedReports(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetSQLiteMemoryStorage(t, true)
+	defer closer()
+	dbStorage := mockStorage.(*storage.DBStorage)
+
+	compressedCluster := testdata.ClusterName
+	plainCluster := testdata.GetRandomClusterID()
+
+	storage.SetCompressReports(dbStorage, true)
+	writeReportToMemoryStorage(
+		t, mockStorage, compressedCluster, testdata.Report3Rules, testdata.Report3RulesParsed, time.Now(),
+	)
+
+	storage.SetCompressReports(dbStorage, false)
+	writeReportToMemoryStorage(
+		t, mockStorage, plainCluster, testdata.Report2Rules, testdata.Report2RulesParsed, time.Now(),
+	)
+
+	storedReport := readStoredReport(t, dbStorage, compressedCluster)
+	assert.True(t, strings.HasPrefix(storedReport, "zstd+base64:"))
+	assert.Less(t, len(storedReport), len(testdata.Report3Rules))
+	assert.Equal(t, string(testdata.Report2Rules), readStoredReport(t, dbStorage, plainCluster))
+
+	reports, err := mockStorage.ReadReportsForClusters([]types.ClusterName{compressedCluster, plainCluster})
+	helpers.FailOnError(t, err)
+
+	assert.Equal(t, map[types.ClusterName]types.ClusterReport{
+		compressedCluster: testdata.Report3Rules,
+		plainCluster:      testdata.Report2Rules,
+	}, reports)
+
+	// rule hits are not affected by the compression
+	ruleHits, _, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, compressedCluster)
+	helpers.FailOnError(t, err)
+	assert.Len(t, ruleHits, 3)
+}
+
+// TestDBStorageCompressedReportsBatch checks that reports written in batch
+// are compressed too
+func TestDBStorageCompressedReportsBatch(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetSQLiteMemoryStorage(t, true)
+	defer closer()
+	dbStorage := mockStorage.(*storage.DBStorage)
+	storage.SetCompressReports(dbStorage, true)
+
+	errs := mockStorage.WriteReportsBatch([]storage.ReportBatchItem{{
+		OrgID:       testdata.OrgID,
+		ClusterName: testdata.ClusterName,
+		Report:      testdata.Report3Rules,
+		Rules:       testdata.Report3RulesParsed,
+		LastChecked: time.Now(),
+		StoredAt:    time.Now(),
+	}})
+	for _, err := range errs {
+		helpers.FailOnError(t, err)
+	}
+
+	assert.True(t, strings.HasPrefix(readStoredReport(t, dbStorage, testdata.ClusterName), "zstd+base64:"))
+
+	reports, err := mockStorage.ReadReportsForClusters([]types.ClusterName{testdata.ClusterName})
+	helpers.FailOnError(t, err)
+	assert.Equal(t, testdata.Report3Rules, reports[testdata.ClusterName])
+}
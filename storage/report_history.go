@@ -0,0 +1,161 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ReportHistoryItem represents one report stored in report_history table
+// together with the rules that were hitting when the cluster was checked
+type ReportHistoryItem struct {
+	LastCheckedAt types.Timestamp    `json:"last_checked_at"`
+	ReportedAt    types.Timestamp    `json:"reported_at"`
+	GatheredAt    types.Timestamp    `json:"gathered_at,omitempty"`
+	HitRules      []types.ReportItem `json:"rules"`
+}
+
+// reportHitRules is a helper struct used to unmarshal just the rule hits
+// from string encoded report
+type reportHitRules struct {
+	HitRules []types.ReportItem `json:"reports"`
+}
+
+// keepsReportHistory checks whether reports of given organization are
+// stored in report_history table
+func (storage DBStorage) keepsReportHistory(orgID types.OrgID) bool {
+	return storage.reportHistoryLength > 0 && !storage.reportHistoryDisabledOrgs[orgID]
+}
+
+// insertReportHistory stores the report into report_history table and
+// removes the oldest reports for given cluster so that at most
+// reportHistoryLength reports remain there
+func (storage DBStorage) insertReportHistory(
+	tx *sql.Tx,
+	orgID types.OrgID,
+	clusterName types.ClusterName,
+	report types.ClusterReport,
+	lastCheckedTime time.Time,
+	gatheredAt time.Time,
+	reportedAtTime time.Time,
+	kafkaOffset types.KafkaOffset,
+) error {
+	gatheredAtInDB := sql.NullTime{Time: gatheredAt, Valid: !gatheredAt.IsZero()}
+
+	_, err := tx.Exec(
+		storage.getReportHistoryUpsertQuery(),
+		orgID, clusterName, report, reportedAtTime, lastCheckedTime, kafkaOffset, gatheredAtInDB,
+	)
+	if err != nil {
+		log.Err(err).Msgf("Unable to insert the cluster report into history (org: %v, cluster: %v)", orgID, clusterName)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM report_history
+		 WHERE org_id = $1 AND cluster = $2
+		   AND last_checked_at NOT IN (
+			  SELECT last_checked_at
+			    FROM report_history
+			   WHERE org_id = $1 AND cluster = $2
+			ORDER BY last_checked_at DESC
+			   LIMIT $3
+		   );
+	`, orgID, clusterName, storage.reportHistoryLength)
+	if err != nil {
+		log.Err(err).Msgf("Unable to remove old reports from history (org: %v, cluster: %v)", orgID, clusterName)
+		return err
+	}
+
+	return nil
+}
+
+// ReadReportHistoryForCluster reads all reports stored in the history for
+// given cluster. Reports are ordered from the oldest one to the newest one.
+func (storage DBStorage) ReadReportHistoryForCluster(
+	orgID types.OrgID, clusterName types.ClusterName,
+) ([]ReportHistoryItem, error) {
+	history := make([]ReportHistoryItem, 0)
+
+	rows, err := storage.connection.Query(`
+		  SELECT report, last_checked_at, reported_at, gathered_at
+		    FROM report_history
+		   WHERE org_id = $1 AND cluster = $2
+		ORDER BY last_checked_at;
+	`, orgID, clusterName)
+
+	err = types.ConvertDBError(err, []interface{}{orgID, clusterName})
+	if err != nil {
+		log.Error().Err(err).Str(clusterKey, string(clusterName)).Msg(
+			"ReadReportHistoryForCluster query from report_history table error",
+		)
+		return history, err
+	}
+
+	defer closeRows(rows)
+
+	for rows.Next() {
+		var (
+			report         types.ClusterReport
+			lastChecked    time.Time
+			reportedAt     time.Time
+			gatheredAtInDB sql.NullTime
+			hitRules       reportHitRules
+		)
+
+		err = rows.Scan(&report, &lastChecked, &reportedAt, &gatheredAtInDB)
+		if err != nil {
+			log.Error().Err(err).Msg("ReadReportHistoryForCluster")
+			return history, err
+		}
+
+		err = json.Unmarshal([]byte(report), &hitRules)
+		if err != nil {
+			log.Error().Err(err).Str(clusterKey, string(clusterName)).Msg(
+				"Unable to parse report stored in history",
+			)
+			return history, err
+		}
+
+		item := ReportHistoryItem{
+			LastCheckedAt: types.Timestamp(lastChecked.UTC().Format(time.RFC3339)),
+			ReportedAt:    types.Timestamp(reportedAt.UTC().Format(time.RFC3339)),
+			HitRules:      hitRules.HitRules,
+		}
+		if gatheredAtInDB.Valid {
+			item.GatheredAt = types.Timestamp(gatheredAtInDB.Time.UTC().Format(time.RFC3339))
+		}
+		if item.HitRules == nil {
+			item.HitRules = []types.ReportItem{}
+		}
+
+		history = append(history, item)
+	}
+
+	if len(history) == 0 {
+		return history, &types.ItemNotFoundError{
+			ItemID: fmt.Sprintf("%v/%v", orgID, clusterName),
+		}
+	}
+
+	return history, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func writeReportToHistory(
+	t *testing.T,
+	mockStorage storage.Storage,
+	report types.ClusterReport,
+	rules []types.ReportItem,
+	lastChecked time.Time,
+) {
+	err := mockStorage.WriteReportForCluster(
+		testdata.OrgID,
+		testdata.ClusterName,
+		report,
+		rules,
+		lastChecked,
+		lastChecked,
+		time.Now(),
+		testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageReadReportHistoryForClusterNotFound(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	_, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDBStorageReadReportHistoryForClusterDisabled(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	storage.SetReportHistoryLength(mockStorage.(*storage.DBStorage), 0)
+
+	writeReportToHistory(t, mockStorage, testdata.Report2Rules, testdata.Report2RulesParsed, testdata.LastCheckedAt)
+
+	_, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDBStorageReportHistoryDisabledForOrg(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	storage.SetReportHistoryLength(mockStorage.(*storage.DBStorage), 10)
+	storage.SetReportHistoryDisabledOrgs(mockStorage.(*storage.DBStorage), map[types.OrgID]bool{testdata.OrgID: true})
+
+	writeReportToHistory(t, mockStorage, testdata.Report2Rules, testdata.Report2RulesParsed, testdata.LastCheckedAt)
+
+	// the latest report is stored, but not its history
+	_, _, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	_, err = mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
+
+func TestDBStorageReadReportHistoryForCluster(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	storage.SetReportHistoryLength(mockStorage.(*storage.DBStorage), 10)
+
+	firstChecked := testdata.LastCheckedAt.UTC()
+	secondChecked := firstChecked.Add(time.Hour)
+
+	writeReportToHistory(t, mockStorage, testdata.Report2Rules, testdata.Report2RulesParsed, firstChecked)
+	writeReportToHistory(t, mockStorage, testdata.Report0Rules, testdata.ReportEmptyRulesParsed, secondChecked)
+
+	history, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, history, 2)
+
+	assert.Equal(t, types.Timestamp(firstChecked.Format(time.RFC3339)), history[0].LastCheckedAt)
+	assert.Len(t, history[0].HitRules, 2)
+	assert.Equal(t, testdata.Rule1ID, history[0].HitRules[0].Module)
+	assert.Equal(t, types.ErrorKey(testdata.ErrorKey1), history[0].HitRules[0].ErrorKey)
+	assert.Equal(t, testdata.Rule2ID, history[0].HitRules[1].Module)
+	assert.Equal(t, types.ErrorKey(testdata.ErrorKey2), history[0].HitRules[1].ErrorKey)
+
+	assert.Equal(t, types.Timestamp(secondChecked.Format(time.RFC3339)), history[1].LastCheckedAt)
+	assert.Empty(t, history[1].HitRules)
+}
+
+func TestDBStorageReportHistoryIsLimited(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	const historyLength = 3
+
+	storage.SetReportHistoryLength(mockStorage.(*storage.DBStorage), historyLength)
+
+	lastChecked := testdata.LastCheckedAt.UTC()
+	for i := 0; i < 5; i++ {
+		lastChecked = lastChecked.Add(time.Hour)
+		writeReportToHistory(t, mockStorage, testdata.Report2Rules, testdata.Report2RulesParsed, lastChecked)
+	}
+
+	history, err := mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	assert.Len(t, history, historyLength)
+	// only the newest reports should be kept
+	assert.Equal(t, types.Timestamp(lastChecked.Format(time.RFC3339)), history[historyLength-1].LastCheckedAt)
+}
+
+func TestDBStorageDeleteReportsForClusterDeletesHistory(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	storage.SetReportHistoryLength(mockStorage.(*storage.DBStorage), 10)
+
+	writeReportToHistory(t, mockStorage, testdata.Report2Rules, testdata.Report2RulesParsed, testdata.LastCheckedAt)
+
+	err := mockStorage.DeleteReportsForCluster(testdata.ClusterName)
+	helpers.FailOnError(t, err)
+
+	_, err = mockStorage.ReadReportHistoryForCluster(testdata.OrgID, testdata.ClusterName)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+}
@@ -0,0 +1,596 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// ReportBatchItem represents one report written by WriteReportsBatch
+// together with its rule hits and info items
+type ReportBatchItem struct {
+	OrgID       types.OrgID
+	ClusterName types.ClusterName
+	Report      types.ClusterReport
+	Rules       []types.ReportItem
+	Info        []types.InfoItem
+	LastChecked time.Time
+	GatheredAt  time.Time
+	StoredAt    time.Time
+	KafkaOffset types.KafkaOffset
+}
+
+// orgClusterKey identifies the cluster rows in tables keyed by both
+// org_id and cluster ID
+type orgClusterKey struct {
+	orgID       types.OrgID
+	clusterName types.ClusterName
+}
+
+// writeReportsOneByOne writes the reports, recommendations and info reports
+// for all items using the methods writing one cluster at a time. Error for
+// each item is returned at the same index as the item.
+func writeReportsOneByOne(storage Storage, items []ReportBatchItem) []error {
+	errs := make([]error, len(items))
+
+	for i := range items {
+		item := &items[i]
+
+		err := storage.WriteReportForCluster(
+			item.OrgID, item.ClusterName, item.Report, item.Rules,
+			item.LastChecked, item.GatheredAt, item.StoredAt, item.KafkaOffset,
+		)
+		if err == nil {
+			err = storage.WriteRecommendationsForCluster(
+				item.OrgID, item.ClusterName, item.Report,
+				types.Timestamp(time.Now().UTC().Format(time.RFC3339)),
+			)
+		}
+		if err == nil {
+			err = storage.WriteReportInfoForCluster(item.OrgID, item.ClusterName, item.Info, item.LastChecked)
+		}
+
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// valuesPlaceholders returns placeholders for multi-row INSERT statement,
+// for example ($1,$2),($3,$4) for two rows with two columns
+func valuesPlaceholders(rows, columns int) string {
+	placeholders := make([]string, rows)
+
+	for row := 0; row < rows; row++ {
+		columnPlaceholders := make([]string, columns)
+		for column := 0; column < columns; column++ {
+			columnPlaceholders[column] = fmt.Sprintf("$%d", row*columns+column+1)
+		}
+		placeholders[row] = "(" + strings.Join(columnPlaceholders, ",") + ")"
+	}
+
+	return strings.Join(placeholders, ",")
+}
+
+// chunkEnd returns the end of the chunk of at most size items starting at
+// start, the end doesn't exceed the number of items
+func chunkEnd(start, size, items int) int {
+	if start+size > items {
+		return items
+	}
+	return start + size
+}
+
+// rowsPerStatement returns how many rows with given number of columns fit
+// into one statement without exceeding the limit of bind parameters
+func (storage DBStorage) rowsPerStatement(columns int) int {
+	rows := storage.maxStatementParameters / columns
+	if rows < 1 {
+		return 1
+	}
+	return rows
+}
+
+// execInChunks executes the multi-row statement returned by query for given
+// number of rows. Values (columns values for each row) are split into
+// chunks, so no statement exceeds the limit of bind parameters.
+func (storage DBStorage) execInChunks(
+	tx *sql.Tx, columns int, values []interface{}, query func(rows int) string,
+) error {
+	rows := len(values) / columns
+	chunkRows := storage.rowsPerStatement(columns)
+
+	for start := 0; start < rows; start += chunkRows {
+		end := chunkEnd(start, chunkRows, rows)
+		if _, err := tx.Exec(query(end-start), values[start*columns:end*columns]...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryForClusters calls the query for the clusters split into chunks, so
+// no IN clause exceeds the limit of bind parameters
+func (storage DBStorage) queryForClusters(
+	clusterNames []types.ClusterName, query func(inClause string, clusterArgs []interface{}) error,
+) error {
+	chunkSize := storage.rowsPerStatement(1)
+
+	for start := 0; start < len(clusterNames); start += chunkSize {
+		chunk := clusterNames[start:chunkEnd(start, chunkSize, len(clusterNames))]
+
+		inClause, err := constructInClausule(len(chunk))
+		if err != nil {
+			return err
+		}
+
+		if err := query(inClause, argsWithClusterNames(chunk)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteReportsBatch writes reports, rule hits, recommendations and info
+// reports for several clusters in one transaction using multi-row
+// statements. Error for each item is returned at the same index as the item,
+// types.ErrOldReport is returned for reports not newer than the stored ones.
+// When the batch can't be written, all reports are written one by one, each
+// of them in its own transaction, so the errors can be assigned to the items
+// that caused them and nothing is written for the items that failed.
+func (storage DBStorage) WriteReportsBatch(items []ReportBatchItem) []error {
+	errs := make([]error, len(items))
+
+	if storage.dbDriverType != types.DBDriverSQLite3 && storage.dbDriverType != types.DBDriverPostgres {
+		for i := range errs {
+			errs[i] = fmt.Errorf("writing report with DB %v is not supported", storage.dbDriverType)
+		}
+		return errs
+	}
+
+	// only the newest report for each cluster is written
+	newest := make(map[types.ClusterName]int)
+	for i := range items {
+		item := &items[i]
+
+		if oldLastChecked, exists := storage.getClusterLastChecked(item.ClusterName); exists && !item.LastChecked.After(oldLastChecked) {
+			errs[i] = types.ErrOldReport
+			continue
+		}
+
+		if j, found := newest[item.ClusterName]; found {
+			if !item.LastChecked.After(items[j].LastChecked) {
+				errs[i] = types.ErrOldReport
+				continue
+			}
+			errs[j] = types.ErrOldReport
+		}
+		newest[item.ClusterName] = i
+	}
+
+	selected := make([]int, 0, len(newest))
+	for _, i := range newest {
+		selected = append(selected, i)
+	}
+	sort.Ints(selected)
+
+	if len(selected) == 0 {
+		return errs
+	}
+
+	if err := storage.writeReportsInTransaction(items, selected, errs); err != nil {
+		log.Error().Err(err).Int("reports", len(selected)).Msg(
+			"Unable to write the reports in batch, writing them one by one",
+		)
+
+		for _, index := range selected {
+			if err := storage.writeReportsInTransaction(items, []int{index}, errs); err != nil {
+				errs[index] = err
+			}
+		}
+	}
+
+	return errs
+}
+
+// writeReportsInTransaction writes the selected items in one transaction,
+// so either all of them are written or none. Items that were skipped because
+// the database already contains more recent reports for their clusters get
+// types.ErrOldReport in errs.
+func (storage DBStorage) writeReportsInTransaction(
+	items []ReportBatchItem, selected []int, errs []error,
+) error {
+	tx, err := storage.connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	written, err := func(tx *sql.Tx) ([]int, error) {
+		return storage.writeReportsBatch(tx, items, selected)
+	}(tx)
+
+	finishTransaction(tx, err)
+
+	if err != nil {
+		return err
+	}
+
+	isWritten := make(map[int]bool, len(written))
+	for _, i := range written {
+		isWritten[i] = true
+		storage.clustersLastChecked.set(items[i].ClusterName, items[i].LastChecked)
+	}
+	metrics.WrittenReports.Add(float64(len(written)))
+
+	// the rest has been skipped because the database already contains more
+	// recent reports for these clusters
+	for _, i := range selected {
+		if !isWritten[i] {
+			errs[i] = types.ErrOldReport
+		}
+	}
+
+	return nil
+}
+
+// writeReportsBatch writes the selected items in given transaction and
+// returns indexes of the items that were written
+func (storage DBStorage) writeReportsBatch(
+	tx *sql.Tx, items []ReportBatchItem, selected []int,
+) ([]int, error) {
+	clusterNames := make([]types.ClusterName, len(selected))
+	for i, index := range selected {
+		clusterNames[i] = items[index].ClusterName
+	}
+
+	// reports that are older than the ones already stored are discarded
+	storedLastChecked, err := storage.readLastCheckedForClusters(tx, clusterNames)
+	if err != nil {
+		return nil, err
+	}
+
+	written := make([]int, 0, len(selected))
+	for _, index := range selected {
+		item := &items[index]
+		if lastChecked, found := storedLastChecked[item.ClusterName]; found && lastChecked.After(item.LastChecked) {
+			log.Warn().Msgf("Database already contains report for organization %d and cluster name %s more recent than %v",
+				item.OrgID, item.ClusterName, item.LastChecked)
+			continue
+		}
+		written = append(written, index)
+	}
+
+	if len(written) == 0 {
+		return written, nil
+	}
+
+	// rules that were already hitting the clusters keep their timestamps
+	ruleHitsCreatedAt, err := storage.readRuleTimestampsForClusters(
+		tx, "SELECT org_id, cluster_id, rule_fqdn, error_key, created_at FROM rule_hit WHERE cluster_id IN (%v);",
+		clusterNames,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	impactedSince, err := storage.readRuleTimestampsForClusters(
+		tx, "SELECT org_id, cluster_id, rule_fqdn, error_key, impacted_since FROM recommendation WHERE cluster_id IN (%v);",
+		clusterNames,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := storage.writeRuleHitsBatch(tx, items, written, ruleHitsCreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := storage.writeReportsRowsBatch(tx, items, written); err != nil {
+		return nil, err
+	}
+
+	if err := storage.writeRecommendationsBatch(tx, items, written, impactedSince); err != nil {
+		return nil, err
+	}
+
+	if err := storage.writeReportInfosBatch(tx, items, written); err != nil {
+		return nil, err
+	}
+
+	return written, nil
+}
+
+// readLastCheckedForClusters reads last_checked_at timestamps of given
+// clusters from report table
+func (storage DBStorage) readLastCheckedForClusters(
+	tx *sql.Tx, clusterNames []types.ClusterName,
+) (map[types.ClusterName]time.Time, error) {
+	lastCheckedMap := make(map[types.ClusterName]time.Time)
+
+	err := storage.queryForClusters(clusterNames, func(inClause string, clusterArgs []interface{}) error {
+		rows, err := tx.Query(
+			"SELECT cluster, last_checked_at FROM report WHERE cluster IN ("+inClause+");", clusterArgs...,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to look up the most recent reports in the database")
+			return err
+		}
+		defer closeRows(rows)
+
+		for rows.Next() {
+			var (
+				clusterName types.ClusterName
+				lastChecked time.Time
+			)
+
+			if err := rows.Scan(&clusterName, &lastChecked); err != nil {
+				return err
+			}
+			lastCheckedMap[clusterName] = lastChecked
+		}
+
+		return rows.Err()
+	})
+
+	return lastCheckedMap, err
+}
+
+// readRuleTimestampsForClusters reads (rule_fqdn, error_key) -> timestamp
+// map for all given clusters using the query with %v placeholder for IN
+// clause
+func (storage DBStorage) readRuleTimestampsForClusters(
+	tx *sql.Tx, query string, clusterNames []types.ClusterName,
+) (map[orgClusterKey]map[string]types.Timestamp, error) {
+	timestamps := make(map[orgClusterKey]map[string]types.Timestamp)
+
+	err := storage.queryForClusters(clusterNames, func(inClause string, clusterArgs []interface{}) error {
+		rows, err := tx.Query(fmt.Sprintf(query, inClause), clusterArgs...)
+		if err != nil {
+			log.Error().Err(err).Msg("error retrieving rule timestamps")
+			return err
+		}
+		defer closeRows(rows)
+
+		for rows.Next() {
+			var (
+				orgID       types.OrgID
+				clusterName types.ClusterName
+				ruleFqdn    string
+				errorKey    string
+				oldTime     time.Time
+			)
+
+			if err := rows.Scan(&orgID, &clusterName, &ruleFqdn, &errorKey, &oldTime); err != nil {
+				log.Error().Err(err).Msg("error scanning for rule id -> timestamp map")
+				return err
+			}
+
+			key := orgClusterKey{orgID, clusterName}
+			if timestamps[key] == nil {
+				timestamps[key] = make(map[string]types.Timestamp)
+			}
+			timestamps[key][ruleFqdn+errorKey] = types.Timestamp(oldTime.UTC().Format(time.RFC3339))
+		}
+
+		return rows.Err()
+	})
+
+	return timestamps, err
+}
+
+// deleteForClusters deletes all rows for the clusters of given items from
+// the table having org_id and cluster_id columns
+func (storage DBStorage) deleteForClusters(
+	tx *sql.Tx, table string, items []ReportBatchItem, indexes []int,
+) (int64, error) {
+	var deleted int64
+	chunkSize := storage.rowsPerStatement(2)
+
+	for start := 0; start < len(indexes); start += chunkSize {
+		chunk := indexes[start:chunkEnd(start, chunkSize, len(indexes))]
+
+		args := make([]interface{}, 0, 2*len(chunk))
+		for _, index := range chunk {
+			args = append(args, items[index].OrgID, items[index].ClusterName)
+		}
+
+		// SQLite supports row values only in comparison with VALUES list
+		values := valuesPlaceholders(len(chunk), 2)
+		if storage.dbDriverType == types.DBDriverSQLite3 {
+			values = "VALUES " + values
+		}
+
+		// it is needed to use `org_id` condition there because it allows DB
+		// to use proper btree indexing and not slow sequential scan
+		result, err := tx.Exec(fmt.Sprintf(
+			"DELETE FROM %v WHERE (org_id, cluster_id) IN (%v);", table, values,
+		), args...)
+		if err != nil {
+			log.Error().Err(err).Msgf("Unable to delete the existing rows from %v", table)
+			return deleted, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += affected
+	}
+
+	return deleted, nil
+}
+
+// writeRuleHitsBatch replaces rule hits of all written clusters
+func (storage DBStorage) writeRuleHitsBatch(
+	tx *sql.Tx,
+	items []ReportBatchItem,
+	written []int,
+	ruleKeyCreatedAt map[orgClusterKey]map[string]types.Timestamp,
+) error {
+	if _, err := storage.deleteForClusters(tx, "rule_hit", items, written); err != nil {
+		return err
+	}
+
+	var (
+		rules  []types.ReportItem
+		values []interface{}
+	)
+	for _, index := range written {
+		item := &items[index]
+		rules = append(rules, item.Rules...)
+		values = append(values, valuesForRuleHitsInsert(
+			item.OrgID, item.ClusterName, item.Rules, ruleKeyCreatedAt[orgClusterKey{item.OrgID, item.ClusterName}],
+		)...)
+	}
+
+	if len(rules) == 0 {
+		return nil
+	}
+
+	err := storage.execInChunks(tx, 6, values, func(rows int) string {
+		return storage.GetRuleHitInsertStatement(rules[:rows])
+	})
+	if err != nil {
+		log.Err(err).Msg("Unable to insert the cluster reports rules")
+		return err
+	}
+
+	return nil
+}
+
+// writeReportsRowsBatch upserts the reports and stores them into the history
+func (storage DBStorage) writeReportsRowsBatch(tx *sql.Tx, items []ReportBatchItem, written []int) error {
+	values := make([]interface{}, 0, 7*len(written))
+	for _, index := range written {
+		item := &items[index]
+		values = append(values,
+			item.OrgID, item.ClusterName, storage.storedReport(item.Report), item.StoredAt, item.LastChecked, item.KafkaOffset,
+			sql.NullTime{Time: item.GatheredAt, Valid: !item.GatheredAt.IsZero()},
+		)
+	}
+
+	if err := storage.execInChunks(tx, 7, values, storage.getReportsBatchUpsertQuery); err != nil {
+		log.Err(err).Msg("Unable to upsert the cluster reports")
+		return err
+	}
+
+	for _, index := range written {
+		item := &items[index]
+		if !storage.keepsReportHistory(item.OrgID) {
+			continue
+		}
+
+		err := storage.insertReportHistory(
+			tx, item.OrgID, item.ClusterName, item.Report,
+			item.LastChecked, item.GatheredAt, item.StoredAt, item.KafkaOffset,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeRecommendationsBatch replaces recommendations of all written clusters
+func (storage DBStorage) writeRecommendationsBatch(
+	tx *sql.Tx,
+	items []ReportBatchItem,
+	written []int,
+	impactedSinceMap map[orgClusterKey]map[string]types.Timestamp,
+) error {
+	deleted, err := storage.deleteForClusters(tx, "recommendation", items, written)
+	if err != nil {
+		return err
+	}
+
+	createdAt := types.Timestamp(time.Now().UTC().Format(time.RFC3339))
+
+	var values []interface{}
+	for _, index := range written {
+		item := &items[index]
+
+		var report types.ReportRules
+		if err := json.Unmarshal([]byte(item.Report), &report); err != nil {
+			return err
+		}
+
+		impactedSinceForCluster := impactedSinceMap[orgClusterKey{item.OrgID, item.ClusterName}]
+		for _, rule := range report.HitRules {
+			ruleFqdn := strings.TrimSuffix(string(rule.Module), ".report")
+			impactedSince, ok := impactedSinceForCluster[ruleFqdn+string(rule.ErrorKey)]
+			if !ok {
+				impactedSince = createdAt
+			}
+			values = append(values,
+				item.OrgID, item.ClusterName, ruleFqdn, rule.ErrorKey,
+				ruleFqdn+"|"+string(rule.ErrorKey), createdAt, impactedSince,
+			)
+		}
+	}
+
+	if len(values) > 0 {
+		err = storage.execInChunks(tx, 7, values, func(rows int) string {
+			return "INSERT INTO recommendation (org_id, cluster_id, rule_fqdn, error_key, rule_id, created_at, impacted_since) VALUES " +
+				valuesPlaceholders(rows, 7)
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("Unable to insert the recommendations")
+			return err
+		}
+	}
+
+	log.Info().
+		Int64("Deleted", deleted).
+		Int("Inserted", len(values)/7).
+		Int("Clusters", len(written)).
+		Msg("Updated recommendation table")
+
+	return nil
+}
+
+// writeReportInfosBatch upserts info reports of all written clusters
+func (storage DBStorage) writeReportInfosBatch(tx *sql.Tx, items []ReportBatchItem, written []int) error {
+	var values []interface{}
+	for _, index := range written {
+		item := &items[index]
+
+		version, info, found, err := reportInfoValues(item.Info)
+		if err != nil {
+			return err
+		}
+		if found {
+			values = append(values, item.OrgID, item.ClusterName, version, info)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return storage.execInChunks(tx, 4, values, storage.getReportInfoBatchUpsertQuery)
+}
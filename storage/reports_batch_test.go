@@ -0,0 +1,223 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func reportBatchItem(clusterName types.ClusterName, lastChecked time.Time) storage.ReportBatchItem {
+	return storage.ReportBatchItem{
+		OrgID:       testdata.OrgID,
+		ClusterName: clusterName,
+		Report:      testdata.Report2Rules,
+		Rules:       testdata.Report2RulesParsed,
+		Info: []types.InfoItem{{
+			InfoID:  "version_info|CLUSTER_VERSION_INFO",
+			Details: map[string]string{"version": "4.9"},
+		}},
+		LastChecked: lastChecked,
+		GatheredAt:  lastChecked,
+		StoredAt:    time.Now(),
+		KafkaOffset: testdata.KafkaOffset,
+	}
+}
+
+func TestDBStorageWriteReportsBatch(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	clusters := []types.ClusterName{testdata.ClusterName, testdata.GetRandomClusterID()}
+
+	errs := mockStorage.WriteReportsBatch([]storage.ReportBatchItem{
+		reportBatchItem(clusters[0], testdata.LastCheckedAt),
+		reportBatchItem(clusters[1], testdata.LastCheckedAt),
+	})
+	assert.Equal(t, []error{nil, nil}, errs)
+
+	for _, cluster := range clusters {
+		rules, _, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, cluster)
+		helpers.FailOnError(t, err)
+		assert.Len(t, rules, 2)
+
+		metadata, err := mockStorage.ReadReportInfoForCluster(testdata.OrgID, cluster)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.Version("4.9"), metadata.Version)
+		assert.Equal(t, map[string]string{"version": "4.9"}, metadata.Info["version_info|CLUSTER_VERSION_INFO"])
+	}
+
+	recommendations, err := mockStorage.ReadRecommendationsForClusters(
+		[]string{string(clusters[0]), string(clusters[1])}, testdata.OrgID,
+	)
+	helpers.FailOnError(t, err)
+	assert.ElementsMatch(t, clusters, recommendations[testdata.Rule1CompositeID])
+	assert.ElementsMatch(t, clusters, recommendations[testdata.Rule2CompositeID])
+}
+
+// TestDBStorageWriteReportsBatchKeepVersion checks that the stored version
+// of the cluster is kept when the info report doesn't contain any
+func TestDBStorageWriteReportsBatchKeepVersion(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	err := mockStorage.WriteReportInfoForCluster(testdata.OrgID, testdata.ClusterName, []types.InfoItem{{
+		InfoID:  "version_info|CLUSTER_VERSION_INFO",
+		Details: map[string]string{"version": "4.9"},
+	}}, testdata.LastCheckedAt)
+	helpers.FailOnError(t, err)
+
+	item := reportBatchItem(testdata.ClusterName, testdata.LastCheckedAt)
+	item.Info = []types.InfoItem{{
+		InfoID:  "platform_info|CLUSTER_PLATFORM_INFO",
+		Details: map[string]string{"platform": "AWS"},
+	}}
+	errs := mockStorage.WriteReportsBatch([]storage.ReportBatchItem{item})
+	assert.Equal(t, []error{nil}, errs)
+
+	metadata, err := mockStorage.ReadReportInfoForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.Version("4.9"), metadata.Version)
+	assert.Equal(t, map[types.RuleID]map[string]string{
+		"platform_info|CLUSTER_PLATFORM_INFO": {"platform": "AWS"},
+	}, metadata.Info)
+}
+
+// TestDBStorageWriteReportsBatchSplitStatements checks that the batch is
+// written in one transaction even when its statements need to be split
+// because of the limit of bind parameters
+func TestDBStorageWriteReportsBatchSplitStatements(t *testing.T) {
+	buf := new(bytes.Buffer)
+	log.Logger = zerolog.New(buf)
+
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	// one row of report or recommendation, two rows of rule hits or report
+	// info in one statement
+	storage.SetMaxStatementParameters(mockStorage.(*storage.DBStorage), 13)
+
+	clusters := []types.ClusterName{testdata.ClusterName, testdata.GetRandomClusterID(), testdata.GetRandomClusterID()}
+
+	items := make([]storage.ReportBatchItem, len(clusters))
+	for i, cluster := range clusters {
+		items[i] = reportBatchItem(cluster, testdata.LastCheckedAt)
+	}
+
+	errs := mockStorage.WriteReportsBatch(items)
+	assert.Equal(t, []error{nil, nil, nil}, errs)
+	assert.NotContains(t, buf.String(), "writing them one by one")
+
+	for _, cluster := range clusters {
+		rules, _, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, cluster)
+		helpers.FailOnError(t, err)
+		assert.Len(t, rules, 2)
+
+		metadata, err := mockStorage.ReadReportInfoForCluster(testdata.OrgID, cluster)
+		helpers.FailOnError(t, err)
+		assert.Equal(t, types.Version("4.9"), metadata.Version)
+	}
+
+	recommendations, err := mockStorage.ReadRecommendationsForClusters(
+		[]string{string(clusters[0]), string(clusters[1]), string(clusters[2])}, testdata.OrgID,
+	)
+	helpers.FailOnError(t, err)
+	assert.ElementsMatch(t, clusters, recommendations[testdata.Rule1CompositeID])
+	assert.ElementsMatch(t, clusters, recommendations[testdata.Rule2CompositeID])
+
+}
+
+func TestDBStorageWriteReportsBatchOldReports(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	newerCluster := testdata.GetRandomClusterID()
+
+	errs := mockStorage.WriteReportsBatch([]storage.ReportBatchItem{
+		reportBatchItem(testdata.ClusterName, testdata.LastCheckedAt),
+	})
+	assert.Equal(t, []error{nil}, errs)
+
+	errs = mockStorage.WriteReportsBatch([]storage.ReportBatchItem{
+		// the same report is already stored
+		reportBatchItem(testdata.ClusterName, testdata.LastCheckedAt),
+		// two reports for the same cluster, only the newer one is written
+		reportBatchItem(newerCluster, testdata.LastCheckedAt.Add(time.Hour)),
+		reportBatchItem(newerCluster, testdata.LastCheckedAt),
+	})
+	assert.Equal(t, []error{types.ErrOldReport, nil, types.ErrOldReport}, errs)
+
+	_, lastChecked, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, newerCluster)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)), lastChecked)
+}
+
+// TestDBStorageWriteReportsBatchMoreRecentInDB checks that report is
+// reported as old when the database contains more recent one that is not
+// known to the cache of last checked timestamps
+func TestDBStorageWriteReportsBatchMoreRecentInDB(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	dbStorage := mockStorage.(*storage.DBStorage)
+	otherStorage := storage.NewFromConnection(dbStorage.GetConnection(), dbStorage.GetDBDriverType())
+
+	errs := otherStorage.WriteReportsBatch([]storage.ReportBatchItem{
+		reportBatchItem(testdata.ClusterName, testdata.LastCheckedAt.Add(time.Hour)),
+	})
+	assert.Equal(t, []error{nil}, errs)
+
+	errs = mockStorage.WriteReportsBatch([]storage.ReportBatchItem{
+		reportBatchItem(testdata.ClusterName, testdata.LastCheckedAt),
+	})
+	assert.Equal(t, []error{types.ErrOldReport}, errs)
+
+	_, lastChecked, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, types.Timestamp(testdata.LastCheckedAt.Add(time.Hour).UTC().Format(time.RFC3339)), lastChecked)
+}
+
+func TestDBStorageWriteReportsBatchFallback(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	badCluster := testdata.GetRandomClusterID()
+	badItem := reportBatchItem(badCluster, testdata.LastCheckedAt)
+	badItem.Report = "this is not a report"
+
+	errs := mockStorage.WriteReportsBatch([]storage.ReportBatchItem{
+		reportBatchItem(testdata.ClusterName, testdata.LastCheckedAt),
+		badItem,
+	})
+	assert.Len(t, errs, 2)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+
+	// the correct report has been written one by one
+	rules, _, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, testdata.ClusterName)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 2)
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+// Names of tables with retention policy
+const (
+	ConsumerErrorTable  = "consumer_error"
+	RuleHitTable        = "rule_hit"
+	RecommendationTable = "recommendation"
+	ReportInfoTable     = "report_info"
+	ReportTable         = "report"
+	ReportHistoryTable  = "report_history"
+)
+
+// Names of tables with rule disables that expire
+const (
+	ClusterRuleToggleTable = "cluster_rule_toggle"
+	RuleDisableTable       = "rule_disable"
+)
+
+// RetentionResult contains number of expired records in one table
+type RetentionResult struct {
+	Table string
+	Rows  int64
+}
+
+// retentionStep describes how expired records are selected in one table.
+// The condition is parametrized by the oldest time that is kept.
+type retentionStep struct {
+	table     string
+	condition string
+	period    func(RetentionConfiguration) time.Duration
+}
+
+// retentionSteps contains all tables with retention policy in the order in
+// which they are cleaned up. Records of rule_hit and report_info tables are
+// selected by the reports they belong to, so they are deleted first.
+var retentionSteps = []retentionStep{
+	{
+		table:     ConsumerErrorTable,
+		condition: "consumed_at < $1",
+		period:    func(retention RetentionConfiguration) time.Duration { return retention.ConsumerError },
+	},
+	{
+		// created_at of rule hit is the time when the rule started to hit
+		// the cluster, so rule hits are selected by the time when the
+		// report of the cluster was stored
+		table:     RuleHitTable,
+		condition: "cluster_id IN (SELECT cluster FROM report WHERE reported_at < $1)",
+		period:    func(retention RetentionConfiguration) time.Duration { return retention.RuleHit },
+	},
+	{
+		table:     RecommendationTable,
+		condition: "created_at < $1",
+		period:    func(retention RetentionConfiguration) time.Duration { return retention.Recommendation },
+	},
+	{
+		table:     ReportInfoTable,
+		condition: "cluster_id IN (SELECT cluster FROM report WHERE reported_at < $1)",
+		period:    func(retention RetentionConfiguration) time.Duration { return retention.Report },
+	},
+	{
+		table:     ReportTable,
+		condition: "reported_at < $1",
+		period:    func(retention RetentionConfiguration) time.Duration { return retention.Report },
+	},
+	{
+		table:     ReportHistoryTable,
+		condition: "last_checked_at < $1",
+		period:    func(retention RetentionConfiguration) time.Duration { return retention.ReportHistory },
+	},
+}
+
+// ruleDisableExpirationSteps contains tables with rule disables that are
+// deleted when their expiration time has passed. The condition is
+// parametrized by the current time and the period is not used.
+var ruleDisableExpirationSteps = []retentionStep{
+	{
+		table:     ClusterRuleToggleTable,
+		condition: "expires_at <= $1",
+	},
+	{
+		table:     RuleDisableTable,
+		condition: "expires_at <= $1",
+	},
+}
+
+// DeleteExpiredRecords deletes records older than retention periods of
+// their tables. Tables with zero retention period are skipped. When dryRun
+// is set, expired records are just counted and nothing is deleted.
+func (storage DBStorage) DeleteExpiredRecords(retention RetentionConfiguration, dryRun bool) ([]RetentionResult, error) {
+	now := time.Now().UTC()
+	results := make([]RetentionResult, 0, len(retentionSteps))
+
+	for _, step := range retentionSteps {
+		period := step.period(retention)
+		if period <= 0 {
+			continue
+		}
+		oldest := now.Add(-period)
+
+		var rows int64
+		var err error
+		if dryRun {
+			rows, err = storage.countExpiredRecords(step, oldest)
+		} else {
+			rows, err = storage.deleteExpiredRecords(step, oldest)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("table", step.table).Msg("Unable to clean up expired records")
+			return results, err
+		}
+
+		results = append(results, RetentionResult{Table: step.table, Rows: rows})
+	}
+
+	return results, nil
+}
+
+// DeleteExpiredRuleDisables deletes rules disabled for clusters and rules
+// disabled system-wide whose expiration time has passed. Expired disables are
+// ignored by all queries, so it just keeps the tables small. When dryRun is
+// set, expired disables are just counted and nothing is deleted.
+func (storage DBStorage) DeleteExpiredRuleDisables(dryRun bool) ([]RetentionResult, error) {
+	now := time.Now().UTC()
+	results := make([]RetentionResult, 0, len(ruleDisableExpirationSteps))
+
+	for _, step := range ruleDisableExpirationSteps {
+		var rows int64
+		var err error
+		if dryRun {
+			rows, err = storage.countExpiredRecords(step, now)
+		} else {
+			rows, err = storage.deleteExpiredRecords(step, now)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("table", step.table).Msg("Unable to clean up expired rule disables")
+			return results, err
+		}
+
+		results = append(results, RetentionResult{Table: step.table, Rows: rows})
+	}
+
+	return results, nil
+}
+
+// countExpiredRecords returns number of records selected by retention step
+func (storage DBStorage) countExpiredRecords(step retentionStep, oldest time.Time) (int64, error) {
+	var count int64
+
+	// #nosec G202
+	err := storage.connection.QueryRow(
+		"SELECT COUNT(*) FROM "+step.table+" WHERE "+step.condition, oldest,
+	).Scan(&count)
+
+	return count, err
+}
+
+// deleteExpiredRecords deletes records selected by retention step
+func (storage DBStorage) deleteExpiredRecords(step retentionStep, oldest time.Time) (int64, error) {
+	var clusters []types.ClusterName
+
+	// clusters whose reports are deleted must be removed from the cache
+	// of last checked timestamps too
+	if step.table == ReportTable {
+		var err error
+		clusters, err = storage.readClustersReportedBefore(oldest)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// #nosec G202
+	result, err := storage.connection.Exec("DELETE FROM "+step.table+" WHERE "+step.condition, oldest)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, clusterName := range clusters {
+		storage.clustersLastChecked.remove(clusterName)
+	}
+
+	return result.RowsAffected()
+}
+
+// readClustersReportedBefore returns clusters whose report was stored
+// before given time
+func (storage DBStorage) readClustersReportedBefore(oldest time.Time) ([]types.ClusterName, error) {
+	rows, err := storage.connection.Query("SELECT cluster FROM report WHERE reported_at < $1", oldest)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRows(rows)
+
+	var clusters []types.ClusterName
+	for rows.Next() {
+		var clusterName types.ClusterName
+		if err := rows.Scan(&clusterName); err != nil {
+			return nil, err
+		}
+		clusters = append(clusters, clusterName)
+	}
+
+	return clusters, rows.Err()
+}
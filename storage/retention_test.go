@@ -0,0 +1,239 @@
+// Copyright 2022 Red Hat, Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/insights-operator-utils/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/RedHatInsights/insights-results-aggregator/storage"
+	ira_helpers "github.com/RedHatInsights/insights-results-aggregator/tests/helpers"
+	"github.com/RedHatInsights/insights-results-aggregator/types"
+)
+
+func checkDeleteExpiredRecords(t *testing.T, mockStorage storage.Storage) {
+	now := time.Now().UTC().Truncate(time.Second)
+	oldCluster := testdata.ClusterName
+	newCluster := testdata.GetRandomClusterID()
+
+	for clusterName, lastChecked := range map[types.ClusterName]time.Time{
+		oldCluster: now.Add(-48 * time.Hour),
+		newCluster: now.Add(-time.Hour),
+	} {
+		writeReportToMemoryStorage(
+			t, mockStorage, clusterName, testdata.Report2Rules, testdata.Report2RulesParsed, lastChecked,
+		)
+
+		err := mockStorage.WriteReportInfoForCluster(testdata.OrgID, clusterName, []types.InfoItem{
+			{
+				InfoID: "version_info|CLUSTER_VERSION_INFO",
+				Details: map[string]string{
+					"version": string(testdata.ClusterVersion),
+				},
+			},
+		}, lastChecked)
+		helpers.FailOnError(t, err)
+	}
+	writeConsumerErrors(t, mockStorage)
+
+	retention := storage.RetentionConfiguration{
+		ConsumerError:  time.Hour,
+		Report:         24 * time.Hour,
+		RuleHit:        24 * time.Hour,
+		Recommendation: 24 * time.Hour,
+		ReportHistory:  24 * time.Hour,
+	}
+	expected := []storage.RetentionResult{
+		{Table: storage.ConsumerErrorTable, Rows: 0},
+		{Table: storage.RuleHitTable, Rows: 2},
+		{Table: storage.RecommendationTable, Rows: 2},
+		{Table: storage.ReportInfoTable, Rows: 1},
+		{Table: storage.ReportTable, Rows: 1},
+		{Table: storage.ReportHistoryTable, Rows: 1},
+	}
+
+	// nothing is deleted in dry run
+	results, err := mockStorage.DeleteExpiredRecords(retention, true)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, expected, results)
+
+	_, _, _, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, oldCluster)
+	helpers.FailOnError(t, err)
+
+	results, err = mockStorage.DeleteExpiredRecords(retention, false)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, expected, results)
+
+	_, _, _, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, oldCluster)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	_, err = mockStorage.ReadReportHistoryForCluster(testdata.OrgID, oldCluster)
+	assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+	rules, _, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, newCluster)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 2)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 3)
+
+	// all expired records have been deleted already
+	results, err = mockStorage.DeleteExpiredRecords(retention, false)
+	helpers.FailOnError(t, err)
+	for _, result := range results {
+		assert.Equal(t, int64(0), result.Rows, result.Table)
+	}
+
+	// the deleted report is not remembered by the same storage instance, so
+	// an older report of the cluster can be stored again
+	olderLastChecked := now.Add(-72 * time.Hour)
+	err = mockStorage.WriteReportForCluster(
+		testdata.OrgID, oldCluster, testdata.Report2Rules, testdata.Report2RulesParsed,
+		olderLastChecked, olderLastChecked, olderLastChecked, testdata.KafkaOffset,
+	)
+	helpers.FailOnError(t, err)
+}
+
+func TestDBStorageDeleteExpiredRecords(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	storage.SetReportHistoryLength(mockStorage.(*storage.DBStorage), 2)
+
+	checkDeleteExpiredRecords(t, mockStorage)
+}
+
+func TestMemoryStorageDeleteExpiredRecords(t *testing.T) {
+	checkDeleteExpiredRecords(t, newMemoryStorage())
+}
+
+// checkDeleteExpiredRuleHits checks that rule hits are deleted before the
+// report of their cluster when their retention period is shorter
+func checkDeleteExpiredRuleHits(t *testing.T, mockStorage storage.Storage) {
+	now := time.Now().UTC().Truncate(time.Second)
+	oldCluster := testdata.ClusterName
+	newCluster := testdata.GetRandomClusterID()
+
+	for clusterName, lastChecked := range map[types.ClusterName]time.Time{
+		oldCluster: now.Add(-48 * time.Hour),
+		newCluster: now.Add(-time.Hour),
+	} {
+		writeReportToMemoryStorage(
+			t, mockStorage, clusterName, testdata.Report2Rules, testdata.Report2RulesParsed, lastChecked,
+		)
+	}
+
+	retention := storage.RetentionConfiguration{
+		Report:  72 * time.Hour,
+		RuleHit: 24 * time.Hour,
+	}
+	results, err := mockStorage.DeleteExpiredRecords(retention, false)
+	helpers.FailOnError(t, err)
+	assert.Equal(t, []storage.RetentionResult{
+		{Table: storage.RuleHitTable, Rows: 2},
+		{Table: storage.ReportInfoTable, Rows: 0},
+		{Table: storage.ReportTable, Rows: 0},
+	}, results)
+
+	// the report is kept without rule hits
+	rules, _, _, _, err := mockStorage.ReadReportForCluster(testdata.OrgID, oldCluster)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, rules)
+
+	rules, _, _, _, err = mockStorage.ReadReportForCluster(testdata.OrgID, newCluster)
+	helpers.FailOnError(t, err)
+	assert.Len(t, rules, 2)
+}
+
+func TestDBStorageDeleteExpiredRuleHits(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	defer closer()
+
+	checkDeleteExpiredRuleHits(t, mockStorage)
+}
+
+func TestMemoryStorageDeleteExpiredRuleHits(t *testing.T) {
+	checkDeleteExpiredRuleHits(t, newMemoryStorage())
+}
+
+func TestDeleteExpiredRecordsNoRetention(t *testing.T) {
+	mockStorage := newMemoryStorage()
+	writeConsumerErrors(t, mockStorage)
+
+	results, err := mockStorage.DeleteExpiredRecords(storage.RetentionConfiguration{}, false)
+	helpers.FailOnError(t, err)
+	assert.Empty(t, results)
+
+	consumerErrors, err := mockStorage.ReadConsumerErrors(storage.ConsumerErrorsFilter{})
+	helpers.FailOnError(t, err)
+	assert.Len(t, consumerErrors, 3)
+}
+
+func TestDeleteExpiredRuleDisables(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			expired, active := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+
+			helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(
+				testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, expired,
+			))
+			helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(
+				testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey2, testdata.OrgID, active,
+			))
+			helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+				testdata.ClusterName, testdata.Rule3ID, testdata.ErrorKey3, testdata.OrgID, storage.RuleToggleDisable,
+			))
+			helpers.FailOnError(t, mockStorage.DisableRuleSystemWideUntil(
+				testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, "x", expired,
+			))
+
+			expectedResults := []storage.RetentionResult{
+				{Table: storage.ClusterRuleToggleTable, Rows: 1},
+				{Table: storage.RuleDisableTable, Rows: 1},
+			}
+
+			// nothing is deleted in dry run
+			results, err := mockStorage.DeleteExpiredRuleDisables(true)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, expectedResults, results)
+
+			results, err = mockStorage.DeleteExpiredRuleDisables(false)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, expectedResults, results)
+
+			results, err = mockStorage.DeleteExpiredRuleDisables(false)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, []storage.RetentionResult{
+				{Table: storage.ClusterRuleToggleTable},
+				{Table: storage.RuleDisableTable},
+			}, results)
+
+			_, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+			assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+			disabledRules, err := mockStorage.ListOfDisabledRules(testdata.OrgID)
+			helpers.FailOnError(t, err)
+			assert.Len(t, disabledRules, 2)
+		})
+	}
+}
@@ -15,6 +15,7 @@
 package storage
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -32,30 +33,49 @@ func (storage DBStorage) DisableRuleSystemWide(
 	errorKey types.ErrorKey,
 	justification string,
 ) error {
+	return storage.DisableRuleSystemWideUntil(orgID, ruleID, errorKey, justification, time.Time{})
+}
+
+// DisableRuleSystemWideUntil disables the selected rule for all clusters
+// visible to given user until expiresAt. Zero expiresAt means the rule is
+// disabled until it's enabled again. Audit events are written in the same
+// transaction.
+func (storage DBStorage) DisableRuleSystemWideUntil(
+	orgID types.OrgID,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	justification string,
+	expiresAt time.Time,
+	auditEvents ...AuditEvent,
+) error {
 
 	now := time.Now()
 
 	const query = `
 	INSERT INTO rule_disable(
-		org_id, rule_id, error_key, justification, created_at
+		org_id, rule_id, error_key, justification, created_at, expires_at
 	)
 	VALUES
-		($1, $2, $3, $4, $5)
+		($1, $2, $3, $4, $5, $6)
 	ON CONFLICT
 		(org_id, rule_id, error_key)
 	DO UPDATE SET
-		justification = $4, created_at = $5
+		justification = $4, created_at = $5, expires_at = $6
 `
 
 	// try to execute the query and check for (any) error
-	_, err := storage.connection.Exec(
-		query,
-		orgID,
-		ruleID,
-		errorKey,
-		justification,
-		now,
-	)
+	err := storage.changeWithAuditEvents(auditEvents, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			query,
+			orgID,
+			ruleID,
+			errorKey,
+			justification,
+			now,
+			ruleDisableExpiresAt(expiresAt),
+		)
+		return err
+	})
 
 	if err != nil {
 		const msg = "Error during execution SQL exec for system wide rule disable"
@@ -67,11 +87,12 @@ func (storage DBStorage) DisableRuleSystemWide(
 }
 
 // EnableRuleSystemWide enables the selected rule for all clusters visible to
-// given user
+// given user. Audit events are written in the same transaction.
 func (storage DBStorage) EnableRuleSystemWide(
 	orgID types.OrgID,
 	ruleID types.RuleID,
 	errorKey types.ErrorKey,
+	auditEvents ...AuditEvent,
 ) error {
 	log.Info().Int("org_id", int(orgID)).Msgf("re-enabling rule %v|%v", ruleID, errorKey)
 
@@ -82,12 +103,15 @@ func (storage DBStorage) EnableRuleSystemWide(
 	              `
 
 	// try to execute the query and check for (any) error
-	_, err := storage.connection.Exec(
-		query,
-		orgID,
-		ruleID,
-		errorKey,
-	)
+	err := storage.changeWithAuditEvents(auditEvents, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			query,
+			orgID,
+			ruleID,
+			errorKey,
+		)
+		return err
+	})
 
 	if err != nil {
 		const msg = "Error during execution SQL exec for system wide rule enable"
@@ -98,12 +122,14 @@ func (storage DBStorage) EnableRuleSystemWide(
 	return nil
 }
 
-// UpdateDisabledRuleJustification change justification for already disabled rule
+// UpdateDisabledRuleJustification change justification for already disabled
+// rule. Audit events are written in the same transaction.
 func (storage DBStorage) UpdateDisabledRuleJustification(
 	orgID types.OrgID,
 	ruleID types.RuleID,
 	errorKey types.ErrorKey,
 	justification string,
+	auditEvents ...AuditEvent,
 ) error {
 
 	now := time.Now()
@@ -116,14 +142,17 @@ func (storage DBStorage) UpdateDisabledRuleJustification(
 	              `
 
 	// try to execute the query and check for (any) error
-	_, err := storage.connection.Exec(
-		query,
-		orgID,
-		ruleID,
-		errorKey,
-		justification,
-		now,
-	)
+	err := storage.changeWithAuditEvents(auditEvents, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			query,
+			orgID,
+			ruleID,
+			errorKey,
+			justification,
+			now,
+		)
+		return err
+	})
 
 	if err != nil {
 		const msg = "Error during execution SQL exec for system wide rule justification change"
@@ -134,7 +163,8 @@ func (storage DBStorage) UpdateDisabledRuleJustification(
 	return nil
 }
 
-// ReadDisabledRule function returns disabled rule (if disabled) from database
+// ReadDisabledRule function returns disabled rule (if disabled) from
+// database. Expired disables are treated as enabled rules.
 func (storage DBStorage) ReadDisabledRule(
 	orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
 ) (ctypes.SystemWideRuleDisable, bool, error) {
@@ -151,10 +181,11 @@ func (storage DBStorage) ReadDisabledRule(
 		WHERE org_id = $1
 		  AND rule_id = $2
 		  AND error_key = $3
+		  AND (expires_at IS NULL OR expires_at > $4)
 	`
 
 	// run the query against database
-	rows, err := storage.connection.Query(query, orgID, ruleID, errorKey)
+	rows, err := storage.connection.Query(query, orgID, ruleID, errorKey, time.Now().UTC())
 
 	// return zero value in case of any error
 	if err != nil {
@@ -186,7 +217,7 @@ func (storage DBStorage) ReadDisabledRule(
 }
 
 // ListOfSystemWideDisabledRules function returns list of all rules that have been
-// disabled for all clusters by given user
+// disabled for all clusters by given user, expired disables are skipped
 func (storage DBStorage) ListOfSystemWideDisabledRules(
 	orgID types.OrgID,
 ) ([]ctypes.SystemWideRuleDisable, error) {
@@ -200,10 +231,11 @@ func (storage DBStorage) ListOfSystemWideDisabledRules(
 			 updated_at
 		 FROM rule_disable
 		WHERE org_id = $1
+		  AND (expires_at IS NULL OR expires_at > $2)
 	`
 
 	// run the query against database
-	rows, err := storage.connection.Query(query, orgID)
+	rows, err := storage.connection.Query(query, orgID, time.Now().UTC())
 	// return empty list in case of any error
 	if err != nil {
 		return disabledRules, err
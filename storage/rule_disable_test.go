@@ -18,6 +18,7 @@ package storage_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 	"github.com/stretchr/testify/assert"
@@ -328,3 +329,44 @@ func TestDBStorageListOfSystemWideDisabledRulesDBError(t *testing.T) {
 	// we expect the error to happen
 	assert.EqualError(t, err, "sql: database is closed")
 }
+
+// Check that expired system-wide disables are treated as enabled rules.
+func TestDisableRuleSystemWideUntil(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			// the first rule disable has expired already
+			helpers.FailOnError(t, mockStorage.DisableRuleSystemWideUntil(
+				testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, "x", time.Now().Add(-time.Minute),
+			))
+			helpers.FailOnError(t, mockStorage.DisableRuleSystemWideUntil(
+				testdata.OrgID, testdata.Rule2ID, testdata.ErrorKey2, "y", time.Now().Add(time.Hour),
+			))
+
+			_, found, err := mockStorage.ReadDisabledRule(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+			helpers.FailOnError(t, err)
+			assert.False(t, found, "Expired rule disable should not be found")
+
+			disabledRule, found, err := mockStorage.ReadDisabledRule(testdata.OrgID, testdata.Rule2ID, testdata.ErrorKey2)
+			helpers.FailOnError(t, err)
+			assert.True(t, found, "Rule should be found")
+			assert.Equal(t, "y", disabledRule.Justification)
+
+			disabledRules, err := mockStorage.ListOfSystemWideDisabledRules(testdata.OrgID)
+			helpers.FailOnError(t, err)
+			assert.Len(t, disabledRules, 1)
+			assert.Equal(t, testdata.Rule2ID, disabledRules[0].RuleID)
+
+			// the rule can be disabled again after the disable has expired
+			helpers.FailOnError(t, mockStorage.DisableRuleSystemWide(
+				testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1, "z",
+			))
+
+			_, found, err = mockStorage.ReadDisabledRule(testdata.OrgID, testdata.Rule1ID, testdata.ErrorKey1)
+			helpers.FailOnError(t, err)
+			assert.True(t, found, "Rule should be found")
+		})
+	}
+}
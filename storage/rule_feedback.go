@@ -38,7 +38,8 @@ type UserFeedbackOnRule struct {
 	UpdatedAt time.Time
 }
 
-// VoteOnRule likes or dislikes rule for cluster by user. If entry exists, it overwrites it
+// VoteOnRule likes or dislikes rule for cluster by user. If entry exists, it
+// overwrites it. Audit events are written in the same transaction.
 func (storage DBStorage) VoteOnRule(
 	clusterID types.ClusterName,
 	ruleID types.RuleID,
@@ -47,8 +48,11 @@ func (storage DBStorage) VoteOnRule(
 	userID types.UserID,
 	userVote types.UserVote,
 	voteMessage string,
+	auditEvents ...AuditEvent,
 ) error {
-	return storage.addOrUpdateUserFeedbackOnRuleForCluster(clusterID, ruleID, errorKey, orgID, userID, &userVote, &voteMessage)
+	return storage.addOrUpdateUserFeedbackOnRuleForCluster(
+		clusterID, ruleID, errorKey, orgID, userID, &userVote, &voteMessage, auditEvents,
+	)
 }
 
 // AddOrUpdateFeedbackOnRule adds feedback on rule for cluster by user. If entry exists, it overwrites it
@@ -60,7 +64,7 @@ func (storage DBStorage) AddOrUpdateFeedbackOnRule(
 	userID types.UserID,
 	message string,
 ) error {
-	return storage.addOrUpdateUserFeedbackOnRuleForCluster(clusterID, ruleID, errorKey, orgID, userID, nil, &message)
+	return storage.addOrUpdateUserFeedbackOnRuleForCluster(clusterID, ruleID, errorKey, orgID, userID, nil, &message, nil)
 }
 
 // addOrUpdateUserFeedbackOnRuleForCluster adds or updates feedback
@@ -73,6 +77,7 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 	userID types.UserID,
 	userVotePtr *types.UserVote,
 	messagePtr *string,
+	auditEvents []AuditEvent,
 ) error {
 	updateVote := false
 	updateMessage := false
@@ -95,21 +100,12 @@ func (storage DBStorage) addOrUpdateUserFeedbackOnRuleForCluster(
 		return err
 	}
 
-	statement, err := storage.connection.Prepare(query)
-	if err != nil {
-		log.Error().Err(err).Msg("Unable to prepare statement")
-		return err
-	}
-	defer func() {
-		err := statement.Close()
-		if err != nil {
-			log.Error().Err(err).Msg(closeStatementError)
-		}
-	}()
-
 	now := time.Now()
 
-	_, err = statement.Exec(clusterID, ruleID, userID, userVote, now, now, message, errorKey, orgID)
+	err = storage.changeWithAuditEvents(auditEvents, func(tx *sql.Tx) error {
+		_, err := tx.Exec(query, clusterID, ruleID, userID, userVote, now, now, message, errorKey, orgID)
+		return err
+	})
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleForCluster")
@@ -286,7 +282,20 @@ func (storage DBStorage) GetUserDisableFeedbackOnRules(
 	return feedbacks, nil
 }
 
-// AddFeedbackOnRuleDisable adds feedback on rule disable
+// addFeedbackOnRuleDisableQuery inserts or updates user's feedback on
+// disabling the rule for the cluster
+const addFeedbackOnRuleDisableQuery = `
+	INSERT INTO cluster_user_rule_disable_feedback
+		(cluster_id, org_id, user_id, rule_id, error_key, message, added_at, updated_at)
+	VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT
+		(cluster_id, user_id, rule_id, error_key)
+	DO UPDATE SET updated_at = $8, message = $6;
+`
+
+// AddFeedbackOnRuleDisable adds feedback on rule disable. Audit events are
+// written in the same transaction.
 func (storage DBStorage) AddFeedbackOnRuleDisable(
 	clusterID types.ClusterName,
 	ruleID types.RuleID,
@@ -294,29 +303,14 @@ func (storage DBStorage) AddFeedbackOnRuleDisable(
 	orgID types.OrgID,
 	userID types.UserID,
 	message string,
+	auditEvents ...AuditEvent,
 ) error {
-	statement, err := storage.connection.Prepare(`
-		INSERT INTO cluster_user_rule_disable_feedback
-			(cluster_id, org_id, user_id, rule_id, error_key, message, added_at, updated_at)
-		VALUES
-			($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT
-			(cluster_id, user_id, rule_id, error_key)
-		DO UPDATE SET updated_at = $8, message = $6;
-	`)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		err := statement.Close()
-		if err != nil {
-			log.Error().Err(err).Msg(closeStatementError)
-		}
-	}()
-
 	now := time.Now()
 
-	_, err = statement.Exec(clusterID, orgID, userID, ruleID, errorKey, message, now, now)
+	err := storage.changeWithAuditEvents(auditEvents, func(tx *sql.Tx) error {
+		_, err := tx.Exec(addFeedbackOnRuleDisableQuery, clusterID, orgID, userID, ruleID, errorKey, message, now, now)
+		return err
+	})
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("addOrUpdateUserFeedbackOnRuleDisableForCluster")
@@ -17,6 +17,7 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -87,7 +88,7 @@ func (storage DBStorage) ListOfReasons(userID types.UserID) ([]DisabledRuleReaso
 }
 
 // ListOfDisabledRules function returns list of all rules disabled from a
-// specified account.
+// specified account. Expired disables are skipped.
 func (storage DBStorage) ListOfDisabledRules(orgID types.OrgID) ([]ctypes.DisabledRule, error) {
 	disabledRules := make([]ctypes.DisabledRule, 0)
 	query := `SELECT
@@ -101,11 +102,12 @@ func (storage DBStorage) ListOfDisabledRules(orgID types.OrgID) ([]ctypes.Disabl
 		cluster_rule_toggle
 	WHERE
 		org_id = $1 and
-		disabled = $2
+		disabled = $2 and
+		(expires_at IS NULL OR expires_at > $3)
 	`
 
 	// run the query against database
-	rows, err := storage.connection.Query(query, orgID, RuleToggleDisable)
+	rows, err := storage.connection.Query(query, orgID, RuleToggleDisable, time.Now().UTC())
 
 	// return empty list in case of any error
 	if err != nil {
@@ -140,7 +142,7 @@ func (storage DBStorage) ListOfDisabledRules(orgID types.OrgID) ([]ctypes.Disabl
 }
 
 // ListOfDisabledRulesForClusters function returns list of all rules disabled from a
-// specified account for given list of clusters.
+// specified account for given list of clusters. Expired disables are skipped.
 func (storage DBStorage) ListOfDisabledRulesForClusters(
 	clusterList []string,
 	orgID types.OrgID,
@@ -152,7 +154,7 @@ func (storage DBStorage) ListOfDisabledRulesForClusters(
 	}
 
 	// #nosec G201
-	whereClause := fmt.Sprintf(`WHERE org_id = $1 AND disabled = $2 AND cluster_id IN (%v)`, inClauseFromSlice(clusterList))
+	whereClause := fmt.Sprintf(`WHERE org_id = $1 AND disabled = $2 AND (expires_at IS NULL OR expires_at > $3) AND cluster_id IN (%v)`, inClauseFromSlice(clusterList))
 
 	// disable "G202 (CWE-89): SQL string concatenation"
 	// #nosec G202
@@ -169,7 +171,7 @@ func (storage DBStorage) ListOfDisabledRulesForClusters(
 	` + whereClause
 
 	// run the query against database
-	rows, err := storage.connection.Query(query, orgID, RuleToggleDisable)
+	rows, err := storage.connection.Query(query, orgID, RuleToggleDisable, time.Now().UTC())
 
 	// return empty list in case of any error
 	if err != nil {
@@ -21,6 +21,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/RedHatInsights/insights-results-aggregator/metrics"
 	"github.com/RedHatInsights/insights-results-aggregator/types"
 )
 
@@ -42,56 +43,131 @@ type ClusterRuleToggle struct {
 	DisabledAt sql.NullTime
 	EnabledAt  sql.NullTime
 	UpdatedAt  sql.NullTime
+	// ExpiresAt is set for rules disabled only for limited time
+	ExpiresAt sql.NullTime
 }
 
-// ToggleRuleForCluster toggles rule for specified cluster
+// IsDisabled returns true when the rule is disabled and the disable has not
+// expired yet
+func (toggle *ClusterRuleToggle) IsDisabled(now time.Time) bool {
+	return toggle.Disabled == RuleToggleDisable && !ruleDisableExpired(toggle.ExpiresAt, now)
+}
+
+// ruleDisableExpiresAt converts expiration time of rule disable into value
+// stored in expires_at column. Zero time means that the disable never
+// expires.
+func ruleDisableExpiresAt(expiresAt time.Time) sql.NullTime {
+	if expiresAt.IsZero() {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: expiresAt.UTC(), Valid: true}
+}
+
+// ruleDisableExpired checks if the rule disable with given expiration time
+// has already expired
+func ruleDisableExpired(expiresAt sql.NullTime, now time.Time) bool {
+	return expiresAt.Valid && !expiresAt.Time.After(now)
+}
+
+// toggleRuleForClusterQuery inserts or updates the rule toggle for the
+// cluster
+const toggleRuleForClusterQuery = `
+	INSERT INTO cluster_rule_toggle(
+		cluster_id, rule_id, error_key, org_id, disabled, disabled_at, enabled_at, updated_at, expires_at
+	)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (cluster_id, rule_id, error_key) DO UPDATE SET
+		org_id = $4,
+		disabled = $5,
+		disabled_at = $6,
+		enabled_at = $7,
+		updated_at = $8,
+		expires_at = $9
+`
+
+// ruleToggleTimes returns disabled_at and enabled_at timestamps stored
+// with the rule toggle
+func ruleToggleTimes(ruleToggle RuleToggle, now time.Time) (disabledAt, enabledAt sql.NullTime, err error) {
+	updatedAt := sql.NullTime{Time: now, Valid: true}
+
+	switch ruleToggle {
+	case RuleToggleDisable:
+		disabledAt = updatedAt
+	case RuleToggleEnable:
+		enabledAt = updatedAt
+	default:
+		err = fmt.Errorf("Unexpected rule toggle value")
+	}
+
+	return
+}
+
+// ToggleRuleForCluster toggles rule for specified cluster. Audit events are
+// written in the same transaction.
 func (storage DBStorage) ToggleRuleForCluster(
 	clusterID types.ClusterName,
 	ruleID types.RuleID,
 	errorKey types.ErrorKey,
 	orgID types.OrgID,
 	ruleToggle RuleToggle,
+	auditEvents ...AuditEvent,
 ) error {
+	return storage.toggleRuleForCluster(clusterID, ruleID, errorKey, orgID, ruleToggle, time.Time{}, auditEvents)
+}
 
-	var query string
-	var enabledAt, disabledAt, updatedAt sql.NullTime
+// DisableRuleForClusterUntil disables rule for specified cluster until
+// expiresAt. Zero expiresAt means the rule is disabled until it's enabled
+// again. Audit events are written in the same transaction.
+func (storage DBStorage) DisableRuleForClusterUntil(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	expiresAt time.Time,
+	auditEvents ...AuditEvent,
+) error {
+	return storage.toggleRuleForCluster(clusterID, ruleID, errorKey, orgID, RuleToggleDisable, expiresAt, auditEvents)
+}
 
+// toggleRuleForCluster contains shared functionality of ToggleRuleForCluster
+// and DisableRuleForClusterUntil. Expiration time is not stored when the rule
+// is enabled.
+func (storage DBStorage) toggleRuleForCluster(
+	clusterID types.ClusterName,
+	ruleID types.RuleID,
+	errorKey types.ErrorKey,
+	orgID types.OrgID,
+	ruleToggle RuleToggle,
+	expiresAt time.Time,
+	auditEvents []AuditEvent,
+) error {
 	now := time.Now()
-	updatedAt = sql.NullTime{Time: now, Valid: true}
 
-	switch ruleToggle {
-	case RuleToggleDisable:
-		disabledAt = updatedAt
-	case RuleToggleEnable:
-		enabledAt = updatedAt
-	default:
-		return fmt.Errorf("Unexpected rule toggle value")
+	disabledAt, enabledAt, err := ruleToggleTimes(ruleToggle, now)
+	if err != nil {
+		return err
 	}
 
-	query = `
-		INSERT INTO cluster_rule_toggle(
-			cluster_id, rule_id, error_key, org_id, disabled, disabled_at, enabled_at, updated_at
-		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (cluster_id, rule_id, error_key) DO UPDATE SET
-			org_id = $4,
-		    disabled = $5,
-			disabled_at = $6,
-			enabled_at = $7,
-			updated_at = $8
-	`
+	if ruleToggle != RuleToggleDisable {
+		expiresAt = time.Time{}
+	}
 
-	_, err := storage.connection.Exec(
-		query,
-		clusterID,
-		ruleID,
-		errorKey,
-		orgID,
-		ruleToggle,
-		disabledAt,
-		enabledAt,
-		now,
-	)
+	err = storage.changeWithAuditEvents(auditEvents, func(tx *sql.Tx) error {
+		_, err := tx.Exec(
+			toggleRuleForClusterQuery,
+			clusterID,
+			ruleID,
+			errorKey,
+			orgID,
+			ruleToggle,
+			disabledAt,
+			enabledAt,
+			now,
+			ruleDisableExpiresAt(expiresAt),
+		)
+		return err
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("Error during execution SQL exec for cluster rule toggle")
 		return err
@@ -100,6 +176,170 @@ func (storage DBStorage) ToggleRuleForCluster(
 	return nil
 }
 
+// RuleToggleBatchItem represents one rule toggled for one cluster by
+// ToggleRulesForClusters
+type RuleToggleBatchItem struct {
+	ClusterID types.ClusterName
+	RuleID    types.RuleID
+	ErrorKey  types.ErrorKey
+	// Justification is stored as user's feedback on disabling the rule when
+	// it's not empty
+	Justification string
+	// ExpiresAt is the time when the disabled rule is enabled again, zero
+	// value means never. It's ignored when rules are enabled.
+	ExpiresAt time.Time
+}
+
+// ToggleRulesForClusters toggles rules for clusters in a single transaction,
+// either all items are stored or none of them. Audit events are written in
+// the same transaction. Organizations of the clusters are checked in the
+// transaction too, items and audit events of clusters that are not known or
+// belong to another organization are skipped and these clusters are returned.
+func (storage DBStorage) ToggleRulesForClusters(
+	orgID types.OrgID,
+	userID types.UserID,
+	items []RuleToggleBatchItem,
+	ruleToggle RuleToggle,
+	auditEvents ...AuditEvent,
+) (map[types.ClusterName]bool, error) {
+	now := time.Now()
+
+	disabledAt, enabledAt, err := ruleToggleTimes(ruleToggle, now)
+	if err != nil {
+		return nil, err
+	}
+
+	feedbacks := 0
+	var unknownClusters map[types.ClusterName]bool
+
+	err = storage.changeWithAuditEvents(nil, func(tx *sql.Tx) error {
+		clusterOrgs, err := storage.readClusterOrgs(tx, ruleToggleBatchClusters(items))
+		if err != nil {
+			return err
+		}
+
+		var toggledItems []RuleToggleBatchItem
+		var toggledEvents []AuditEvent
+		toggledItems, toggledEvents, unknownClusters = skipClustersOfOtherOrgs(orgID, clusterOrgs, items, auditEvents)
+
+		for _, item := range toggledItems {
+			expiresAt := sql.NullTime{}
+			if ruleToggle == RuleToggleDisable {
+				expiresAt = ruleDisableExpiresAt(item.ExpiresAt)
+			}
+
+			_, err := tx.Exec(
+				toggleRuleForClusterQuery,
+				item.ClusterID, item.RuleID, item.ErrorKey, orgID, ruleToggle, disabledAt, enabledAt, now, expiresAt,
+			)
+			if err != nil {
+				return err
+			}
+
+			if item.Justification == "" {
+				continue
+			}
+
+			_, err = tx.Exec(
+				addFeedbackOnRuleDisableQuery,
+				item.ClusterID, orgID, userID, item.RuleID, item.ErrorKey, item.Justification, now, now,
+			)
+			if err != nil {
+				return err
+			}
+			feedbacks++
+		}
+
+		return insertAuditEvents(tx, toggledEvents)
+	})
+	if err != nil {
+		log.Error().Err(err).Int("items", len(items)).Msg("Unable to toggle rules for clusters")
+		return nil, types.ConvertDBError(err, nil)
+	}
+
+	metrics.FeedbackOnRules.Add(float64(feedbacks))
+
+	return unknownClusters, nil
+}
+
+// readClusterOrgs reads organizations of given clusters in the transaction,
+// clusters without report are not returned
+func (storage DBStorage) readClusterOrgs(
+	tx *sql.Tx, clusterNames []types.ClusterName,
+) (map[types.ClusterName]types.OrgID, error) {
+	clusterOrgs := make(map[types.ClusterName]types.OrgID, len(clusterNames))
+
+	err := storage.queryForClusters(clusterNames, func(inClause string, clusterArgs []interface{}) error {
+		// disable "G202 (CWE-89): SQL string concatenation"
+		// #nosec G202
+		rows, err := tx.Query("SELECT cluster, org_id FROM report WHERE cluster IN ("+inClause+");", clusterArgs...)
+		if err != nil {
+			return err
+		}
+		defer closeRows(rows)
+
+		for rows.Next() {
+			var (
+				clusterName types.ClusterName
+				clusterOrg  types.OrgID
+			)
+			if err := rows.Scan(&clusterName, &clusterOrg); err != nil {
+				return err
+			}
+			clusterOrgs[clusterName] = clusterOrg
+		}
+
+		return rows.Err()
+	})
+
+	return clusterOrgs, err
+}
+
+// ruleToggleBatchClusters returns distinct clusters of the items
+func ruleToggleBatchClusters(items []RuleToggleBatchItem) []types.ClusterName {
+	found := make(map[types.ClusterName]bool, len(items))
+	clusterNames := make([]types.ClusterName, 0, len(items))
+
+	for _, item := range items {
+		if !found[item.ClusterID] {
+			found[item.ClusterID] = true
+			clusterNames = append(clusterNames, item.ClusterID)
+		}
+	}
+
+	return clusterNames
+}
+
+// skipClustersOfOtherOrgs removes items and audit events of clusters that
+// are not known or belong to another organization, these clusters are
+// returned
+func skipClustersOfOtherOrgs(
+	orgID types.OrgID,
+	clusterOrgs map[types.ClusterName]types.OrgID,
+	items []RuleToggleBatchItem,
+	auditEvents []AuditEvent,
+) ([]RuleToggleBatchItem, []AuditEvent, map[types.ClusterName]bool) {
+	unknownClusters := make(map[types.ClusterName]bool)
+
+	keptItems := make([]RuleToggleBatchItem, 0, len(items))
+	for _, item := range items {
+		if clusterOrg, found := clusterOrgs[item.ClusterID]; !found || clusterOrg != orgID {
+			unknownClusters[item.ClusterID] = true
+			continue
+		}
+		keptItems = append(keptItems, item)
+	}
+
+	keptEvents := make([]AuditEvent, 0, len(auditEvents))
+	for _, event := range auditEvents {
+		if !unknownClusters[event.ClusterID] {
+			keptEvents = append(keptEvents, event)
+		}
+	}
+
+	return keptItems, keptEvents, unknownClusters
+}
+
 // GetFromClusterRuleToggle gets a rule from cluster_rule_toggle
 func (storage DBStorage) GetFromClusterRuleToggle(
 	clusterID types.ClusterName, ruleID types.RuleID,
@@ -116,7 +356,8 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 		disabled,
 		disabled_at,
 		enabled_at,
-		updated_at
+		updated_at,
+		expires_at
 	FROM
 		cluster_rule_toggle
 	WHERE
@@ -138,6 +379,7 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 		&disabledRule.DisabledAt,
 		&disabledRule.EnabledAt,
 		&disabledRule.UpdatedAt,
+		&disabledRule.ExpiresAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, &types.ItemNotFoundError{ItemID: ruleID}
@@ -146,7 +388,8 @@ func (storage DBStorage) GetFromClusterRuleToggle(
 	return &disabledRule, err
 }
 
-// GetTogglesForRules gets enable/disable toggle for rules
+// GetTogglesForRules gets enable/disable toggle for rules, expired disables
+// are treated as enabled rules
 func (storage DBStorage) GetTogglesForRules(
 	clusterID types.ClusterName,
 	rulesReport []types.RuleOnReport,
@@ -169,12 +412,13 @@ func (storage DBStorage) GetTogglesForRules(
 		cluster_id = $1 AND
 		org_id = $2 AND
 		disabled = $3 AND
+		(expires_at IS NULL OR expires_at > $4) AND
 		rule_id in (%v)
 	`
 	whereInStatement := inClauseFromSlice(ruleIDs)
 	query = fmt.Sprintf(query, whereInStatement)
 
-	rows, err := storage.connection.Query(query, clusterID, orgID, RuleToggleDisable)
+	rows, err := storage.connection.Query(query, clusterID, orgID, RuleToggleDisable, time.Now().UTC())
 	if err != nil {
 		return toggles, err
 	}
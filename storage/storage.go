@@ -57,13 +57,20 @@ type Storage interface {
 	ListOfClustersForOrgSpecificRule(
 		orgID types.OrgID, ruleID types.RuleSelector, activeClusters []string,
 	) ([]ctypes.HittingClustersData, error)
+	ListOfOrgsPage(options ListingOptions) ([]types.OrgID, string, error)
+	ListOfClustersForOrgPage(
+		orgID types.OrgID, since, until time.Time, options ListingOptions,
+	) ([]types.ClusterName, string, error)
+	ListOfClustersForOrgSpecificRulePage(
+		orgID types.OrgID, ruleID types.RuleSelector, activeClusters []string, options ListingOptions,
+	) ([]ctypes.HittingClustersData, string, error)
 	ReadReportForCluster(
 		orgID types.OrgID, clusterName types.ClusterName) (
 		[]types.RuleOnReport, types.Timestamp, types.Timestamp, types.Timestamp, error,
 	)
 	ReadReportInfoForCluster(
 		types.OrgID, types.ClusterName) (
-		types.Version, error,
+		types.ClusterMetadata, error,
 	)
 	ReadReportsForClusters(
 		clusterNames []types.ClusterName) (map[types.ClusterName]types.ClusterReport, error)
@@ -96,6 +103,7 @@ type Storage interface {
 		report types.ClusterReport,
 		creationTime types.Timestamp,
 	) error
+	WriteReportsBatch(items []ReportBatchItem) []error
 	ReportsCount() (int, error)
 	VoteOnRule(
 		clusterID types.ClusterName,
@@ -105,6 +113,7 @@ type Storage interface {
 		userID types.UserID,
 		userVote types.UserVote,
 		voteMessage string,
+		auditEvents ...AuditEvent,
 	) error
 	AddOrUpdateFeedbackOnRule(
 		clusterID types.ClusterName,
@@ -121,6 +130,7 @@ type Storage interface {
 		orgID types.OrgID,
 		userID types.UserID,
 		message string,
+		auditEvents ...AuditEvent,
 	) error
 	GetUserFeedbackOnRule(
 		clusterID types.ClusterName,
@@ -142,7 +152,23 @@ type Storage interface {
 		errorKey types.ErrorKey,
 		orgID types.OrgID,
 		ruleToggle RuleToggle,
+		auditEvents ...AuditEvent,
 	) error
+	DisableRuleForClusterUntil(
+		clusterID types.ClusterName,
+		ruleID types.RuleID,
+		errorKey types.ErrorKey,
+		orgID types.OrgID,
+		expiresAt time.Time,
+		auditEvents ...AuditEvent,
+	) error
+	ToggleRulesForClusters(
+		orgID types.OrgID,
+		userID types.UserID,
+		items []RuleToggleBatchItem,
+		ruleToggle RuleToggle,
+		auditEvents ...AuditEvent,
+	) (map[types.ClusterName]bool, error)
 	GetFromClusterRuleToggle(
 		types.ClusterName,
 		types.RuleID,
@@ -158,6 +184,11 @@ type Storage interface {
 	) error
 	GetOrgIDByClusterID(cluster types.ClusterName) (types.OrgID, error)
 	WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error
+	ReadConsumerErrors(filter ConsumerErrorsFilter) ([]ConsumerError, error)
+	ReadConsumerError(topic string, partition int32, offset int64) (ConsumerError, error)
+	DeleteConsumerError(topic string, partition int32, offset int64) error
+	DeleteExpiredRecords(retention RetentionConfiguration, dryRun bool) ([]RetentionResult, error)
+	DeleteExpiredRuleDisables(dryRun bool) ([]RetentionResult, error)
 	GetUserFeedbackOnRules(
 		clusterID types.ClusterName,
 		rulesReport []types.RuleOnReport,
@@ -185,6 +216,7 @@ type Storage interface {
 		types.RuleID,
 		types.ErrorKey,
 		types.UserVote,
+		...AuditEvent,
 	) error
 	GetRuleRating(
 		types.OrgID,
@@ -194,16 +226,24 @@ type Storage interface {
 		orgID types.OrgID, ruleID types.RuleID,
 		errorKey types.ErrorKey, justification string,
 	) error
+	DisableRuleSystemWideUntil(
+		orgID types.OrgID, ruleID types.RuleID,
+		errorKey types.ErrorKey, justification string,
+		expiresAt time.Time,
+		auditEvents ...AuditEvent,
+	) error
 	EnableRuleSystemWide(
 		orgID types.OrgID,
 		ruleID types.RuleID,
 		errorKey types.ErrorKey,
+		auditEvents ...AuditEvent,
 	) error
 	UpdateDisabledRuleJustification(
 		orgID types.OrgID,
 		ruleID types.RuleID,
 		errorKey types.ErrorKey,
 		justification string,
+		auditEvents ...AuditEvent,
 	) error
 	ReadDisabledRule(
 		orgID types.OrgID, ruleID types.RuleID, errorKey types.ErrorKey,
@@ -212,9 +252,15 @@ type Storage interface {
 		orgID types.OrgID,
 	) ([]ctypes.SystemWideRuleDisable, error)
 	ReadRecommendationsForClusters([]string, types.OrgID) (ctypes.RecommendationImpactedClusters, error)
+	ReadRecommendationsSummary(orgID types.OrgID) ([]RecommendationSummary, error)
 	ReadClusterListRecommendations(clusterList []string, orgID types.OrgID) (
-		ctypes.ClusterRecommendationMap, error,
+		types.ClusterRecommendationMap, error,
 	)
+	ReadReportHistoryForCluster(
+		orgID types.OrgID, clusterName types.ClusterName,
+	) ([]ReportHistoryItem, error)
+	WriteAuditEvents(events []AuditEvent) error
+	ReadAuditEvents(filter AuditEventsFilter, options ListingOptions) ([]AuditEvent, string, error)
 }
 
 // DBStorage is an implementation of Storage interface that use selected SQL like database
@@ -224,8 +270,24 @@ type Storage interface {
 type DBStorage struct {
 	connection   *sql.DB
 	dbDriverType types.DBDriver
-	// clusterLastCheckedDict is a dictionary of timestamps when the clusters were last checked.
-	clustersLastChecked map[types.ClusterName]time.Time
+	// clustersLastChecked is a cache of timestamps when the clusters were last checked.
+	clustersLastChecked *clustersLastCheckedCache
+	// clustersLastCheckedDBFallback enables reading the timestamp from
+	// report table when the cluster is not found in clustersLastChecked
+	clustersLastCheckedDBFallback bool
+	// reportHistoryLength is the number of reports kept in report_history
+	// table for each cluster (zero means that the history is not stored)
+	reportHistoryLength int
+	// reportHistoryDisabledOrgs contains organizations whose reports are
+	// not stored in report_history table
+	reportHistoryDisabledOrgs map[types.OrgID]bool
+	// compressReports enables storing of reports in report table in
+	// compressed form
+	compressReports bool
+	// maxStatementParameters is the maximum number of bind parameters in
+	// one SQL statement, multi-row statements are split into several ones
+	// to stay under this limit
+	maxStatementParameters int
 }
 
 // New function creates and initializes a new instance of Storage interface
@@ -246,18 +308,39 @@ func New(configuration Configuration) (*DBStorage, error) {
 		return nil, err
 	}
 
-	return NewFromConnection(connection, driverType), nil
+	storage := NewFromConnection(connection, driverType)
+	storage.reportHistoryLength = configuration.ReportHistoryLength
+	storage.reportHistoryDisabledOrgs = configuration.ReportHistoryDisabledOrgs
+	storage.clustersLastChecked = newClustersLastCheckedCache(
+		configuration.ClustersLastCheckedCacheSize,
+		configuration.ClustersLastCheckedCacheTTL,
+	)
+	storage.clustersLastCheckedDBFallback = configuration.ClustersLastCheckedDBFallback
+	storage.compressReports = configuration.CompressReports
+
+	return storage, nil
 }
 
 // NewFromConnection function creates and initializes a new instance of Storage interface from prepared connection
 func NewFromConnection(connection *sql.DB, dbDriverType types.DBDriver) *DBStorage {
 	return &DBStorage{
-		connection:          connection,
-		dbDriverType:        dbDriverType,
-		clustersLastChecked: map[types.ClusterName]time.Time{},
+		connection:             connection,
+		dbDriverType:           dbDriverType,
+		clustersLastChecked:    newClustersLastCheckedCache(0, 0),
+		maxStatementParameters: maxStatementParametersForDriver(dbDriverType),
 	}
 }
 
+// maxStatementParametersForDriver returns the maximum number of bind
+// parameters in one SQL statement supported by given database
+func maxStatementParametersForDriver(dbDriverType types.DBDriver) int {
+	if dbDriverType == types.DBDriverSQLite3 {
+		return sqliteMaxStatementParameters
+	}
+
+	return postgresMaxStatementParameters
+}
+
 // initAndGetDriver initializes driver(with logs if logSQLQueries is true),
 // checks if it's supported and returns driver type, driver name, dataSource and error
 func initAndGetDriver(configuration Configuration) (driverType types.DBDriver, driverName, dataSource string, err error) {
@@ -306,8 +389,20 @@ func (storage DBStorage) MigrateToLatest() error {
 // Init performs all database initialization
 // tasks necessary for further service operation.
 func (storage DBStorage) Init() error {
-	// Read clusterName:LastChecked dictionary from DB.
-	rows, err := storage.connection.Query("SELECT cluster, last_checked_at FROM report;")
+	// Read clusterName:LastChecked dictionary from DB. When the cache is
+	// bounded, only the most recently checked clusters are preloaded.
+	var (
+		rows *sql.Rows
+		err  error
+	)
+	if storage.clustersLastChecked.maxSize > 0 {
+		rows, err = storage.connection.Query(
+			"SELECT cluster, last_checked_at FROM report ORDER BY last_checked_at DESC LIMIT $1;",
+			storage.clustersLastChecked.maxSize,
+		)
+	} else {
+		rows, err = storage.connection.Query("SELECT cluster, last_checked_at FROM report;")
+	}
 	if err != nil {
 		return err
 	}
@@ -325,7 +420,7 @@ func (storage DBStorage) Init() error {
 			return err
 		}
 
-		storage.clustersLastChecked[clusterName] = lastChecked
+		storage.clustersLastChecked.set(clusterName, lastChecked)
 	}
 
 	// Not using defer to close the rows here to:
@@ -365,59 +460,115 @@ func closeRows(rows *sql.Rows) {
 
 // ListOfOrgs reads list of all organizations that have at least one cluster report
 func (storage DBStorage) ListOfOrgs() ([]types.OrgID, error) {
+	orgs, _, err := storage.ListOfOrgsPage(ListingOptions{})
+	return orgs, err
+}
+
+// ListOfOrgsPage reads one page of the list of organizations that have at
+// least one cluster report. Cursor of the next page is returned too, it's
+// empty when there are no more organizations.
+func (storage DBStorage) ListOfOrgsPage(options ListingOptions) ([]types.OrgID, string, error) {
 	orgs := make([]types.OrgID, 0)
 
-	rows, err := storage.connection.Query("SELECT DISTINCT org_id FROM report ORDER BY org_id;")
+	query, args, err := orgsListing.buildQuery("SELECT DISTINCT org_id FROM report", nil, nil, options)
+	if err != nil {
+		return orgs, "", err
+	}
+
+	rows, err := storage.connection.Query(query, args...)
 	err = types.ConvertDBError(err, nil)
 	if err != nil {
-		return orgs, err
+		return orgs, "", err
 	}
 	defer closeRows(rows)
 
+	items := make([]listingItem, 0)
 	for rows.Next() {
 		var orgID types.OrgID
 
 		err = rows.Scan(&orgID)
 		if err == nil {
 			orgs = append(orgs, orgID)
+			items = append(items, listingItem{id: fmt.Sprint(orgID)})
 		} else {
 			log.Error().Err(err).Msg("ListOfOrgID")
 		}
 	}
-	return orgs, nil
+
+	count, nextCursor := nextListingCursor(items, options)
+	return orgs[:count], nextCursor, nil
 }
 
 // ListOfClustersForOrg reads list of all clusters fro given organization
 func (storage DBStorage) ListOfClustersForOrg(orgID types.OrgID, timeLimit time.Time) ([]types.ClusterName, error) {
+	clusters, _, err := storage.ListOfClustersForOrgPage(orgID, timeLimit, time.Time{}, ListingOptions{})
+	return clusters, err
+}
+
+// ListOfClustersForOrgPage reads one page of the list of clusters for given
+// organization reported between since and until (inclusive), zero until
+// means no upper limit. Cursor of the next page is returned too, it's empty
+// when there are no more clusters.
+func (storage DBStorage) ListOfClustersForOrgPage(
+	orgID types.OrgID, since, until time.Time, options ListingOptions,
+) ([]types.ClusterName, string, error) {
 	clusters := make([]types.ClusterName, 0)
 
-	q := `
-		  SELECT cluster
-		    FROM report
-		   WHERE org_id = $1
-		     AND reported_at >= $2
-		ORDER BY cluster;
-	`
+	sortField, err := clustersListing.sortField(options)
+	if err != nil {
+		return clusters, "", err
+	}
+
+	conditions := []string{"org_id = $1", "reported_at >= $2"}
+	args := []interface{}{orgID, since}
+	if !until.IsZero() {
+		conditions = append(conditions, "reported_at <= $3")
+		args = append(args, until)
+	}
+
+	query, args, err := clustersListing.buildQuery(
+		"SELECT cluster, reported_at, last_checked_at FROM report", conditions, args, options,
+	)
+	if err != nil {
+		return clusters, "", err
+	}
 
-	rows, err := storage.connection.Query(q, orgID, timeLimit)
+	rows, err := storage.connection.Query(query, args...)
 
 	err = types.ConvertDBError(err, orgID)
 	if err != nil {
-		return clusters, err
+		return clusters, "", err
 	}
 	defer closeRows(rows)
 
+	items := make([]listingItem, 0)
 	for rows.Next() {
-		var clusterName string
+		var (
+			clusterName string
+			reportedAt  time.Time
+			lastChecked time.Time
+		)
 
-		err = rows.Scan(&clusterName)
-		if err == nil {
-			clusters = append(clusters, types.ClusterName(clusterName))
-		} else {
+		err = rows.Scan(&clusterName, &reportedAt, &lastChecked)
+		if err != nil {
 			log.Error().Err(err).Msg("ListOfClustersForOrg")
+			continue
+		}
+
+		item := listingItem{id: clusterName}
+		switch sortField.name {
+		case SortByReportedAt:
+			item.sortValue = timeSortValue(reportedAt)
+		case SortByLastCheckedAt:
+			item.sortValue = timeSortValue(lastChecked)
 		}
+
+		clusters = append(clusters, types.ClusterName(clusterName))
+		items = append(items, item)
 	}
-	return clusters, nil
+
+	count, nextCursor := nextListingCursor(items, options)
+	return clusters[:count], nextCursor, nil
 }
 
 // ListOfClustersForOrgSpecificRule returns list of all clusters for given organization that are affect by given rule
@@ -426,24 +577,47 @@ func (storage DBStorage) ListOfClustersForOrgSpecificRule(
 	ruleID types.RuleSelector,
 	activeClusters []string) (
 	[]ctypes.HittingClustersData, error) {
+	results, _, err := storage.ListOfClustersForOrgSpecificRulePage(orgID, ruleID, activeClusters, ListingOptions{})
+	return results, err
+}
+
+// ListOfClustersForOrgSpecificRulePage returns one page of the list of
+// clusters for given organization that are affected by given rule. Cursor of
+// the next page is returned too, it's empty when there are no more clusters.
+func (storage DBStorage) ListOfClustersForOrgSpecificRulePage(
+	orgID types.OrgID,
+	ruleID types.RuleSelector,
+	activeClusters []string,
+	options ListingOptions,
+) ([]ctypes.HittingClustersData, string, error) {
 	results := make([]ctypes.HittingClustersData, 0)
 
-	var whereClause string
+	sortField, err := ruleClustersListing.sortField(options)
+	if err != nil {
+		return results, "", err
+	}
+
+	conditions := []string{"org_id = $1", "rule_id = $2"}
 	if len(activeClusters) > 0 {
 		// #nosec G201
-		whereClause = fmt.Sprintf(`WHERE org_id = $1 AND rule_id = $2 AND cluster_id IN (%v)`,
-			inClauseFromSlice(activeClusters))
-	} else {
-		whereClause = `WHERE org_id = $1 AND rule_id = $2`
+		conditions = append(conditions, fmt.Sprintf("cluster_id IN (%v)", inClauseFromSlice(activeClusters)))
+	}
+
+	query, args, err := ruleClustersListing.buildQuery(
+		"SELECT cluster_id, created_at, impacted_since FROM recommendation",
+		conditions,
+		[]interface{}{orgID, ruleID},
+		options,
+	)
+	if err != nil {
+		return results, "", err
 	}
-	// #nosec G202
-	query := `SELECT cluster_id, created_at, impacted_since FROM recommendation ` + whereClause + ` ORDER BY cluster_id;`
 
-	rows, err := storage.connection.Query(query, orgID, ruleID)
+	rows, err := storage.connection.Query(query, args...)
 
 	err = types.ConvertDBError(err, orgID)
 	if err != nil {
-		return results, err
+		return results, "", err
 	}
 
 	defer closeRows(rows)
@@ -453,6 +627,7 @@ func (storage DBStorage) ListOfClustersForOrgSpecificRule(
 		lastSeen      string
 		impactedSince string
 	)
+	items := make([]listingItem, 0)
 	for rows.Next() {
 		err = rows.Scan(&clusterName, &lastSeen, &impactedSince)
 		if err != nil {
@@ -463,15 +638,27 @@ func (storage DBStorage) ListOfClustersForOrgSpecificRule(
 			LastSeen:      lastSeen,
 			ImpactedSince: impactedSince,
 		})
+
+		item := listingItem{id: string(clusterName)}
+		switch sortField.name {
+		case SortByLastSeen:
+			item.sortValue = lastSeen
+		case SortByImpactedSince:
+			item.sortValue = impactedSince
+		}
+		items = append(items, item)
 	}
 
 	// This is to ensure 404 when no recommendation is found for the given orgId + selector.
 	// We can, alternatively, return something like this with a 204 (no content):
 	// {"data":[],"meta":{"count":0,"component":"test.rule","error_key":"ek"},"status":"not_found"}
-	if len(results) == 0 {
-		return results, &types.ItemNotFoundError{ItemID: ruleID}
+	// Following pages can be empty when the last page was full.
+	if len(results) == 0 && options.Cursor == "" {
+		return results, "", &types.ItemNotFoundError{ItemID: ruleID}
 	}
-	return results, nil
+
+	count, nextCursor := nextListingCursor(items, options)
+	return results[:count], nextCursor, nil
 }
 
 // GetOrgIDByClusterID reads OrgID for specified cluster
@@ -672,6 +859,12 @@ func (storage DBStorage) ReadReportsForClusters(clusterNames []types.ClusterName
 			return reports, err
 		}
 
+		clusterReport, err = decompressReport(clusterReport)
+		if err != nil {
+			log.Error().Err(err).Str(clusterKey, string(clusterName)).Msg("Unable to decompress report")
+			return reports, err
+		}
+
 		reports[clusterName] = clusterReport
 	}
 
@@ -895,10 +1088,11 @@ func (storage DBStorage) updateReport(
 		}
 	}
 
+	storedReport := storage.storedReport(report)
 	if gatheredAt.IsZero() {
-		_, err = tx.Exec(reportUpsertQuery, orgID, clusterName, report, reportedAtTime, lastCheckedTime, kafkaOffset, sql.NullTime{Valid: false})
+		_, err = tx.Exec(reportUpsertQuery, orgID, clusterName, storedReport, reportedAtTime, lastCheckedTime, kafkaOffset, sql.NullTime{Valid: false})
 	} else {
-		_, err = tx.Exec(reportUpsertQuery, orgID, clusterName, report, reportedAtTime, lastCheckedTime, kafkaOffset, gatheredAt)
+		_, err = tx.Exec(reportUpsertQuery, orgID, clusterName, storedReport, reportedAtTime, lastCheckedTime, kafkaOffset, gatheredAt)
 	}
 
 	if err != nil {
@@ -906,6 +1100,15 @@ func (storage DBStorage) updateReport(
 		return err
 	}
 
+	if storage.keepsReportHistory(orgID) {
+		err = storage.insertReportHistory(
+			tx, orgID, clusterName, report, lastCheckedTime, gatheredAt, reportedAtTime, kafkaOffset,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1028,6 +1231,35 @@ func (storage DBStorage) getRuleKeyCreatedAtMap(
 	return RuleKeyCreatedAt, err
 }
 
+// getClusterLastChecked returns the timestamp when given cluster was last
+// checked. The cache is looked up first and, if enabled, the report table is
+// read when the cluster is not cached.
+func (storage DBStorage) getClusterLastChecked(clusterName types.ClusterName) (time.Time, bool) {
+	if lastChecked, found := storage.clustersLastChecked.get(clusterName); found {
+		return lastChecked, true
+	}
+
+	if !storage.clustersLastCheckedDBFallback {
+		return time.Time{}, false
+	}
+
+	var lastChecked time.Time
+	err := storage.connection.QueryRow(
+		"SELECT last_checked_at FROM report WHERE cluster = $1;", clusterName,
+	).Scan(&lastChecked)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Error().Err(err).Str(clusterKey, string(clusterName)).Msg(
+				"Unable to read last checked timestamp from the database",
+			)
+		}
+		return time.Time{}, false
+	}
+
+	storage.clustersLastChecked.set(clusterName, lastChecked)
+	return lastChecked, true
+}
+
 // WriteReportForCluster writes result (health status) for selected cluster for given organization
 func (storage DBStorage) WriteReportForCluster(
 	orgID types.OrgID,
@@ -1041,7 +1273,7 @@ func (storage DBStorage) WriteReportForCluster(
 ) error {
 	// Skip writing the report if it isn't newer than a report
 	// that is already in the database for the same cluster.
-	if oldLastChecked, exists := storage.clustersLastChecked[clusterName]; exists && !lastCheckedTime.After(oldLastChecked) {
+	if oldLastChecked, exists := storage.getClusterLastChecked(clusterName); exists && !lastCheckedTime.After(oldLastChecked) {
 		return types.ErrOldReport
 	}
 
@@ -1073,7 +1305,7 @@ func (storage DBStorage) WriteReportForCluster(
 		if rows.Next() {
 			log.Warn().Msgf("Database already contains report for organization %d and cluster name %s more recent than %v",
 				orgID, clusterName, lastCheckedTime)
-			return nil
+			return types.ErrOldReport
 		}
 
 		err = storage.updateReport(tx, orgID, clusterName, report, rules, lastCheckedTime, gatheredAt, storedAtTime, kafkaOffset)
@@ -1081,7 +1313,7 @@ func (storage DBStorage) WriteReportForCluster(
 			return err
 		}
 
-		storage.clustersLastChecked[clusterName] = lastCheckedTime
+		storage.clustersLastChecked.set(clusterName, lastCheckedTime)
 		metrics.WrittenReports.Inc()
 
 		return nil
@@ -1113,7 +1345,7 @@ func (storage DBStorage) WriteRecommendationsForCluster(
 	err = func(tx *sql.Tx) error {
 		var deleted int64 = 0
 		// Delete current recommendations for the cluster if some report has been previously stored for this cluster
-		if _, ok := storage.clustersLastChecked[clusterName]; ok {
+		if _, ok := storage.getClusterLastChecked(clusterName); ok {
 
 			// Get impacted_since if present
 			query := "SELECT rule_fqdn, error_key, impacted_since FROM recommendation WHERE org_id = $1 AND cluster_id = $2 LIMIT 1;"
@@ -1238,9 +1470,9 @@ func (storage DBStorage) ReadRecommendationsForClusters(
 func (storage DBStorage) ReadClusterListRecommendations(
 	clusterList []string,
 	orgID types.OrgID,
-) (ctypes.ClusterRecommendationMap, error) {
+) (types.ClusterRecommendationMap, error) {
 
-	clusterMap := make(ctypes.ClusterRecommendationMap, 0)
+	clusterMap := make(types.ClusterRecommendationMap, 0)
 
 	if len(clusterList) < 1 {
 		return clusterMap, nil
@@ -1316,7 +1548,7 @@ func (storage DBStorage) ReadClusterListRecommendations(
 			clusterMap[clusterID] = cluster
 		} else {
 			// create entry in map for new cluster ID
-			clusterMap[clusterID] = ctypes.ClusterRecommendationList{
+			clusterMap[clusterID] = types.ClusterRecommendationList{
 				// created at is the same for all rows for each cluster
 				CreatedAt:       timestamp,
 				Recommendations: []ctypes.RuleID{ruleID},
@@ -1341,12 +1573,24 @@ func (storage DBStorage) ReportsCount() (int, error) {
 // DeleteReportsForOrg deletes all reports related to the specified organization from the storage.
 func (storage DBStorage) DeleteReportsForOrg(orgID types.OrgID) error {
 	_, err := storage.connection.Exec("DELETE FROM report WHERE org_id = $1;", orgID)
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.connection.Exec("DELETE FROM report_history WHERE org_id = $1;", orgID)
 	return err
 }
 
 // DeleteReportsForCluster deletes all reports related to the specified cluster from the storage.
 func (storage DBStorage) DeleteReportsForCluster(clusterName types.ClusterName) error {
 	_, err := storage.connection.Exec("DELETE FROM report WHERE cluster = $1;", clusterName)
+	if err != nil {
+		return err
+	}
+
+	storage.clustersLastChecked.remove(clusterName)
+
+	_, err = storage.connection.Exec("DELETE FROM report_history WHERE cluster = $1;", clusterName)
 	return err
 }
 
@@ -1356,10 +1600,14 @@ func (storage DBStorage) GetConnection() *sql.DB {
 }
 
 // WriteConsumerError writes a report about a consumer error into the storage.
+// The error and the time of consuming are updated when the message failed
+// already before, for example when it is replayed from consumer_error table.
 func (storage DBStorage) WriteConsumerError(msg *sarama.ConsumerMessage, consumerErr error) error {
 	_, err := storage.connection.Exec(`
 		INSERT INTO consumer_error (topic, partition, topic_offset, key, produced_at, consumed_at, message, error)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (topic, partition, topic_offset)
+		DO UPDATE SET consumed_at = $6, error = $8`,
 		msg.Topic, msg.Partition, msg.Offset, msg.Key, msg.Timestamp, time.Now().UTC(), msg.Value, consumerErr.Error())
 
 	return err
@@ -1421,12 +1669,13 @@ func (storage DBStorage) ListOfDisabledClusters(
 		AND toggle.rule_id = $2
 		AND toggle.error_key = $3
 		AND toggle.disabled = $4
+		AND (toggle.expires_at IS NULL OR toggle.expires_at > $5)
 	ORDER BY
 		toggle.disabled_at DESC
 	`
 
 	// run the query against database
-	rows, err := storage.connection.Query(query, orgID, ruleID, errorKey, RuleToggleDisable)
+	rows, err := storage.connection.Query(query, orgID, ruleID, errorKey, RuleToggleDisable, time.Now().UTC())
 
 	// return empty list in case of any error
 	if err != nil {
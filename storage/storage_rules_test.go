@@ -15,7 +15,6 @@
 package storage_test
 
 import (
-	"bytes"
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
@@ -28,8 +27,6 @@ import (
 	utypes "github.com/RedHatInsights/insights-operator-utils/types"
 	"github.com/RedHatInsights/insights-results-aggregator-data/testdata"
 	ctypes "github.com/RedHatInsights/insights-results-types"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/RedHatInsights/insights-results-aggregator/storage"
@@ -91,6 +88,215 @@ func TestDBStorage_ToggleRuleForCluster_DBError(t *testing.T) {
 	assert.EqualError(t, err, "sql: database is closed")
 }
 
+// TestToggleRulesForClusters checks that rules are toggled for all items and
+// justifications are stored as disable feedbacks
+func TestToggleRulesForClusters(t *testing.T) {
+	clusters := []types.ClusterName{testdata.GetRandomClusterID(), testdata.GetRandomClusterID()}
+
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			for _, clusterName := range clusters {
+				writeReportForCluster(t, mockStorage, testdata.OrgID, clusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed)
+			}
+
+			unknownClusters, err := mockStorage.ToggleRulesForClusters(testdata.OrgID, testdata.UserID, []storage.RuleToggleBatchItem{
+				{ClusterID: clusters[0], RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, Justification: "maintenance"},
+				{ClusterID: clusters[1], RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+			}, storage.RuleToggleDisable)
+			helpers.FailOnError(t, err)
+			assert.Empty(t, unknownClusters)
+
+			disabledRules, err := mockStorage.ListOfDisabledRulesForClusters(
+				[]string{string(clusters[0]), string(clusters[1])}, testdata.OrgID,
+			)
+			helpers.FailOnError(t, err)
+			assert.Len(t, disabledRules, 2)
+
+			feedback, err := mockStorage.GetUserFeedbackOnRuleDisable(
+				clusters[0], testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, "maintenance", feedback.Message)
+
+			_, err = mockStorage.GetUserFeedbackOnRuleDisable(
+				clusters[1], testdata.Rule1ID, testdata.ErrorKey1, testdata.UserID,
+			)
+			assert.IsType(t, &types.ItemNotFoundError{}, err)
+
+			_, err = mockStorage.ToggleRulesForClusters(testdata.OrgID, testdata.UserID, []storage.RuleToggleBatchItem{
+				{ClusterID: clusters[0], RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+			}, storage.RuleToggleEnable)
+			helpers.FailOnError(t, err)
+
+			disabledRules, err = mockStorage.ListOfDisabledRulesForClusters(
+				[]string{string(clusters[0]), string(clusters[1])}, testdata.OrgID,
+			)
+			helpers.FailOnError(t, err)
+			assert.Len(t, disabledRules, 1)
+			assert.Equal(t, clusters[1], disabledRules[0].ClusterID)
+		})
+	}
+}
+
+func TestToggleRulesForClusters_UnexpectedRuleToggleValue(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			_, err := mockStorage.ToggleRulesForClusters(testdata.OrgID, testdata.UserID, []storage.RuleToggleBatchItem{
+				{ClusterID: testdata.ClusterName, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+			}, -999)
+			assert.EqualError(t, err, "Unexpected rule toggle value")
+
+			_, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDBStorage_ToggleRulesForClusters_DBError(t *testing.T) {
+	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
+	closer()
+
+	_, err := mockStorage.ToggleRulesForClusters(testdata.OrgID, testdata.UserID, []storage.RuleToggleBatchItem{
+		{ClusterID: testdata.ClusterName, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+	}, storage.RuleToggleDisable)
+	assert.EqualError(t, err, "sql: database is closed")
+}
+
+// TestToggleRulesForClustersOfOtherOrgs checks that rules are not toggled
+// for clusters that are not known or belong to another organization and
+// that no audit events are written for them
+func TestToggleRulesForClustersOfOtherOrgs(t *testing.T) {
+	ownCluster := testdata.GetRandomClusterID()
+	otherOrgCluster := testdata.GetRandomClusterID()
+	unknownCluster := testdata.GetRandomClusterID()
+
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			writeReportForCluster(t, mockStorage, testdata.OrgID, ownCluster, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed)
+			writeReportForCluster(t, mockStorage, testdata.Org2ID, otherOrgCluster, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed)
+
+			items := []storage.RuleToggleBatchItem{
+				{ClusterID: ownCluster, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+				{ClusterID: otherOrgCluster, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+				{ClusterID: unknownCluster, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+			}
+			auditEvents := make([]storage.AuditEvent, len(items))
+			for i, item := range items {
+				auditEvents[i] = storage.AuditEvent{
+					OrgID:     testdata.OrgID,
+					UserID:    testdata.UserID,
+					Action:    storage.AuditActionDisableRule,
+					ClusterID: item.ClusterID,
+					RuleID:    item.RuleID,
+					ErrorKey:  item.ErrorKey,
+				}
+			}
+
+			unknownClusters, err := mockStorage.ToggleRulesForClusters(
+				testdata.OrgID, testdata.UserID, items, storage.RuleToggleDisable, auditEvents...,
+			)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, map[types.ClusterName]bool{otherOrgCluster: true, unknownCluster: true}, unknownClusters)
+
+			disabledRules, err := mockStorage.ListOfDisabledRulesForClusters(
+				[]string{string(ownCluster), string(otherOrgCluster), string(unknownCluster)}, testdata.OrgID,
+			)
+			helpers.FailOnError(t, err)
+			assert.Len(t, disabledRules, 1)
+			assert.Equal(t, ownCluster, disabledRules[0].ClusterID)
+
+			events, _, err := mockStorage.ReadAuditEvents(
+				storage.AuditEventsFilter{OrgID: testdata.OrgID}, storage.ListingOptions{},
+			)
+			helpers.FailOnError(t, err)
+			assert.Len(t, events, 1)
+			assert.Equal(t, ownCluster, events[0].ClusterID)
+		})
+	}
+}
+
+func TestDisableRuleForClusterUntil(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			now := time.Now()
+
+			// the first rule disable has expired already
+			helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(
+				testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, now.Add(-time.Minute),
+			))
+			helpers.FailOnError(t, mockStorage.DisableRuleForClusterUntil(
+				testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey2, testdata.OrgID, now.Add(time.Hour),
+			))
+
+			toggles, err := mockStorage.GetTogglesForRules(testdata.ClusterName, []types.RuleOnReport{
+				{Module: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1},
+				{Module: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2},
+			}, testdata.OrgID)
+			helpers.FailOnError(t, err)
+			assert.Equal(t, map[types.RuleID]bool{testdata.Rule2ID: true}, toggles)
+
+			disabledRules, err := mockStorage.ListOfDisabledRules(testdata.OrgID)
+			helpers.FailOnError(t, err)
+			assert.Len(t, disabledRules, 1)
+			assert.Equal(t, testdata.Rule2ID, disabledRules[0].RuleID)
+
+			toggle, err := mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule1ID)
+			helpers.FailOnError(t, err)
+			assert.False(t, toggle.IsDisabled(time.Now()))
+
+			toggle, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule2ID)
+			helpers.FailOnError(t, err)
+			assert.True(t, toggle.IsDisabled(time.Now()))
+			assert.True(t, toggle.ExpiresAt.Valid)
+
+			// expiration is not kept when the rule is disabled again
+			helpers.FailOnError(t, mockStorage.ToggleRuleForCluster(
+				testdata.ClusterName, testdata.Rule2ID, testdata.ErrorKey2, testdata.OrgID, storage.RuleToggleDisable,
+			))
+
+			toggle, err = mockStorage.GetFromClusterRuleToggle(testdata.ClusterName, testdata.Rule2ID)
+			helpers.FailOnError(t, err)
+			assert.False(t, toggle.ExpiresAt.Valid)
+		})
+	}
+}
+
+func TestToggleRulesForClustersWithExpiration(t *testing.T) {
+	for name, newStorage := range listingStorages(t) {
+		t.Run(name, func(t *testing.T) {
+			mockStorage, closer := newStorage()
+			defer closer()
+
+			writeReportForCluster(t, mockStorage, testdata.OrgID, testdata.ClusterName, testdata.ClusterReportEmpty, testdata.ReportEmptyRulesParsed)
+
+			_, err := mockStorage.ToggleRulesForClusters(testdata.OrgID, testdata.UserID, []storage.RuleToggleBatchItem{
+				{ClusterID: testdata.ClusterName, RuleID: testdata.Rule1ID, ErrorKey: testdata.ErrorKey1, ExpiresAt: time.Now().Add(-time.Minute)},
+				{ClusterID: testdata.ClusterName, RuleID: testdata.Rule2ID, ErrorKey: testdata.ErrorKey2, ExpiresAt: time.Now().Add(time.Hour)},
+			}, storage.RuleToggleDisable)
+			helpers.FailOnError(t, err)
+
+			disabledRules, err := mockStorage.ListOfDisabledRulesForClusters(
+				[]string{string(testdata.ClusterName)}, testdata.OrgID,
+			)
+			helpers.FailOnError(t, err)
+			assert.Len(t, disabledRules, 1)
+			assert.Equal(t, testdata.Rule2ID, disabledRules[0].RuleID)
+		})
+	}
+}
+
 func TestDBStorageGetTogglesForRules_NoRules(t *testing.T) {
 	mockStorage, closer := ira_helpers.MustGetMockStorage(t, true)
 	defer closer()
@@ -540,31 +746,27 @@ func TestDBStorageVoteOnRuleDBExecError(t *testing.T) {
 	}
 }
 
-func TestDBStorageVoteOnRuleDBCloseError(t *testing.T) {
-	// TODO: seems to be not coverable because of the bug in golang
-	// related issues:
-	// https://github.com/DATA-DOG/go-sqlmock/issues/185
-	// https://github.com/golang/go/issues/37973
-
-	const errStr = "close error"
-
-	buf := new(bytes.Buffer)
-	log.Logger = zerolog.New(buf)
+// TestDBStorageVoteOnRuleDBCommitError checks that the error returned when
+// the transaction with the vote and its audit event can't be committed is
+// propagated to the caller
+func TestDBStorageVoteOnRuleDBCommitError(t *testing.T) {
+	const errStr = "commit error"
 
 	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpects(t)
 	defer ira_helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
 
-	expects.ExpectPrepare("INSERT").
-		WillBeClosed().
-		WillReturnCloseError(fmt.Errorf(errStr)).
-		ExpectExec().
+	expects.ExpectBegin()
+	expects.ExpectExec("INSERT INTO cluster_rule_user_feedback").
 		WillReturnResult(driver.ResultNoRows)
+	expects.ExpectExec("INSERT INTO audit_event").
+		WillReturnResult(driver.ResultNoRows)
+	expects.ExpectCommit().WillReturnError(fmt.Errorf(errStr))
 
-	err := mockStorage.VoteOnRule(testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, testdata.UserID, types.UserVoteNone, "")
-	helpers.FailOnError(t, err)
-
-	// TODO: uncomment when issues upthere resolved
-	// assert.Contains(t, buf.String(), errStr)
+	err := mockStorage.VoteOnRule(
+		testdata.ClusterName, testdata.Rule1ID, testdata.ErrorKey1, testdata.OrgID, testdata.UserID, types.UserVoteNone, "",
+		storage.AuditEvent{OrgID: testdata.OrgID, UserID: testdata.UserID, Action: storage.AuditActionVote},
+	)
+	assert.EqualError(t, err, errStr)
 }
 
 func TestDBStorageGetVotesForNoRules(t *testing.T) {
@@ -627,8 +627,8 @@ func TestDBStorageListOfClustersForOrgScanError(t *testing.T) {
 	mockStorage, expects := ira_helpers.MustGetMockStorageWithExpects(t)
 	defer ira_helpers.MustCloseMockStorageWithExpects(t, mockStorage, expects)
 
-	expects.ExpectQuery("SELECT cluster FROM report").WillReturnRows(
-		sqlmock.NewRows([]string{"cluster"}).AddRow(nil),
+	expects.ExpectQuery("SELECT cluster, reported_at, last_checked_at FROM report").WillReturnRows(
+		sqlmock.NewRows([]string{"cluster", "reported_at", "last_checked_at"}).AddRow(nil, time.Now(), time.Now()),
 	)
 
 	_, err := mockStorage.ListOfClustersForOrg(testdata.OrgID, time.Now().Add(-time.Hour))
@@ -1399,7 +1399,7 @@ func TestDBStorageReadClusterListRecommendationsDifferentCluster(t *testing.T) {
 	for i := range clusterList {
 		clusterList[i] = string(testdata.GetRandomClusterID())
 	}
-	expect := make(ctypes.ClusterRecommendationMap)
+	expect := make(types.ClusterRecommendationMap)
 
 	res, err := mockStorage.ReadClusterListRecommendations(clusterList, testdata.OrgID)
 	helpers.FailOnError(t, err)
@@ -127,6 +127,31 @@ type InfoItem struct {
 	Details map[string]string `json:"details"`
 }
 
+// ClusterMetadata represents metadata of the cluster gathered by info rules
+// of its latest report. Info contains details of all info items (platform,
+// cluster ID, nodes etc.) keyed by their info ID.
+type ClusterMetadata struct {
+	Version Version                      `json:"cluster_version"`
+	Info    map[RuleID]map[string]string `json:"info,omitempty"`
+}
+
+// ReportResponseMetainfo represents the response of /report/info endpoint,
+// it contains metadata of the cluster together with the report's metainfo
+type ReportResponseMetainfo struct {
+	types.ReportResponseMetainfo
+	Meta ClusterMetadata `json:"meta"`
+}
+
+// ClusterRecommendationList is used for the clusters list
+type ClusterRecommendationList struct {
+	CreatedAt       time.Time       `json:"created_at"`
+	Meta            ClusterMetadata `json:"meta"`
+	Recommendations []RuleID        `json:"recommendations"`
+}
+
+// ClusterRecommendationMap is used for the clusters list
+type ClusterRecommendationMap map[ClusterName]ClusterRecommendationList
+
 // ClusterReports is a data structure containing list of clusters, list of
 // errors and dictionary with results per cluster.
 type ClusterReports = types.ClusterReports